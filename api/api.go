@@ -5,15 +5,14 @@ import (
 	"fmt"
 
 	"github.com/d-fi/GoFi/logger"
-	"github.com/d-fi/GoFi/request"
 	"github.com/d-fi/GoFi/types"
 )
 
 // GetTrackInfoPublicApi fetches public track information from the API.
-func GetTrackInfoPublicApi(sngID string) (types.TrackTypePublicAPI, error) {
+func (c *Client) GetTrackInfoPublicApi(sngID string) (types.TrackTypePublicAPI, error) {
 	var result types.TrackTypePublicAPI
 	logger.Debug("Requesting track info from public API for ID: %s", sngID)
-	data, err := request.RequestPublicApi("/track/" + sngID)
+	data, err := c.session.RequestPublicApi("/track/" + sngID)
 	if err != nil {
 		logger.Error("Failed to fetch track info: %v", err)
 		return result, err
@@ -26,10 +25,10 @@ func GetTrackInfoPublicApi(sngID string) (types.TrackTypePublicAPI, error) {
 }
 
 // GetAlbumInfoPublicApi fetches public album information from the API.
-func GetAlbumInfoPublicApi(albID string) (types.AlbumTypePublicApi, error) {
+func (c *Client) GetAlbumInfoPublicApi(albID string) (types.AlbumTypePublicApi, error) {
 	var result types.AlbumTypePublicApi
 	logger.Debug("Requesting album info from public API for ID: %s", albID)
-	data, err := request.RequestPublicApi("/album/" + albID)
+	data, err := c.session.RequestPublicApi("/album/" + albID)
 	if err != nil {
 		logger.Error("Failed to fetch album info: %v", err)
 		return result, err
@@ -42,10 +41,20 @@ func GetAlbumInfoPublicApi(albID string) (types.AlbumTypePublicApi, error) {
 }
 
 // GetTrackInfo fetches detailed track information.
-func GetTrackInfo(sngID string) (types.TrackType, error) {
+func (c *Client) GetTrackInfo(sngID string) (types.TrackType, error) {
+	return c.trackInfo(sngID, c.session.Request)
+}
+
+// RefreshTrackInfo is GetTrackInfo past the response cache. Track tokens
+// expire sooner than cached responses, so a token refresh needs it.
+func (c *Client) RefreshTrackInfo(sngID string) (types.TrackType, error) {
+	return c.trackInfo(sngID, c.session.RequestFresh)
+}
+
+func (c *Client) trackInfo(sngID string, request func(map[string]any, string) ([]byte, error)) (types.TrackType, error) {
 	var result types.TrackType
 	logger.Debug("Requesting detailed track info for ID: %s", sngID)
-	data, err := request.Request(map[string]any{"sng_id": sngID}, "song.getData")
+	data, err := request(map[string]any{"sng_id": sngID}, "song.getData")
 	if err != nil {
 		logger.Error("Failed to fetch detailed track info: %v", err)
 		return result, err
@@ -58,10 +67,10 @@ func GetTrackInfo(sngID string) (types.TrackType, error) {
 }
 
 // GetLyrics fetches lyrics for a given track.
-func GetLyrics(sngID string) (types.LyricsType, error) {
+func (c *Client) GetLyrics(sngID string) (types.LyricsType, error) {
 	var result types.LyricsType
 	logger.Debug("Requesting lyrics for track ID: %s", sngID)
-	data, err := request.Request(map[string]any{"sng_id": sngID}, "song.getLyrics")
+	data, err := c.session.Request(map[string]any{"sng_id": sngID}, "song.getLyrics")
 	if err != nil {
 		logger.Error("Failed to fetch lyrics: %v", err)
 		return result, err
@@ -74,10 +83,10 @@ func GetLyrics(sngID string) (types.LyricsType, error) {
 }
 
 // GetAlbumInfo fetches detailed album information.
-func GetAlbumInfo(albID string) (types.AlbumType, error) {
+func (c *Client) GetAlbumInfo(albID string) (types.AlbumType, error) {
 	var result types.AlbumType
 	logger.Debug("Requesting detailed album info for ID: %s", albID)
-	data, err := request.Request(map[string]any{"alb_id": albID}, "album.getData")
+	data, err := c.session.Request(map[string]any{"alb_id": albID}, "album.getData")
 	if err != nil {
 		logger.Error("Failed to fetch detailed album info: %v", err)
 		return result, err
@@ -90,10 +99,10 @@ func GetAlbumInfo(albID string) (types.AlbumType, error) {
 }
 
 // GetAlbumTracks fetches tracks of a given album.
-func GetAlbumTracks(albID string) (types.AlbumTracksType, error) {
+func (c *Client) GetAlbumTracks(albID string) (types.AlbumTracksType, error) {
 	var result types.AlbumTracksType
 	logger.Debug("Requesting tracks for album ID: %s", albID)
-	data, err := request.Request(map[string]any{
+	data, err := c.session.Request(map[string]any{
 		"alb_id": albID,
 		"lang":   "us",
 		"nb":     -1,
@@ -110,10 +119,10 @@ func GetAlbumTracks(albID string) (types.AlbumTracksType, error) {
 }
 
 // GetPlaylistInfo fetches information about a playlist.
-func GetPlaylistInfo(playlistID string) (types.PlaylistInfo, error) {
+func (c *Client) GetPlaylistInfo(playlistID string) (types.PlaylistInfo, error) {
 	var result types.PlaylistInfo
 	logger.Debug("Requesting playlist info for ID: %s", playlistID)
-	data, err := request.Request(map[string]any{
+	data, err := c.session.Request(map[string]any{
 		"playlist_id": playlistID,
 		"lang":        "en",
 	}, "playlist.getData")
@@ -129,10 +138,10 @@ func GetPlaylistInfo(playlistID string) (types.PlaylistInfo, error) {
 }
 
 // GetPlaylistTracks fetches tracks in a given playlist.
-func GetPlaylistTracks(playlistID string) (types.PlaylistTracksType, error) {
+func (c *Client) GetPlaylistTracks(playlistID string) (types.PlaylistTracksType, error) {
 	var result types.PlaylistTracksType
 	logger.Debug("Requesting playlist tracks for ID: %s", playlistID)
-	data, err := request.Request(map[string]any{
+	data, err := c.session.Request(map[string]any{
 		"playlist_id": playlistID,
 		"lang":        "en",
 		"nb":          -1,
@@ -162,10 +171,10 @@ func GetPlaylistTracks(playlistID string) (types.PlaylistTracksType, error) {
 }
 
 // GetArtistInfo fetches information about an artist.
-func GetArtistInfo(artID string) (types.ArtistInfoType, error) {
+func (c *Client) GetArtistInfo(artID string) (types.ArtistInfoType, error) {
 	var result types.ArtistInfoType
 	logger.Debug("Requesting artist info for ID: %s", artID)
-	data, err := request.Request(map[string]any{
+	data, err := c.session.Request(map[string]any{
 		"art_id":         artID,
 		"filter_role_id": []int{0},
 		"lang":           "en",
@@ -185,12 +194,18 @@ func GetArtistInfo(artID string) (types.ArtistInfoType, error) {
 }
 
 // GetDiscography fetches an artist's discography.
-func GetDiscography(artID string, nb int) (types.DiscographyType, error) {
+func (c *Client) GetDiscography(artID string, nb int) (types.DiscographyType, error) {
+	return c.GetDiscographyByRole(artID, nb, []int{0})
+}
+
+// GetDiscographyByRole fetches the releases of an artist in the given roles,
+// such as 0 for main artist and 5 for featured.
+func (c *Client) GetDiscographyByRole(artID string, nb int, roleIDs []int) (types.DiscographyType, error) {
 	var result types.DiscographyType
-	logger.Debug("Requesting discography for artist ID: %s", artID)
-	data, err := request.Request(map[string]any{
+	logger.Debug("Requesting discography for artist ID: %s, roles: %v", artID, roleIDs)
+	data, err := c.session.Request(map[string]any{
 		"art_id":         artID,
-		"filter_role_id": []int{0},
+		"filter_role_id": roleIDs,
 		"lang":           "en",
 		"nb":             nb,
 		"nb_songs":       -1,
@@ -208,10 +223,10 @@ func GetDiscography(artID string, nb int) (types.DiscographyType, error) {
 }
 
 // GetProfile fetches user profile information.
-func GetProfile(userID string) (types.ProfileType, error) {
+func (c *Client) GetProfile(userID string) (types.ProfileType, error) {
 	var result types.ProfileType
 	logger.Debug("Requesting profile info for user ID: %s", userID)
-	data, err := request.Request(map[string]any{
+	data, err := c.session.Request(map[string]any{
 		"user_id": userID,
 		"tab":     "loved",
 		"nb":      -1,
@@ -227,11 +242,134 @@ func GetProfile(userID string) (types.ProfileType, error) {
 	return result, err
 }
 
+// GetProfileTracks fetches the tracks a user loved.
+func (c *Client) GetProfileTracks(userID string) (types.ProfileTabType[types.TrackType], error) {
+	return getProfileTab[types.TrackType](c, userID, "loved")
+}
+
+// GetProfilePlaylists fetches the playlists a user created or follows.
+func (c *Client) GetProfilePlaylists(userID string) (types.ProfileTabType[types.PlaylistInfoMinimal], error) {
+	return getProfileTab[types.PlaylistInfoMinimal](c, userID, "playlists")
+}
+
+// GetProfileAlbums fetches the albums in a user's favourites.
+func (c *Client) GetProfileAlbums(userID string) (types.ProfileTabType[types.AlbumType], error) {
+	return getProfileTab[types.AlbumType](c, userID, "albums")
+}
+
+// GetProfileArtists fetches the artists a user follows.
+func (c *Client) GetProfileArtists(userID string) (types.ProfileTabType[types.ArtistType], error) {
+	return getProfileTab[types.ArtistType](c, userID, "artists")
+}
+
+// getProfileTab fetches one tab of a user's profile page. Deezer nests the
+// items under TAB.<tab>, next to the user in DATA.USER.
+func getProfileTab[T any](c *Client, userID, tab string) (types.ProfileTabType[T], error) {
+	var result types.ProfileTabType[T]
+	logger.Debug("Requesting profile tab %s for user ID: %s", tab, userID)
+	data, err := c.session.Request(map[string]any{
+		"user_id": userID,
+		"tab":     tab,
+		"nb":      10000,
+	}, "deezer.pageProfile")
+	if err != nil {
+		logger.Error("Failed to fetch profile tab %s: %v", tab, err)
+		return result, err
+	}
+	var page struct {
+		DATA struct {
+			USER types.UserProfileType `json:"USER"`
+		} `json:"DATA"`
+		TAB map[string]json.RawMessage `json:"TAB"`
+	}
+	if err := json.Unmarshal(data, &page); err != nil {
+		logger.Error("Failed to unmarshal profile tab %s: %v", tab, err)
+		return result, err
+	}
+	if raw, ok := page.TAB[tab]; ok {
+		if err := json.Unmarshal(raw, &result); err != nil {
+			logger.Error("Failed to unmarshal profile tab %s: %v", tab, err)
+			return result, err
+		}
+	}
+	result.USER = page.DATA.USER
+	return result, nil
+}
+
+// GetUserFlow fetches the current Flow of a user, the personal radio Deezer
+// builds from their listening.
+func (c *Client) GetUserFlow(userID string) (types.RadioTracksType, error) {
+	var result types.RadioTracksType
+	logger.Debug("Fetching Flow for user ID: %s", userID)
+	data, err := c.session.Request(map[string]any{
+		"user_id": userID,
+	}, "radio.getUserRadio")
+	if err != nil {
+		logger.Error("Failed to fetch Flow: %v", err)
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		logger.Error("Failed to unmarshal Flow: %v", err)
+	}
+	return result, err
+}
+
+// GetRadioInfoPublicApi fetches a genre or mood mix from the public API.
+func (c *Client) GetRadioInfoPublicApi(radioID string) (types.RadioTypePublicApi, error) {
+	var result types.RadioTypePublicApi
+	logger.Debug("Requesting radio info from public API for ID: %s", radioID)
+	data, err := c.session.RequestPublicApi("/radio/" + radioID)
+	if err != nil {
+		logger.Error("Failed to fetch radio info: %v", err)
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		logger.Error("Failed to unmarshal radio info: %v", err)
+	}
+	return result, err
+}
+
+// GetRadioTracksPublicApi fetches up to limit tracks of a genre or mood mix
+// from the public API.
+func (c *Client) GetRadioTracksPublicApi(radioID string, limit int) (types.TrackDataPublicApiList, error) {
+	var result types.TrackDataPublicApiList
+	logger.Debug("Requesting radio tracks from public API for ID: %s", radioID)
+	data, err := c.session.RequestPublicApi(fmt.Sprintf("/radio/%s/tracks?limit=%d", radioID, limit))
+	if err != nil {
+		logger.Error("Failed to fetch radio tracks: %v", err)
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		logger.Error("Failed to unmarshal radio tracks: %v", err)
+	}
+	return result, err
+}
+
+// GetArtistRadioPublicApi fetches up to limit tracks of an artist's radio
+// from the public API.
+func (c *Client) GetArtistRadioPublicApi(artID string, limit int) (types.TrackDataPublicApiList, error) {
+	var result types.TrackDataPublicApiList
+	logger.Debug("Requesting artist radio from public API for ID: %s", artID)
+	data, err := c.session.RequestPublicApi(fmt.Sprintf("/artist/%s/radio?limit=%d", artID, limit))
+	if err != nil {
+		logger.Error("Failed to fetch artist radio: %v", err)
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		logger.Error("Failed to unmarshal artist radio: %v", err)
+	}
+	return result, err
+}
+
 // SearchAlternative searches for alternative tracks by artist and song name.
-func SearchAlternative(artist, song string, nb int) (types.SearchType, error) {
+func (c *Client) SearchAlternative(artist, song string, nb int) (types.SearchType, error) {
 	var result types.SearchType
 	logger.Debug("Searching for alternative tracks by artist: %s and song: %s", artist, song)
-	data, err := request.Request(map[string]any{
+	data, err := c.session.Request(map[string]any{
 		"query": fmt.Sprintf("artist:'%s' track:'%s'", artist, song),
 		"types": []string{"TRACK"},
 		"nb":    nb,
@@ -248,7 +386,7 @@ func SearchAlternative(artist, song string, nb int) (types.SearchType, error) {
 }
 
 // SearchMusic searches for music based on a query.
-func SearchMusic(query string, nb int, searchTypes ...string) (types.SearchType, error) {
+func (c *Client) SearchMusic(query string, nb int, searchTypes ...string) (types.SearchType, error) {
 	var result types.SearchType
 
 	if len(searchTypes) == 0 {
@@ -256,7 +394,7 @@ func SearchMusic(query string, nb int, searchTypes ...string) (types.SearchType,
 	}
 
 	logger.Debug("Searching music with query: %s", query)
-	data, err := request.Request(map[string]any{
+	data, err := c.session.Request(map[string]any{
 		"query":          query,
 		"start":          0,
 		"nb":             nb,
@@ -280,10 +418,10 @@ func SearchMusic(query string, nb int, searchTypes ...string) (types.SearchType,
 }
 
 // GetUser fetches the current user's information.
-func GetUser() (types.UserType, error) {
+func (c *Client) GetUser() (types.UserType, error) {
 	var result types.UserType
 	logger.Debug("Fetching current user info")
-	data, err := request.RequestGet("user_getInfo", nil)
+	data, err := c.session.RequestGet("user_getInfo", nil)
 	if err != nil {
 		logger.Error("Failed to fetch user info: %v", err)
 		return result, err
@@ -296,10 +434,10 @@ func GetUser() (types.UserType, error) {
 }
 
 // GetChannelList fetches a list of available channels.
-func GetChannelList() (types.ChannelSearchType, error) {
+func (c *Client) GetChannelList() (types.ChannelSearchType, error) {
 	var result types.ChannelSearchType
 	logger.Debug("Fetching channel list")
-	data, err := request.Request(nil, "search_getChannels")
+	data, err := c.session.Request(nil, "search_getChannels")
 	if err != nil {
 		logger.Error("Failed to fetch channel list: %v", err)
 		return result, err
@@ -312,10 +450,10 @@ func GetChannelList() (types.ChannelSearchType, error) {
 }
 
 // GetShowInfo fetches information about a show.
-func GetShowInfo(showID string, nb, start int) (types.ShowType, error) {
+func (c *Client) GetShowInfo(showID string, nb, start int) (types.ShowType, error) {
 	var result types.ShowType
 	logger.Debug("Fetching show info for ID: %s", showID)
-	data, err := request.Request(map[string]any{
+	data, err := c.session.Request(map[string]any{
 		"SHOW_ID": showID,
 		"NB":      nb,
 		"START":   start,
@@ -331,8 +469,36 @@ func GetShowInfo(showID string, nb, start int) (types.ShowType, error) {
 	return result, err
 }
 
+// GetEpisodeInfo fetches information about a show episode.
+func (c *Client) GetEpisodeInfo(episodeID string) (types.ShowEpisodeType, error) {
+	return c.episodeInfo(episodeID, c.session.Request)
+}
+
+// RefreshEpisodeInfo is GetEpisodeInfo past the response cache, for a new
+// episode token.
+func (c *Client) RefreshEpisodeInfo(episodeID string) (types.ShowEpisodeType, error) {
+	return c.episodeInfo(episodeID, c.session.RequestFresh)
+}
+
+func (c *Client) episodeInfo(episodeID string, request func(map[string]any, string) ([]byte, error)) (types.ShowEpisodeType, error) {
+	var result types.ShowEpisodeType
+	logger.Debug("Fetching episode info for ID: %s", episodeID)
+	data, err := request(map[string]any{
+		"EPISODE_ID": episodeID,
+	}, "episode.getData")
+	if err != nil {
+		logger.Error("Failed to fetch episode info: %v", err)
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		logger.Error("Failed to unmarshal episode info: %v", err)
+	}
+	return result, err
+}
+
 // GetPlaylistChannel fetches Deezer playlist channel page information.
-func GetPlaylistChannel(page string) (types.PlaylistChannelType, error) {
+func (c *Client) GetPlaylistChannel(page string) (types.PlaylistChannelType, error) {
 	var result types.PlaylistChannelType
 	logger.Debug("Fetching playlist channel page: %s", page)
 
@@ -371,7 +537,7 @@ func GetPlaylistChannel(page string) (types.PlaylistChannelType, error) {
 		"timezone_offset": "6",
 	}
 
-	data, err := request.RequestGet("app_page_get", map[string]any{
+	data, err := c.session.RequestGet("app_page_get", map[string]any{
 		"gateway_input": gatewayInput,
 	}, page)
 	if err != nil {
@@ -129,6 +129,42 @@ func TestGetProfile(t *testing.T) {
 	assert.Equal(t, "user", response.USER.TYPE_INTERNAL)
 }
 
+func TestGetProfilePlaylists(t *testing.T) {
+	USER_ID := "2064440442"
+	response, err := GetProfilePlaylists(USER_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "sayem314", response.USER.BLOG_NAME)
+	assert.NotEmpty(t, response.Data)
+	assert.LessOrEqual(t, len(response.Data), response.Total)
+}
+
+func TestGetUserFlow(t *testing.T) {
+	user, err := GetUser()
+	require.NoError(t, err)
+	response, err := GetUserFlow(user.UserID)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Data)
+}
+
+func TestGetRadioTracksPublicApi(t *testing.T) {
+	RADIO_ID := "37151"
+	info, err := GetRadioInfoPublicApi(RADIO_ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "radio", info.Type)
+	response, err := GetRadioTracksPublicApi(RADIO_ID, 10)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Data)
+	assert.LessOrEqual(t, len(response.Data), 10)
+}
+
+func TestGetArtistRadioPublicApi(t *testing.T) {
+	ART_ID := "27"
+	response, err := GetArtistRadioPublicApi(ART_ID, 10)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Data)
+	assert.LessOrEqual(t, len(response.Data), 10)
+}
+
 func TestSearchAlternative(t *testing.T) {
 	ARTIST := "Eminem"
 	TRACK := "The Real Slim Shady"
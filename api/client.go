@@ -0,0 +1,171 @@
+package api
+
+import (
+	"github.com/d-fi/GoFi/request"
+	"github.com/d-fi/GoFi/types"
+)
+
+// Client fetches Deezer data through one session. The package-level functions
+// use the default session from request.Default.
+type Client struct {
+	session *request.Session
+}
+
+// New returns a Client bound to session. A nil session means request.Default.
+func New(session *request.Session) *Client {
+	if session == nil {
+		session = request.Default()
+	}
+	return &Client{session: session}
+}
+
+func defaultClient() *Client {
+	return New(request.Default())
+}
+
+// GetTrackInfoPublicApi fetches public track information from the API using the default session.
+func GetTrackInfoPublicApi(sngID string) (types.TrackTypePublicAPI, error) {
+	return defaultClient().GetTrackInfoPublicApi(sngID)
+}
+
+// GetAlbumInfoPublicApi fetches public album information from the API using the default session.
+func GetAlbumInfoPublicApi(albID string) (types.AlbumTypePublicApi, error) {
+	return defaultClient().GetAlbumInfoPublicApi(albID)
+}
+
+// GetTrackInfo fetches detailed track information using the default session.
+func GetTrackInfo(sngID string) (types.TrackType, error) {
+	return defaultClient().GetTrackInfo(sngID)
+}
+
+// RefreshTrackInfo fetches detailed track information past the response cache
+// using the default session.
+func RefreshTrackInfo(sngID string) (types.TrackType, error) {
+	return defaultClient().RefreshTrackInfo(sngID)
+}
+
+// GetLyrics fetches lyrics for a given track using the default session.
+func GetLyrics(sngID string) (types.LyricsType, error) {
+	return defaultClient().GetLyrics(sngID)
+}
+
+// GetAlbumInfo fetches detailed album information using the default session.
+func GetAlbumInfo(albID string) (types.AlbumType, error) {
+	return defaultClient().GetAlbumInfo(albID)
+}
+
+// GetAlbumTracks fetches tracks of a given album using the default session.
+func GetAlbumTracks(albID string) (types.AlbumTracksType, error) {
+	return defaultClient().GetAlbumTracks(albID)
+}
+
+// GetPlaylistInfo fetches information about a playlist using the default session.
+func GetPlaylistInfo(playlistID string) (types.PlaylistInfo, error) {
+	return defaultClient().GetPlaylistInfo(playlistID)
+}
+
+// GetPlaylistTracks fetches tracks in a given playlist using the default session.
+func GetPlaylistTracks(playlistID string) (types.PlaylistTracksType, error) {
+	return defaultClient().GetPlaylistTracks(playlistID)
+}
+
+// GetArtistInfo fetches information about an artist using the default session.
+func GetArtistInfo(artID string) (types.ArtistInfoType, error) {
+	return defaultClient().GetArtistInfo(artID)
+}
+
+// GetDiscography fetches an artist's discography using the default session.
+func GetDiscography(artID string, nb int) (types.DiscographyType, error) {
+	return defaultClient().GetDiscography(artID, nb)
+}
+
+// GetDiscographyByRole fetches an artist's releases in the given roles using the default session.
+func GetDiscographyByRole(artID string, nb int, roleIDs []int) (types.DiscographyType, error) {
+	return defaultClient().GetDiscographyByRole(artID, nb, roleIDs)
+}
+
+// GetProfile fetches user profile information using the default session.
+func GetProfile(userID string) (types.ProfileType, error) {
+	return defaultClient().GetProfile(userID)
+}
+
+// GetProfileTracks fetches a user's loved tracks using the default session.
+func GetProfileTracks(userID string) (types.ProfileTabType[types.TrackType], error) {
+	return defaultClient().GetProfileTracks(userID)
+}
+
+// GetProfilePlaylists fetches a user's playlists using the default session.
+func GetProfilePlaylists(userID string) (types.ProfileTabType[types.PlaylistInfoMinimal], error) {
+	return defaultClient().GetProfilePlaylists(userID)
+}
+
+// GetProfileAlbums fetches a user's favourite albums using the default session.
+func GetProfileAlbums(userID string) (types.ProfileTabType[types.AlbumType], error) {
+	return defaultClient().GetProfileAlbums(userID)
+}
+
+// GetProfileArtists fetches the artists a user follows using the default session.
+func GetProfileArtists(userID string) (types.ProfileTabType[types.ArtistType], error) {
+	return defaultClient().GetProfileArtists(userID)
+}
+
+// GetUserFlow fetches a user's Flow using the default session.
+func GetUserFlow(userID string) (types.RadioTracksType, error) {
+	return defaultClient().GetUserFlow(userID)
+}
+
+// GetRadioInfoPublicApi fetches a mix from the public API using the default session.
+func GetRadioInfoPublicApi(radioID string) (types.RadioTypePublicApi, error) {
+	return defaultClient().GetRadioInfoPublicApi(radioID)
+}
+
+// GetRadioTracksPublicApi fetches the tracks of a mix from the public API using the default session.
+func GetRadioTracksPublicApi(radioID string, limit int) (types.TrackDataPublicApiList, error) {
+	return defaultClient().GetRadioTracksPublicApi(radioID, limit)
+}
+
+// GetArtistRadioPublicApi fetches an artist's radio from the public API using the default session.
+func GetArtistRadioPublicApi(artID string, limit int) (types.TrackDataPublicApiList, error) {
+	return defaultClient().GetArtistRadioPublicApi(artID, limit)
+}
+
+// SearchAlternative searches for alternative tracks by artist and song name using the default session.
+func SearchAlternative(artist, song string, nb int) (types.SearchType, error) {
+	return defaultClient().SearchAlternative(artist, song, nb)
+}
+
+// SearchMusic searches for music based on a query using the default session.
+func SearchMusic(query string, nb int, searchTypes ...string) (types.SearchType, error) {
+	return defaultClient().SearchMusic(query, nb, searchTypes...)
+}
+
+// GetUser fetches the current user's information using the default session.
+func GetUser() (types.UserType, error) {
+	return defaultClient().GetUser()
+}
+
+// GetChannelList fetches a list of available channels using the default session.
+func GetChannelList() (types.ChannelSearchType, error) {
+	return defaultClient().GetChannelList()
+}
+
+// GetShowInfo fetches information about a show using the default session.
+func GetShowInfo(showID string, nb, start int) (types.ShowType, error) {
+	return defaultClient().GetShowInfo(showID, nb, start)
+}
+
+// GetEpisodeInfo fetches information about a show episode using the default session.
+func GetEpisodeInfo(episodeID string) (types.ShowEpisodeType, error) {
+	return defaultClient().GetEpisodeInfo(episodeID)
+}
+
+// RefreshEpisodeInfo fetches show episode information past the response cache
+// using the default session.
+func RefreshEpisodeInfo(episodeID string) (types.ShowEpisodeType, error) {
+	return defaultClient().RefreshEpisodeInfo(episodeID)
+}
+
+// GetPlaylistChannel fetches Deezer playlist channel page information using the default session.
+func GetPlaylistChannel(page string) (types.PlaylistChannelType, error) {
+	return defaultClient().GetPlaylistChannel(page)
+}
@@ -23,6 +23,22 @@ func main() {
 		}
 		return
 	}
+	if len(os.Args) > 1 && os.Args[1] == "upgrade" {
+		if err := dfi.RunUpgrade(context.Background(), os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			pauseOnWindowsError()
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "retag" {
+		if err := dfi.RunRetag(context.Background(), os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			pauseOnWindowsError()
+			os.Exit(1)
+		}
+		return
+	}
 	if err := dfi.Run(context.Background(), os.Args[1:]); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		pauseOnWindowsError()
@@ -34,6 +50,7 @@ func runWeb(args []string) error {
 	fs := flag.NewFlagSet("d-fi web", flag.ContinueOnError)
 	addr := fs.String("addr", "127.0.0.1:8080", "HTTP listen address")
 	config := fs.String("config", "d-fi.config.json", "config file path")
+	state := fs.String("state", "", "job state file path (default d-fi.jobs.json next to the config)")
 	if err := fs.Parse(args); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			return nil
@@ -44,6 +61,7 @@ func runWeb(args []string) error {
 	return web.Run(context.Background(), web.Options{
 		Addr:       *addr,
 		ConfigPath: *config,
+		StatePath:  *state,
 	})
 }
 
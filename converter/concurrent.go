@@ -93,3 +93,51 @@ func convertTrackListsConcurrently[T any](items []T, convert func(int, T) []type
 	}
 	return tracks
 }
+
+// resolveGroupsConcurrently resolves items into one result each, keeping
+// their order and dropping the ones resolve rejects.
+func resolveGroupsConcurrently[T any](items []T, resolve func(T) (ParseResult, bool)) []ParseResult {
+	type result struct {
+		index int
+		group ParseResult
+		ok    bool
+	}
+
+	jobs := make(chan int)
+	results := make(chan result, len(items))
+
+	workerCount := min(len(items), converterConcurrency)
+
+	var wg sync.WaitGroup
+	for range workerCount {
+		wg.Go(func() {
+			for index := range jobs {
+				group, ok := resolve(items[index])
+				results <- result{index: index, group: group, ok: ok}
+			}
+		})
+	}
+
+	for index := range items {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+
+	ordered := make([]*ParseResult, len(items))
+	for result := range results {
+		if result.ok {
+			group := result.group
+			ordered[result.index] = &group
+		}
+	}
+
+	groups := make([]ParseResult, 0, len(items))
+	for _, group := range ordered {
+		if group != nil {
+			groups = append(groups, *group)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,249 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
+)
+
+// Release types a discography filter selects.
+const (
+	ReleaseAlbum       = "album"
+	ReleaseSingle      = "single"
+	ReleaseEP          = "ep"
+	ReleaseCompilation = "compilation"
+	ReleaseLive        = "live"
+)
+
+// Editions to keep when Deezer has a release both as explicit and as clean.
+const (
+	EditionBoth     = "both"
+	EditionExplicit = "explicit"
+	EditionClean    = "clean"
+)
+
+// Deezer discography role IDs.
+const (
+	roleMain     = 0
+	roleFeatured = 5
+)
+
+var (
+	liveTitleRE    = regexp.MustCompile(`(?i)(^live$|[(\[]live\b|\blive (at|in|from|on)\b|\s-\s+live\b)`)
+	editionTitleRE = regexp.MustCompile(`(?i)\s*[(\[](explicit|clean|edited)( version)?[)\]]`)
+)
+
+// DiscographyFilter narrows which releases an artist link downloads. The zero
+// value keeps every release where the artist is the main artist.
+type DiscographyFilter struct {
+	// Types lists the release types to keep. Empty means all of them.
+	Types []string `json:"types"`
+	// FromYear and ToYear bound the release year. Zero leaves that side open.
+	FromYear int `json:"fromYear"`
+	ToYear   int `json:"toYear"`
+	// Featured adds releases of other artists the artist appears on.
+	Featured bool `json:"featured"`
+	// Edition is both, explicit or clean.
+	Edition string `json:"edition"`
+}
+
+// ParseOptions tunes how ParseInfoWithOptions resolves a URL.
+type ParseOptions struct {
+	Discography DiscographyFilter
+	// Snapshot keeps the first Snapshot tracks of a Flow, mix or artist radio
+	// and dates its playlist title. Zero fetches the whole mix undated.
+	Snapshot int
+}
+
+// ReleaseTypes returns every release type in display order.
+func ReleaseTypes() []string {
+	return []string{ReleaseAlbum, ReleaseSingle, ReleaseEP, ReleaseCompilation, ReleaseLive}
+}
+
+// ParseReleaseTypes parses a comma-separated list of release types. An empty
+// value or "all" selects every type.
+func ParseReleaseTypes(value string) ([]string, error) {
+	var out []string
+	for part := range strings.SplitSeq(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		switch part {
+		case "":
+			continue
+		case "all":
+			return ReleaseTypes(), nil
+		case "compile":
+			part = ReleaseCompilation
+		}
+		if !slices.Contains(ReleaseTypes(), part) {
+			return nil, fmt.Errorf("invalid release type %q, use album, single, ep, compilation or live", part)
+		}
+		if !slices.Contains(out, part) {
+			out = append(out, part)
+		}
+	}
+	if len(out) == 0 {
+		return ReleaseTypes(), nil
+	}
+	return out, nil
+}
+
+// ParseEdition parses an edition preference. An empty value is both.
+func ParseEdition(value string) (string, error) {
+	switch edition := strings.ToLower(strings.TrimSpace(value)); edition {
+	case "":
+		return EditionBoth, nil
+	case EditionBoth, EditionExplicit, EditionClean:
+		return edition, nil
+	default:
+		return "", fmt.Errorf("invalid edition %q, use both, explicit or clean", value)
+	}
+}
+
+// Normalize validates filter and returns it with canonical types and edition.
+func (filter DiscographyFilter) Normalize() (DiscographyFilter, error) {
+	releaseTypes, err := ParseReleaseTypes(strings.Join(filter.Types, ","))
+	if err != nil {
+		return filter, err
+	}
+	filter.Types = releaseTypes
+	if filter.Edition, err = ParseEdition(filter.Edition); err != nil {
+		return filter, err
+	}
+	if filter.FromYear < 0 || filter.ToYear < 0 {
+		return filter, fmt.Errorf("release years can't be negative")
+	}
+	if filter.FromYear > 0 && filter.ToYear > 0 && filter.FromYear > filter.ToYear {
+		return filter, fmt.Errorf("fromYear %d is after toYear %d", filter.FromYear, filter.ToYear)
+	}
+	return filter, nil
+}
+
+func (filter DiscographyFilter) roleIDs() []int {
+	if filter.Featured {
+		return []int{roleMain, roleFeatured}
+	}
+	return []int{roleMain}
+}
+
+// FilterDiscography returns the albums of artistID's discography that pass
+// filter, in their original order.
+func FilterDiscography(albums []types.AlbumType, artistID string, filter DiscographyFilter) []types.AlbumType {
+	kept := make([]types.AlbumType, 0, len(albums))
+	for _, album := range albums {
+		main := album.ART_ID == artistID || albumContainsArtist(album, artistID)
+		if !main && !filter.Featured {
+			continue
+		}
+		if len(filter.Types) > 0 && !slices.Contains(filter.Types, ReleaseType(album)) {
+			continue
+		}
+		if !yearInRange(ReleaseYear(album), filter.FromYear, filter.ToYear) {
+			continue
+		}
+		kept = append(kept, album)
+	}
+	if filter.Edition == EditionExplicit || filter.Edition == EditionClean {
+		kept = preferEdition(kept, filter.Edition)
+	}
+	return kept
+}
+
+// ReleaseType classifies album as album, single, ep, compilation or live.
+// Deezer has no live type, so releases titled like "Live at ..." or
+// "(Live)" count as live whatever Deezer calls them.
+func ReleaseType(album types.AlbumType) string {
+	if liveTitleRE.MatchString(album.ALB_TITLE) {
+		return ReleaseLive
+	}
+	switch album.TYPE {
+	case "0":
+		return ReleaseSingle
+	case "2":
+		return ReleaseCompilation
+	case "3":
+		return ReleaseEP
+	default:
+		return ReleaseAlbum
+	}
+}
+
+// ReleaseYear returns the year album came out, or 0 when Deezer has no date.
+func ReleaseYear(album types.AlbumType) int {
+	year, _ := strconv.Atoi(utils.ReleaseYear(utils.BestReleaseDate(utils.StructMap(album), nil)))
+	return year
+}
+
+// yearInRange reports whether year is within from and to, where zero bounds
+// are open. Undated releases only pass when no range is set.
+func yearInRange(year, from, to int) bool {
+	if from == 0 && to == 0 {
+		return true
+	}
+	if year == 0 {
+		return false
+	}
+	return (from == 0 || year >= from) && (to == 0 || year <= to)
+}
+
+// preferEdition drops the explicit or clean copy of releases Deezer has in
+// both editions, keeping the one edition asks for.
+func preferEdition(albums []types.AlbumType, edition string) []types.AlbumType {
+	editions := map[string]map[string]bool{}
+	for _, album := range albums {
+		key := editionKey(album)
+		if editions[key] == nil {
+			editions[key] = map[string]bool{}
+		}
+		editions[key][albumEdition(album)] = true
+	}
+	kept := albums[:0:0]
+	for _, album := range albums {
+		found := editions[editionKey(album)]
+		if current := albumEdition(album); current != "" && current != edition && found[edition] {
+			continue
+		}
+		kept = append(kept, album)
+	}
+	return kept
+}
+
+func editionKey(album types.AlbumType) string {
+	title := editionTitleRE.ReplaceAllString(album.ALB_TITLE, "")
+	return ReleaseType(album) + "\x00" + strings.ToLower(strings.TrimSpace(title))
+}
+
+// albumEdition returns explicit or clean from Deezer's lyrics status, or an
+// empty string when the release is neither.
+func albumEdition(album types.AlbumType) string {
+	switch album.EXPLICIT_ALBUM_CONTENT.EXPLICIT_LYRICS_STATUS {
+	case 1, 4:
+		return EditionExplicit
+	case 3:
+		return EditionClean
+	default:
+		return ""
+	}
+}
+
+// trackByArtist reports whether track belongs in artistID's discography:
+// tracks where the artist is the main artist, or with featured, any track
+// the artist is credited on.
+func trackByArtist(track types.TrackType, artistID string, featured bool) bool {
+	if track.ART_ID == artistID {
+		return true
+	}
+	if !featured {
+		return false
+	}
+	for _, artist := range track.ARTISTS {
+		if artist.ART_ID == artistID {
+			return true
+		}
+	}
+	return false
+}
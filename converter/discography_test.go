@@ -0,0 +1,155 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRelease(id, title, albumType, date, artistID string, lyricsStatus int) types.AlbumType {
+	album := types.AlbumType{
+		ALB_ID:                id,
+		ALB_TITLE:             title,
+		TYPE:                  albumType,
+		ORIGINAL_RELEASE_DATE: date,
+		ART_ID:                artistID,
+	}
+	album.EXPLICIT_ALBUM_CONTENT.EXPLICIT_LYRICS_STATUS = lyricsStatus
+	return album
+}
+
+func releaseIDs(albums []types.AlbumType) []string {
+	ids := make([]string, 0, len(albums))
+	for _, album := range albums {
+		ids = append(ids, album.ALB_ID)
+	}
+	return ids
+}
+
+func TestParseReleaseTypes(t *testing.T) {
+	got, err := ParseReleaseTypes(" EP, album,ep,compile ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{ReleaseEP, ReleaseAlbum, ReleaseCompilation}, got)
+
+	for _, value := range []string{"", "all", " , "} {
+		got, err := ParseReleaseTypes(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, ReleaseTypes(), got, value)
+	}
+
+	_, err = ParseReleaseTypes("album,mixtape")
+	assert.Error(t, err)
+}
+
+func TestDiscographyFilterNormalize(t *testing.T) {
+	filter, err := DiscographyFilter{Types: []string{"Single"}, Edition: "Clean", FromYear: 2001, ToYear: 2001}.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, []string{ReleaseSingle}, filter.Types)
+	assert.Equal(t, EditionClean, filter.Edition)
+
+	filter, err = DiscographyFilter{}.Normalize()
+	require.NoError(t, err)
+	assert.Equal(t, ReleaseTypes(), filter.Types)
+	assert.Equal(t, EditionBoth, filter.Edition)
+
+	invalid := []DiscographyFilter{
+		{Edition: "radio"},
+		{Types: []string{"bootleg"}},
+		{FromYear: -1},
+		{FromYear: 2010, ToYear: 2000},
+	}
+	for _, filter := range invalid {
+		_, err := filter.Normalize()
+		assert.Error(t, err, "%+v", filter)
+	}
+}
+
+func TestReleaseType(t *testing.T) {
+	tests := []struct {
+		title     string
+		albumType string
+		want      string
+	}{
+		{"Discovery", "1", ReleaseAlbum},
+		{"One More Time", "0", ReleaseSingle},
+		{"Musique Vol. 1", "2", ReleaseCompilation},
+		{"Daft Club EP", "3", ReleaseEP},
+		{"Alive 2007", "1", ReleaseAlbum},
+		{"Alive 1997 (Live)", "1", ReleaseLive},
+		{"Live at Wembley", "1", ReleaseLive},
+		{"Around the World - Live", "0", ReleaseLive},
+		{"Live", "1", ReleaseLive},
+		{"Live Forever", "0", ReleaseSingle},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ReleaseType(types.AlbumType{ALB_TITLE: tt.title, TYPE: tt.albumType}), tt.title)
+	}
+}
+
+func TestFilterDiscographyByTypeAndYear(t *testing.T) {
+	albums := []types.AlbumType{
+		testRelease("1", "Homework", "1", "1997-01-20", "27", 0),
+		testRelease("2", "Discovery", "1", "2001-03-07", "27", 0),
+		testRelease("3", "One More Time", "0", "2000-11-13", "27", 0),
+		testRelease("4", "Alive 2007 (Live)", "1", "2007-11-19", "27", 0),
+		testRelease("5", "Undated", "1", "", "27", 0),
+		testRelease("6", "Guest Spot", "1", "2001-05-01", "99", 0),
+	}
+
+	all := FilterDiscography(albums, "27", DiscographyFilter{})
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, releaseIDs(all))
+
+	got := FilterDiscography(albums, "27", DiscographyFilter{
+		Types:    []string{ReleaseAlbum, ReleaseLive},
+		FromYear: 2000,
+	})
+	assert.Equal(t, []string{"2", "4"}, releaseIDs(got))
+
+	got = FilterDiscography(albums, "27", DiscographyFilter{ToYear: 2000})
+	assert.Equal(t, []string{"1", "3"}, releaseIDs(got))
+}
+
+func TestFilterDiscographyFeatured(t *testing.T) {
+	credited := testRelease("2", "Collab", "1", "2013-05-17", "99", 0)
+	credited.ARTISTS = []types.ArtistType{{ART_ID: "99"}, {ART_ID: "27"}}
+	albums := []types.AlbumType{
+		testRelease("1", "Random Access Memories", "1", "2013-05-17", "27", 0),
+		credited,
+		testRelease("3", "Starboy", "1", "2016-11-25", "99", 0),
+	}
+
+	assert.Equal(t, []string{"1", "2"}, releaseIDs(FilterDiscography(albums, "27", DiscographyFilter{})))
+	assert.Equal(t, []string{"1", "2", "3"}, releaseIDs(FilterDiscography(albums, "27", DiscographyFilter{Featured: true})))
+}
+
+func TestFilterDiscographyEdition(t *testing.T) {
+	albums := []types.AlbumType{
+		testRelease("1", "Album", "1", "2019-01-01", "27", 1),
+		testRelease("2", "Album (Clean)", "1", "2019-01-01", "27", 3),
+		testRelease("3", "Only Explicit", "1", "2019-01-01", "27", 1),
+		testRelease("4", "Only Clean", "1", "2019-01-01", "27", 3),
+		testRelease("5", "Album", "0", "2019-01-01", "27", 3),
+	}
+
+	both := FilterDiscography(albums, "27", DiscographyFilter{Edition: EditionBoth})
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, releaseIDs(both))
+
+	explicit := FilterDiscography(albums, "27", DiscographyFilter{Edition: EditionExplicit})
+	assert.Equal(t, []string{"1", "3", "4", "5"}, releaseIDs(explicit))
+
+	clean := FilterDiscography(albums, "27", DiscographyFilter{Edition: EditionClean})
+	assert.Equal(t, []string{"2", "3", "4", "5"}, releaseIDs(clean))
+}
+
+func TestTrackByArtist(t *testing.T) {
+	var own, guest types.TrackType
+	own.ART_ID = "27"
+	guest.ART_ID = "99"
+	guest.ARTISTS = []types.ArtistType{{ART_ID: "99"}, {ART_ID: "27"}}
+
+	assert.True(t, trackByArtist(own, "27", false))
+	assert.False(t, trackByArtist(guest, "27", false))
+	assert.True(t, trackByArtist(guest, "27", true))
+}
@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/types"
+)
+
+// FlowTitle names the playlist a Flow is saved as.
+const FlowTitle = "Flow"
+
+// defaultMixSize is how many tracks of a mix or artist radio are fetched
+// when no snapshot size is given.
+const defaultMixSize = 100
+
+// SnapshotDateLayout formats the date a snapshot title ends with.
+const SnapshotDateLayout = "2006-01-02"
+
+func mixSize(options ParseOptions) int {
+	if options.Snapshot > 0 {
+		return options.Snapshot
+	}
+	return defaultMixSize
+}
+
+// flowToDeezer resolves the current Flow of userID.
+func flowToDeezer(userID string, options ParseOptions) (ParseResult, error) {
+	flow, err := api.GetUserFlow(userID)
+	if err != nil {
+		return ParseResult{}, err
+	}
+	return mixResult(FlowTitle, flow.Data, options.Snapshot, time.Now()), nil
+}
+
+// radioToDeezer resolves a genre or mood mix.
+func radioToDeezer(radioID string, options ParseOptions) (ParseResult, error) {
+	radio, err := api.GetRadioInfoPublicApi(radioID)
+	if err != nil {
+		return ParseResult{}, err
+	}
+	tracks, err := api.GetRadioTracksPublicApi(radioID, mixSize(options))
+	if err != nil {
+		return ParseResult{}, err
+	}
+	return mixResult(radio.Title, publicTracksToDeezer(tracks.Data), options.Snapshot, time.Now()), nil
+}
+
+// artistRadioToDeezer resolves the radio of the artist artistID, the mix
+// Deezer builds from them and similar artists.
+func artistRadioToDeezer(artistID string, options ParseOptions) (ParseResult, error) {
+	artist, err := api.GetArtistInfo(artistID)
+	if err != nil {
+		return ParseResult{}, err
+	}
+	tracks, err := api.GetArtistRadioPublicApi(artistID, mixSize(options))
+	if err != nil {
+		return ParseResult{}, err
+	}
+	return mixResult(artist.ART_NAME+" Mix", publicTracksToDeezer(tracks.Data), options.Snapshot, time.Now()), nil
+}
+
+func publicTracksToDeezer(tracks []types.TrackDataPublicApi) []types.TrackType {
+	return convertTracksConcurrently(tracks, func(_ int, track types.TrackDataPublicApi) (types.TrackType, bool) {
+		full, err := api.GetTrackInfo(strconv.Itoa(track.ID))
+		return full, err == nil
+	})
+}
+
+// mixResult describes the tracks of a mix as a playlist titled title. With a
+// snapshot size it keeps that many tracks and adds date to the title, so every
+// snapshot lands in its own playlist folder.
+func mixResult(title string, tracks []types.TrackType, snapshot int, date time.Time) ParseResult {
+	if snapshot > 0 {
+		tracks = tracks[:min(snapshot, len(tracks))]
+		title += " " + date.Format(SnapshotDateLayout)
+	}
+	return ParseResult{
+		LinkType: "playlist",
+		LinkInfo: types.PlaylistInfo{Title: title, TYPE_INTERNAL: "playlist"},
+		Tracks:   numberPlaylistTracks(tracks),
+	}
+}
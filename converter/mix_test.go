@@ -0,0 +1,46 @@
+package converter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/d-fi/GoFi/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mixTracks(ids ...string) []types.TrackType {
+	tracks := make([]types.TrackType, 0, len(ids))
+	for _, id := range ids {
+		var track types.TrackType
+		track.SNG_ID = id
+		tracks = append(tracks, track)
+	}
+	return tracks
+}
+
+func TestMixResult(t *testing.T) {
+	date := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	result := mixResult("Hits", mixTracks("1", "2", "3"), 0, date)
+	assert.Equal(t, "playlist", result.LinkType)
+	playlist, ok := result.LinkInfo.(types.PlaylistInfo)
+	require.True(t, ok)
+	assert.Equal(t, "Hits", playlist.Title)
+	assert.Len(t, result.Tracks, 3)
+	require.NotNil(t, result.Tracks[2].TRACK_POSITION)
+	assert.Equal(t, 3, *result.Tracks[2].TRACK_POSITION)
+}
+
+func TestMixResultSnapshot(t *testing.T) {
+	date := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+
+	result := mixResult(FlowTitle, mixTracks("1", "2", "3"), 2, date)
+	assert.Equal(t, "Flow 2026-10-18", result.LinkInfo.(types.PlaylistInfo).Title)
+	assert.Equal(t, []string{"1", "2"}, []string{result.Tracks[0].SNG_ID, result.Tracks[1].SNG_ID})
+	assert.Len(t, result.Tracks, 2)
+
+	short := mixResult("Daft Punk Mix", mixTracks("1"), 25, date)
+	assert.Len(t, short.Tracks, 1)
+	assert.Equal(t, "Daft Punk Mix 2026-10-18", short.LinkInfo.(types.PlaylistInfo).Title)
+}
@@ -21,6 +21,9 @@ type ParseResult struct {
 	LinkType string            `json:"linktype"`
 	LinkInfo any               `json:"linkinfo"`
 	Tracks   []types.TrackType `json:"tracks"`
+	// Groups holds one result per download when the link stands for several,
+	// such as every playlist of a profile. Tracks then lists all their tracks.
+	Groups []ParseResult `json:"groups,omitempty"`
 }
 
 // GetURLParts parses supported Deezer, Spotify, Tidal, and YouTube URLs into an id/type pair.
@@ -110,6 +113,12 @@ func deezerShareDestination(parsed *url.URL) string {
 
 // ParseInfo resolves a supported Deezer, Spotify, Tidal, or YouTube URL into Deezer tracks.
 func ParseInfo(rawURL string) (ParseResult, error) {
+	return ParseInfoWithOptions(rawURL, ParseOptions{})
+}
+
+// ParseInfoWithOptions is ParseInfo with a discography filter for Deezer
+// artist links.
+func ParseInfoWithOptions(rawURL string, options ParseOptions) (ParseResult, error) {
 	info, err := GetURLParts(rawURL)
 	if err != nil {
 		return ParseResult{}, err
@@ -157,38 +166,50 @@ func ParseInfo(rawURL string) (ParseResult, error) {
 		result.LinkInfo = playlist
 		result.Tracks = tracks.Data
 	case "artist":
-		artist, err := api.GetArtistInfo(info.ID)
+		artist, tracks, err := artistToDeezer(info.ID, options.Discography)
 		if err != nil {
 			return result, err
 		}
-		albums, err := api.GetDiscography(info.ID, 500)
+		result.LinkType = "artist"
+		result.LinkInfo = artist
+		result.Tracks = append(result.Tracks, tracks...)
+	case "profile-loved", "profile-playlists", "profile-albums", "profile-artists", "profile-flow":
+		profile, err := profileToDeezer(info.ID, strings.TrimPrefix(info.Type, "profile-"), options)
 		if err != nil {
 			return result, err
 		}
-		result.LinkType = "artist"
-		result.LinkInfo = artist
-		artistAlbums := make([]types.AlbumType, 0, len(albums.Data))
-		for _, album := range albums.Data {
-			if !albumContainsArtist(album, info.ID) {
-				continue
-			}
-			artistAlbums = append(artistAlbums, album)
+		result.LinkType = profile.LinkType
+		result.LinkInfo = profile.LinkInfo
+		result.Tracks = profile.Tracks
+		result.Groups = profile.Groups
+	case "radio", "artist-radio":
+		resolve := radioToDeezer
+		if info.Type == "artist-radio" {
+			resolve = artistRadioToDeezer
 		}
-
-		tracks := convertTrackListsConcurrently(artistAlbums, func(_ int, album types.AlbumType) []types.TrackType {
-			albumTracks, err := api.GetAlbumTracks(album.ALB_ID)
-			if err != nil {
-				return nil
-			}
-			tracks := make([]types.TrackType, 0, len(albumTracks.Data))
-			for _, track := range albumTracks.Data {
-				if track.ART_ID == info.ID {
-					tracks = append(tracks, track)
-				}
-			}
-			return tracks
-		})
-		result.Tracks = append(result.Tracks, tracks...)
+		mix, err := resolve(info.ID, options)
+		if err != nil {
+			return result, err
+		}
+		result.LinkType = mix.LinkType
+		result.LinkInfo = mix.LinkInfo
+		result.Tracks = mix.Tracks
+	case "show":
+		show, tracks, err := ShowToDeezer(info.ID)
+		if err != nil {
+			return result, err
+		}
+		result.LinkType = "show"
+		result.LinkInfo = show.Data
+		result.Tracks = tracks
+	case "episode":
+		episode, err := api.GetEpisodeInfo(info.ID)
+		if err != nil {
+			return result, err
+		}
+		result.LinkType = "show"
+		result.LinkInfo = episode
+		result.Tracks = append(result.Tracks, EpisodeToTrack(episode))
 	case "youtube-track":
 		track, err := YouTubeTrackToDeezer(info.ID)
 		if err != nil {
@@ -257,16 +278,57 @@ func ParseInfo(rawURL string) (ParseResult, error) {
 		return result, fmt.Errorf("unknown type: %s", info.Type)
 	}
 
-	for i := range result.Tracks {
-		version := result.Tracks[i].VERSION
-		if version != nil && *version != "" && !strings.Contains(result.Tracks[i].SNG_TITLE, *version) {
-			result.Tracks[i].SNG_TITLE += " " + *version
-		}
+	appendTrackVersions(result.Tracks)
+	for i := range result.Groups {
+		appendTrackVersions(result.Groups[i].Tracks)
+	}
+	if len(result.Groups) > 0 {
+		result.Tracks = flattenGroups(result.Groups)
 	}
 
 	return result, nil
 }
 
+// appendTrackVersions adds each track's version, such as "(Live)", to its
+// title unless the title has it already.
+func appendTrackVersions(tracks []types.TrackType) {
+	for i := range tracks {
+		version := tracks[i].VERSION
+		if version != nil && *version != "" && !strings.Contains(tracks[i].SNG_TITLE, *version) {
+			tracks[i].SNG_TITLE += " " + *version
+		}
+	}
+}
+
+// artistToDeezer returns an artist and the tracks of the releases in their
+// discography that pass filter.
+func artistToDeezer(artistID string, filter DiscographyFilter) (types.ArtistInfoType, []types.TrackType, error) {
+	artist, err := api.GetArtistInfo(artistID)
+	if err != nil {
+		return artist, nil, err
+	}
+	albums, err := api.GetDiscographyByRole(artistID, 500, filter.roleIDs())
+	if err != nil {
+		return artist, nil, err
+	}
+	artistAlbums := FilterDiscography(albums.Data, artistID, filter)
+
+	tracks := convertTrackListsConcurrently(artistAlbums, func(_ int, album types.AlbumType) []types.TrackType {
+		albumTracks, err := api.GetAlbumTracks(album.ALB_ID)
+		if err != nil {
+			return nil
+		}
+		tracks := make([]types.TrackType, 0, len(albumTracks.Data))
+		for _, track := range albumTracks.Data {
+			if trackByArtist(track, artistID, filter.Featured) {
+				tracks = append(tracks, track)
+			}
+		}
+		return tracks
+	})
+	return artist, tracks, nil
+}
+
 func parseSpotifyURL(rawURL string) (URLParts, error) {
 	if strings.HasPrefix(rawURL, "spotify:") {
 		parts := strings.Split(rawURL, ":")
@@ -311,14 +373,21 @@ func parseDeezerURL(rawURL string) (URLParts, error) {
 	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
 	for i := 0; i < len(parts)-1; i++ {
 		switch parts[i] {
-		case "track", "album", "audiobook", "artist", "playlist":
+		case "track", "album", "audiobook", "artist", "playlist", "show", "episode", "radio":
+			if parts[i] == "artist" && i+2 < len(parts) && parts[i+2] == "radio" {
+				return URLParts{Type: "artist-radio", ID: parts[i+1]}, nil
+			}
 			if parts[i+1] != "" {
 				return URLParts{Type: parts[i], ID: parts[i+1]}, nil
 			}
+		case "profile":
+			if info, ok := profileURLParts(parts[i+1:]); ok {
+				return info, nil
+			}
 		}
 	}
 
-	re := regexp.MustCompile(`/(track|album|audiobook|artist|playlist)/(\d+)`)
+	re := regexp.MustCompile(`/(track|album|audiobook|artist|playlist|show|episode|radio)/(\d+)`)
 	matches := re.FindStringSubmatch(rawURL)
 	if len(matches) == 3 {
 		return URLParts{Type: matches[1], ID: matches[2]}, nil
@@ -39,6 +39,51 @@ func TestGetURLParts(t *testing.T) {
 			url:      "https://www.deezer.com/en/album/6575789",
 			expected: URLParts{ID: "6575789", Type: "album"},
 		},
+		{
+			name:     "deezer show",
+			url:      "https://www.deezer.com/en/show/338532",
+			expected: URLParts{ID: "338532", Type: "show"},
+		},
+		{
+			name:     "deezer episode",
+			url:      "https://www.deezer.com/episode/294961882",
+			expected: URLParts{ID: "294961882", Type: "episode"},
+		},
+		{
+			name:     "deezer profile",
+			url:      "https://www.deezer.com/en/profile/2064440442",
+			expected: URLParts{ID: "2064440442", Type: "profile-loved"},
+		},
+		{
+			name:     "deezer profile playlists",
+			url:      "https://www.deezer.com/en/profile/2064440442/playlists",
+			expected: URLParts{ID: "2064440442", Type: "profile-playlists"},
+		},
+		{
+			name:     "deezer profile artists",
+			url:      "https://www.deezer.com/profile/2064440442/Artists/",
+			expected: URLParts{ID: "2064440442", Type: "profile-artists"},
+		},
+		{
+			name:     "deezer flow",
+			url:      "https://www.deezer.com/profile/2064440442/flow",
+			expected: URLParts{ID: "2064440442", Type: "profile-flow"},
+		},
+		{
+			name:     "deezer mix",
+			url:      "https://www.deezer.com/en/radio/37151",
+			expected: URLParts{ID: "37151", Type: "radio"},
+		},
+		{
+			name:     "deezer artist radio",
+			url:      "https://www.deezer.com/us/artist/27/radio",
+			expected: URLParts{ID: "27", Type: "artist-radio"},
+		},
+		{
+			name:     "deezer artist top tracks",
+			url:      "https://www.deezer.com/us/artist/27/top_track",
+			expected: URLParts{ID: "27", Type: "artist"},
+		},
 		{
 			name:     "youtube watch",
 			url:      "https://www.youtube.com/watch?v=qFLhGq0060w&feature=share",
@@ -0,0 +1,175 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/types"
+)
+
+// Profile tabs a profile link can point at. A profile link without a tab
+// stands for the loved tracks.
+const (
+	ProfileLoved     = "loved"
+	ProfilePlaylists = "playlists"
+	ProfileAlbums    = "albums"
+	ProfileArtists   = "artists"
+	ProfileFlow      = "flow"
+)
+
+// LovedTracksTitle names the playlist loved tracks are saved as.
+const LovedTracksTitle = "Loved Tracks"
+
+// ProfileTabs returns every profile tab in display order.
+func ProfileTabs() []string {
+	return []string{ProfileLoved, ProfilePlaylists, ProfileAlbums, ProfileArtists, ProfileFlow}
+}
+
+// ProfileURL returns the Deezer link for tab of the profile userID.
+func ProfileURL(userID, tab string) string {
+	return "https://www.deezer.com/profile/" + userID + "/" + tab
+}
+
+// profileURLParts returns the URL parts of a profile link whose path
+// segments after "profile" are rest.
+func profileURLParts(rest []string) (URLParts, bool) {
+	if len(rest) == 0 || rest[0] == "" {
+		return URLParts{}, false
+	}
+	tab := ProfileLoved
+	if len(rest) > 1 && rest[1] != "" {
+		tab = strings.ToLower(rest[1])
+	}
+	for _, known := range ProfileTabs() {
+		if tab == known {
+			return URLParts{Type: "profile-" + tab, ID: rest[0]}, true
+		}
+	}
+	return URLParts{}, false
+}
+
+// profileToDeezer resolves tab of the profile userID. Loved tracks and Flow
+// come back as one playlist; playlists, albums and artists as one group each.
+func profileToDeezer(userID, tab string, options ParseOptions) (ParseResult, error) {
+	result := ParseResult{LinkType: "profile"}
+	switch tab {
+	case ProfileLoved:
+		loved, err := api.GetProfileTracks(userID)
+		if err != nil {
+			return result, err
+		}
+		result.LinkType = "playlist"
+		result.LinkInfo = lovedTracksPlaylist(loved.USER)
+		result.Tracks = numberPlaylistTracks(loved.Data)
+	case ProfilePlaylists:
+		playlists, err := api.GetProfilePlaylists(userID)
+		if err != nil {
+			return result, err
+		}
+		result.LinkInfo = playlists.USER
+		result.Groups = resolveGroupsConcurrently(playlists.Data, func(playlist types.PlaylistInfoMinimal) (ParseResult, bool) {
+			tracks, err := api.GetPlaylistTracks(playlist.PlaylistID)
+			if err != nil {
+				return ParseResult{}, false
+			}
+			return ParseResult{
+				Info:     URLParts{Type: "playlist", ID: playlist.PlaylistID},
+				LinkType: "playlist",
+				LinkInfo: playlistFromMinimal(playlist),
+				Tracks:   tracks.Data,
+			}, true
+		})
+	case ProfileAlbums:
+		albums, err := api.GetProfileAlbums(userID)
+		if err != nil {
+			return result, err
+		}
+		result.LinkInfo = albums.USER
+		result.Groups = resolveGroupsConcurrently(albums.Data, func(album types.AlbumType) (ParseResult, bool) {
+			tracks, err := api.GetAlbumTracks(album.ALB_ID)
+			if err != nil {
+				return ParseResult{}, false
+			}
+			return ParseResult{
+				Info:     URLParts{Type: "album", ID: album.ALB_ID},
+				LinkType: "album",
+				LinkInfo: album,
+				Tracks:   tracks.Data,
+			}, true
+		})
+	case ProfileArtists:
+		artists, err := api.GetProfileArtists(userID)
+		if err != nil {
+			return result, err
+		}
+		result.LinkInfo = artists.USER
+		// Each artist already fetches its albums concurrently.
+		for _, artist := range artists.Data {
+			info, tracks, err := artistToDeezer(artist.ART_ID, options.Discography)
+			if err != nil {
+				continue
+			}
+			result.Groups = append(result.Groups, ParseResult{
+				Info:     URLParts{Type: "artist", ID: artist.ART_ID},
+				LinkType: "artist",
+				LinkInfo: info,
+				Tracks:   tracks,
+			})
+		}
+	case ProfileFlow:
+		return flowToDeezer(userID, options)
+	default:
+		return result, fmt.Errorf("unknown profile tab: %s", tab)
+	}
+	return result, nil
+}
+
+// lovedTracksPlaylist describes the loved tracks of user as a playlist, so
+// they get the playlist layout and an .m3u8 like any other playlist.
+func lovedTracksPlaylist(user types.UserProfileType) types.PlaylistInfo {
+	return types.PlaylistInfo{
+		Title:          LovedTracksTitle,
+		ParentUsername: user.BLOG_NAME,
+		ParentUserID:   user.USER_ID,
+		UserID:         user.USER_ID,
+		TYPE_INTERNAL:  "playlist",
+	}
+}
+
+func playlistFromMinimal(playlist types.PlaylistInfoMinimal) types.PlaylistInfo {
+	return types.PlaylistInfo{
+		PlaylistID:        playlist.PlaylistID,
+		ParentUsername:    playlist.ParentUsername,
+		ParentUserPicture: playlist.ParentUserPicture,
+		ParentUserID:      playlist.ParentUserID,
+		PictureType:       playlist.PictureType,
+		PlaylistPicture:   playlist.PlaylistPicture,
+		Title:             playlist.Title,
+		Type:              playlist.Type,
+		Status:            playlist.Status,
+		UserID:            playlist.ParentUserID,
+		NbSong:            playlist.NbSong,
+		HasArtistLinked:   playlist.HasArtistLinked,
+		TYPE_INTERNAL:     "playlist",
+	}
+}
+
+// numberPlaylistTracks sets TRACK_POSITION to the position in the list, the
+// way api.GetPlaylistTracks does.
+func numberPlaylistTracks(tracks []types.TrackType) []types.TrackType {
+	for index := range tracks {
+		position := index + 1
+		tracks[index].TRACK_POSITION = &position
+	}
+	return tracks
+}
+
+// flattenGroups returns the tracks of every group in order.
+func flattenGroups(groups []ParseResult) []types.TrackType {
+	tracks := []types.TrackType{}
+	for _, group := range groups {
+		tracks = append(tracks, group.Tracks...)
+	}
+	return tracks
+}
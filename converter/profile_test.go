@@ -0,0 +1,63 @@
+package converter
+
+import (
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileURLRejectsUnknownTab(t *testing.T) {
+	_, err := GetURLParts("https://www.deezer.com/profile/2064440442/followings")
+	assert.Error(t, err)
+
+	info, err := GetURLParts(ProfileURL("2064440442", ProfileAlbums))
+	require.NoError(t, err)
+	assert.Equal(t, URLParts{ID: "2064440442", Type: "profile-albums"}, info)
+}
+
+func TestLovedTracksPlaylist(t *testing.T) {
+	playlist := lovedTracksPlaylist(types.UserProfileType{USER_ID: "2064440442", BLOG_NAME: "sayem314"})
+	assert.Equal(t, LovedTracksTitle, playlist.Title)
+	assert.Equal(t, "sayem314", playlist.ParentUsername)
+	assert.Equal(t, "2064440442", playlist.UserID)
+
+	tracks := numberPlaylistTracks([]types.TrackType{{}, {}})
+	require.NotNil(t, tracks[1].TRACK_POSITION)
+	assert.Equal(t, 2, *tracks[1].TRACK_POSITION)
+}
+
+func TestPlaylistFromMinimal(t *testing.T) {
+	playlist := playlistFromMinimal(types.PlaylistInfoMinimal{
+		PlaylistID:     "4523119944",
+		Title:          "wtf playlist",
+		ParentUserID:   "2064440442",
+		ParentUsername: "sayem314",
+		NbSong:         180,
+	})
+	assert.Equal(t, "4523119944", playlist.PlaylistID)
+	assert.Equal(t, "wtf playlist", playlist.Title)
+	assert.Equal(t, "sayem314", playlist.ParentUsername)
+	assert.Equal(t, 180, playlist.NbSong)
+	assert.Equal(t, "playlist", playlist.TYPE_INTERNAL)
+}
+
+func TestResolveGroupsConcurrentlyKeepsOrder(t *testing.T) {
+	ids := []string{"1", "2", "3", "4"}
+	groups := resolveGroupsConcurrently(ids, func(id string) (ParseResult, bool) {
+		if id == "3" {
+			return ParseResult{}, false
+		}
+		var track types.TrackType
+		track.SNG_ID = id
+		return ParseResult{Info: URLParts{Type: "playlist", ID: id}, Tracks: []types.TrackType{track}}, true
+	})
+
+	require.Len(t, groups, 3)
+	var got []string
+	for _, track := range flattenGroups(groups) {
+		got = append(got, track.SNG_ID)
+	}
+	assert.Equal(t, []string{"1", "2", "4"}, got)
+}
@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/types"
+)
+
+// showEpisodePageSize is how many episodes are requested per show page.
+const showEpisodePageSize = 100
+
+// ShowToDeezer fetches a Deezer show with all of its episodes as tracks.
+func ShowToDeezer(showID string) (types.ShowType, []types.TrackType, error) {
+	return showToDeezer(showID, api.GetShowInfo)
+}
+
+func showToDeezer(showID string, fetch func(showID string, nb, start int) (types.ShowType, error)) (types.ShowType, []types.TrackType, error) {
+	var show types.ShowType
+	var tracks []types.TrackType
+	for start := 0; ; {
+		page, err := fetch(showID, showEpisodePageSize, start)
+		if err != nil {
+			return show, nil, err
+		}
+		if start == 0 {
+			show = page
+		}
+		for _, episode := range page.Episodes.Data {
+			if episode.ShowID == "" {
+				episode.ShowID = show.Data.ShowID
+			}
+			if episode.ShowName == "" {
+				episode.ShowName = show.Data.ShowName
+			}
+			if episode.ShowArtMD5 == "" {
+				episode.ShowArtMD5 = show.Data.ShowArtMD5
+			}
+			if episode.ShowDescription == "" {
+				episode.ShowDescription = show.Data.ShowDescription
+			}
+			tracks = append(tracks, EpisodeToTrack(episode))
+		}
+		start += len(page.Episodes.Data)
+		if len(page.Episodes.Data) == 0 || start >= page.Episodes.Total {
+			break
+		}
+	}
+	if show.Data.ShowID == "" {
+		return show, nil, fmt.Errorf("show %s not found", showID)
+	}
+	show.Episodes.Data = nil
+	show.Episodes.Count = len(tracks)
+	return show, tracks, nil
+}
+
+// EpisodeToTrack maps a show episode onto a track so it can go through the
+// regular download pipeline. The show stands in for the album and artist.
+func EpisodeToTrack(episode types.ShowEpisodeType) types.TrackType {
+	var track types.TrackType
+	track.SNG_ID = episode.EpisodeID
+	track.SNG_TITLE = episode.EpisodeTitle
+	track.ALB_ID = episode.ShowID
+	track.ALB_TITLE = episode.ShowName
+	track.ALB_PICTURE = episode.ShowArtMD5
+	track.ART_NAME = episode.ShowName
+	track.TRACK_TOKEN = episode.TrackToken
+	track.TRACK_TOKEN_EXPIRE = episode.TrackTokenExpire
+	track.Type = "episode"
+	if duration, err := strconv.Atoi(episode.Duration); err == nil {
+		track.DURATION = types.StringOrInt(duration)
+	}
+	if size, err := strconv.Atoi(episode.FilesizeMP364); err == nil {
+		track.FILESIZE_MP3_64 = types.StringOrInt(size)
+	}
+	track.EPISODE = &episode
+	return track
+}
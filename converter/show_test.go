@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowToDeezerFetchesAllPages(t *testing.T) {
+	var starts []int
+	fetch := func(showID string, nb, start int) (types.ShowType, error) {
+		starts = append(starts, start)
+		var page types.ShowType
+		page.Data.ShowID = showID
+		page.Data.ShowName = "Show"
+		page.Data.ShowArtMD5 = "art"
+		page.Episodes.Total = 3
+		for i := start; i < min(start+2, 3); i++ {
+			page.Episodes.Data = append(page.Episodes.Data, types.ShowEpisodeType{
+				EpisodeID:    strconv.Itoa(i + 1),
+				EpisodeTitle: "Episode",
+				Duration:     "60",
+			})
+		}
+		return page, nil
+	}
+
+	show, tracks, err := showToDeezer("338532", fetch)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2}, starts)
+	assert.Equal(t, "Show", show.Data.ShowName)
+	require.Len(t, tracks, 3)
+	for i, track := range tracks {
+		assert.Equal(t, strconv.Itoa(i+1), track.SNG_ID)
+		assert.Equal(t, "Show", track.ALB_TITLE)
+		assert.Equal(t, "art", track.ALB_PICTURE)
+		assert.Equal(t, types.StringOrInt(60), track.DURATION)
+		require.NotNil(t, track.EPISODE)
+		assert.Equal(t, "338532", track.EPISODE.ShowID)
+	}
+}
+
+func TestShowToDeezerNotFound(t *testing.T) {
+	_, _, err := showToDeezer("1", func(string, int, int) (types.ShowType, error) {
+		return types.ShowType{}, nil
+	})
+	assert.EqualError(t, err, "show 1 not found")
+}
+
+func TestEpisodeTrackSurvivesJSON(t *testing.T) {
+	track := EpisodeToTrack(types.ShowEpisodeType{EpisodeID: "7", EpisodeTitle: "Episode", ShowName: "Show"})
+	data, err := json.Marshal(track)
+	require.NoError(t, err)
+
+	var decoded types.TrackType
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "7", decoded.SNG_ID)
+	require.NotNil(t, decoded.EPISODE)
+	assert.Equal(t, "Show", decoded.EPISODE.ShowName)
+}
@@ -9,7 +9,6 @@ import (
 	"strings"
 
 	"github.com/d-fi/GoFi/logger"
-	"golang.org/x/crypto/blowfish"
 )
 
 type TrackType struct {
@@ -79,48 +78,22 @@ func GetBlowfishKey(trackID string) string {
 
 // DecryptChunk decrypts a chunk of data using the blowfish key.
 func DecryptChunk(chunk []byte, blowfishKey string) []byte {
-	iv := []byte{0, 1, 2, 3, 4, 5, 6, 7}
-	block, err := blowfish.NewCipher([]byte(blowfishKey))
-	if err != nil {
-		logger.Error("Failed to create blowfish cipher: %v", err)
-		panic(err)
-	}
-
 	dst := make([]byte, len(chunk))
-	mode := cipher.NewCBCDecrypter(block, iv)
+	mode := cipher.NewCBCDecrypter(newBlowfishCipher(blowfishKey), stripeIV)
 	mode.CryptBlocks(dst, chunk)
 	return dst
 }
 
 // DecryptDownload decrypts the downloaded track using the blowfish key.
 func DecryptDownload(source []byte, trackID string) []byte {
-	blowfishKey := GetBlowfishKey(trackID)
 	logger.Debug("Decrypting download with track ID: %s", trackID)
+	block := newBlowfishCipher(GetBlowfishKey(trackID))
 
-	i := 0
-	position := 0
 	destBuffer := make([]byte, len(source))
-
-	for i := range destBuffer {
-		destBuffer[i] = 0
-	}
-
-	for position < len(source) {
-		size := len(source) - position
-		chunkSize := min(size, 2048)
-
-		chunk := make([]byte, chunkSize)
-		copy(chunk, source[position:position+chunkSize])
-
-		if i%3 > 0 || chunkSize < 2048 {
-			copy(destBuffer[position:], chunk)
-		} else {
-			decryptedChunk := DecryptChunk(chunk, blowfishKey)
-			copy(destBuffer[position:], decryptedChunk)
-		}
-
-		position += chunkSize
-		i++
+	copy(destBuffer, source)
+	for i, position := 0, 0; position < len(destBuffer); i, position = i+1, position+StripeChunkSize {
+		end := min(position+StripeChunkSize, len(destBuffer))
+		decryptStripeChunk(block, destBuffer[position:end], i)
 	}
 
 	logger.Debug("Decryption completed for track ID: %s", trackID)
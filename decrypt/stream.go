@@ -0,0 +1,123 @@
+package decrypt
+
+import (
+	"crypto/cipher"
+	"io"
+
+	"github.com/d-fi/GoFi/logger"
+	"golang.org/x/crypto/blowfish"
+)
+
+// StripeChunkSize is the size of each chunk in Deezer's BF_CBC_STRIPE cipher.
+// Every third full chunk, starting with the first, is Blowfish-CBC encrypted.
+const StripeChunkSize = 2048
+
+var stripeIV = []byte{0, 1, 2, 3, 4, 5, 6, 7}
+
+func newBlowfishCipher(blowfishKey string) cipher.Block {
+	block, err := blowfish.NewCipher([]byte(blowfishKey))
+	if err != nil {
+		logger.Error("Failed to create blowfish cipher: %v", err)
+		panic(err)
+	}
+	return block
+}
+
+// decryptStripeChunk decrypts chunk in place when its index falls on the stripe.
+// Trailing chunks shorter than StripeChunkSize are never encrypted.
+func decryptStripeChunk(block cipher.Block, chunk []byte, index int) {
+	if index%3 > 0 || len(chunk) < StripeChunkSize {
+		return
+	}
+	cipher.NewCBCDecrypter(block, stripeIV).CryptBlocks(chunk, chunk)
+}
+
+// Reader decrypts an encrypted track stream as it is read, holding at most one chunk in memory.
+type Reader struct {
+	src     io.Reader
+	block   cipher.Block
+	chunk   [StripeChunkSize]byte
+	pending []byte
+	index   int
+	err     error
+}
+
+// NewReader returns a Reader that decrypts the encrypted track read from r.
+// r must start at the beginning of the encrypted stream.
+func NewReader(r io.Reader, trackID string) *Reader {
+	return &Reader{
+		src:   r,
+		block: newBlowfishCipher(GetBlowfishKey(trackID)),
+	}
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		n, err := io.ReadFull(r.src, r.chunk[:])
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		r.err = err
+		decryptStripeChunk(r.block, r.chunk[:n], r.index)
+		r.pending = r.chunk[:n]
+		r.index++
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Writer decrypts encrypted track data written to it and forwards the plain audio to the underlying writer.
+type Writer struct {
+	dst   io.Writer
+	block cipher.Block
+	chunk [StripeChunkSize]byte
+	size  int
+	index int
+}
+
+// NewWriter returns a Writer that writes the decrypted track to w.
+// Close must be called to flush the final partial chunk.
+func NewWriter(w io.Writer, trackID string) *Writer {
+	return &Writer{
+		dst:   w,
+		block: newBlowfishCipher(GetBlowfishKey(trackID)),
+	}
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.chunk[w.size:], p)
+		w.size += n
+		written += n
+		p = p[n:]
+		if w.size == StripeChunkSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close flushes any buffered trailing bytes. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.size == 0 {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	decryptStripeChunk(w.block, w.chunk[:w.size], w.index)
+	_, err := w.dst.Write(w.chunk[:w.size])
+	w.size = 0
+	w.index++
+	return err
+}
@@ -0,0 +1,81 @@
+package decrypt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTrackID = "3135556"
+
+// encryptStripe builds a BF_CBC_STRIPE encrypted copy of plain for tests.
+func encryptStripe(t *testing.T, plain []byte, trackID string) []byte {
+	t.Helper()
+	block := newBlowfishCipher(GetBlowfishKey(trackID))
+	out := append([]byte(nil), plain...)
+	for i, position := 0, 0; position+StripeChunkSize <= len(out); i, position = i+1, position+StripeChunkSize {
+		if i%3 == 0 {
+			chunk := out[position : position+StripeChunkSize]
+			cipher.NewCBCEncrypter(block, stripeIV).CryptBlocks(chunk, chunk)
+		}
+	}
+	return out
+}
+
+func testPlainAudio(size int) []byte {
+	plain := make([]byte, size)
+	for i := range plain {
+		plain[i] = byte(i * 7)
+	}
+	return plain
+}
+
+func TestDecryptDownload(t *testing.T) {
+	plain := testPlainAudio(StripeChunkSize*7 + 123)
+	encrypted := encryptStripe(t, plain, testTrackID)
+	require.NotEqual(t, plain, encrypted)
+
+	assert.Equal(t, plain, DecryptDownload(encrypted, testTrackID))
+}
+
+func TestReaderMatchesDecryptDownload(t *testing.T) {
+	for _, size := range []int{0, 100, StripeChunkSize, StripeChunkSize*3 + 1, StripeChunkSize * 10} {
+		plain := testPlainAudio(size)
+		encrypted := encryptStripe(t, plain, testTrackID)
+
+		got, err := io.ReadAll(NewReader(iotest.HalfReader(bytes.NewReader(encrypted)), testTrackID))
+		require.NoError(t, err)
+		assert.Equal(t, plain, got, "size %d", size)
+	}
+}
+
+func TestReaderPropagatesSourceError(t *testing.T) {
+	encrypted := encryptStripe(t, testPlainAudio(StripeChunkSize*2), testTrackID)
+	source := io.MultiReader(bytes.NewReader(encrypted[:StripeChunkSize]), iotest.ErrReader(io.ErrClosedPipe))
+
+	_, err := io.ReadAll(NewReader(source, testTrackID))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestWriterMatchesDecryptDownload(t *testing.T) {
+	plain := testPlainAudio(StripeChunkSize*5 + 999)
+	encrypted := encryptStripe(t, plain, testTrackID)
+
+	var out bytes.Buffer
+	writer := NewWriter(&out, testTrackID)
+	for data := encrypted; len(data) > 0; {
+		n := min(len(data), 1500)
+		written, err := writer.Write(data[:n])
+		require.NoError(t, err)
+		require.Equal(t, n, written)
+		data = data[n:]
+	}
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, plain, out.Bytes())
+}
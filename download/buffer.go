@@ -6,32 +6,30 @@ import (
 	"fmt"
 	"io"
 
-	"github.com/d-fi/GoFi/api"
 	"github.com/d-fi/GoFi/decrypt"
 	"github.com/d-fi/GoFi/logger"
 	"github.com/d-fi/GoFi/metadata"
-	"github.com/d-fi/GoFi/request"
 )
 
 // DownloadTrackToBuffer downloads a track, decrypts if necessary, adds metadata, and returns the buffer.
-func DownloadTrackToBuffer(ctx context.Context, options DownloadTrackToBufferOptions) ([]byte, error) {
+func (c *Client) DownloadTrackToBuffer(ctx context.Context, options DownloadTrackToBufferOptions) ([]byte, error) {
 	logger.Debug("Starting download for track ID: %s with quality: %d", options.SngID, options.Quality)
 
-	track, err := api.GetTrackInfo(options.SngID)
+	track, err := c.api().GetTrackInfo(options.SngID)
 	if err != nil {
 		logger.Debug("Failed to fetch track info: %v", err)
 		return nil, fmt.Errorf("failed to fetch track info: %v", err)
 	}
 
-	trackData, err := GetTrackDownloadUrl(ctx, track, options.Quality)
+	trackData, err := c.GetTrackDownloadUrl(ctx, track, options.Quality)
 	if err != nil || trackData == nil {
 		logger.Debug("Failed to retrieve downloadable URL: %v", err)
-		return nil, fmt.Errorf("failed to retrieve downloadable URL: %v", err)
+		return nil, fmt.Errorf("failed to retrieve downloadable URL: %w", err)
 	}
 	logger.Debug("Download URL retrieved: %s", trackData.TrackUrl)
 
 	// Use the context to create an HTTP request with timeout or cancellation support
-	req := request.Client.R().
+	req := c.session.Client.R().
 		SetDoNotParseResponse(true).
 		SetContext(ctx)
 
@@ -39,7 +37,7 @@ func DownloadTrackToBuffer(ctx context.Context, options DownloadTrackToBufferOpt
 	resp, err := req.Get(trackData.TrackUrl)
 	if err != nil {
 		logger.Debug("Failed to download track: %v", err)
-		return nil, fmt.Errorf("failed to download track: %v", err)
+		return nil, fmt.Errorf("failed to download track: %w", err)
 	}
 	defer resp.RawBody().Close()
 
@@ -47,24 +45,23 @@ func DownloadTrackToBuffer(ctx context.Context, options DownloadTrackToBufferOpt
 
 	// Buffer to store the downloaded content
 	var buffer bytes.Buffer
-	_, err = io.Copy(&buffer, resp.RawBody())
+	var body io.Reader = resp.RawBody()
+	if trackData.IsEncrypted {
+		logger.Debug("Track is encrypted, decrypting while downloading")
+		body = decrypt.NewReader(body, track.SNG_ID)
+	}
+	_, err = io.Copy(&buffer, body)
 	if err != nil {
 		logger.Debug("Failed during download: %v", err)
-		return nil, fmt.Errorf("failed during download: %v", err)
+		return nil, fmt.Errorf("failed during download: %w", err)
 	}
 
 	logger.Debug("Track downloaded successfully")
 
-	// Read the buffer to decrypt if necessary
 	trackBody := buffer.Bytes()
-	if trackData.IsEncrypted {
-		logger.Debug("Track is encrypted, starting decryption process")
-		trackBody = decrypt.DecryptDownload(trackBody, track.SNG_ID)
-		logger.Debug("Track decrypted successfully")
-	}
 
 	// Add metadata to the downloaded track
-	trackWithMetadata, err := metadata.AddTrackTags(trackBody, track, metadata.TagOptions{
+	trackWithMetadata, err := c.metadata().AddTrackTags(trackBody, track, metadata.TagOptions{
 		CoverSize: options.CoverSize,
 		CoverMode: metadata.CoverMode(options.CoverMode),
 	})
@@ -0,0 +1,71 @@
+package download
+
+import (
+	"context"
+
+	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/metadata"
+	"github.com/d-fi/GoFi/request"
+	"github.com/d-fi/GoFi/types"
+)
+
+// Client resolves and downloads tracks with the account of one session. The
+// package-level functions use the default session from request.Default.
+type Client struct {
+	session *request.Session
+}
+
+// New returns a Client bound to session. A nil session means request.Default.
+func New(session *request.Session) *Client {
+	if session == nil {
+		session = request.Default()
+	}
+	return &Client{session: session}
+}
+
+func defaultClient() *Client {
+	return New(request.Default())
+}
+
+func (c *Client) api() *api.Client {
+	return api.New(c.session)
+}
+
+func (c *Client) metadata() *metadata.Client {
+	return metadata.New(c.session)
+}
+
+// DzAuthenticate retrieves the license of the default session.
+func DzAuthenticate(ctx context.Context) (*UserData, error) {
+	return defaultClient().DzAuthenticate(ctx)
+}
+
+// GetTrackUrlFromServer fetches a track URL with the default session.
+func GetTrackUrlFromServer(ctx context.Context, trackToken, format string) (string, error) {
+	return defaultClient().GetTrackUrlFromServer(ctx, trackToken, format)
+}
+
+// GetTrackDownloadUrl retrieves the download URL of a track with the default session.
+func GetTrackDownloadUrl(ctx context.Context, track types.TrackType, quality int) (*TrackDownloadUrl, error) {
+	return defaultClient().GetTrackDownloadUrl(ctx, track, quality)
+}
+
+// GetEpisodeDownloadUrl retrieves the download URL of a podcast episode with the default session.
+func GetEpisodeDownloadUrl(ctx context.Context, episode types.ShowEpisodeType) (*TrackDownloadUrl, error) {
+	return defaultClient().GetEpisodeDownloadUrl(ctx, episode)
+}
+
+// DownloadTrack downloads and tags a track with the default session.
+func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, error) {
+	return defaultClient().DownloadTrack(ctx, options)
+}
+
+// DownloadTrackToBuffer downloads and tags a track in memory with the default session.
+func DownloadTrackToBuffer(ctx context.Context, options DownloadTrackToBufferOptions) ([]byte, error) {
+	return defaultClient().DownloadTrackToBuffer(ctx, options)
+}
+
+// DownloadTrackWithoutMetadata downloads an untagged track in memory with the default session.
+func DownloadTrackWithoutMetadata(ctx context.Context, options DownloadTrackWithoutMetadataOptions) ([]byte, error) {
+	return defaultClient().DownloadTrackWithoutMetadata(ctx, options)
+}
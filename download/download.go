@@ -10,18 +10,16 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/d-fi/GoFi/api"
 	"github.com/d-fi/GoFi/decrypt"
 	"github.com/d-fi/GoFi/logger"
 	"github.com/d-fi/GoFi/metadata"
-	"github.com/d-fi/GoFi/request"
 	"github.com/d-fi/GoFi/utils"
 )
 
 // DownloadTrack downloads a track, adds metadata, and saves it to the specified directory.
-func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, error) {
+func (c *Client) DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, error) {
 	logger.Debug("Starting download for track ID: %s with quality: %d", options.SngID, options.Quality)
-	track, err := api.GetTrackInfo(options.SngID)
+	track, err := c.api().GetTrackInfo(options.SngID)
 	if err != nil {
 		logger.Debug("Failed to fetch track info: %v", err)
 		return "", fmt.Errorf("failed to fetch track info: %v", err)
@@ -38,10 +36,10 @@ func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, e
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	trackData, err := GetTrackDownloadUrl(ctx, track, options.Quality)
+	trackData, err := c.GetTrackDownloadUrl(ctx, track, options.Quality)
 	if err != nil || trackData == nil {
 		logger.Debug("Failed to retrieve downloadable URL: %v", err)
-		return "", fmt.Errorf("failed to retrieve downloadable URL: %v", err)
+		return "", fmt.Errorf("failed to retrieve downloadable URL: %w", err)
 	}
 	logger.Debug("Download URL retrieved: %s", trackData.TrackUrl)
 
@@ -63,7 +61,7 @@ func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, e
 	// If the file exists, update its timestamp and return the path
 	if _, err := os.Stat(savedPath); err == nil {
 		if metadata.ShouldSaveCoverFile(metadata.CoverMode(options.CoverMode)) {
-			if _, err := metadata.SaveAlbumCoverFile(filepath.Dir(savedPath), options.CoverName, track.ALB_PICTURE, options.CoverSize); err != nil {
+			if _, err := c.metadata().SaveAlbumCoverFile(filepath.Dir(savedPath), options.CoverName, track.ALB_PICTURE, options.CoverSize); err != nil {
 				logger.Debug("Failed to save album cover file: %v", err)
 				return "", fmt.Errorf("failed to save album cover file: %v", err)
 			}
@@ -76,29 +74,29 @@ func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, e
 		return savedPath, nil
 	}
 
-	// Open the destination file
-	out, err := os.Create(savedPath)
+	// Download into a partial file next to the destination, so savedPath only
+	// ever holds a complete track and the check above never skips a truncated one.
+	out, err := utils.CreatePartial(savedPath)
 	if err != nil {
 		logger.Debug("Failed to create file: %v", err)
 		return "", fmt.Errorf("failed to create file: %v", err)
 	}
+	partialPath := out.Name()
 	defer func() {
 		out.Close()
-		if err != nil {
-			logger.Debug("Removing incomplete file: %s", savedPath)
-			_ = os.Remove(savedPath)
-		}
+		logger.Debug("Removing partial file: %s", partialPath)
+		_ = os.Remove(partialPath)
 	}()
 
 	// Download the track from the generated URL with progress tracking
-	resp, err := request.Client.R().
+	resp, err := c.session.Client.R().
 		SetContext(ctx).
 		SetDoNotParseResponse(true). // Do not parse the response to handle the stream manually
 		Get(trackData.TrackUrl)
 
 	if err != nil {
 		logger.Debug("Failed to download track: %v", err)
-		return "", fmt.Errorf("failed to download track: %v", err)
+		return "", fmt.Errorf("failed to download track: %w", err)
 	}
 	defer resp.RawBody().Close()
 
@@ -107,6 +105,15 @@ func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, e
 	// Get the total size of the file from the response headers
 	contentLength := resp.RawResponse.ContentLength
 
+	// Decrypt on the fly so the encrypted file never has to be held in memory
+	var sink io.Writer = out
+	var decrypter *decrypt.Writer
+	if trackData.IsEncrypted {
+		logger.Debug("Track is encrypted, decrypting while downloading")
+		decrypter = decrypt.NewWriter(out, track.SNG_ID)
+		sink = decrypter
+	}
+
 	// Track download progress
 	buffer := make([]byte, 32*1024) // 32 KB buffer size
 	var totalBytesRead int64
@@ -114,7 +121,7 @@ func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, e
 	for {
 		n, readErr := resp.RawBody().Read(buffer)
 		if n > 0 {
-			_, writeErr := out.Write(buffer[:n])
+			_, writeErr := sink.Write(buffer[:n])
 			if writeErr != nil {
 				logger.Debug("Failed to write to file: %v", writeErr)
 				return "", fmt.Errorf("failed to write to file: %v", writeErr)
@@ -135,49 +142,38 @@ func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, e
 
 		if readErr != nil {
 			if ctx.Err() != nil {
-				logger.Debug("Download interrupted, removing incomplete file: %s", savedPath)
-				_ = os.Remove(savedPath)
+				logger.Debug("Download interrupted: %v", ctx.Err())
 				return "", fmt.Errorf("download interrupted: %w", ctx.Err())
 			}
 			logger.Debug("Failed during download: %v", readErr)
-			_ = os.Remove(savedPath)
-			return "", fmt.Errorf("failed during download: %v", readErr)
+			return "", fmt.Errorf("failed during download: %w", readErr)
 		}
 	}
 
-	logger.Debug("Track downloaded successfully")
-
-	// Decrypt the downloaded track if necessary
-	trackBody, err := os.ReadFile(savedPath)
-	if err != nil {
-		logger.Debug("Failed to read downloaded file for decryption: %v", err)
-		return "", fmt.Errorf("failed to read downloaded file for decryption: %v", err)
-	}
-
-	if trackData.IsEncrypted {
-		logger.Debug("Track is encrypted, starting decryption process")
-		trackBody = decrypt.DecryptDownload(trackBody, track.SNG_ID)
+	if decrypter != nil {
+		if err := decrypter.Close(); err != nil {
+			logger.Debug("Failed to write to file: %v", err)
+			return "", fmt.Errorf("failed to write to file: %v", err)
+		}
 		logger.Debug("Track decrypted successfully")
 	}
+	logger.Debug("Track downloaded successfully")
 
-	// Add metadata to the downloaded track
-	trackWithMetadata, err := metadata.AddTrackTags(trackBody, track, metadata.TagOptions{
+	if err := out.Close(); err != nil {
+		logger.Debug("Failed to write to file: %v", err)
+		return "", fmt.Errorf("failed to write to file: %v", err)
+	}
+	// Tag from the partial file into savedPath, so the track is never held in memory
+	if err := c.metadata().AddTrackTagsFile(partialPath, savedPath, track, metadata.TagOptions{
 		CoverSize: options.CoverSize,
 		CoverMode: metadata.CoverMode(options.CoverMode),
-	})
-	if err != nil {
-		logger.Debug("Failed to add metadata: %v", err)
-		return "", fmt.Errorf("failed to add metadata: %v", err)
-	}
-	logger.Debug("Metadata added successfully")
-
-	// Write the track with metadata back to the specified file
-	if err := os.WriteFile(savedPath, trackWithMetadata, 0644); err != nil {
+	}); err != nil {
 		logger.Debug("Failed to save track with metadata: %v", err)
 		return "", fmt.Errorf("failed to save track with metadata: %v", err)
 	}
+	logger.Debug("Metadata added successfully")
 	if metadata.ShouldSaveCoverFile(metadata.CoverMode(options.CoverMode)) {
-		if _, err := metadata.SaveAlbumCoverFile(filepath.Dir(savedPath), options.CoverName, track.ALB_PICTURE, options.CoverSize); err != nil {
+		if _, err := c.metadata().SaveAlbumCoverFile(filepath.Dir(savedPath), options.CoverName, track.ALB_PICTURE, options.CoverSize); err != nil {
 			logger.Debug("Failed to save album cover file: %v", err)
 			return "", fmt.Errorf("failed to save album cover file: %v", err)
 		}
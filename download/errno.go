@@ -0,0 +1,14 @@
+//go:build !plan9
+
+package download
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isConnErrno reports whether err carries an errno of a dropped or refused
+// connection.
+func isConnErrno(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,7 @@
+package download
+
+// isConnErrno reports false: Plan 9 has no errno values, and its network
+// errors are caught as net.Error.
+func isConnErrno(err error) bool {
+	return false
+}
@@ -0,0 +1,125 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/d-fi/GoFi/utils"
+	"github.com/d-fi/GoFi/verify"
+)
+
+// ErrorClass groups track download failures by how they can be recovered from.
+type ErrorClass string
+
+const (
+	ErrorNetwork      ErrorClass = "network"       // Timeouts, dropped connections, HTTP 5xx and 429.
+	ErrorCDN          ErrorClass = "cdn"           // The CDN refused or lost the file, e.g. HTTP 403 or 404.
+	ErrorTokenExpired ErrorClass = "token_expired" // The track token is no longer accepted.
+	ErrorLicense      ErrorClass = "license"       // The account cannot stream the requested format.
+	ErrorGeo          ErrorClass = "geo"           // The track is blocked in the account's country.
+	ErrorDecrypt      ErrorClass = "decrypt"       // The file could not be decrypted or failed verification.
+	ErrorCanceled     ErrorClass = "canceled"      // The download was canceled.
+	ErrorUnknown      ErrorClass = "unknown"
+)
+
+// ErrorClasses lists every class in the order they are documented.
+var ErrorClasses = []ErrorClass{ErrorNetwork, ErrorCDN, ErrorTokenExpired, ErrorLicense, ErrorGeo, ErrorDecrypt, ErrorCanceled, ErrorUnknown}
+
+// DownloadError is a classified track download failure.
+type DownloadError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *DownloadError) Error() string {
+	if e.Err == nil {
+		return string(e.Class)
+	}
+	return e.Err.Error()
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// NewError wraps err with an explicit class.
+func NewError(class ErrorClass, err error) error {
+	return &DownloadError{Class: class, Err: err}
+}
+
+// StatusError classifies an unsuccessful HTTP response from the CDN.
+func StatusError(resp *http.Response) error {
+	return &DownloadError{
+		Class: statusClass(resp.StatusCode),
+		Err:   &utils.StatusCodeError{StatusCode: resp.StatusCode, Status: resp.Status},
+	}
+}
+
+func statusClass(statusCode int) ErrorClass {
+	switch {
+	case statusCode == http.StatusRequestTimeout, statusCode == http.StatusTooEarly, statusCode == http.StatusTooManyRequests, statusCode >= 500:
+		return ErrorNetwork
+	case statusCode >= 400:
+		return ErrorCDN
+	default:
+		return ErrorUnknown
+	}
+}
+
+// Classify returns the class of a download error, or "" for nil.
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ""
+	}
+	var downloadErr *DownloadError
+	if errors.As(err, &downloadErr) {
+		return downloadErr.Class
+	}
+	var wrongLicense *WrongLicense
+	if errors.As(err, &wrongLicense) {
+		return ErrorLicense
+	}
+	var geoBlocked *GeoBlocked
+	if errors.As(err, &geoBlocked) {
+		return ErrorGeo
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorCanceled
+	}
+	if errors.Is(err, verify.ErrCorrupt) {
+		return ErrorDecrypt
+	}
+	var statusErr *utils.StatusCodeError
+	if errors.As(err, &statusErr) {
+		return statusClass(statusErr.StatusCode)
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		isConnErrno(err) ||
+		errors.As(err, &netErr) {
+		return ErrorNetwork
+	}
+	return ErrorUnknown
+}
+
+// mediaError classifies an error entry returned by the media get_url endpoint.
+func mediaError(code int, message string, country string) error {
+	if code == 2002 {
+		return &GeoBlocked{Country: country}
+	}
+	err := fmt.Errorf("API error %d: %s", code, message)
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "expired"):
+		return NewError(ErrorTokenExpired, err)
+	case strings.Contains(lower, "license") || strings.Contains(lower, "right"):
+		return NewError(ErrorLicense, err)
+	default:
+		return NewError(ErrorUnknown, err)
+	}
+}
@@ -0,0 +1,58 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/d-fi/GoFi/utils"
+	"github.com/d-fi/GoFi/verify"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "explicit", err: NewError(ErrorTokenExpired, errors.New("expired")), want: ErrorTokenExpired},
+		{name: "wrapped explicit", err: fmt.Errorf("outer: %w", NewError(ErrorDecrypt, errors.New("bad"))), want: ErrorDecrypt},
+		{name: "license", err: &WrongLicense{Format: "FLAC"}, want: ErrorLicense},
+		{name: "geo", err: fmt.Errorf("resolve: %w", &GeoBlocked{Country: "US"}), want: ErrorGeo},
+		{name: "canceled", err: fmt.Errorf("download: %w", context.Canceled), want: ErrorCanceled},
+		{name: "verify", err: fmt.Errorf("track failed verification: %w", verify.ErrCorrupt), want: ErrorDecrypt},
+		{name: "cdn 404", err: &utils.StatusCodeError{StatusCode: http.StatusNotFound}, want: ErrorCDN},
+		{name: "cdn 403", err: &utils.StatusCodeError{StatusCode: http.StatusForbidden}, want: ErrorCDN},
+		{name: "server error", err: &utils.StatusCodeError{StatusCode: http.StatusBadGateway}, want: ErrorNetwork},
+		{name: "rate limited", err: &utils.StatusCodeError{StatusCode: http.StatusTooManyRequests}, want: ErrorNetwork},
+		{name: "timeout", err: &url.Error{Op: "Get", URL: "https://cdn", Err: context.DeadlineExceeded}, want: ErrorNetwork},
+		{name: "short body", err: fmt.Errorf("segment 2: %w", io.ErrUnexpectedEOF), want: ErrorNetwork},
+		{name: "unknown", err: errors.New("boom"), want: ErrorUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.err))
+		})
+	}
+}
+
+func TestStatusErrorKeepsStatusLine(t *testing.T) {
+	err := StatusError(&http.Response{StatusCode: http.StatusForbidden, Status: "403 Forbidden"})
+	assert.Equal(t, "403 Forbidden", err.Error())
+	assert.Equal(t, ErrorCDN, Classify(err))
+}
+
+func TestMediaError(t *testing.T) {
+	var geoBlocked *GeoBlocked
+	assert.ErrorAs(t, mediaError(2002, "Track not available", "FR"), &geoBlocked)
+	assert.Equal(t, "FR", geoBlocked.Country)
+	assert.Equal(t, ErrorTokenExpired, Classify(mediaError(2001, "Track token has expired", "FR")))
+	assert.Equal(t, ErrorLicense, Classify(mediaError(2000, "License token has no sufficient rights on requested media", "FR")))
+	assert.Equal(t, ErrorUnknown, Classify(mediaError(1, "Something else", "FR")))
+}
@@ -1,12 +1,9 @@
 package download
 
-// UserData struct stores user license and streaming capabilities
-type UserData struct {
-	LicenseToken      string
-	CanStreamLossless bool
-	CanStreamHQ       bool
-	Country           string
-}
+import "github.com/d-fi/GoFi/request"
+
+// UserData stores user license and streaming capabilities.
+type UserData = request.License
 
 // TrackDownloadUrl represents the details of a track's download URL.
 type TrackDownloadUrl struct {
@@ -5,11 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
-	"sync"
 
 	"github.com/d-fi/GoFi/decrypt"
 	"github.com/d-fi/GoFi/logger"
-	"github.com/d-fi/GoFi/request"
 	"github.com/d-fi/GoFi/types"
 	"github.com/d-fi/GoFi/utils"
 )
@@ -32,9 +30,6 @@ func (e *GeoBlocked) Error() string {
 	return fmt.Sprintf("This track is not available in your country (%s)", e.Country)
 }
 
-var userData *UserData
-var userDataMu sync.Mutex
-
 type deezerUserDataResponse struct {
 	Results struct {
 		Country string `json:"COUNTRY"`
@@ -70,40 +65,34 @@ func (b *deezerBool) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// DzAuthenticate authenticates with Deezer and retrieves user data.
-func DzAuthenticate(ctx context.Context) (*UserData, error) {
-	userDataMu.Lock()
-	defer userDataMu.Unlock()
-	if userData != nil {
-		logger.Debug("Using cached user data.")
-		return userData, nil
-	}
-
-	logger.Debug("Authenticating with Deezer to retrieve user data.")
-	resp, err := request.Client.R().
-		SetContext(ctx).
-		SetQueryParams(map[string]string{
-			"method":      "deezer.getUserData",
-			"api_version": "1.0",
-			"api_token":   "null",
-		}).
-		Get("https://www.deezer.com/ajax/gw-light.php")
-
-	if err != nil {
-		logger.Debug("Failed to authenticate with Deezer: %v", err)
-		return nil, err
-	}
+// DzAuthenticate authenticates with Deezer and retrieves user data. The result
+// is cached on the session until it logs in with another ARL.
+func (c *Client) DzAuthenticate(ctx context.Context) (*UserData, error) {
+	return c.session.License(func() (*UserData, error) {
+		logger.Debug("Authenticating with Deezer to retrieve user data.")
+		resp, err := c.session.Client.R().
+			SetContext(ctx).
+			SetQueryParams(map[string]string{
+				"method":      "deezer.getUserData",
+				"api_version": "1.0",
+				"api_token":   "null",
+			}).
+			Get("https://www.deezer.com/ajax/gw-light.php")
 
-	parsed, err := parseDeezerUserData(resp.Body())
-	if err != nil {
-		logger.Debug("Failed to parse Deezer user data response: %v", err)
-		return nil, err
-	}
+		if err != nil {
+			logger.Debug("Failed to authenticate with Deezer: %v", err)
+			return nil, err
+		}
 
-	userData = parsed
-	logger.Debug("Deezer authentication successful. User country: %s", userData.Country)
+		parsed, err := parseDeezerUserData(resp.Body())
+		if err != nil {
+			logger.Debug("Failed to parse Deezer user data response: %v", err)
+			return nil, err
+		}
 
-	return userData, nil
+		logger.Debug("Deezer authentication successful. User country: %s", parsed.Country)
+		return parsed, nil
+	})
 }
 
 func parseDeezerUserData(body []byte) (*UserData, error) {
@@ -124,9 +113,9 @@ func parseDeezerUserData(body []byte) (*UserData, error) {
 }
 
 // GetTrackUrlFromServer fetches the track URL from the server based on the track token and format.
-func GetTrackUrlFromServer(ctx context.Context, trackToken, format string) (string, error) {
+func (c *Client) GetTrackUrlFromServer(ctx context.Context, trackToken, format string) (string, error) {
 	logger.Debug("Fetching track URL from server for format: %s", format)
-	user, err := DzAuthenticate(ctx)
+	user, err := c.DzAuthenticate(ctx)
 	if err != nil {
 		logger.Debug("Error during Deezer authentication: %v", err)
 		return "", err
@@ -138,7 +127,7 @@ func GetTrackUrlFromServer(ctx context.Context, trackToken, format string) (stri
 		return "", &WrongLicense{Format: format}
 	}
 
-	resp, err := request.Client.R().
+	resp, err := c.session.Client.R().
 		SetContext(ctx).
 		SetBody(map[string]any{
 			"license_token": user.LicenseToken,
@@ -167,13 +156,11 @@ func GetTrackUrlFromServer(ctx context.Context, trackToken, format string) (stri
 	if len(data) > 0 {
 		trackData := data[0].(map[string]any)
 		if errors, exists := trackData["errors"]; exists {
-			errorCode := errors.([]any)[0].(map[string]any)["code"].(float64)
-			if errorCode == 2002 {
-				logger.Debug("Track is geo-blocked in user's country: %s", user.Country)
-				return "", &GeoBlocked{Country: user.Country}
-			}
+			entry := errors.([]any)[0].(map[string]any)
+			errorCode, _ := entry["code"].(float64)
+			message, _ := entry["message"].(string)
 			logger.Debug("API returned an error: %v", errors)
-			return "", fmt.Errorf("API error: %v", errors)
+			return "", mediaError(int(errorCode), message, user.Country)
 		}
 
 		if media := trackData["media"].([]any); len(media) > 0 {
@@ -189,7 +176,7 @@ func GetTrackUrlFromServer(ctx context.Context, trackToken, format string) (stri
 }
 
 // GetTrackDownloadUrl retrieves the download URL of a track based on quality.
-func GetTrackDownloadUrl(ctx context.Context, track types.TrackType, quality int) (*TrackDownloadUrl, error) {
+func (c *Client) GetTrackDownloadUrl(ctx context.Context, track types.TrackType, quality int) (*TrackDownloadUrl, error) {
 	var formatName string
 	switch quality {
 	case 9:
@@ -208,7 +195,7 @@ func GetTrackDownloadUrl(ctx context.Context, track types.TrackType, quality int
 	var geoBlocked *GeoBlocked
 
 	// Attempt to get the URL with the official API.
-	url, err := GetTrackUrlFromServer(ctx, track.TRACK_TOKEN, formatName)
+	url, err := c.GetTrackUrlFromServer(ctx, track.TRACK_TOKEN, formatName)
 	if err == nil && url != "" {
 		fileSize, err := utils.CheckURLFileSize(ctx, url, nil)
 		if err == nil && fileSize > 0 {
@@ -264,3 +251,43 @@ func GetTrackDownloadUrl(ctx context.Context, track types.TrackType, quality int
 	logger.Debug("Failed to obtain track URL: %v", err)
 	return nil, err
 }
+
+// GetEpisodeDownloadUrl retrieves the download URL of a podcast episode.
+// Shows hosted elsewhere stream straight from their feed; the others go
+// through get_url with the episode token like any track. A nil result means
+// the episode has no audio to download.
+func (c *Client) GetEpisodeDownloadUrl(ctx context.Context, episode types.ShowEpisodeType) (*TrackDownloadUrl, error) {
+	if episode.EpisodeDirectStreamURL != "" {
+		// Feed hosts don't always answer HEAD, so the size is only a hint.
+		fileSize, err := utils.CheckURLFileSize(ctx, episode.EpisodeDirectStreamURL, nil)
+		if err != nil {
+			logger.Debug("Failed to check episode stream size: %v", err)
+		}
+		return &TrackDownloadUrl{TrackUrl: episode.EpisodeDirectStreamURL, FileSize: fileSize}, nil
+	}
+	if episode.TrackToken == "" {
+		return nil, nil
+	}
+
+	for _, format := range []string{"MP3_64", "MP3_32"} {
+		url, err := c.GetTrackUrlFromServer(ctx, episode.TrackToken, format)
+		if err != nil {
+			logger.Debug("Failed to get episode URL for format %s: %v", format, err)
+			return nil, err
+		}
+		if url == "" {
+			continue
+		}
+		fileSize, err := utils.CheckURLFileSize(ctx, url, nil)
+		if err != nil {
+			logger.Debug("Failed to verify episode URL: %v", err)
+			return nil, err
+		}
+		return &TrackDownloadUrl{
+			TrackUrl:    url,
+			IsEncrypted: strings.Contains(url, "/mobile/") || strings.Contains(url, "/media/"),
+			FileSize:    fileSize,
+		}, nil
+	}
+	return nil, nil
+}
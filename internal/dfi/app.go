@@ -13,9 +13,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/converter"
+	"github.com/d-fi/GoFi/download"
 	"github.com/d-fi/GoFi/request"
 	"github.com/d-fi/GoFi/types"
 	"github.com/d-fi/GoFi/utils"
@@ -35,6 +36,29 @@ type options struct {
 	resolveFullPath bool
 	createPlaylist  bool
 	update          bool
+	archive         string
+	dryRun          dryRunFlag
+	outputFormat    string
+	onExisting      string
+	releaseTypes    string
+	fromYear        int
+	toYear          int
+	featured        bool
+	edition         string
+	snapshot        int
+	skipSpaceCheck  bool
+	// discographySet holds the discography flags given on the command line;
+	// only those override the config.
+	discographySet map[string]bool
+	// discography is the config filter with the flags applied, set by Run.
+	discography converter.DiscographyFilter
+	// out receives the status lines and prompts. It is stderr when stdout
+	// carries JSON.
+	out io.Writer
+	// planOutput receives dry-run plans.
+	planOutput io.Writer
+	// events is set with --output-format json.
+	events *eventWriter
 }
 
 // Run starts the d-fi compatible CLI.
@@ -47,13 +71,18 @@ func Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	printBanner()
-	if _, err := CleanupStaleDownloadTemps(".", time.Hour); err != nil {
-		fmt.Fprintln(os.Stderr, warn("Unable to clean stale resumable download files: "+err.Error()))
+	opts.out, opts.planOutput = os.Stdout, os.Stdout
+	if opts.dryRun == "json" || opts.outputFormat == "json" {
+		// Keep stdout for JSON; status lines go to stderr.
+		opts.out = os.Stderr
+		if opts.outputFormat == "json" {
+			opts.events = newEventWriter(os.Stdout)
+		}
 	}
 
+	printBanner(opts.out)
 	if opts.update {
-		fmt.Println(info("Binary self-update is not available for the Go build yet."))
+		fmt.Fprintln(opts.out, info("Binary self-update is not available for the Go build yet."))
 		return nil
 	}
 
@@ -66,32 +95,50 @@ func Run(ctx context.Context, args []string) error {
 
 	cfg := LoadConfig(opts.configFile)
 	if cfg.UserConfigLocation != "" {
-		fmt.Println(info("Config loaded --> " + cfg.UserConfigLocation))
+		fmt.Fprintln(opts.out, info("Config loaded --> "+cfg.UserConfigLocation))
+	}
+	cleanupLeftovers(cfg, opts)
+	if opts.onExisting == "" {
+		opts.onExisting = string(cfg.OnExisting)
+	}
+	if opts.discography, err = opts.discographyFilter(cfg.Discography); err != nil {
+		return err
+	}
+	SetMaxBandwidth(cfg.MaxBandwidthBytes())
+	if cfg.MaxBandwidthBytes() > 0 {
+		fmt.Fprintln(opts.out, info("Bandwidth limited to "+cfg.MaxBandwidth))
 	}
 
 	if opts.setARL != "" {
 		if err := cfg.Set("cookies.arl", opts.setARL); err != nil {
 			return err
 		}
-		fmt.Println(info("cookies.arl set to --> " + opts.setARL))
-		fmt.Println(note(opts.configFile))
+		fmt.Fprintln(opts.out, info("cookies.arl set to --> "+opts.setARL))
+		fmt.Fprintln(opts.out, note(opts.configFile))
 		return nil
 	}
 
-	fmt.Println(pending("Initializing session..."))
-	arl := resolveARL(cfg)
-	if arl == "" {
-		return fmt.Errorf("missing Deezer ARL. Set DEEZER_ARL or run d-fi --set-arl <arl>")
-	}
-	fmt.Println(pending("Verifying session..."))
-	if _, err := request.InitDeezerAPI(arl); err != nil {
+	if err := initSession(opts.out, cfg); err != nil {
 		return err
 	}
-	user, err := api.GetUser()
-	if err != nil {
-		return err
+
+	archivePath := opts.archive
+	if archivePath == "" {
+		archivePath = cfg.Archive
+	}
+	var archive *Archive
+	if archivePath != "" && opts.dryRun != "" && !fileExists(archivePath) {
+		// A dry run reads the archive but never creates it.
+		archivePath = ""
+	}
+	if archivePath != "" {
+		archive, err = OpenArchive(archivePath)
+		if err != nil {
+			return fmt.Errorf("unable to open download archive: %w", err)
+		}
+		defer archive.Close()
+		fmt.Fprintln(opts.out, info(fmt.Sprintf("Download archive --> %s (%d tracks)", archivePath, archive.Len())))
 	}
-	fmt.Println(success("Logged in as " + user.BlogName))
 
 	if opts.inputFile != "" {
 		data, err := os.ReadFile(opts.inputFile)
@@ -103,15 +150,34 @@ func Run(ctx context.Context, args []string) error {
 			if line == "" || !LooksLikeURL(line) {
 				continue
 			}
-			fmt.Println(info("Starting download: " + line))
-			if err := startDownload(ctx, cfg, opts, line, true); err != nil {
+			fmt.Fprintln(opts.out, info("Starting download: "+line))
+			if err := startDownload(ctx, cfg, opts, archive, line, true); err != nil {
+				opts.events.sourceError(line, err)
 				fmt.Fprintln(os.Stderr, failure(err.Error()))
 			}
 		}
 		return nil
 	}
 
-	return startDownload(ctx, cfg, opts, opts.url, false)
+	if err := startDownload(ctx, cfg, opts, archive, opts.url, false); err != nil {
+		opts.events.sourceError(opts.url, err)
+		return err
+	}
+	return nil
+}
+
+// cleanupLeftovers removes the partial files and stale download temps that
+// earlier runs left behind. A dry run leaves the disk alone.
+func cleanupLeftovers(cfg Config, opts options) {
+	if opts.dryRun != "" {
+		return
+	}
+	if _, err := CleanupPartialFiles(append(cfg.Layouts(), opts.output), defaultDownloadTempMaxAge); err != nil {
+		fmt.Fprintln(os.Stderr, warn("Unable to clean leftover partial files: "+err.Error()))
+	}
+	if _, err := CleanupDownloadTemps(cfg, nil); err != nil {
+		fmt.Fprintln(os.Stderr, warn("Unable to clean stale resumable download files: "+err.Error()))
+	}
 }
 
 func resolveARL(cfg Config) string {
@@ -148,6 +214,17 @@ func parseOptions(args []string) (options, error) {
 	fs.BoolVar(&opts.resolveFullPath, "rfp", false, "Use absolute path for playlists")
 	fs.BoolVar(&opts.createPlaylist, "create-playlist", false, "Force create a playlist file for non playlists")
 	fs.BoolVar(&opts.createPlaylist, "cp", false, "Force create a playlist file for non playlists")
+	fs.StringVar(&opts.archive, "archive", "", "Skip tracks recorded in this download archive file")
+	fs.Var(&opts.dryRun, "dry-run", "Print what would be downloaded without downloading (table or json)")
+	fs.StringVar(&opts.outputFormat, "output-format", "text", "Output format: text or json (one event per line)")
+	fs.StringVar(&opts.onExisting, "on-existing", "", "What to do with files that already exist: skip, overwrite, retag, skip-if-same-quality or rename")
+	fs.StringVar(&opts.releaseTypes, "release-types", "", "Artist release types to download: album, single, ep, compilation, live or all")
+	fs.IntVar(&opts.fromYear, "from-year", 0, "Only download artist releases from this year on")
+	fs.IntVar(&opts.toYear, "to-year", 0, "Only download artist releases up to this year")
+	fs.BoolVar(&opts.featured, "featured", false, "Include releases the artist is featured on")
+	fs.StringVar(&opts.edition, "edition", "", "Edition to keep when a release is explicit and clean: both, explicit or clean")
+	fs.IntVar(&opts.snapshot, "snapshot", 0, "Keep the first n tracks of a Flow, mix or artist radio in a dated playlist folder")
+	fs.BoolVar(&opts.skipSpaceCheck, "skip-space-check", false, "Download even when the estimate does not fit in free disk space")
 	fs.BoolVar(&opts.update, "update", false, "Update this program to latest version")
 	fs.BoolVar(&opts.update, "U", false, "Update this program to latest version")
 	if err := fs.Parse(args); err != nil {
@@ -156,9 +233,64 @@ func parseOptions(args []string) (options, error) {
 	if opts.url == "" && fs.NArg() > 0 {
 		opts.url = fs.Arg(0)
 	}
+	switch opts.outputFormat = strings.ToLower(strings.TrimSpace(opts.outputFormat)); opts.outputFormat {
+	case "text":
+	case "json":
+		if opts.dryRun != "" {
+			opts.dryRun = "json"
+		}
+	default:
+		return opts, fmt.Errorf("invalid output format %q, use text or json", opts.outputFormat)
+	}
+	if opts.onExisting != "" {
+		policy, err := ParseExistingPolicy(opts.onExisting)
+		if err != nil {
+			return opts, err
+		}
+		opts.onExisting = string(policy)
+	}
+	if opts.snapshot < 0 {
+		return opts, fmt.Errorf("invalid snapshot size %d, use a positive number of tracks", opts.snapshot)
+	}
+	opts.discographySet = map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "release-types", "from-year", "to-year", "featured", "edition":
+			opts.discographySet[f.Name] = true
+		}
+	})
+	if _, err := opts.discographyFilter(converter.DiscographyFilter{}); err != nil {
+		return opts, err
+	}
 	return opts, nil
 }
 
+// discographyFilter returns base with the discography flags that were given
+// applied on top.
+func (opts options) discographyFilter(base converter.DiscographyFilter) (converter.DiscographyFilter, error) {
+	filter := base
+	if opts.discographySet["release-types"] {
+		releaseTypes, err := converter.ParseReleaseTypes(opts.releaseTypes)
+		if err != nil {
+			return filter, err
+		}
+		filter.Types = releaseTypes
+	}
+	if opts.discographySet["from-year"] {
+		filter.FromYear = opts.fromYear
+	}
+	if opts.discographySet["to-year"] {
+		filter.ToYear = opts.toYear
+	}
+	if opts.discographySet["featured"] {
+		filter.Featured = opts.featured
+	}
+	if opts.discographySet["edition"] {
+		filter.Edition = opts.edition
+	}
+	return filter.Normalize()
+}
+
 func printUsage(w io.Writer) {
 	fmt.Fprintln(w, "Usage of d-fi:")
 	fmt.Fprintln(w, "  -q, --quality <quality>       The quality of the files to download: 128/320/flac")
@@ -171,25 +303,38 @@ func printUsage(w io.Writer) {
 	fmt.Fprintln(w, "  -conf, --config-file <file>   Custom location to your config file")
 	fmt.Fprintln(w, "  -rfp, --resolve-full-path     Use absolute path for playlists")
 	fmt.Fprintln(w, "  -cp, --create-playlist        Force create a playlist file for non playlists")
+	fmt.Fprintln(w, "  --archive <file>              Skip tracks recorded in this download archive file")
+	fmt.Fprintln(w, "  --dry-run[=json]              Print the planned files as a table or JSON without downloading")
+	fmt.Fprintln(w, "  --output-format <format>      text or json, json prints one event per line on stdout")
+	fmt.Fprintln(w, "  --on-existing <policy>        skip, overwrite, retag, skip-if-same-quality or rename")
+	fmt.Fprintln(w, "  --release-types <types>       Artist release types: album,single,ep,compilation,live or all")
+	fmt.Fprintln(w, "  --from-year <year>            Only artist releases from this year on")
+	fmt.Fprintln(w, "  --to-year <year>              Only artist releases up to this year")
+	fmt.Fprintln(w, "  --featured                    Include releases the artist is featured on")
+	fmt.Fprintln(w, "  --edition <edition>           both, explicit or clean when a release has both")
+	fmt.Fprintln(w, "  --snapshot <n>                Keep the first n tracks of a mix in a dated playlist folder")
+	fmt.Fprintln(w, "  --skip-space-check            Download even when the estimate exceeds free disk space")
 	fmt.Fprintln(w, "  -U, --update                  Update this program to latest version")
 	fmt.Fprintln(w, "  -h, --help                    Shows this help")
 	fmt.Fprintln(w)
 	fmt.Fprintln(w, "Commands:")
 	fmt.Fprintln(w, "  web                           Start the web UI")
+	fmt.Fprintln(w, "  upgrade <dir>                 Replace files in dir with a higher quality download")
+	fmt.Fprintln(w, "  retag <path...>               Refresh the tags of downloaded files in place")
 }
 
-func printBanner() {
-	fmt.Println("             ♥ d-fi - " + Version + " ♥")
-	fmt.Println(" ──────────────────────────────────────────────")
-	fmt.Println(" │ github   https://github.com/d-fi           │")
-	fmt.Println(" │ telegram https://t.me/dFiCommunity         │")
-	fmt.Println(" ──────────────────────────────────────────────")
+func printBanner(w io.Writer) {
+	fmt.Fprintln(w, "             ♥ d-fi - "+Version+" ♥")
+	fmt.Fprintln(w, " ──────────────────────────────────────────────")
+	fmt.Fprintln(w, " │ github   https://github.com/d-fi           │")
+	fmt.Fprintln(w, " │ telegram https://t.me/dFiCommunity         │")
+	fmt.Fprintln(w, " ──────────────────────────────────────────────")
 }
 
-func startDownload(ctx context.Context, cfg Config, opts options, rawURL string, skipPrompt bool) error {
+func startDownload(ctx context.Context, cfg Config, opts options, archive *Archive, rawURL string, skipPrompt bool) error {
 	reader := bufio.NewReader(os.Stdin)
 	if opts.quality == "" {
-		quality, err := promptQuality(reader)
+		quality, err := promptQuality(opts.out, reader)
 		if err != nil {
 			return err
 		}
@@ -202,7 +347,7 @@ func startDownload(ctx context.Context, cfg Config, opts options, rawURL string,
 		opts.quality = quality
 	}
 	if rawURL == "" {
-		fmt.Print("Enter URL or search: ")
+		fmt.Fprint(opts.out, "Enter URL or search: ")
 		value, err := reader.ReadString('\n')
 		if err != nil {
 			return err
@@ -210,25 +355,58 @@ func startDownload(ctx context.Context, cfg Config, opts options, rawURL string,
 		rawURL = strings.TrimSpace(value)
 	}
 
-	data, err := resolveInput(rawURL, opts.headless, reader)
+	data, err := resolveInput(opts.out, rawURL, opts.headless, reader, converter.ParseOptions{Discography: opts.discography, Snapshot: opts.snapshot})
 	if err != nil {
 		return err
 	}
-	if !opts.headless && len(data.Tracks) > 1 {
-		data.Tracks, err = promptTracks(reader, data.Tracks)
+	groups := []ResolvedInput{data}
+	if len(data.Groups) > 0 {
+		groups = data.Groups
+		if !opts.headless {
+			groups, err = promptGroups(opts.out, reader, groups)
+			if err != nil {
+				return err
+			}
+		}
+	} else if !opts.headless && len(data.Tracks) > 1 {
+		groups[0].Tracks, err = promptTracks(opts.out, reader, data.Tracks)
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(data.Tracks) == 0 {
-		fmt.Println(info("No items to download!"))
+	if countGroupTracks(groups) == 0 {
+		fmt.Fprintln(opts.out, info("No items to download!"))
 		return nil
 	}
+	if opts.dryRun == "" {
+		if err := checkDiskSpace(cfg, opts, archive, groups, reader); err != nil {
+			return err
+		}
+	}
+	for _, group := range groups {
+		if len(group.Tracks) == 0 {
+			continue
+		}
+		if err := downloadResolved(ctx, cfg, opts, archive, rawURL, group); err != nil {
+			return err
+		}
+	}
+
+	if !opts.headless && !skipPrompt {
+		return startDownload(ctx, cfg, opts, archive, "", skipPrompt)
+	}
+	return nil
+}
 
-	fmt.Println(info(fmt.Sprintf("Proceeding to download %d tracks. Be patient.", len(data.Tracks))))
+// downloadResolved downloads the tracks of one resolved link, or prints their
+// plan in a dry run, and writes its playlist file.
+func downloadResolved(ctx context.Context, cfg Config, opts options, archive *Archive, rawURL string, data ResolvedInput) error {
+	opts.events.emit(Event{Event: EventResolved, Source: rawURL, LinkType: data.LinkType, Tracks: len(data.Tracks)})
+
+	fmt.Fprintln(opts.out, info(fmt.Sprintf("Proceeding to download %d tracks. Be patient.", len(data.Tracks))))
 	if data.LinkType == "playlist" {
-		data.Tracks = dedupePlaylistTracks(data.Tracks)
+		data.Tracks = dedupePlaylistTracks(opts.out, data.Tracks)
 	}
 
 	resolveFullPath := opts.resolveFullPath || cfg.Playlist.ResolveFullPath
@@ -240,87 +418,188 @@ func startDownload(ctx context.Context, cfg Config, opts options, rawURL string,
 		concurrency = 1
 	}
 
-	pathTemplate := opts.output
-	if pathTemplate == "" {
-		pathTemplate = cfg.Layout(data.LinkType)
+	pathTemplate := opts.pathTemplate(cfg, data.LinkType)
+
+	if opts.dryRun != "" {
+		plan := planResolved(cfg, opts, archive, data)
+		if opts.planOutput == nil {
+			opts.planOutput = os.Stdout
+		}
+		if opts.dryRun == "json" {
+			return WritePlanJSON(opts.planOutput, plan)
+		}
+		return WritePlanTable(opts.planOutput, plan)
 	}
 
-	savedFiles := downloadAll(ctx, data, cfg, opts, pathTemplate, concurrency)
+	savedFiles, failures := downloadAll(ctx, data, cfg, opts, archive, pathTemplate, concurrency)
 	if len(savedFiles) > 0 {
-		fmt.Println(info("Saved in " + strings.Join(uniqueDirs(savedFiles), ", ")))
+		fmt.Fprintln(opts.out, info("Saved in "+strings.Join(uniqueDirs(savedFiles), ", ")))
 	}
+	printFailureSummary(os.Stderr, failures)
 
-	if (opts.createPlaylist || data.LinkType == "playlist") && os.Getenv("SIMULATE") == "" && len(savedFiles) > 1 {
-		if _, err := WritePlaylistFile(data.LinkInfo, savedFiles, resolveFullPath); err != nil {
+	playlistPath := ""
+	if (opts.createPlaylist || data.LinkType == "playlist") && len(savedFiles) > 1 {
+		var err error
+		playlistPath, err = WritePlaylistFile(data.LinkInfo, savedFiles, resolveFullPath)
+		if err != nil {
 			return err
 		}
 	}
+	opts.events.summary(rawURL, data, savedFiles, failures, playlistPath)
+	return nil
+}
 
-	if !opts.headless && !skipPrompt {
-		return startDownload(ctx, cfg, opts, "", skipPrompt)
+// pathTemplate returns --output, or the configured layout for linkType.
+func (opts options) pathTemplate(cfg Config, linkType string) string {
+	if opts.output != "" {
+		return opts.output
+	}
+	return cfg.Layout(linkType)
+}
+
+// planResolved plans the tracks of data the way downloadResolved downloads
+// them.
+func planResolved(cfg Config, opts options, archive *Archive, data ResolvedInput) DownloadPlan {
+	tracks := data.Tracks
+	if data.LinkType == "playlist" {
+		tracks = dedupePlaylistTracks(opts.out, tracks)
+	}
+	return PlanDownloads(tracks, PlanOptions{
+		LinkType:        data.LinkType,
+		Quality:         opts.quality,
+		Info:            data.LinkInfo,
+		Path:            opts.pathTemplate(cfg, data.LinkType),
+		TrackNumber:     cfg.TrackNumber,
+		FallbackTrack:   cfg.FallbackTrack,
+		FallbackQuality: cfg.FallbackQuality,
+		CoverMode:       cfg.Cover.Mode,
+		CoverFileName:   cfg.Cover.FileName,
+		CreatePlaylist:  opts.createPlaylist,
+		Archive:         archive,
+		OnExisting:      ExistingPolicy(opts.onExisting),
+	})
+}
+
+// checkDiskSpace prints the estimated size of groups at the chosen quality
+// and refuses to start when it does not fit in the free space of the target
+// volume minus diskReserve. Interactive runs ask instead of refusing.
+func checkDiskSpace(cfg Config, opts options, archive *Archive, groups []ResolvedInput, reader *bufio.Reader) error {
+	var estimate int64
+	dir := ""
+	for _, group := range groups {
+		plan := planResolved(cfg, opts, archive, group)
+		estimate += plan.TotalSize
+		if dir == "" {
+			dir = PlanDir(plan)
+		}
+	}
+	if dir == "" {
+		return nil
+	}
+	check, err := CheckDiskSpace(dir, estimate, cfg.DiskReserveBytes())
+	if err != nil {
+		fmt.Fprintln(opts.out, info("Estimated size: "+formatSize(estimate)))
+		fmt.Fprintln(os.Stderr, warn("Unable to check free disk space: "+err.Error()))
+		return nil
+	}
+	fmt.Fprintln(opts.out, info(fmt.Sprintf("Estimated size: %s, %s free", formatSize(estimate), formatSize(check.Free))))
+	if check.Enough || opts.skipSpaceCheck {
+		return nil
+	}
+	if opts.headless {
+		return fmt.Errorf("%w\n%s", check.Err(), note("Free some space, lower diskReserve or pass --skip-space-check"))
+	}
+	fmt.Fprintln(opts.out, warn(check.Err().Error()))
+	fmt.Fprint(opts.out, "Download anyway? [y/N] ")
+	value, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if answer := strings.ToLower(strings.TrimSpace(value)); answer != "y" && answer != "yes" {
+		return errors.New("download canceled")
 	}
 	return nil
 }
 
-func resolveInput(rawURL string, headless bool, reader *bufio.Reader) (ResolvedInput, error) {
+func resolveInput(w io.Writer, rawURL string, headless bool, reader *bufio.Reader, parse converter.ParseOptions) (ResolvedInput, error) {
+	if IsMeInput(rawURL) {
+		profileURL, err := MeProfileURL(rawURL)
+		if err != nil {
+			return ResolvedInput{}, err
+		}
+		rawURL = profileURL
+	}
 	if !LooksLikeURL(rawURL) {
 		if headless {
 			return ResolvedInput{}, fmt.Errorf("please provide a valid URL. Unknown URL: %s", rawURL)
 		}
-		return resolveSearch(rawURL, reader)
+		return resolveSearch(w, rawURL, reader, parse)
 	}
-	if strings.Contains(rawURL, "playlist") || strings.Contains(rawURL, "artist") {
-		fmt.Println(info("Fetching data. Please hold on."))
+	if strings.Contains(rawURL, "playlist") || strings.Contains(rawURL, "artist") || strings.Contains(rawURL, "profile") || strings.Contains(rawURL, "radio") {
+		fmt.Fprintln(w, info("Fetching data. Please hold on."))
 	}
-	data, err := ParseResolvedURL(rawURL)
+	data, err := ParseResolvedURL(rawURL, parse)
 	if err != nil {
 		return ResolvedInput{}, err
 	}
 	return data, nil
 }
 
-func resolveSearch(query string, reader *bufio.Reader) (ResolvedInput, error) {
+func resolveSearch(w io.Writer, query string, reader *bufio.Reader, parse converter.ParseOptions) (ResolvedInput, error) {
 	switch {
 	case strings.HasPrefix(query, "artist:"):
 		search, err := api.SearchMusic(strings.TrimPrefix(query, "artist:"), SearchOptionLimit, "ARTIST")
 		if err != nil {
 			return ResolvedInput{}, err
 		}
-		index, err := promptChoice(reader, fmt.Sprintf("Select one artist. (found %d artists)", len(search.ARTIST.Data)), len(search.ARTIST.Data), func(i int) string {
+		index, err := promptChoice(w, reader, fmt.Sprintf("Select one artist. (found %d artists)", len(search.ARTIST.Data)), len(search.ARTIST.Data), func(i int) string {
 			item := search.ARTIST.Data[i]
 			return fmt.Sprintf("%s - %d fans", item.ART_NAME, item.NB_FAN)
 		})
 		if err != nil {
 			return ResolvedInput{}, err
 		}
-		fmt.Println(info("Fetching data. Please hold on."))
-		return resolveInput("https://deezer.com/us/artist/"+search.ARTIST.Data[index].ART_ID, false, reader)
+		fmt.Fprintln(w, info("Fetching data. Please hold on."))
+		return resolveInput(w, "https://deezer.com/us/artist/"+search.ARTIST.Data[index].ART_ID, false, reader, parse)
 	case strings.HasPrefix(query, "album:"):
 		search, err := api.SearchMusic(strings.TrimPrefix(query, "album:"), SearchOptionLimit, "ALBUM")
 		if err != nil {
 			return ResolvedInput{}, err
 		}
-		index, err := promptChoice(reader, fmt.Sprintf("Select one album. (found %d albums)", len(search.ALBUM.Data)), len(search.ALBUM.Data), func(i int) string {
+		index, err := promptChoice(w, reader, fmt.Sprintf("Select one album. (found %d albums)", len(search.ALBUM.Data)), len(search.ALBUM.Data), func(i int) string {
 			item := search.ALBUM.Data[i]
 			return fmt.Sprintf("%s - by %s, %s tracks", item.ALB_TITLE, item.ART_NAME, item.NUMBER_TRACK)
 		})
 		if err != nil {
 			return ResolvedInput{}, err
 		}
-		return resolveInput("https://deezer.com/us/album/"+search.ALBUM.Data[index].ALB_ID, false, reader)
+		return resolveInput(w, "https://deezer.com/us/album/"+search.ALBUM.Data[index].ALB_ID, false, reader, parse)
 	case strings.HasPrefix(query, "playlist:"):
 		search, err := api.SearchMusic(strings.TrimPrefix(query, "playlist:"), SearchOptionLimit, "PLAYLIST")
 		if err != nil {
 			return ResolvedInput{}, err
 		}
-		index, err := promptChoice(reader, fmt.Sprintf("Select one playlist. (found %d playlists)", len(search.PLAYLIST.Data)), len(search.PLAYLIST.Data), func(i int) string {
+		index, err := promptChoice(w, reader, fmt.Sprintf("Select one playlist. (found %d playlists)", len(search.PLAYLIST.Data)), len(search.PLAYLIST.Data), func(i int) string {
 			item := search.PLAYLIST.Data[i]
 			return fmt.Sprintf("%s - by %s, %d tracks", item.Title, item.ParentUsername, item.NbSong)
 		})
 		if err != nil {
 			return ResolvedInput{}, err
 		}
-		return resolveInput("https://deezer.com/us/playlist/"+search.PLAYLIST.Data[index].PlaylistID, false, reader)
+		return resolveInput(w, "https://deezer.com/us/playlist/"+search.PLAYLIST.Data[index].PlaylistID, false, reader, parse)
+	case strings.HasPrefix(query, "radio:"):
+		search, err := api.SearchMusic(strings.TrimPrefix(query, "radio:"), SearchOptionLimit, "RADIO")
+		if err != nil {
+			return ResolvedInput{}, err
+		}
+		index, err := promptChoice(w, reader, fmt.Sprintf("Select one mix. (found %d mixes)", len(search.RADIO.Data)), len(search.RADIO.Data), func(i int) string {
+			item := search.RADIO.Data[i]
+			return fmt.Sprintf("%s - %s", item.TITLE, RadioDescription(item))
+		})
+		if err != nil {
+			return ResolvedInput{}, err
+		}
+		return resolveInput(w, "https://deezer.com/us/radio/"+search.RADIO.Data[index].RADIO_ID, false, reader, parse)
 	default:
 		data, err := ResolveTrackSearch(query)
 		if err != nil {
@@ -330,13 +609,13 @@ func resolveSearch(query string, reader *bufio.Reader) (ResolvedInput, error) {
 	}
 }
 
-func promptQuality(reader *bufio.Reader) (string, error) {
+func promptQuality(w io.Writer, reader *bufio.Reader) (string, error) {
 	for {
-		fmt.Println("Select music quality:")
-		fmt.Println("1) MP3  - 128 kbps")
-		fmt.Println("2) MP3  - 320 kbps")
-		fmt.Println("3) FLAC - 1411 kbps")
-		fmt.Print("> ")
+		fmt.Fprintln(w, "Select music quality:")
+		fmt.Fprintln(w, "1) MP3  - 128 kbps")
+		fmt.Fprintln(w, "2) MP3  - 320 kbps")
+		fmt.Fprintln(w, "3) FLAC - 1411 kbps")
+		fmt.Fprint(w, "> ")
 		value, err := reader.ReadString('\n')
 		if err != nil {
 			return "", err
@@ -349,20 +628,20 @@ func promptQuality(reader *bufio.Reader) (string, error) {
 		case "3":
 			return "flac", nil
 		default:
-			fmt.Println("Invalid quality. Choose 1, 2, or 3.")
+			fmt.Fprintln(w, "Invalid quality. Choose 1, 2, or 3.")
 		}
 	}
 }
 
-func promptChoice(reader *bufio.Reader, message string, count int, describe func(int) string) (int, error) {
+func promptChoice(w io.Writer, reader *bufio.Reader, message string, count int, describe func(int) string) (int, error) {
 	if count == 0 {
 		return 0, fmt.Errorf("no items found")
 	}
-	fmt.Println(message)
+	fmt.Fprintln(w, message)
 	for i := range count {
-		fmt.Printf("%d) %s\n", i+1, describe(i))
+		fmt.Fprintf(w, "%d) %s\n", i+1, describe(i))
 	}
-	fmt.Print("> ")
+	fmt.Fprint(w, "> ")
 	value, err := reader.ReadString('\n')
 	if err != nil {
 		return 0, err
@@ -374,22 +653,47 @@ func promptChoice(reader *bufio.Reader, message string, count int, describe func
 	return index - 1, nil
 }
 
-func promptTracks(reader *bufio.Reader, tracks []types.TrackType) ([]types.TrackType, error) {
-	fmt.Printf("Select songs to download. Total of %d tracks.\n", len(tracks))
+func promptTracks(w io.Writer, reader *bufio.Reader, tracks []types.TrackType) ([]types.TrackType, error) {
+	fmt.Fprintf(w, "Select songs to download. Total of %d tracks.\n", len(tracks))
 	for i, track := range tracks {
-		fmt.Printf("%d) %s - Artist: %s, Album: %s, Duration: %s\n", i+1, track.SNG_TITLE, track.ART_NAME, track.ALB_TITLE, formatSecondsReadable(AsInt(track.DURATION)))
+		fmt.Fprintf(w, "%d) %s - Artist: %s, Album: %s, Duration: %s\n", i+1, track.SNG_TITLE, track.ART_NAME, track.ALB_TITLE, formatSecondsReadable(AsInt(track.DURATION)))
 	}
-	fmt.Print("Comma separated numbers, ranges, or blank for all: ")
+	indexes, err := readSelection(w, reader, len(tracks))
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]types.TrackType, 0, len(indexes))
+	for _, index := range indexes {
+		selected = append(selected, tracks[index])
+	}
+	return selected, nil
+}
+
+// readSelection asks for numbers and ranges out of count items and returns
+// the chosen ones as zero-based indexes. A blank answer selects everything.
+func readSelection(w io.Writer, reader *bufio.Reader, count int) ([]int, error) {
+	fmt.Fprint(w, "Comma separated numbers, ranges, or blank for all: ")
 	value, err := reader.ReadString('\n')
 	if err != nil {
 		return nil, err
 	}
 	value = strings.TrimSpace(value)
 	if value == "" {
-		return tracks, nil
+		indexes := make([]int, count)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes, nil
 	}
-	selected := []types.TrackType{}
+	selected := []int{}
 	seen := map[int]bool{}
+	add := func(index int) {
+		if index < 1 || index > count || seen[index] {
+			return
+		}
+		seen[index] = true
+		selected = append(selected, index-1)
+	}
 	for part := range strings.SplitSeq(value, ",") {
 		part = strings.TrimSpace(part)
 		if strings.Contains(part, "-") {
@@ -397,28 +701,20 @@ func promptTracks(reader *bufio.Reader, tracks []types.TrackType) ([]types.Track
 			start, _ := strconv.Atoi(strings.TrimSpace(edges[0]))
 			end, _ := strconv.Atoi(strings.TrimSpace(edges[1]))
 			for i := start; i <= end; i++ {
-				addTrackSelection(&selected, seen, tracks, i)
+				add(i)
 			}
 			continue
 		}
 		index, _ := strconv.Atoi(part)
-		addTrackSelection(&selected, seen, tracks, index)
+		add(index)
 	}
 	return selected, nil
 }
 
-func addTrackSelection(selected *[]types.TrackType, seen map[int]bool, tracks []types.TrackType, index int) {
-	if index < 1 || index > len(tracks) || seen[index] {
-		return
-	}
-	seen[index] = true
-	*selected = append(*selected, tracks[index-1])
-}
-
-func dedupePlaylistTracks(tracks []types.TrackType) []types.TrackType {
+func dedupePlaylistTracks(w io.Writer, tracks []types.TrackType) []types.TrackType {
 	filtered, duplicates := dedupePlaylistTrackList(tracks)
 	if duplicates > 0 {
-		fmt.Println(warn(fmt.Sprintf("Removed %d duplicate %s.", duplicates, plural("track", duplicates))))
+		fmt.Fprintln(w, warn(fmt.Sprintf("Removed %d duplicate %s.", duplicates, plural("track", duplicates))))
 	}
 	return filtered
 }
@@ -476,7 +772,33 @@ func plural(word string, count int) string {
 	return word + "s"
 }
 
-func downloadAll(ctx context.Context, data ResolvedInput, cfg Config, opts options, pathTemplate string, concurrency int) []string {
+// trackFailure is a track that could not be downloaded and the class of its final error.
+type trackFailure struct {
+	title string
+	class download.ErrorClass
+	err   error
+}
+
+// initSession logs in with the configured ARL.
+func initSession(w io.Writer, cfg Config) error {
+	fmt.Fprintln(w, pending("Initializing session..."))
+	arl := resolveARL(cfg)
+	if arl == "" {
+		return fmt.Errorf("missing Deezer ARL. Set DEEZER_ARL or run d-fi --set-arl <arl>")
+	}
+	fmt.Fprintln(w, pending("Verifying session..."))
+	if _, err := request.InitDeezerAPI(arl); err != nil {
+		return err
+	}
+	user, err := api.GetUser()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, success("Logged in as "+user.BlogName))
+	return nil
+}
+
+func downloadAll(ctx context.Context, data ResolvedInput, cfg Config, opts options, archive *Archive, pathTemplate string, concurrency int) ([]string, []trackFailure) {
 	type job struct {
 		index int
 		track types.TrackType
@@ -486,13 +808,15 @@ func downloadAll(ctx context.Context, data ResolvedInput, cfg Config, opts optio
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	savedFiles := []string{}
+	var failures []trackFailure
+	retry := cfg.Retry.Policy()
 	workerCount := min(len(data.Tracks), concurrency)
 	coverPolicy := CoverFilePolicy(data.Tracks, data.LinkInfo, pathTemplate, cfg.TrackNumber)
 
 	for range workerCount {
 		wg.Go(func() {
 			for item := range jobs {
-				savedPath, err := downloadTrack(ctx, DownloadTrackOptions{
+				savedPath, err := downloadTrack(ctx, opts.events, item.index+1, DownloadTrackOptions{
 					Track:           item.track,
 					Quality:         opts.quality,
 					Info:            data.LinkInfo,
@@ -500,16 +824,27 @@ func downloadAll(ctx context.Context, data ResolvedInput, cfg Config, opts optio
 					CoverMode:       cfg.Cover.Mode,
 					CoverFileName:   cfg.Cover.FileName,
 					CoverFilePolicy: coverPolicy,
+					Lyrics:          cfg.Lyrics.Modes,
+					TagProfile:      cfg.Tags,
 					Path:            pathTemplate,
 					TotalTracks:     len(data.Tracks),
 					TrackNumber:     cfg.TrackNumber,
 					FallbackTrack:   cfg.FallbackTrack,
 					FallbackQuality: cfg.FallbackQuality,
+					Archive:         archive,
+					WorkDir:         cfg.TempDir(),
+					OnExisting:      ExistingPolicy(opts.onExisting),
+					Retry:           retry,
 					Message:         fmt.Sprintf("(%d/%d)", item.index, len(data.Tracks)),
 				})
 				if err != nil {
-					fmt.Fprintln(os.Stderr, failure(item.track.SNG_TITLE))
+					class := download.Classify(err)
+					fmt.Fprintln(os.Stderr, failure(fmt.Sprintf("%s [%s]", item.track.SNG_TITLE, class)))
 					fmt.Fprintln(os.Stderr, note(err.Error()))
+					opts.events.trackError(item.index+1, item.track, opts.quality, err)
+					mu.Lock()
+					failures = append(failures, trackFailure{title: item.track.SNG_TITLE, class: class, err: err})
+					mu.Unlock()
 					continue
 				}
 				if savedPath == "" {
@@ -527,10 +862,31 @@ func downloadAll(ctx context.Context, data ResolvedInput, cfg Config, opts optio
 	}
 	close(jobs)
 	wg.Wait()
-	return savedFiles
+	return savedFiles, failures
 }
 
-func WritePlaylistFile(info any, savedFiles []string, resolveFullPath bool) (string, error) {
+func printFailureSummary(w io.Writer, failures []trackFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	counts := map[download.ErrorClass]int{}
+	for _, item := range failures {
+		counts[item.class]++
+	}
+	var classes []string
+	for _, class := range download.ErrorClasses {
+		if counts[class] > 0 {
+			classes = append(classes, fmt.Sprintf("%s: %d", class, counts[class]))
+		}
+	}
+	fmt.Fprintln(w, failure(fmt.Sprintf("Failed %d %s (%s)", len(failures), plural("track", len(failures)), strings.Join(classes, ", "))))
+	for _, item := range failures {
+		fmt.Fprintln(w, note(fmt.Sprintf("[%s] %s: %v", item.class, item.title, item.err)))
+	}
+}
+
+// playlistFilePath returns where WritePlaylistFile saves the playlist for savedFiles.
+func playlistFilePath(info any, savedFiles []string) string {
 	playlistDir := commonPath(uniqueDirs(savedFiles))
 	if playlistDir == "" {
 		playlistDir = "."
@@ -539,6 +895,12 @@ func WritePlaylistFile(info any, savedFiles []string, resolveFullPath bool) (str
 	if name == "" {
 		name = "playlist"
 	}
+	return filepath.Join(playlistDir, utils.SanitizeFileName(name)+".m3u8")
+}
+
+func WritePlaylistFile(info any, savedFiles []string, resolveFullPath bool) (string, error) {
+	path := playlistFilePath(info, savedFiles)
+	playlistDir := filepath.Dir(path)
 
 	entries := append([]string(nil), savedFiles...)
 	if resolveFullPath {
@@ -562,8 +924,7 @@ func WritePlaylistFile(info any, savedFiles []string, resolveFullPath bool) (str
 	}
 	sort.Strings(entries)
 	content := "#EXTM3U\n" + strings.Join(entries, "\n")
-	path := filepath.Join(playlistDir, utils.SanitizeFileName(name)+".m3u8")
-	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+	if err := utils.WriteFileAtomic(path, []byte(content), 0644); err != nil {
 		return "", err
 	}
 	return path, nil
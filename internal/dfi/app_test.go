@@ -2,12 +2,15 @@ package dfi
 
 import (
 	"bufio"
+	"io"
 	"strings"
 	"testing"
+
+	"github.com/d-fi/GoFi/converter"
 )
 
 func TestPromptQualityRejectsInvalidChoice(t *testing.T) {
-	quality, err := promptQuality(bufio.NewReader(strings.NewReader("4\n2\n")))
+	quality, err := promptQuality(io.Discard, bufio.NewReader(strings.NewReader("4\n2\n")))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -28,7 +31,7 @@ func TestPromptQualityMapsMenuChoices(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(strings.TrimSpace(tt.input), func(t *testing.T) {
-			quality, err := promptQuality(bufio.NewReader(strings.NewReader(tt.input)))
+			quality, err := promptQuality(io.Discard, bufio.NewReader(strings.NewReader(tt.input)))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -38,3 +41,59 @@ func TestPromptQualityMapsMenuChoices(t *testing.T) {
 		})
 	}
 }
+
+func TestDiscographyFlagsOverrideConfig(t *testing.T) {
+	cfg := converter.DiscographyFilter{
+		Types:    []string{converter.ReleaseAlbum},
+		FromYear: 1990,
+		Featured: true,
+		Edition:  converter.EditionClean,
+	}
+
+	opts, err := parseOptions(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err := opts.discographyFilter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(filter.Types, ",") != "album" || filter.FromYear != 1990 || !filter.Featured || filter.Edition != "clean" {
+		t.Fatalf("filter without flags = %+v", filter)
+	}
+
+	opts, err = parseOptions([]string{"--release-types", "single,live", "--to-year", "2005", "--featured=false", "--edition", "Explicit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter, err = opts.discographyFilter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(filter.Types, ",") != "single,live" || filter.FromYear != 1990 || filter.ToYear != 2005 || filter.Featured || filter.Edition != "explicit" {
+		t.Fatalf("filter with flags = %+v", filter)
+	}
+
+	for _, args := range [][]string{
+		{"--release-types", "mixtape"},
+		{"--edition", "radio"},
+		{"--from-year", "2010", "--to-year", "2000"},
+	} {
+		if _, err := parseOptions(args); err == nil {
+			t.Fatalf("parseOptions(%v) accepted an invalid filter", args)
+		}
+	}
+}
+
+func TestSnapshotFlag(t *testing.T) {
+	opts, err := parseOptions([]string{"--snapshot", "25", "me:flow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.snapshot != 25 || opts.url != "me:flow" {
+		t.Fatalf("parseOptions = snapshot %d, url %q", opts.snapshot, opts.url)
+	}
+	if _, err := parseOptions([]string{"--snapshot", "-1"}); err == nil {
+		t.Fatal("expected a negative snapshot size to be rejected")
+	}
+}
@@ -0,0 +1,190 @@
+package dfi
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d-fi/GoFi/types"
+)
+
+// ArchiveEntry records one saved track in the download archive.
+type ArchiveEntry struct {
+	SngID   string    `json:"sngId"`
+	Quality int       `json:"quality"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Time    time.Time `json:"time"`
+}
+
+type archiveKey struct {
+	sngID   string
+	quality int
+}
+
+// archiveEpisodePrefix sets podcast episodes apart from songs in the archive;
+// an episode ID can match an unrelated SNG_ID.
+const archiveEpisodePrefix = "episode:"
+
+// archiveID returns the ID track is archived under: its SNG_ID, or for a
+// podcast episode its episode ID with archiveEpisodePrefix.
+func archiveID(track types.TrackType) string {
+	if track.EPISODE != nil {
+		return archiveEpisodePrefix + track.EPISODE.EpisodeID
+	}
+	return track.SNG_ID
+}
+
+// Archive is an append-only JSONL log of downloaded tracks keyed by SNG_ID and
+// quality. Tracks found in it are skipped no matter where they were saved.
+// A nil *Archive is valid and never reports a hit.
+type Archive struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	entries map[archiveKey]ArchiveEntry
+}
+
+// OpenArchive loads the archive at path, creating it when missing. Lines that
+// cannot be parsed, such as a partial write from an interrupted run, are ignored.
+func OpenArchive(path string) (*Archive, error) {
+	archive := &Archive{
+		path:    path,
+		entries: map[archiveKey]ArchiveEntry{},
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry ArchiveEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.SngID == "" {
+			continue
+		}
+		archive.entries[archiveKey{entry.SngID, entry.Quality}] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	archive.file = file
+	return archive, nil
+}
+
+// Path returns the file the archive was opened from.
+func (a *Archive) Path() string {
+	if a == nil {
+		return ""
+	}
+	return a.path
+}
+
+// Len returns the number of archived tracks.
+func (a *Archive) Len() int {
+	if a == nil {
+		return 0
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.entries)
+}
+
+// Lookup returns the archived entry for a track at the given quality.
+func (a *Archive) Lookup(sngID string, quality int) (ArchiveEntry, bool) {
+	if a == nil {
+		return ArchiveEntry{}, false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.entries[archiveKey{sngID, quality}]
+	return entry, ok
+}
+
+// Record appends entry to the archive file. Time defaults to now.
+func (a *Archive) Record(entry ArchiveEntry) error {
+	if a == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	a.entries[archiveKey{entry.SngID, entry.Quality}] = entry
+	return nil
+}
+
+// Close closes the archive file.
+func (a *Archive) Close() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// recordSaved archives savedPath under the saved key and under each of
+// requested that is at the same quality, so a track fallback is found again
+// under the track that was asked for. A quality fallback is not archived at
+// the requested quality, so a later run can still fetch that quality. Keys
+// that are archived already are left alone.
+func (a *Archive) recordSaved(savedPath string, saved archiveKey, requested ...archiveKey) error {
+	if a == nil {
+		return nil
+	}
+	var size int64
+	if stat, err := os.Stat(savedPath); err == nil {
+		size = stat.Size()
+	}
+	keys := []archiveKey{saved}
+	for _, key := range requested {
+		if key.quality == saved.quality {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		if key.sngID == "" {
+			continue
+		}
+		if _, ok := a.Lookup(key.sngID, key.quality); ok {
+			continue
+		}
+		if err := a.Record(ArchiveEntry{SngID: key.sngID, Quality: key.quality, Path: savedPath, Size: size}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package dfi
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+)
+
+func TestArchiveRecordsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive", "d-fi.archive.jsonl")
+	archive, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Record(ArchiveEntry{SngID: "3135556", Quality: 9, Path: "Music/a.flac", Size: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := archive.Lookup("3135556", 3); ok {
+		t.Fatal("archive should be keyed by quality")
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	entry, ok := reopened.Lookup("3135556", 9)
+	if !ok {
+		t.Fatal("expected archived track after reopening")
+	}
+	if entry.Path != "Music/a.flac" || entry.Size != 42 || entry.Time.IsZero() {
+		t.Fatalf("entry = %+v", entry)
+	}
+}
+
+func TestArchiveIgnoresMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.archive.jsonl")
+	data := "{\"sngId\":\"1\",\"quality\":3,\"path\":\"one.mp3\"}\nnot json\n\n{\"sngId\":\"2\",\"qual"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if archive.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", archive.Len())
+	}
+	if _, ok := archive.Lookup("1", 3); !ok {
+		t.Fatal("expected valid line to load")
+	}
+}
+
+func TestNilArchive(t *testing.T) {
+	var archive *Archive
+	if _, ok := archive.Lookup("1", 3); ok {
+		t.Fatal("nil archive should never match")
+	}
+	if err := archive.Record(ArchiveEntry{SngID: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadTrackSkipsArchivedTrack(t *testing.T) {
+	archive, err := OpenArchive(filepath.Join(t.TempDir(), "d-fi.archive.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if err := archive.Record(ArchiveEntry{SngID: "3135556", Quality: 3, Path: "elsewhere/Song.mp3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var track types.TrackType
+	track.SNG_ID = "3135556"
+	track.SNG_TITLE = "Song"
+	var skipped string
+	savedPath, err := DownloadTrack(context.Background(), DownloadTrackOptions{
+		Track:   track,
+		Quality: "320",
+		Path:    filepath.Join(t.TempDir(), "{SNG_TITLE}"),
+		Archive: archive,
+		Hooks: DownloadTrackHooks{
+			Skip: func(track types.TrackType, savedPath, reason string) {
+				skipped = reason
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != "archived" || savedPath != "elsewhere/Song.mp3" {
+		t.Fatalf("skip reason = %q, path = %q", skipped, savedPath)
+	}
+}
+
+func TestDownloadTrackArchivesExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := OpenArchive(filepath.Join(dir, "d-fi.archive.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	existing := filepath.Join(dir, "Song.mp3")
+	if err := os.WriteFile(existing, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var track types.TrackType
+	track.SNG_ID = "3135556"
+	track.SNG_TITLE = "Song"
+	if _, err := DownloadTrack(context.Background(), DownloadTrackOptions{
+		Track:   track,
+		Quality: "320",
+		Path:    filepath.Join(dir, "{SNG_TITLE}"),
+		Archive: archive,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := archive.Lookup("3135556", 3)
+	if !ok || entry.Path != existing || entry.Size != 5 {
+		t.Fatalf("entry = %+v, ok = %v", entry, ok)
+	}
+}
+
+func TestRecordSavedSkipsQualityFallback(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := OpenArchive(filepath.Join(dir, "d-fi.archive.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	// FLAC was requested and 320 kbps was saved.
+	if err := archive.recordSaved(filepath.Join(dir, "Song.mp3"), archiveKey{"3135556", 3}, archiveKey{"3135556", 9}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := archive.Lookup("3135556", 9); ok {
+		t.Fatal("quality fallback was archived at the requested quality")
+	}
+	if _, ok := archive.Lookup("3135556", 3); !ok {
+		t.Fatal("saved quality was not archived")
+	}
+
+	// An alternative track was saved in FLAC for a FLAC request.
+	if err := archive.recordSaved(filepath.Join(dir, "Alt.flac"), archiveKey{"3135557", 9}, archiveKey{"3135555", 9}); err != nil {
+		t.Fatal(err)
+	}
+	for _, sngID := range []string{"3135555", "3135557"} {
+		if entry, ok := archive.Lookup(sngID, 9); !ok || entry.Path != filepath.Join(dir, "Alt.flac") {
+			t.Fatalf("%s entry = %+v, ok = %v", sngID, entry, ok)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package dfi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLimiterSleep bounds each wait so a rate change applies to readers that
+// are already blocked.
+const maxLimiterSleep = 250 * time.Millisecond
+
+var bandwidthUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1000,
+	"kb":  1000,
+	"kib": 1024,
+	"m":   1000 * 1000,
+	"mb":  1000 * 1000,
+	"mib": 1024 * 1024,
+	"g":   1000 * 1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1024 * 1024 * 1024,
+}
+
+// ParseBandwidth converts a rate such as "4MiB/s", "500KB/s" or "1048576" to
+// bytes per second. An empty value, "0" or "unlimited" means no limit.
+func ParseBandwidth(value string) (int64, error) {
+	text := strings.ToLower(strings.TrimSpace(value))
+	text = strings.TrimSuffix(text, "/s")
+	text = strings.TrimSpace(text)
+	if text == "" || text == "0" || text == "unlimited" {
+		return 0, nil
+	}
+
+	split := strings.IndexFunc(text, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := text, ""
+	if split >= 0 {
+		number, unit = text[:split], strings.TrimSpace(text[split:])
+	}
+	multiplier, ok := bandwidthUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid bandwidth %q: unknown unit %q", value, unit)
+	}
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q", value)
+	}
+	return int64(amount * multiplier), nil
+}
+
+// RateLimiter is a token bucket shared by every reader it wraps. The rate can
+// be changed while readers are waiting.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter for bytesPerSecond; zero disables it.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	limiter := &RateLimiter{}
+	limiter.SetRate(bytesPerSecond)
+	return limiter
+}
+
+// SetRate changes the limit in bytes per second; zero disables it.
+func (l *RateLimiter) SetRate(bytesPerSecond int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.rate = float64(max(bytesPerSecond, 0))
+	l.tokens = min(l.tokens, l.burst())
+}
+
+// Rate returns the current limit in bytes per second.
+func (l *RateLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.rate)
+}
+
+// burst is a quarter second of traffic, so the cap holds over short windows.
+func (l *RateLimiter) burst() float64 {
+	return max(l.rate/4, 1)
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() && l.rate > 0 {
+		l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, l.burst())
+	}
+	l.last = now
+}
+
+// WaitN blocks until n bytes may pass or ctx is done.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	remaining := float64(n)
+	for remaining > 0 {
+		l.mu.Lock()
+		if l.rate == 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		l.refill(time.Now())
+		take := min(remaining, l.tokens)
+		l.tokens -= take
+		remaining -= take
+		wait := time.Duration(min(remaining, l.burst()) / l.rate * float64(time.Second))
+		l.mu.Unlock()
+		if remaining <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(min(max(wait, time.Millisecond), maxLimiterSleep))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// Reader wraps r so its reads share the limiter's bandwidth.
+func (l *RateLimiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &limitedReader{ctx: ctx, reader: r, limiter: l}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if rate := r.limiter.Rate(); rate > 0 {
+		p = p[:min(len(p), max(int(rate/4), 1))]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+var downloadLimiter = NewRateLimiter(0)
+
+// SetMaxBandwidth caps the combined speed of every track download in the
+// process. Downloads already in progress pick up the new rate immediately.
+func SetMaxBandwidth(bytesPerSecond int64) {
+	downloadLimiter.SetRate(bytesPerSecond)
+}
+
+// MaxBandwidth returns the current download cap in bytes per second.
+func MaxBandwidth() int64 {
+	return downloadLimiter.Rate()
+}
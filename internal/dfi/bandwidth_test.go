@@ -0,0 +1,87 @@
+package dfi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{value: "", want: 0},
+		{value: "0", want: 0},
+		{value: "unlimited", want: 0},
+		{value: "4MiB/s", want: 4 * 1024 * 1024},
+		{value: "500KB/s", want: 500 * 1000},
+		{value: "1.5 MiB", want: 1536 * 1024},
+		{value: "2048", want: 2048},
+		{value: "1gib/s", want: 1024 * 1024 * 1024},
+	}
+	for _, tt := range tests {
+		got, err := ParseBandwidth(tt.value)
+		if err != nil {
+			t.Fatalf("ParseBandwidth(%q) error = %v", tt.value, err)
+		}
+		if got != tt.want {
+			t.Fatalf("ParseBandwidth(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"fast", "4MiB/h", "-1MB/s", "1..2M"} {
+		if _, err := ParseBandwidth(value); err == nil {
+			t.Fatalf("ParseBandwidth(%q) should fail", value)
+		}
+	}
+}
+
+func TestRateLimiterReaderCapsThroughput(t *testing.T) {
+	limiter := NewRateLimiter(40_000)
+	data := bytes.Repeat([]byte("x"), 20_000)
+
+	started := time.Now()
+	out, err := io.ReadAll(limiter.Reader(context.Background(), bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(started)
+	if !bytes.Equal(out, data) {
+		t.Fatal("limited reader changed the data")
+	}
+	if elapsed < 400*time.Millisecond {
+		t.Fatalf("read 20000 bytes at 40000 B/s in %s, want about 500ms", elapsed)
+	}
+}
+
+func TestRateLimiterRateChangeReleasesWaiters(t *testing.T) {
+	limiter := NewRateLimiter(100)
+	done := make(chan error, 1)
+	go func() {
+		done <- limiter.WaitN(context.Background(), 10_000)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	limiter.SetRate(0)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not released after the limit was removed")
+	}
+}
+
+func TestRateLimiterHonorsContext(t *testing.T) {
+	limiter := NewRateLimiter(100)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.WaitN(ctx, 10_000); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitN error = %v, want deadline exceeded", err)
+	}
+}
@@ -5,20 +5,32 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/d-fi/GoFi/converter"
 	"github.com/d-fi/GoFi/metadata"
 )
 
 type Config struct {
-	Concurrency        int          `json:"concurrency"`
-	SaveLayout         SaveLayouts  `json:"saveLayout"`
-	Playlist           PlaylistConf `json:"playlist"`
-	TrackNumber        bool         `json:"trackNumber"`
-	FallbackTrack      bool         `json:"fallbackTrack"`
-	FallbackQuality    bool         `json:"fallbackQuality"`
-	CoverSize          CoverSizes   `json:"coverSize"`
-	Cover              CoverConfig  `json:"cover"`
-	Cookies            Cookies      `json:"cookies"`
+	Concurrency        int                         `json:"concurrency"`
+	SaveLayout         SaveLayouts                 `json:"saveLayout"`
+	Playlist           PlaylistConf                `json:"playlist"`
+	TrackNumber        bool                        `json:"trackNumber"`
+	FallbackTrack      bool                        `json:"fallbackTrack"`
+	FallbackQuality    bool                        `json:"fallbackQuality"`
+	CoverSize          CoverSizes                  `json:"coverSize"`
+	Cover              CoverConfig                 `json:"cover"`
+	Lyrics             LyricsConfig                `json:"lyrics"`
+	Tags               metadata.TagProfile         `json:"tags"`
+	Cookies            Cookies                     `json:"cookies"`
+	Archive            string                      `json:"archive"`
+	MaxBandwidth       string                      `json:"maxBandwidth"`
+	DiskReserve        string                      `json:"diskReserve"`
+	Retry              RetryConfig                 `json:"retry"`
+	WorkDir            string                      `json:"workDir"`
+	TempMaxAge         string                      `json:"tempMaxAge"`
+	OnExisting         ExistingPolicy              `json:"onExisting"`
+	Discography        converter.DiscographyFilter `json:"discography"`
 	path               string
 	UserConfigLocation string `json:"-"`
 }
@@ -28,6 +40,7 @@ type SaveLayouts struct {
 	Album    string `json:"album"`
 	Artist   string `json:"artist"`
 	Playlist string `json:"playlist"`
+	Show     string `json:"show"`
 }
 
 type PlaylistConf struct {
@@ -45,6 +58,26 @@ type CoverConfig struct {
 	FileName string             `json:"fileName"`
 }
 
+// LyricsConfig picks how lyrics are saved. Modes is any combination of
+// unsynced, synced and lrc; an empty list saves no lyrics.
+type LyricsConfig struct {
+	Modes []metadata.LyricsMode `json:"modes"`
+}
+
+// EmbedModes returns Modes without the .lrc sidecar.
+func (lyrics LyricsConfig) EmbedModes() []metadata.LyricsMode {
+	if lyrics.Modes == nil {
+		return nil
+	}
+	modes := []metadata.LyricsMode{}
+	for _, mode := range lyrics.Modes {
+		if mode != metadata.LyricsFile {
+			modes = append(modes, mode)
+		}
+	}
+	return modes
+}
+
 type Cookies struct {
 	ARL string `json:"arl"`
 }
@@ -57,6 +90,7 @@ func defaultConfig() Config {
 			Album:    "Music/{ALB_TITLE}/{SNG_TITLE}",
 			Artist:   "Music/{ALB_TITLE}/{SNG_TITLE}",
 			Playlist: "Playlist/{TITLE}/{SNG_TITLE}",
+			Show:     "Podcasts/{SHOW_NAME}/{EPISODE_TITLE}",
 		},
 		Playlist: PlaylistConf{
 			ResolveFullPath: false,
@@ -73,6 +107,23 @@ func defaultConfig() Config {
 			Mode:     metadata.CoverModeEmbed,
 			FileName: metadata.DefaultCoverFileName,
 		},
+		Lyrics: LyricsConfig{
+			Modes: metadata.DefaultLyricsModes(),
+		},
+		Tags: metadata.TagProfile{
+			Disabled:           []string{},
+			TrackNumberPadding: 2,
+			VorbisKeys:         map[string]string{},
+			ID3Version:         4,
+		},
+		DiskReserve: defaultDiskReserve,
+		Retry:       defaultRetryConfig(),
+		TempMaxAge:  "24h",
+		OnExisting:  ExistingSkip,
+		Discography: converter.DiscographyFilter{
+			Types:   converter.ReleaseTypes(),
+			Edition: converter.EditionBoth,
+		},
 	}
 }
 
@@ -134,6 +185,9 @@ func mergeConfig(cfg *Config, user Config) {
 	if user.SaveLayout.Playlist != "" {
 		cfg.SaveLayout.Playlist = user.SaveLayout.Playlist
 	}
+	if user.SaveLayout.Show != "" {
+		cfg.SaveLayout.Show = user.SaveLayout.Show
+	}
 	cfg.Playlist.ResolveFullPath = user.Playlist.ResolveFullPath
 	if user.TrackNumber {
 		cfg.TrackNumber = user.TrackNumber
@@ -159,9 +213,51 @@ func mergeConfig(cfg *Config, user Config) {
 	if user.Cover.FileName != "" {
 		cfg.Cover.FileName = metadata.NormalizeCoverFileName(user.Cover.FileName)
 	}
+	if user.Lyrics.Modes != nil {
+		if modes, err := metadata.NormalizeLyricsModes(user.Lyrics.Modes); err == nil {
+			cfg.Lyrics.Modes = modes
+		}
+	}
 	if user.Cookies.ARL != "" {
 		cfg.Cookies.ARL = user.Cookies.ARL
 	}
+	if user.Archive != "" {
+		cfg.Archive = strings.TrimSpace(user.Archive)
+	}
+	if _, err := ParseBandwidth(user.MaxBandwidth); err == nil {
+		cfg.MaxBandwidth = strings.TrimSpace(user.MaxBandwidth)
+	}
+	if _, err := ParseDiskReserve(user.DiskReserve); err == nil && user.DiskReserve != "" {
+		cfg.DiskReserve = strings.TrimSpace(user.DiskReserve)
+	}
+	cfg.Retry = NormalizeRetryConfig(user.Retry, cfg.Retry)
+	if user.WorkDir != "" {
+		cfg.WorkDir = strings.TrimSpace(user.WorkDir)
+	}
+	if _, err := ParseTempMaxAge(user.TempMaxAge); err == nil {
+		cfg.TempMaxAge = strings.TrimSpace(user.TempMaxAge)
+	}
+	if user.OnExisting != "" {
+		cfg.OnExisting = NormalizeExistingPolicy(user.OnExisting)
+	}
+	if filter, err := user.Discography.Normalize(); err == nil {
+		cfg.Discography = filter
+	}
+	if profile, err := user.Tags.Normalize(); err == nil {
+		if profile.TrackNumberPadding == 0 {
+			profile.TrackNumberPadding = cfg.Tags.TrackNumberPadding
+		}
+		if profile.Disabled == nil {
+			profile.Disabled = cfg.Tags.Disabled
+		}
+		if profile.VorbisKeys == nil {
+			profile.VorbisKeys = cfg.Tags.VorbisKeys
+		}
+		if profile.ID3Version == 0 {
+			profile.ID3Version = cfg.Tags.ID3Version
+		}
+		cfg.Tags = profile
+	}
 }
 
 func (cfg *Config) Set(key string, value any) error {
@@ -176,12 +272,91 @@ func (cfg *Config) Set(key string, value any) error {
 		cfg.Cover.Mode = metadata.NormalizeCoverMode(metadata.CoverMode(fmt.Sprintf("%v", value)))
 	case "cover.fileName":
 		cfg.Cover.FileName = metadata.NormalizeCoverFileName(fmt.Sprintf("%v", value))
+	case "lyrics.modes":
+		modes, err := metadata.ParseLyricsModes(fmt.Sprintf("%v", value))
+		if err != nil {
+			return err
+		}
+		cfg.Lyrics.Modes = modes
+	case "archive":
+		cfg.Archive = strings.TrimSpace(fmt.Sprintf("%v", value))
+	case "maxBandwidth":
+		rate := strings.TrimSpace(fmt.Sprintf("%v", value))
+		if _, err := ParseBandwidth(rate); err != nil {
+			return err
+		}
+		cfg.MaxBandwidth = rate
+	case "diskReserve":
+		reserve := strings.TrimSpace(fmt.Sprintf("%v", value))
+		if _, err := ParseDiskReserve(reserve); err != nil {
+			return err
+		}
+		cfg.DiskReserve = reserve
+	case "workDir":
+		cfg.WorkDir = strings.TrimSpace(fmt.Sprintf("%v", value))
+	case "tempMaxAge":
+		age := strings.TrimSpace(fmt.Sprintf("%v", value))
+		if _, err := ParseTempMaxAge(age); err != nil {
+			return err
+		}
+		cfg.TempMaxAge = age
+	case "onExisting":
+		policy, err := ParseExistingPolicy(fmt.Sprintf("%v", value))
+		if err != nil {
+			return err
+		}
+		cfg.OnExisting = policy
 	default:
 		return fmt.Errorf("unsupported config key: %s", key)
 	}
 	return cfg.Save()
 }
 
+// MaxBandwidthBytes returns maxBandwidth in bytes per second, or 0 for no limit.
+func (cfg Config) MaxBandwidthBytes() int64 {
+	rate, err := ParseBandwidth(cfg.MaxBandwidth)
+	if err != nil {
+		return 0
+	}
+	return rate
+}
+
+// DiskReserveBytes returns diskReserve in bytes.
+func (cfg Config) DiskReserveBytes() int64 {
+	reserve, err := ParseDiskReserve(cfg.DiskReserve)
+	if err != nil {
+		return 0
+	}
+	return reserve
+}
+
+// TempDir returns the directory for resumable temp files.
+func (cfg Config) TempDir() string {
+	if cfg.WorkDir != "" {
+		return cfg.WorkDir
+	}
+	return DefaultWorkDir()
+}
+
+// TempMaxAgeDuration returns how long untouched temp files are kept.
+func (cfg Config) TempMaxAgeDuration() time.Duration {
+	age, err := ParseTempMaxAge(cfg.TempMaxAge)
+	if err != nil {
+		return defaultTempMaxAge
+	}
+	return age
+}
+
+// ParseTempMaxAge parses a tempMaxAge value such as "24h" or "90m".
+func ParseTempMaxAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	age, err := time.ParseDuration(value)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid tempMaxAge %q, use a duration such as 24h", value)
+	}
+	return age, nil
+}
+
 func (cfg Config) Save() error {
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
@@ -190,6 +365,11 @@ func (cfg Config) Save() error {
 	return os.WriteFile(cfg.path, append(data, '\n'), 0644)
 }
 
+// Layouts returns every configured save layout.
+func (cfg Config) Layouts() []string {
+	return []string{cfg.SaveLayout.Track, cfg.SaveLayout.Album, cfg.SaveLayout.Artist, cfg.SaveLayout.Playlist, cfg.SaveLayout.Show}
+}
+
 func (cfg Config) Layout(linkType string) string {
 	switch strings.ToLower(linkType) {
 	case "album":
@@ -198,6 +378,8 @@ func (cfg Config) Layout(linkType string) string {
 		return cfg.SaveLayout.Artist
 	case "playlist":
 		return cfg.SaveLayout.Playlist
+	case "show":
+		return cfg.SaveLayout.Show
 	default:
 		return cfg.SaveLayout.Track
 	}
@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/d-fi/GoFi/metadata"
 )
 
 func TestLoadConfigDefaults(t *testing.T) {
@@ -181,3 +185,194 @@ func TestResolveARLFallsBackToConfig(t *testing.T) {
 		t.Fatalf("resolveARL = %q, want config-arl", got)
 	}
 }
+
+func TestConfigSetArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	cfg := LoadConfig(path)
+
+	if err := cfg.Set("archive", " d-fi.archive.jsonl "); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadConfig(path)
+	if loaded.Archive != "d-fi.archive.jsonl" {
+		t.Fatalf("Archive = %q, want d-fi.archive.jsonl", loaded.Archive)
+	}
+}
+
+func TestConfigSetMaxBandwidth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	cfg := LoadConfig(path)
+
+	if err := cfg.Set("maxBandwidth", "fast"); err == nil {
+		t.Fatal("expected invalid bandwidth to be rejected")
+	}
+	if err := cfg.Set("maxBandwidth", "4MiB/s"); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadConfig(path)
+	if loaded.MaxBandwidth != "4MiB/s" {
+		t.Fatalf("MaxBandwidth = %q, want 4MiB/s", loaded.MaxBandwidth)
+	}
+	if got := loaded.MaxBandwidthBytes(); got != 4*1024*1024 {
+		t.Fatalf("MaxBandwidthBytes() = %d", got)
+	}
+}
+
+func TestConfigSetDiskReserve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	cfg := LoadConfig(path)
+	if got := cfg.DiskReserveBytes(); got != 1<<30 {
+		t.Fatalf("default DiskReserveBytes() = %d", got)
+	}
+
+	if err := cfg.Set("diskReserve", "plenty"); err == nil {
+		t.Fatal("expected an invalid reserve to be rejected")
+	}
+	if err := cfg.Set("diskReserve", "0"); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadConfig(path)
+	if loaded.DiskReserve != "0" || loaded.DiskReserveBytes() != 0 {
+		t.Fatalf("DiskReserve = %q (%d bytes), want no reserve", loaded.DiskReserve, loaded.DiskReserveBytes())
+	}
+}
+
+func TestConfigLyricsModes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	cfg := LoadConfig(path)
+	if len(cfg.Lyrics.Modes) != 1 || cfg.Lyrics.Modes[0] != metadata.LyricsUnsynced {
+		t.Fatalf("default Lyrics.Modes = %v", cfg.Lyrics.Modes)
+	}
+
+	if err := cfg.Set("lyrics.modes", "unsynced,karaoke"); err == nil {
+		t.Fatal("expected an unknown lyrics mode to be rejected")
+	}
+	if err := cfg.Set("lyrics.modes", "synced, lrc"); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadConfig(path)
+	if len(loaded.Lyrics.Modes) != 2 || loaded.Lyrics.Modes[1] != metadata.LyricsFile {
+		t.Fatalf("Lyrics.Modes = %v, want synced and lrc", loaded.Lyrics.Modes)
+	}
+	if embed := loaded.Lyrics.EmbedModes(); len(embed) != 1 || embed[0] != metadata.LyricsSynced {
+		t.Fatalf("EmbedModes() = %v", embed)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"lyrics": {"modes": []}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := LoadConfig(path); loaded.Lyrics.Modes == nil || len(loaded.Lyrics.Modes) != 0 {
+		t.Fatalf("empty modes = %#v, want lyrics off", loaded.Lyrics.Modes)
+	}
+}
+
+func TestConfigTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	if cfg := LoadConfig(path); cfg.Tags.TrackNumberPadding != 2 || len(cfg.Tags.Disabled) != 0 {
+		t.Fatalf("default Tags = %+v", cfg.Tags)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"tags": {"disabled": ["totaltracks"], "multiValueArtists": true}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := LoadConfig(path)
+	if len(cfg.Tags.Disabled) != 1 || cfg.Tags.Disabled[0] != "TOTALTRACKS" || !cfg.Tags.MultiValueArtists || cfg.Tags.TrackNumberPadding != 2 || cfg.Tags.ID3Version != 4 {
+		t.Fatalf("Tags = %+v", cfg.Tags)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"tags": {"id3Version": 3, "id3v1": true}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := LoadConfig(path); cfg.Tags.ID3Version != 3 || !cfg.Tags.ID3v1 {
+		t.Fatalf("Tags = %+v, want ID3v2.3 with an ID3v1 tag", cfg.Tags)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"tags": {"disabled": ["COMMENT"], "trackNumberPadding": 3}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := LoadConfig(path); cfg.Tags.TrackNumberPadding != 2 || len(cfg.Tags.Disabled) != 0 {
+		t.Fatalf("invalid tags should keep the defaults, got %+v", cfg.Tags)
+	}
+}
+
+func TestLoadConfigMergesRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	if err := os.WriteFile(path, []byte(`{"retry": {"maxAttempts": 5, "retryOn": ["network", "decrypt"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := LoadConfig(path)
+	if cfg.Retry.MaxAttempts != 5 || cfg.Retry.InitialDelay != "1s" || cfg.Retry.MaxDelay != "30s" {
+		t.Fatalf("Retry = %+v", cfg.Retry)
+	}
+	if strings.Join(cfg.Retry.RetryOn, ",") != "network,decrypt" {
+		t.Fatalf("RetryOn = %v", cfg.Retry.RetryOn)
+	}
+}
+
+func TestConfigWorkDirAndTempMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	cfg := LoadConfig(path)
+	if cfg.TempDir() != DefaultWorkDir() {
+		t.Fatalf("TempDir() = %q, want %q", cfg.TempDir(), DefaultWorkDir())
+	}
+	if cfg.TempMaxAgeDuration() != 24*time.Hour {
+		t.Fatalf("TempMaxAgeDuration() = %v, want 24h", cfg.TempMaxAgeDuration())
+	}
+
+	if err := cfg.Set("tempMaxAge", "soon"); err == nil {
+		t.Fatal("expected invalid tempMaxAge to be rejected")
+	}
+	if err := cfg.Set("tempMaxAge", "90m"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Set("workDir", " /var/tmp/d-fi "); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadConfig(path)
+	if loaded.TempDir() != "/var/tmp/d-fi" {
+		t.Fatalf("TempDir() = %q, want /var/tmp/d-fi", loaded.TempDir())
+	}
+	if loaded.TempMaxAgeDuration() != 90*time.Minute {
+		t.Fatalf("TempMaxAgeDuration() = %v, want 90m", loaded.TempMaxAgeDuration())
+	}
+}
+
+func TestConfigSetOnExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	cfg := LoadConfig(path)
+	if cfg.OnExisting != ExistingSkip {
+		t.Fatalf("OnExisting = %q, want skip", cfg.OnExisting)
+	}
+	if err := cfg.Set("onExisting", "replace"); err == nil {
+		t.Fatal("expected invalid onExisting to be rejected")
+	}
+	if err := cfg.Set("onExisting", "Retag"); err != nil {
+		t.Fatal(err)
+	}
+	if loaded := LoadConfig(path); loaded.OnExisting != ExistingRetag {
+		t.Fatalf("OnExisting = %q, want retag", loaded.OnExisting)
+	}
+}
+
+func TestLoadConfigDiscography(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d-fi.config.json")
+	if cfg := LoadConfig(path); len(cfg.Discography.Types) != 5 || cfg.Discography.Edition != "both" {
+		t.Fatalf("default Discography = %+v", cfg.Discography)
+	}
+
+	data := `{"discography": {"types": ["Album", "EP"], "fromYear": 2000, "featured": true, "edition": "clean"}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := LoadConfig(path)
+	if strings.Join(cfg.Discography.Types, ",") != "album,ep" || cfg.Discography.FromYear != 2000 || !cfg.Discography.Featured || cfg.Discography.Edition != "clean" {
+		t.Fatalf("Discography = %+v", cfg.Discography)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"discography": {"fromYear": 2010, "toYear": 2000}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := LoadConfig(path); cfg.Discography.FromYear != 0 || cfg.Discography.ToYear != 0 {
+		t.Fatalf("invalid year range was kept: %+v", cfg.Discography)
+	}
+}
@@ -0,0 +1,89 @@
+package dfi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultDiskReserve is the free space a download leaves on the target
+// volume unless diskReserve says otherwise.
+const defaultDiskReserve = "1GiB"
+
+// freeSpace reports the bytes available to this user on the volume holding
+// dir.
+var freeSpace = diskFreeSpace
+
+// SpaceCheck compares the estimated size of a download with the free space of
+// the volume it saves to.
+type SpaceCheck struct {
+	Dir      string `json:"dir"`
+	Estimate int64  `json:"estimate"`
+	Free     int64  `json:"free"`
+	Reserve  int64  `json:"reserve"`
+	Enough   bool   `json:"enough"`
+}
+
+// ParseDiskReserve converts a size such as "1GiB", "500MB" or "1048576" to
+// bytes. An empty value or "0" reserves nothing.
+func ParseDiskReserve(value string) (int64, error) {
+	text := strings.ToLower(strings.TrimSpace(value))
+	if text == "" || text == "0" {
+		return 0, nil
+	}
+	size, err := ParseBandwidth(text)
+	if err != nil || strings.HasSuffix(text, "/s") || text == "unlimited" {
+		return 0, fmt.Errorf("invalid diskReserve %q, use a size such as 1GiB", value)
+	}
+	return size, nil
+}
+
+// CheckDiskSpace compares estimate plus reserve with the free space of the
+// volume dir is on. dir does not need to exist yet.
+func CheckDiskSpace(dir string, estimate, reserve int64) (SpaceCheck, error) {
+	check := SpaceCheck{Dir: existingDir(dir), Estimate: estimate, Reserve: reserve}
+	free, err := freeSpace(check.Dir)
+	if err != nil {
+		return check, err
+	}
+	check.Free = free
+	check.Enough = estimate <= free-reserve
+	return check, nil
+}
+
+// Err returns nil when the download fits, or an error saying by how much it
+// does not.
+func (check SpaceCheck) Err() error {
+	if check.Enough {
+		return nil
+	}
+	return fmt.Errorf("not enough disk space in %s: the download needs about %s, %s is free and %s is reserved",
+		check.Dir, formatSize(check.Estimate), formatSize(check.Free), formatSize(check.Reserve))
+}
+
+// PlanDir returns the directory the first download of plan saves to, or ""
+// when the plan downloads nothing.
+func PlanDir(plan DownloadPlan) string {
+	for _, item := range plan.Tracks {
+		if item.Status == PlanDownload {
+			return filepath.Dir(item.Path)
+		}
+	}
+	return ""
+}
+
+// existingDir returns dir or its closest ancestor that exists.
+func existingDir(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if stat, err := os.Stat(dir); err == nil && stat.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "."
+		}
+		dir = parent
+	}
+}
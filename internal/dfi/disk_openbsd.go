@@ -0,0 +1,13 @@
+//go:build openbsd
+
+package dfi
+
+import "golang.org/x/sys/unix"
+
+func diskFreeSpace(dir string) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.F_bavail) * int64(stat.F_bsize), nil
+}
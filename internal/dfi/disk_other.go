@@ -0,0 +1,13 @@
+//go:build !darwin && !dragonfly && !freebsd && !illumos && !linux && !netbsd && !openbsd && !solaris && !windows
+
+package dfi
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+func diskFreeSpace(dir string) (int64, error) {
+	return 0, fmt.Errorf("free disk space on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
@@ -0,0 +1,13 @@
+//go:build darwin || dragonfly || freebsd || linux
+
+package dfi
+
+import "golang.org/x/sys/unix"
+
+func diskFreeSpace(dir string) (int64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
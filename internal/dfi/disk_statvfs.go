@@ -0,0 +1,13 @@
+//go:build illumos || netbsd || solaris
+
+package dfi
+
+import "golang.org/x/sys/unix"
+
+func diskFreeSpace(dir string) (int64, error) {
+	var stat unix.Statvfs_t
+	if err := unix.Statvfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return int64(stat.Bavail) * int64(stat.Frsize), nil
+}
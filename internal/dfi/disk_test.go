@@ -0,0 +1,119 @@
+package dfi
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+)
+
+func stubFreeSpace(t *testing.T, free int64) *string {
+	t.Helper()
+	var checked string
+	original := freeSpace
+	freeSpace = func(dir string) (int64, error) {
+		checked = dir
+		return free, nil
+	}
+	t.Cleanup(func() { freeSpace = original })
+	return &checked
+}
+
+func TestParseDiskReserve(t *testing.T) {
+	tests := map[string]int64{
+		"":       0,
+		"0":      0,
+		"1GiB":   1 << 30,
+		"500 MB": 500_000_000,
+		"4096":   4096,
+	}
+	for value, want := range tests {
+		got, err := ParseDiskReserve(value)
+		if err != nil || got != want {
+			t.Fatalf("ParseDiskReserve(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"lots", "1GiB/s", "unlimited", "-1"} {
+		if _, err := ParseDiskReserve(value); err == nil {
+			t.Fatalf("ParseDiskReserve(%q) accepted an invalid size", value)
+		}
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	checked := stubFreeSpace(t, 1000)
+
+	check, err := CheckDiskSpace(filepath.Join(dir, "Music", "Album"), 700, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *checked != dir || !check.Enough || check.Err() != nil {
+		t.Fatalf("CheckDiskSpace = %+v, checked %q", check, *checked)
+	}
+
+	check, err = CheckDiskSpace(dir, 701, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Enough || check.Err() == nil || !strings.Contains(check.Err().Error(), "not enough disk space") {
+		t.Fatalf("CheckDiskSpace over the reserve = %+v", check)
+	}
+}
+
+func TestPlanDir(t *testing.T) {
+	plan := DownloadPlan{Tracks: []PlannedTrack{
+		{Status: PlanExists, Path: filepath.Join("Music", "Old", "One.mp3")},
+		{Status: PlanDownload, Path: filepath.Join("Music", "New", "Two.mp3")},
+	}}
+	if got := PlanDir(plan); got != filepath.Join("Music", "New") {
+		t.Fatalf("PlanDir = %q", got)
+	}
+	if got := PlanDir(DownloadPlan{}); got != "" {
+		t.Fatalf("PlanDir of an empty plan = %q", got)
+	}
+}
+
+func TestCheckDiskSpaceBeforeDownload(t *testing.T) {
+	stubFreeSpace(t, 10_000_000)
+	cfg := defaultConfig()
+	cfg.DiskReserve = "2MB"
+	groups := []ResolvedInput{{
+		LinkType: "album",
+		Tracks: []types.TrackType{
+			planTrack("1", "One", 6_000_000, 3_000_000, 1_000_000),
+			planTrack("2", "Two", 0, 3_000_000, 1_000_000),
+		},
+	}}
+	opts := options{out: io.Discard, quality: "flac", output: filepath.Join(t.TempDir(), "{SNG_TITLE}"), headless: true}
+	reader := bufio.NewReader(strings.NewReader(""))
+
+	// FLAC falls back to MP3 320 for the second track: 9MB does not fit in
+	// 10MB free minus the 2MB reserve.
+	opts.quality = "320"
+	if err := checkDiskSpace(cfg, opts, nil, groups, reader); err != nil {
+		t.Fatalf("6MB estimate refused: %v", err)
+	}
+	opts.quality = "flac"
+	err := checkDiskSpace(cfg, opts, nil, groups, reader)
+	if err == nil || !strings.Contains(err.Error(), "not enough disk space") {
+		t.Fatalf("checkDiskSpace = %v", err)
+	}
+
+	opts.skipSpaceCheck = true
+	if err := checkDiskSpace(cfg, opts, nil, groups, reader); err != nil {
+		t.Fatalf("--skip-space-check refused: %v", err)
+	}
+
+	opts.skipSpaceCheck = false
+	opts.headless = false
+	if err := checkDiskSpace(cfg, opts, nil, groups, bufio.NewReader(strings.NewReader("y\n"))); err != nil {
+		t.Fatalf("confirmed download refused: %v", err)
+	}
+	if err := checkDiskSpace(cfg, opts, nil, groups, bufio.NewReader(strings.NewReader("\n"))); err == nil {
+		t.Fatal("expected an unconfirmed download to be canceled")
+	}
+}
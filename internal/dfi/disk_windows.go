@@ -0,0 +1,17 @@
+//go:build windows
+
+package dfi
+
+import "golang.org/x/sys/windows"
+
+func diskFreeSpace(dir string) (int64, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return int64(available), nil
+}
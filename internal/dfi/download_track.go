@@ -15,25 +15,47 @@ import (
 	"github.com/d-fi/GoFi/download"
 	"github.com/d-fi/GoFi/metadata"
 	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
+	"github.com/d-fi/GoFi/verify"
 )
 
 type DownloadTrackOptions struct {
-	Track             types.TrackType
-	Quality           any
-	Info              any
-	CoverSizes        CoverSizes
-	Path              string
-	TotalTracks       int
-	TrackNumber       bool
-	FallbackTrack     bool
-	FallbackQuality   bool
-	CoverMode         metadata.CoverMode
-	CoverFileName     string
-	CoverFilePolicy   map[string]bool
+	Track           types.TrackType
+	Quality         any
+	Info            any
+	CoverSizes      CoverSizes
+	Path            string
+	TotalTracks     int
+	TrackNumber     bool
+	FallbackTrack   bool
+	FallbackQuality bool
+	CoverMode       metadata.CoverMode
+	CoverFileName   string
+	CoverFilePolicy map[string]bool
+	// Lyrics picks the lyrics modes. Nil embeds the plain lyrics only.
+	Lyrics            []metadata.LyricsMode
+	TagProfile        metadata.TagProfile
 	IsFallback        bool
 	IsQualityFallback bool
 	Message           string
-	Hooks             DownloadTrackHooks
+	Archive           *Archive
+	// SavePath, when set, is used instead of the save layout. The quality's
+	// extension is appended to it.
+	SavePath string
+	// WorkDir holds the resumable temp files. Empty means the current directory.
+	WorkDir string
+	// OnExisting decides what happens when the save path exists. Empty means skip.
+	OnExisting ExistingPolicy
+	Retry      RetryPolicy
+	Hooks      DownloadTrackHooks
+
+	// tokenRefreshed is set once get_url rejected the track token and it was fetched again.
+	tokenRefreshed bool
+	// requested is the track and quality first asked for, archived alongside
+	// a track fallback saved at that quality.
+	requested archiveKey
+	// redownloaded is set once a track that failed verification was fetched again.
+	redownloaded bool
 }
 
 type DownloadTrackHooks struct {
@@ -44,12 +66,46 @@ type DownloadTrackHooks struct {
 	Done     func(track types.TrackType, savedPath string, isFallback, isQualityFallback bool, label string)
 }
 
-func downloadTrack(ctx context.Context, options DownloadTrackOptions) (string, error) {
-	options.Hooks = terminalDownloadHooks(options.Message)
+// downloadTrack runs DownloadTrack with CLI hooks: terminal output, or events
+// for the index-th track when events is set.
+func downloadTrack(ctx context.Context, events *eventWriter, index int, options DownloadTrackOptions) (string, error) {
+	if events != nil {
+		options.Hooks = events.hooks(index, options)
+	} else {
+		options.Hooks = terminalDownloadHooks(options.Message)
+	}
 	return DownloadTrack(ctx, options)
 }
 
+// DownloadTrack downloads, verifies, tags and saves one track. Failures whose
+// class is listed in options.Retry are retried with backoff before falling back
+// to a lower quality. Returned errors can be classified with download.Classify.
 func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, error) {
+	for attempt := 1; ; attempt++ {
+		savedPath, err := downloadTrackOnce(ctx, &options)
+		if err == nil || ctx.Err() != nil {
+			return savedPath, err
+		}
+		class := download.Classify(err)
+		if options.Retry.allows(class, attempt) {
+			if options.Hooks.Status != nil {
+				options.Hooks.Status(fmt.Sprintf("Retrying %s after %s error (attempt %d/%d)", options.Track.SNG_TITLE, class, attempt+1, options.Retry.MaxAttempts))
+			}
+			if err := sleepContext(ctx, options.Retry.backoff(attempt)); err != nil {
+				return "", err
+			}
+			continue
+		}
+		quality, _, _ := ParseQuality(options.Quality)
+		if options.Retry.retries(class) && options.tryQualityFallback(quality) {
+			attempt = 0
+			continue
+		}
+		return "", err
+	}
+}
+
+func downloadTrackOnce(ctx context.Context, options *DownloadTrackOptions) (string, error) {
 	track := options.Track
 	if options.Hooks.Start != nil {
 		options.Hooks.Start(track)
@@ -58,128 +114,257 @@ func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, e
 		return "", err
 	}
 
-	quality, ext, label := ParseQuality(options.Quality)
-	coverSize := CoverSizeForQuality(options.CoverSizes, label)
-	if os.Getenv("SIMULATE") != "" {
-		coverSize = 56
+	quality, _, _ := ParseQuality(options.Quality)
+	ext, label := trackFormat(track, quality)
+	if options.requested.sngID == "" {
+		options.requested = archiveKey{archiveID(track), quality}
+	}
+	if entry, ok := options.Archive.Lookup(archiveID(track), quality); ok && !options.OnExisting.replacesFiles() {
+		if options.Hooks.Skip != nil {
+			options.Hooks.Skip(track, entry.Path, "archived")
+		}
+		return entry.Path, nil
 	}
+	coverSize := CoverSizeForQuality(options.CoverSizes, label)
 
-	savePath := SaveLayout(track, options.Info, options.Path, options.TrackNumber, options.TotalTracks) + ext
+	base := options.SavePath
+	if base == "" {
+		base = SaveLayout(track, options.Info, options.Path, options.TrackNumber, options.TotalTracks)
+	}
+	savePath := options.OnExisting.existingPath(track, base, ext, quality)
 	if _, err := os.Stat(savePath); err == nil {
-		if options.shouldSaveCoverFile(savePath) && os.Getenv("SIMULATE") == "" && metadata.ShouldSaveCoverFile(options.CoverMode) {
-			if _, err := metadata.SaveAlbumCoverFile(coverFileDir(savePath, options.Path), options.CoverFileName, track.ALB_PICTURE, coverSize); err != nil {
+		if options.OnExisting.keepExisting(savePath, quality) {
+			if options.shouldSaveCoverFile(savePath) && metadata.ShouldSaveCoverFile(options.CoverMode) {
+				if _, err := metadata.SaveTrackCoverFile(coverFileDir(savePath, options.Path), options.CoverFileName, track, coverSize); err != nil {
+					return "", err
+				}
+			}
+			if err := options.Archive.recordSaved(savePath, archiveKey{archiveID(track), quality}); err != nil {
 				return "", err
 			}
+			if options.Hooks.Skip != nil {
+				options.Hooks.Skip(track, savePath, "exists")
+			}
+			return savePath, nil
 		}
-		if options.Hooks.Skip != nil {
-			options.Hooks.Skip(track, savePath, "exists")
+		switch NormalizeExistingPolicy(options.OnExisting) {
+		case ExistingRetag:
+			return retagExisting(ctx, options, savePath, quality)
+		case ExistingRename:
+			savePath = keepBothPath(savePath)
 		}
-		return savePath, nil
+		// Anything else downloads again and replaces the file when saving.
 	}
 
-	trackData, err := download.GetTrackDownloadUrl(ctx, track, quality)
-	if err != nil {
-		var geoBlocked *download.GeoBlocked
-		if errors.As(err, &geoBlocked) && track.FALLBACK != nil {
-			// Try the alternate track below when geo-blocked.
-		} else if options.tryQualityFallback(quality) {
-			return DownloadTrack(ctx, options)
-		} else {
+	workDir := options.WorkDir
+	if workDir == "" {
+		workDir = "."
+	}
+	// A partial temp whose recorded media URL is still valid resumes without
+	// asking Deezer for a new URL.
+	var trackData *download.TrackDownloadUrl
+	resumed := false
+	resumeFile := filepath.Join(workDir, DownloadTempName(quality, track))
+	if info, ok := loadTempInfo(resumeFile); ok {
+		trackData, resumed = info.trackData(resumeFile, time.Now())
+	}
+	if !resumed && track.EPISODE != nil {
+		var err error
+		trackData, err = episodeDownloadUrl(ctx, options)
+		if err != nil {
 			return "", err
 		}
-	}
+		track = options.Track
+		if trackData == nil {
+			if options.Hooks.Skip != nil {
+				options.Hooks.Skip(track, "", "not available")
+			}
+			return "", nil
+		}
+	} else if !resumed {
+		var err error
+		if trackTokenExpired(track, time.Now()) {
+			if options.Hooks.Status != nil {
+				options.Hooks.Status("Refreshing track token for " + track.SNG_TITLE)
+			}
+			// A failed refresh still leaves the legacy CDN URL to try below.
+			if refreshed, err := refreshTrackToken(track); err == nil {
+				track = refreshed
+				options.Track = refreshed
+			}
+		}
 
-	if trackData == nil {
-		if options.FallbackTrack && track.FALLBACK != nil && !options.IsFallback && track.ART_ID == track.FALLBACK.ART_ID {
-			fallback := track
-			fallback.SongType = *track.FALLBACK
-			fallback.FALLBACK = nil
-			fallback.TRACK_POSITION = track.TRACK_POSITION
-			options.Track = fallback
-			options.FallbackTrack = false
-			options.IsFallback = true
-			return DownloadTrack(ctx, options)
+		trackData, err = download.GetTrackDownloadUrl(ctx, track, quality)
+		if err != nil && download.Classify(err) == download.ErrorTokenExpired && !options.tokenRefreshed {
+			options.tokenRefreshed = true
+			refreshed, refreshErr := refreshTrackToken(track)
+			if refreshErr != nil {
+				return "", download.NewError(download.ErrorTokenExpired, refreshErr)
+			}
+			options.Track = refreshed
+			return downloadTrackOnce(ctx, options)
 		}
-		if options.tryQualityFallback(quality) {
-			return DownloadTrack(ctx, options)
+		if err != nil {
+			var geoBlocked *download.GeoBlocked
+			if errors.As(err, &geoBlocked) && track.FALLBACK != nil {
+				// Try the alternate track below when geo-blocked.
+			} else if !options.Retry.retries(download.Classify(err)) && options.tryQualityFallback(quality) {
+				return downloadTrackOnce(ctx, options)
+			} else {
+				return "", err
+			}
 		}
-		if options.Hooks.Skip != nil {
-			options.Hooks.Skip(track, "", "not available")
+
+		if trackData == nil {
+			if options.FallbackTrack && track.FALLBACK != nil && !options.IsFallback && track.ART_ID == track.FALLBACK.ART_ID {
+				fallback := track
+				fallback.SongType = *track.FALLBACK
+				fallback.FALLBACK = nil
+				fallback.TRACK_POSITION = track.TRACK_POSITION
+				options.Track = fallback
+				options.FallbackTrack = false
+				options.IsFallback = true
+				return downloadTrackOnce(ctx, options)
+			}
+			if options.tryQualityFallback(quality) {
+				return downloadTrackOnce(ctx, options)
+			}
+			if options.Hooks.Skip != nil {
+				options.Hooks.Skip(track, "", "not available")
+			}
+			return "", nil
 		}
-		return "", nil
 	}
 
-	tmpFile := fmt.Sprintf("d-fi_%d_%s_%s", quality, track.SNG_ID, track.MD5_ORIGIN)
-	if os.Getenv("SIMULATE") != "" {
-		tmpFile = fmt.Sprintf("d-fi_%d_%s_simulate", quality, track.SNG_ID)
+	tmpFile := filepath.Join(workDir, DownloadTempName(quality, track))
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return "", err
+	}
+	defer claimDownloadTemp(tmpFile)()
+	if !resumed {
+		if info, ok := loadTempInfo(tmpFile); ok && info.Size != trackData.FileSize {
+			// The partial temp belongs to another version of the file.
+			removeDownloadTemp(tmpFile)
+		}
+		// Without the sidecar the temp still resumes, just with a new URL.
+		_ = tempInfo{
+			SngID:      track.SNG_ID,
+			Quality:    quality,
+			URL:        trackData.TrackUrl,
+			URLExpires: urlExpiry(trackData.TrackUrl),
+			Size:       trackData.FileSize,
+			Encrypted:  trackData.IsEncrypted,
+		}.save(tmpFile)
 	}
 	if err := downloadToTemp(ctx, trackData, tmpFile, func(transferred, total int64) {
 		if options.Hooks.Progress != nil {
 			options.Hooks.Progress(track, transferred, total)
 		}
 	}); err != nil {
-		if options.tryQualityFallback(quality) {
-			return DownloadTrack(ctx, options)
+		if resumed {
+			// The recorded URL may have stopped working; ask for a new one next time.
+			_ = os.Remove(tempInfoPath(tmpFile))
+		}
+		if !options.Retry.retries(download.Classify(err)) && options.tryQualityFallback(quality) {
+			return downloadTrackOnce(ctx, options)
 		}
 		return "", err
 	}
-	defer os.Remove(tmpFile)
+	defer removeDownloadTemp(tmpFile)
 	if err := ctx.Err(); err != nil {
 		return "", err
 	}
 
-	raw, err := os.ReadFile(tmpFile)
-	if err != nil {
+	if trackData.IsEncrypted && options.Hooks.Status != nil {
+		options.Hooks.Status("Decrypting " + track.SNG_TITLE + " by " + track.ART_NAME)
+	}
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
 		return "", err
 	}
+	decrypted, err := decryptDownloadTemp(tmpFile, savePath, trackData.IsEncrypted, track.SNG_ID)
+	if err != nil {
+		return "", download.NewError(download.ErrorDecrypt, err)
+	}
+	// Once committed the partial file is gone and these do nothing.
+	defer os.Remove(decrypted.Name())
+	defer decrypted.Close()
 	if err := ctx.Err(); err != nil {
 		return "", err
 	}
-	if trackData.IsEncrypted {
+
+	if track.EPISODE != nil {
+		// Feeds outside Deezer may serve AAC or Ogg instead of MP3.
+		head := make([]byte, 8)
+		n, _ := decrypted.ReadAt(head, 0)
+		if audioExt := episodeAudioExt(head[:n]); audioExt != filepath.Ext(savePath) {
+			savePath = strings.TrimSuffix(savePath, filepath.Ext(savePath)) + audioExt
+		}
+	}
+	if verifiesAudio(track) {
 		if options.Hooks.Status != nil {
-			options.Hooks.Status("Decrypting " + track.SNG_TITLE + " by " + track.ART_NAME)
+			options.Hooks.Status("Verifying " + track.SNG_TITLE + " by " + track.ART_NAME)
+		}
+		if err := verify.File(decrypted.Name()); err != nil {
+			if !errors.Is(err, verify.ErrCorrupt) {
+				return "", err
+			}
+			decrypted.Close()
+			os.Remove(decrypted.Name())
+			removeDownloadTemp(tmpFile)
+			if !options.redownloaded {
+				options.redownloaded = true
+				return downloadTrackOnce(ctx, options)
+			}
+			if options.tryQualityFallback(quality) {
+				return downloadTrackOnce(ctx, options)
+			}
+			return "", fmt.Errorf("%s failed verification: %w", track.SNG_TITLE, err)
 		}
-		raw = decrypt.DecryptDownload(raw, track.SNG_ID)
 	}
 	if err := ctx.Err(); err != nil {
 		return "", err
 	}
 
-	if options.Hooks.Status != nil {
-		options.Hooks.Status("Tagging " + track.SNG_TITLE + " by " + track.ART_NAME)
-	}
-	tagged, err := metadata.AddTrackTags(raw, track, metadata.TagOptions{
-		CoverSize: coverSize,
-		CoverMode: options.CoverMode,
-		AlbumInfo: options.Info,
-	})
+	if tagsAudio(track, savePath) {
+		if options.Hooks.Status != nil {
+			options.Hooks.Status("Tagging " + track.SNG_TITLE + " by " + track.ART_NAME)
+		}
+		err = metadata.AddTrackTagsFile(decrypted.Name(), savePath, track, metadata.TagOptions{
+			CoverSize: coverSize,
+			CoverMode: options.CoverMode,
+			AlbumInfo: options.Info,
+			Lyrics:    options.Lyrics,
+			Profile:   options.TagProfile,
+		})
+	} else {
+		if options.Hooks.Status != nil {
+			options.Hooks.Status("Saving " + track.SNG_TITLE + " by " + track.ART_NAME)
+		}
+		err = utils.CommitPartial(decrypted, savePath, 0644)
+	}
 	if err != nil {
 		return "", err
 	}
-	if err := ctx.Err(); err != nil {
+	if options.shouldSaveCoverFile(savePath) && metadata.ShouldSaveCoverFile(options.CoverMode) {
+		if _, err := metadata.SaveTrackCoverFile(coverFileDir(savePath, options.Path), options.CoverFileName, track, coverSize); err != nil {
+			return "", err
+		}
+	}
+	if err := options.saveLyricsFile(savePath, track); err != nil {
 		return "", err
 	}
-
-	if options.Hooks.Status != nil {
-		options.Hooks.Status("Saving " + track.SNG_TITLE + " by " + track.ART_NAME)
+	if err := ctx.Err(); err != nil {
+		_ = os.Remove(savePath)
+		return "", err
 	}
-	if os.Getenv("SIMULATE") == "" {
-		if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
-			return "", err
-		}
-		if err := os.WriteFile(savePath, tagged, 0644); err != nil {
-			return "", err
-		}
-		if options.shouldSaveCoverFile(savePath) && metadata.ShouldSaveCoverFile(options.CoverMode) {
-			if _, err := metadata.SaveAlbumCoverFile(coverFileDir(savePath, options.Path), options.CoverFileName, track.ALB_PICTURE, coverSize); err != nil {
-				return "", err
-			}
-		}
-		if err := ctx.Err(); err != nil {
-			_ = os.Remove(savePath)
+	if track.EPISODE == nil && NormalizeExistingPolicy(options.OnExisting) == ExistingSkipIfSameQuality {
+		if err := removeLowerQuality(savePath, quality); err != nil {
 			return "", err
 		}
 	}
+	if err := options.Archive.recordSaved(savePath, archiveKey{archiveID(track), quality}, options.requested); err != nil {
+		return "", err
+	}
 
 	if options.Hooks.Done != nil {
 		options.Hooks.Done(track, savePath, options.IsFallback, options.IsQualityFallback, label)
@@ -188,7 +373,7 @@ func DownloadTrack(ctx context.Context, options DownloadTrackOptions) (string, e
 }
 
 func (options *DownloadTrackOptions) tryQualityFallback(quality int) bool {
-	if !options.FallbackQuality || quality == 1 {
+	if !options.FallbackQuality || quality == 1 || options.Track.EPISODE != nil {
 		return false
 	}
 	if quality == 9 {
@@ -197,9 +382,19 @@ func (options *DownloadTrackOptions) tryQualityFallback(quality int) bool {
 		options.Quality = 1
 	}
 	options.IsQualityFallback = true
+	options.redownloaded = false
 	return true
 }
 
+// saveLyricsFile writes the .lrc sidecar of savePath when the lyrics modes ask for one.
+func (options DownloadTrackOptions) saveLyricsFile(savePath string, track types.TrackType) error {
+	if !metadata.ShouldSaveLyricsFile(options.Lyrics) {
+		return nil
+	}
+	_, err := metadata.SaveLyricsFile(savePath, track)
+	return err
+}
+
 func (options DownloadTrackOptions) shouldSaveCoverFile(savePath string) bool {
 	if options.CoverFilePolicy == nil {
 		return false
@@ -228,10 +423,15 @@ func terminalDownloadHooks(message string) DownloadTrackHooks {
 			}
 		},
 		Skip: func(track types.TrackType, savedPath, reason string) {
-			if reason == "exists" {
+			switch reason {
+			case "exists":
 				terminalStatus.Println(info(fmt.Sprintf("Skipped %q, track already exists.", track.SNG_TITLE)))
 				terminalStatus.Println(note(savedPath))
 				return
+			case "archived":
+				terminalStatus.Println(info(fmt.Sprintf("Skipped %q, track is in the download archive.", track.SNG_TITLE)))
+				terminalStatus.Println(note(savedPath))
+				return
 			}
 			terminalStatus.Println(warn(fmt.Sprintf("Skipped %q, track not available.", track.SNG_TITLE)))
 		},
@@ -252,13 +452,50 @@ func terminalDownloadHooks(message string) DownloadTrackHooks {
 	}
 }
 
+// decryptDownloadTemp copies a finished temp download into a partial file
+// next to savePath, decrypting it through decrypt.Writer on the way. The
+// caller commits or removes the returned file.
+func decryptDownloadTemp(tmpFile, savePath string, encrypted bool, trackID string) (*os.File, error) {
+	in, err := os.Open(tmpFile)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	out, err := utils.CreatePartial(savePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var sink io.Writer = out
+	var decrypter *decrypt.Writer
+	if encrypted {
+		decrypter = decrypt.NewWriter(out, trackID)
+		sink = decrypter
+	}
+	_, err = io.Copy(sink, in)
+	if err == nil && decrypter != nil {
+		err = decrypter.Close()
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return nil, err
+	}
+	return out, nil
+}
+
 func downloadToTemp(ctx context.Context, trackData *download.TrackDownloadUrl, tmpFile string, onProgress func(transferred, total int64)) error {
+	if useSegmentedDownload(trackData, tmpFile) {
+		err := downloadSegmentsToTemp(ctx, trackData, tmpFile, onProgress)
+		if !errors.Is(err, errRangeNotSupported) {
+			return err
+		}
+	}
+
 	var downloaded int64
 	resuming := false
 	headers := http.Header{}
-	if os.Getenv("SIMULATE") != "" {
-		headers.Set("Range", "bytes=0-1023")
-	} else if stat, err := os.Stat(tmpFile); err == nil {
+	if stat, err := os.Stat(tmpFile); err == nil {
 		downloaded = stat.Size()
 		if downloaded > 0 {
 			resuming = true
@@ -282,7 +519,7 @@ func downloadToTemp(ctx context.Context, trackData *download.TrackDownloadUrl, t
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("download failed: %s", resp.Status)
+		return fmt.Errorf("download failed: %w", download.StatusError(resp))
 	}
 	if resuming && resp.StatusCode != http.StatusPartialContent {
 		resp.Body.Close()
@@ -304,9 +541,10 @@ func downloadToTemp(ctx context.Context, trackData *download.TrackDownloadUrl, t
 	lastPrinted := transferred
 	progressUpdateInterval := time.Second
 	lastProgressUpdate := time.Now().Add(-progressUpdateInterval)
+	body := downloadLimiter.Reader(ctx, resp.Body)
 	buffer := make([]byte, 32*1024)
 	for {
-		n, readErr := resp.Body.Read(buffer)
+		n, readErr := body.Read(buffer)
 		if n > 0 {
 			written, writeErr := out.Write(buffer[:n])
 			if writeErr != nil {
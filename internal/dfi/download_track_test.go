@@ -1,6 +1,7 @@
 package dfi
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net/http"
@@ -9,8 +10,10 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/d-fi/GoFi/decrypt"
 	"github.com/d-fi/GoFi/download"
 	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
 )
 
 func TestTryQualityFallback(t *testing.T) {
@@ -44,6 +47,16 @@ func TestTryQualityFallback(t *testing.T) {
 		}
 	})
 
+	t.Run("resets verification retry", func(t *testing.T) {
+		options := DownloadTrackOptions{FallbackQuality: true, redownloaded: true}
+		if !options.tryQualityFallback(9) {
+			t.Fatal("expected quality fallback")
+		}
+		if options.redownloaded {
+			t.Fatal("lower quality should get its own re-download attempt")
+		}
+	})
+
 	t.Run("disabled", func(t *testing.T) {
 		options := DownloadTrackOptions{FallbackQuality: false}
 		if options.tryQualityFallback(9) {
@@ -124,6 +137,45 @@ func TestDownloadToTempAppendsPartialContent(t *testing.T) {
 	}
 }
 
+func TestDecryptDownloadTempDecryptsStripes(t *testing.T) {
+	dir := t.TempDir()
+	tmpFile := filepath.Join(dir, "d-fi_partial")
+	savePath := filepath.Join(dir, "album", "01 - Song.mp3")
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	raw := make([]byte, decrypt.StripeChunkSize*4+17)
+	for i := range raw {
+		raw[i] = byte(i)
+	}
+	if err := os.WriteFile(tmpFile, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, encrypted := range []bool{false, true} {
+		out, err := decryptDownloadTemp(tmpFile, savePath, encrypted, "3135556")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Close()
+		if filepath.Dir(out.Name()) != filepath.Dir(savePath) || !utils.IsPartialFile(filepath.Base(out.Name())) {
+			t.Fatalf("decrypted to %q, want a partial file next to %q", out.Name(), savePath)
+		}
+		data, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := raw
+		if encrypted {
+			want = decrypt.DecryptDownload(raw, "3135556")
+		}
+		if !bytes.Equal(data, want) {
+			t.Fatalf("encrypted = %v: streamed decryption does not match", encrypted)
+		}
+		os.Remove(out.Name())
+	}
+}
+
 func TestWritePlaylistFileRelative(t *testing.T) {
 	dir := t.TempDir()
 	albumDir := filepath.Join(dir, "album")
@@ -0,0 +1,118 @@
+package dfi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/download"
+	"github.com/d-fi/GoFi/types"
+)
+
+// Podcast episodes hosted by Deezer only come as MP3, whatever quality was
+// asked for. Episodes streamed from the show's own feed keep the container
+// the feed serves; see episodeAudioExt.
+const (
+	episodeExt   = ".mp3"
+	episodeLabel = "podcast"
+)
+
+// episodeExts lists every extension episodeAudioExt picks.
+var episodeExts = []string{episodeExt, ".m4a", ".ogg", ".aac"}
+
+// fetchEpisodeInfo re-reads an episode whose token ran out. Going through the
+// response cache would return the same expired token.
+var fetchEpisodeInfo = api.RefreshEpisodeInfo
+
+// episodeAudioExt picks the file extension for episode audio from its
+// container. MP3, with or without an ID3 tag, and anything unrecognized get
+// episodeExt.
+func episodeAudioExt(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return episodeExt
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return ".m4a"
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return ".ogg"
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xF6 == 0xF0:
+		// ADTS: an MPEG sync word with layer bits 00.
+		return ".aac"
+	}
+	return episodeExt
+}
+
+// existingSavePath returns the path a track saved as base+ext is looked for
+// at. The container of an episode is only known once it is downloaded, so an
+// earlier copy may be on disk under any of episodeExts.
+func existingSavePath(track types.TrackType, base, ext string) string {
+	if track.EPISODE != nil {
+		for _, candidate := range episodeExts {
+			if fileExists(base + candidate) {
+				return base + candidate
+			}
+		}
+	}
+	return base + ext
+}
+
+// tagsAudio reports whether the file at savePath gets tags. Episodes saved
+// in a container other than MP3 are left as the feed served them.
+func tagsAudio(track types.TrackType, savePath string) bool {
+	return track.EPISODE == nil || filepath.Ext(savePath) == episodeExt
+}
+
+// verifiesAudio reports whether track is checked with verify.Audio before it
+// is saved. Episodes streamed from a third-party feed are not: their
+// encoders vary too much for a strict frame walk.
+func verifiesAudio(track types.TrackType) bool {
+	return track.EPISODE == nil || track.EPISODE.EpisodeDirectStreamURL == ""
+}
+
+// trackFormat returns the file extension and quality label track is saved
+// with in quality.
+func trackFormat(track types.TrackType, quality int) (ext, label string) {
+	if track.EPISODE != nil {
+		return episodeExt, episodeLabel
+	}
+	_, ext, label = ParseQuality(quality)
+	return ext, label
+}
+
+// episodeAvailable reports whether episode has audio to download.
+func episodeAvailable(episode types.ShowEpisodeType) bool {
+	return episode.EpisodeDirectStreamURL != "" || episode.TrackToken != ""
+}
+
+// episodeFileSize returns the size Deezer reports for episode, or 0 when it
+// is streamed from the show's own feed.
+func episodeFileSize(episode types.ShowEpisodeType) int64 {
+	for _, value := range []string{episode.FilesizeMP364, episode.FilesizeMP332} {
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > 0 {
+			return size
+		}
+	}
+	return 0
+}
+
+// episodeDownloadUrl resolves the audio of the episode options.Track stands
+// for, fetching the episode again first when its token is about to expire.
+func episodeDownloadUrl(ctx context.Context, options *DownloadTrackOptions) (*download.TrackDownloadUrl, error) {
+	episode := *options.Track.EPISODE
+	expire := int64(episode.TrackTokenExpire)
+	if episode.EpisodeDirectStreamURL == "" && (episode.TrackToken == "" || expire > 0 && time.Now().Add(trackTokenRefreshMargin).Unix() >= expire) {
+		fresh, err := fetchEpisodeInfo(episode.EpisodeID)
+		if err != nil {
+			return nil, fmt.Errorf("refresh episode %s: %w", episode.EpisodeID, err)
+		}
+		episode.TrackToken = fresh.TrackToken
+		episode.TrackTokenExpire = fresh.TrackTokenExpire
+		episode.EpisodeDirectStreamURL = fresh.EpisodeDirectStreamURL
+		options.Track.EPISODE = &episode
+	}
+	return download.GetEpisodeDownloadUrl(ctx, episode)
+}
@@ -0,0 +1,241 @@
+package dfi
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/d-fi/GoFi/converter"
+	"github.com/d-fi/GoFi/metadata"
+	"github.com/d-fi/GoFi/types"
+)
+
+func testEpisode(id, title string) types.TrackType {
+	return converter.EpisodeToTrack(types.ShowEpisodeType{
+		EpisodeID:            id,
+		EpisodeTitle:         title,
+		ShowID:               "338532",
+		ShowName:             "Show",
+		ShowArtMD5:           "art",
+		FilesizeMP364:        "1200000",
+		TrackToken:           "token",
+		EpisodePublishedTime: "2021-04-20 09:00:00",
+	})
+}
+
+func TestSaveLayoutEpisode(t *testing.T) {
+	track := testEpisode("10", "Episode One")
+	track.TRACK_NUMBER = 4
+
+	got := SaveLayout(track, nil, "Podcasts/{SHOW_NAME}/{EPISODE_TITLE}", true, 1)
+	if want := filepath.Join("Podcasts", "Show", "Episode One"); got != want {
+		t.Fatalf("SaveLayout = %q, want %q", got, want)
+	}
+}
+
+func TestPlanDownloadsEpisodes(t *testing.T) {
+	dir := t.TempDir()
+	direct := testEpisode("11", "Direct")
+	direct.EPISODE.FilesizeMP364 = "0"
+	direct.EPISODE.TrackToken = ""
+	direct.EPISODE.EpisodeDirectStreamURL = "https://feed.example/direct.mp3"
+	gone := testEpisode("12", "Gone")
+	gone.EPISODE.TrackToken = ""
+
+	plan := PlanDownloads([]types.TrackType{testEpisode("10", "Hosted"), direct, gone}, PlanOptions{
+		LinkType:        "show",
+		Quality:         "flac",
+		Path:            filepath.Join(dir, "{SHOW_NAME}", "{EPISODE_TITLE}"),
+		FallbackQuality: true,
+	})
+
+	hosted := plan.Tracks[0]
+	if hosted.Status != PlanDownload || hosted.Size != 1200000 || hosted.Quality != episodeLabel || hosted.QualityFallback {
+		t.Fatalf("hosted episode = %+v", hosted)
+	}
+	if want := filepath.Join(dir, "Show", "Hosted.mp3"); hosted.Path != want {
+		t.Fatalf("hosted path = %q, want %q", hosted.Path, want)
+	}
+	if plan.Tracks[1].Status != PlanDownload || plan.Tracks[1].Size != 0 {
+		t.Fatalf("direct stream episode = %+v", plan.Tracks[1])
+	}
+	if plan.Tracks[2].Status != PlanUnavailable {
+		t.Fatalf("episode without audio = %+v", plan.Tracks[2])
+	}
+	if plan.Downloads != 2 || plan.TotalSize != 1200000 {
+		t.Fatalf("downloads = %d, total = %d", plan.Downloads, plan.TotalSize)
+	}
+}
+
+func TestEpisodeSkipsQualityFallback(t *testing.T) {
+	options := DownloadTrackOptions{Track: testEpisode("10", "Episode"), Quality: 9, FallbackQuality: true}
+	if options.tryQualityFallback(9) {
+		t.Fatal("episodes have no lower quality to fall back to")
+	}
+}
+
+func TestEpisodeDownloadTempName(t *testing.T) {
+	name := DownloadTempName(3, testEpisode("10", "Episode"))
+	if name != "d-fi_3_10_ep" {
+		t.Fatalf("DownloadTempName = %q, want d-fi_3_10_ep", name)
+	}
+	if !isDownloadTempName(name) || !isDownloadTempName(name+tempInfoSuffix) {
+		t.Fatalf("%q is not recognized by cleanup", name)
+	}
+}
+
+func TestArchiveKeepsEpisodesApartFromSongs(t *testing.T) {
+	archive, err := OpenArchive(filepath.Join(t.TempDir(), "d-fi.archive.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	episode := testEpisode("10", "Episode")
+	if err := archive.recordSaved("Show/Episode.mp3", archiveKey{archiveID(episode), 3}); err != nil {
+		t.Fatal(err)
+	}
+	var song types.TrackType
+	song.SNG_ID = "10"
+	if _, ok := archive.Lookup(archiveID(song), 3); ok {
+		t.Fatal("an archived episode matched a song with the same ID")
+	}
+	if entry, ok := archive.Lookup(archiveID(episode), 3); !ok || entry.SngID != "episode:10" {
+		t.Fatalf("episode entry = %+v, ok = %v", entry, ok)
+	}
+}
+
+func TestDownloadDirectStreamEpisodeKeepsContainer(t *testing.T) {
+	audio := append([]byte{0, 0, 0, 0x18}, []byte("ftypM4A isomiso2 rest of the file")...)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(audio)))
+		_, _ = w.Write(audio)
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	episode := testEpisode("11", "Direct")
+	episode.EPISODE.TrackToken = ""
+	episode.EPISODE.EpisodeDirectStreamURL = server.URL + "/direct.m4a"
+	savedPath, err := DownloadTrack(context.Background(), DownloadTrackOptions{
+		Track:   episode,
+		Quality: "320",
+		Path:    filepath.Join(dir, "{SHOW_NAME}", "{EPISODE_TITLE}"),
+		WorkDir: filepath.Join(dir, "work"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "Show", "Direct.m4a"); savedPath != want {
+		t.Fatalf("savedPath = %q, want %q", savedPath, want)
+	}
+	data, err := os.ReadFile(savedPath)
+	if err != nil || !bytes.Equal(data, audio) {
+		t.Fatalf("saved audio = %q, %v", data, err)
+	}
+}
+
+func TestDownloadDirectStreamEpisodeTagsMP3(t *testing.T) {
+	audio := bytes.Repeat(append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...), 20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(audio)))
+		_, _ = w.Write(audio)
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	episode := testEpisode("11", "Direct")
+	episode.EPISODE.TrackToken = ""
+	episode.EPISODE.EpisodeDirectStreamURL = server.URL + "/direct.mp3"
+	savedPath, err := DownloadTrack(context.Background(), DownloadTrackOptions{
+		Track:     episode,
+		Quality:   "320",
+		Path:      filepath.Join(dir, "{SHOW_NAME}", "{EPISODE_TITLE}"),
+		WorkDir:   filepath.Join(dir, "work"),
+		CoverMode: metadata.CoverModeNone,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(savedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("ID3")) || !bytes.HasSuffix(data, audio) {
+		t.Fatalf("saved episode is not the tagged audio: % x", data[:min(len(data), 16)])
+	}
+	entries, err := os.ReadDir(filepath.Dir(savedPath))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("files next to the episode: %v, %v", entries, err)
+	}
+}
+
+func TestExistingEpisodeFoundInAnyContainer(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "Show", "Direct.m4a")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, []byte("saved"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	episode := testEpisode("11", "Direct")
+	episode.EPISODE.TrackToken = ""
+	episode.EPISODE.EpisodeDirectStreamURL = server.URL + "/direct.m4a"
+	layout := filepath.Join(dir, "{SHOW_NAME}", "{EPISODE_TITLE}")
+
+	plan := PlanDownloads([]types.TrackType{episode}, PlanOptions{LinkType: "episode", Quality: "320", Path: layout})
+	if item := plan.Tracks[0]; item.Status != PlanExists || item.Path != existing {
+		t.Fatalf("planned episode = %+v, want existing %q", item, existing)
+	}
+
+	var skipped string
+	savedPath, err := DownloadTrack(context.Background(), DownloadTrackOptions{
+		Track:   episode,
+		Quality: "320",
+		Path:    layout,
+		WorkDir: filepath.Join(dir, "work"),
+		Hooks: DownloadTrackHooks{Skip: func(_ types.TrackType, _, reason string) {
+			skipped = reason
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if savedPath != existing || skipped != "exists" || requests != 0 {
+		t.Fatalf("savedPath = %q, skipped = %q, requests = %d", savedPath, skipped, requests)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Show", "Direct.mp3")); !os.IsNotExist(err) {
+		t.Fatalf("an .mp3 copy was saved next to the .m4a: %v", err)
+	}
+}
+
+func TestEpisodeAudioExt(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{[]byte("ID3\x04\x00"), ".mp3"},
+		{[]byte{0xFF, 0xFB, 0x90, 0x00}, ".mp3"},
+		{[]byte("\x00\x00\x00\x18ftypM4A "), ".m4a"},
+		{[]byte("OggS\x00"), ".ogg"},
+		{[]byte{0xFF, 0xF1, 0x50, 0x80}, ".aac"},
+		{[]byte("unknown"), ".mp3"},
+	}
+	for _, test := range tests {
+		if got := episodeAudioExt(test.data); got != test.want {
+			t.Fatalf("episodeAudioExt(% x) = %q, want %q", test.data, got, test.want)
+		}
+	}
+}
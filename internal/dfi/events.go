@@ -0,0 +1,178 @@
+package dfi
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/d-fi/GoFi/download"
+	"github.com/d-fi/GoFi/types"
+)
+
+// Event types written by --output-format json.
+const (
+	EventResolved = "resolved"
+	EventStart    = "start"
+	EventStatus   = "status"
+	EventProgress = "progress"
+	EventSkip     = "skip"
+	EventDone     = "done"
+	EventError    = "error"
+	EventSummary  = "summary"
+)
+
+// eventProgressStep is how many bytes a track downloads between two progress
+// events.
+const eventProgressStep = 1024 * 1024
+
+// Event is one line of the NDJSON stream. Track events carry the track fields;
+// resolved and summary events carry the source fields.
+type Event struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+
+	Index           int    `json:"index,omitempty"`
+	SngID           string `json:"SNG_ID,omitempty"`
+	Title           string `json:"title,omitempty"`
+	Artist          string `json:"artist,omitempty"`
+	Album           string `json:"album,omitempty"`
+	Path            string `json:"path,omitempty"`
+	Quality         string `json:"quality,omitempty"`
+	TrackFallback   bool   `json:"trackFallback,omitempty"`
+	QualityFallback bool   `json:"qualityFallback,omitempty"`
+	Message         string `json:"message,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	Transferred     int64  `json:"transferred,omitempty"`
+	Total           int64  `json:"total,omitempty"`
+	ErrorClass      string `json:"errorClass,omitempty"`
+	Error           string `json:"error,omitempty"`
+
+	Source   string      `json:"source,omitempty"`
+	LinkType string      `json:"linkType,omitempty"`
+	Tracks   int         `json:"tracks,omitempty"`
+	Summary  *RunSummary `json:"summary,omitempty"`
+}
+
+// RunSummary holds the totals of a summary event.
+type RunSummary struct {
+	Saved         int            `json:"saved"`
+	Failed        int            `json:"failed"`
+	FailedByClass map[string]int `json:"failedByClass"`
+	Playlist      string         `json:"playlist,omitempty"`
+}
+
+// eventWriter writes events as NDJSON. It is safe for concurrent use.
+type eventWriter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	now     func() time.Time
+}
+
+func newEventWriter(w io.Writer) *eventWriter {
+	return &eventWriter{encoder: json.NewEncoder(w), now: time.Now}
+}
+
+func (w *eventWriter) emit(event Event) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	event.Time = w.now().UTC()
+	_ = w.encoder.Encode(event)
+}
+
+func trackEvent(kind string, index int, track types.TrackType) Event {
+	return Event{
+		Event:  kind,
+		Index:  index,
+		SngID:  track.SNG_ID,
+		Title:  track.SNG_TITLE,
+		Artist: track.ART_NAME,
+		Album:  track.ALB_TITLE,
+	}
+}
+
+// hooks returns DownloadTrackHooks that turn every callback for the index-th
+// track of options into an event.
+func (w *eventWriter) hooks(index int, options DownloadTrackOptions) DownloadTrackHooks {
+	_, _, requested := ParseQuality(options.Quality)
+	var lastProgress int64
+	return DownloadTrackHooks{
+		Start: func(track types.TrackType) {
+			event := trackEvent(EventStart, index, track)
+			event.Quality = requested
+			w.emit(event)
+		},
+		Status: func(message string) {
+			event := trackEvent(EventStatus, index, options.Track)
+			event.Message = message
+			w.emit(event)
+		},
+		Progress: func(track types.TrackType, transferred, total int64) {
+			if transferred-lastProgress < eventProgressStep && (total <= 0 || transferred < total) {
+				return
+			}
+			lastProgress = transferred
+			event := trackEvent(EventProgress, index, track)
+			event.Transferred = transferred
+			event.Total = total
+			w.emit(event)
+		},
+		Skip: func(track types.TrackType, savedPath, reason string) {
+			event := trackEvent(EventSkip, index, track)
+			event.Path = savedPath
+			event.Reason = reason
+			event.Quality = requested
+			w.emit(event)
+		},
+		Done: func(track types.TrackType, savedPath string, isFallback, isQualityFallback bool, label string) {
+			event := trackEvent(EventDone, index, track)
+			event.Path = savedPath
+			event.Quality = label
+			event.TrackFallback = isFallback
+			event.QualityFallback = isQualityFallback
+			w.emit(event)
+		},
+	}
+}
+
+// trackError reports a track whose download returned err.
+func (w *eventWriter) trackError(index int, track types.TrackType, quality string, err error) {
+	event := trackEvent(EventError, index, track)
+	event.Quality = quality
+	event.ErrorClass = string(download.Classify(err))
+	event.Error = err.Error()
+	w.emit(event)
+}
+
+// sourceError reports an input that failed before or after its tracks ran.
+func (w *eventWriter) sourceError(source string, err error) {
+	w.emit(Event{
+		Event:      EventError,
+		Source:     source,
+		ErrorClass: string(download.Classify(err)),
+		Error:      err.Error(),
+	})
+}
+
+// summary reports the end of one input.
+func (w *eventWriter) summary(source string, data ResolvedInput, savedFiles []string, failures []trackFailure, playlistPath string) {
+	summary := &RunSummary{
+		Saved:         len(savedFiles),
+		Failed:        len(failures),
+		FailedByClass: map[string]int{},
+		Playlist:      playlistPath,
+	}
+	for _, item := range failures {
+		summary.FailedByClass[string(item.class)]++
+	}
+	w.emit(Event{
+		Event:    EventSummary,
+		Source:   source,
+		LinkType: data.LinkType,
+		Tracks:   len(data.Tracks),
+		Summary:  summary,
+	})
+}
@@ -0,0 +1,108 @@
+package dfi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/d-fi/GoFi/download"
+	"github.com/d-fi/GoFi/types"
+)
+
+func readEvents(t *testing.T, data []byte) []Event {
+	t.Helper()
+	var events []Event
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEventHooks(t *testing.T) {
+	var out bytes.Buffer
+	events := newEventWriter(&out)
+	events.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	track := types.TrackType{SongType: types.SongType{SNG_ID: "3135556", SNG_TITLE: "Song", ART_NAME: "Artist", ALB_TITLE: "Album"}}
+
+	hooks := events.hooks(2, DownloadTrackOptions{Track: track, Quality: "flac"})
+	hooks.Start(track)
+	hooks.Status("Tagging Song by Artist")
+	hooks.Progress(track, 1024, 4*eventProgressStep)
+	hooks.Progress(track, eventProgressStep+1, 4*eventProgressStep)
+	hooks.Progress(track, 4*eventProgressStep, 4*eventProgressStep)
+	hooks.Skip(track, "Music/Song.flac", "exists")
+	hooks.Done(track, "Music/Song.mp3", false, true, "320")
+	events.trackError(2, track, "flac", download.NewError(download.ErrorCDN, errors.New("403 Forbidden")))
+
+	got := readEvents(t, out.Bytes())
+	kinds := []string{EventStart, EventStatus, EventProgress, EventProgress, EventSkip, EventDone, EventError}
+	if len(got) != len(kinds) {
+		t.Fatalf("got %d events, want %d:\n%s", len(got), len(kinds), out.String())
+	}
+	for i, event := range got {
+		if event.Event != kinds[i] || event.SngID != "3135556" || event.Title != "Song" || event.Index != 2 {
+			t.Fatalf("event %d = %+v", i, event)
+		}
+		if !event.Time.Equal(time.Unix(1_700_000_000, 0)) {
+			t.Fatalf("event %d time = %v", i, event.Time)
+		}
+	}
+	if got[0].Quality != "flac" || got[1].Message != "Tagging Song by Artist" {
+		t.Fatalf("start/status = %+v %+v", got[0], got[1])
+	}
+	if got[3].Transferred != 4*eventProgressStep || got[3].Total != 4*eventProgressStep {
+		t.Fatalf("final progress = %+v", got[3])
+	}
+	if got[4].Reason != "exists" || got[4].Path != "Music/Song.flac" {
+		t.Fatalf("skip = %+v", got[4])
+	}
+	if got[5].Quality != "320" || !got[5].QualityFallback || got[5].TrackFallback || got[5].Path != "Music/Song.mp3" {
+		t.Fatalf("done = %+v", got[5])
+	}
+	if got[6].ErrorClass != "cdn" || got[6].Error == "" {
+		t.Fatalf("error = %+v", got[6])
+	}
+}
+
+func TestEventSummary(t *testing.T) {
+	var out bytes.Buffer
+	events := newEventWriter(&out)
+	data := ResolvedInput{LinkType: "playlist", Tracks: make([]types.TrackType, 3)}
+	events.summary("https://www.deezer.com/playlist/1", data, []string{"a.mp3"}, []trackFailure{
+		{title: "b", class: download.ErrorNetwork, err: errors.New("reset")},
+		{title: "c", class: download.ErrorNetwork, err: errors.New("reset")},
+	}, "")
+
+	got := readEvents(t, out.Bytes())
+	if len(got) != 1 || got[0].Event != EventSummary || got[0].Summary == nil {
+		t.Fatalf("summary events = %+v", got)
+	}
+	summary := got[0].Summary
+	if got[0].Tracks != 3 || summary.Saved != 1 || summary.Failed != 2 || summary.FailedByClass["network"] != 2 {
+		t.Fatalf("summary = %+v %+v", got[0], summary)
+	}
+
+	var nilWriter *eventWriter
+	nilWriter.emit(Event{Event: EventStart})
+}
+
+func TestParseOptionsOutputFormat(t *testing.T) {
+	opts, err := parseOptions([]string{"--output-format", "JSON", "--dry-run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.outputFormat != "json" || opts.dryRun != "json" {
+		t.Fatalf("outputFormat = %q, dryRun = %q", opts.outputFormat, opts.dryRun)
+	}
+	if _, err := parseOptions([]string{"--output-format", "yaml"}); err == nil {
+		t.Fatal("parseOptions accepted --output-format yaml")
+	}
+}
@@ -0,0 +1,201 @@
+package dfi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/d-fi/GoFi/metadata"
+	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
+)
+
+// ExistingPolicy decides what DownloadTrack does when the save path already
+// exists.
+type ExistingPolicy string
+
+const (
+	// ExistingSkip leaves the file alone.
+	ExistingSkip ExistingPolicy = "skip"
+	// ExistingOverwrite downloads the track again and replaces the file.
+	ExistingOverwrite ExistingPolicy = "overwrite"
+	// ExistingRetag rewrites the tags and cover of the file without
+	// downloading the audio again.
+	ExistingRetag ExistingPolicy = "retag"
+	// ExistingSkipIfSameQuality skips files already at the requested quality
+	// or better and replaces lower quality ones.
+	ExistingSkipIfSameQuality ExistingPolicy = "skip-if-same-quality"
+	// ExistingRename keeps the file and saves the new download next to it as
+	// "name (2).ext".
+	ExistingRename ExistingPolicy = "rename"
+)
+
+// NormalizeExistingPolicy returns policy in canonical form, or ExistingSkip
+// for unknown values.
+func NormalizeExistingPolicy(policy ExistingPolicy) ExistingPolicy {
+	normalized, err := ParseExistingPolicy(string(policy))
+	if err != nil {
+		return ExistingSkip
+	}
+	return normalized
+}
+
+// ParseExistingPolicy parses an onExisting value. An empty value is skip.
+func ParseExistingPolicy(value string) (ExistingPolicy, error) {
+	policy := ExistingPolicy(strings.ToLower(strings.TrimSpace(value)))
+	switch policy {
+	case "":
+		return ExistingSkip, nil
+	case ExistingSkip, ExistingOverwrite, ExistingRetag, ExistingSkipIfSameQuality, ExistingRename:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid onExisting %q, use skip, overwrite, retag, skip-if-same-quality or rename", value)
+	}
+}
+
+// replacesFiles reports whether policy works on files the archive already
+// lists, so the archive must not skip them.
+func (policy ExistingPolicy) replacesFiles() bool {
+	switch NormalizeExistingPolicy(policy) {
+	case ExistingOverwrite, ExistingRetag:
+		return true
+	default:
+		return false
+	}
+}
+
+// keepExisting reports whether an existing file at savePath should be kept
+// as it is when a track in quality would be saved there.
+func (policy ExistingPolicy) keepExisting(savePath string, quality int) bool {
+	switch NormalizeExistingPolicy(policy) {
+	case ExistingSkip:
+		return true
+	case ExistingSkipIfSameQuality:
+		// A file whose quality can't be read is kept rather than replaced.
+		existing, err := fileQuality(savePath)
+		return err != nil || existing >= quality
+	default:
+		return false
+	}
+}
+
+// songExts are the extensions a song is saved with, best quality first.
+var songExts = []string{".flac", ".mp3"}
+
+// existingPath returns the file the existence check looks at for a track
+// saved as base+ext in quality. With skip-if-same-quality, a copy of a song
+// in the other format counts when it is at least as good, so a FLAC is kept
+// when MP3 320 is asked for.
+func (policy ExistingPolicy) existingPath(track types.TrackType, base, ext string, quality int) string {
+	if track.EPISODE == nil && NormalizeExistingPolicy(policy) == ExistingSkipIfSameQuality {
+		for _, candidate := range songExts {
+			if existing, err := existingQuality(base + candidate); err == nil && existing >= quality {
+				return base + candidate
+			}
+		}
+	}
+	return existingSavePath(track, base, ext)
+}
+
+// existingQuality is fileQuality for a file that has to exist, which
+// fileQuality takes for granted for a FLAC.
+func existingQuality(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	return fileQuality(path)
+}
+
+// removeLowerQuality deletes the copies of savePath in the other song format
+// whose quality is below quality, once skip-if-same-quality replaced them.
+// Copies whose quality can't be read are kept.
+func removeLowerQuality(savePath string, quality int) error {
+	base := strings.TrimSuffix(savePath, filepath.Ext(savePath))
+	for _, ext := range songExts {
+		sibling := base + ext
+		if sibling == savePath {
+			continue
+		}
+		if existing, err := existingQuality(sibling); err == nil && existing < quality {
+			if err := os.Remove(sibling); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// keepBothPath returns the first "name (n).ext" next to path that does not
+// exist yet, starting at 2.
+func keepBothPath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// retagExisting replaces the tags and cover of the file at savePath with the
+// ones a fresh download of options.Track would get. The audio is kept.
+func retagExisting(ctx context.Context, options *DownloadTrackOptions, savePath string, quality int) (string, error) {
+	track := options.Track
+	if existing, err := fileQuality(savePath); err == nil {
+		quality = existing
+	}
+	_, label := trackFormat(track, quality)
+	coverSize := CoverSizeForQuality(options.CoverSizes, label)
+
+	if options.Hooks.Status != nil {
+		options.Hooks.Status("Retagging " + track.SNG_TITLE + " by " + track.ART_NAME)
+	}
+	if tagsAudio(track, savePath) {
+		stat, err := os.Stat(savePath)
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(savePath)
+		if err != nil {
+			return "", err
+		}
+		audio, err := metadata.StripTags(data)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", savePath, err)
+		}
+		tagged, err := metadata.AddTrackTags(audio, track, metadata.TagOptions{
+			CoverSize: coverSize,
+			CoverMode: options.CoverMode,
+			AlbumInfo: options.Info,
+			Lyrics:    options.Lyrics,
+			Profile:   options.TagProfile,
+		})
+		if err != nil {
+			return "", err
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := utils.WriteFileAtomic(savePath, tagged, stat.Mode().Perm()); err != nil {
+			return "", err
+		}
+	}
+	if options.shouldSaveCoverFile(savePath) && metadata.ShouldSaveCoverFile(options.CoverMode) {
+		if _, err := metadata.SaveTrackCoverFile(coverFileDir(savePath, options.Path), options.CoverFileName, track, coverSize); err != nil {
+			return "", err
+		}
+	}
+	if err := options.saveLyricsFile(savePath, track); err != nil {
+		return "", err
+	}
+	if err := options.Archive.recordSaved(savePath, archiveKey{archiveID(track), quality}, options.requested); err != nil {
+		return "", err
+	}
+	if options.Hooks.Done != nil {
+		options.Hooks.Done(track, savePath, options.IsFallback, options.IsQualityFallback, label)
+	}
+	return savePath, nil
+}
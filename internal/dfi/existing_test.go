@@ -0,0 +1,184 @@
+package dfi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+)
+
+func TestParseExistingPolicy(t *testing.T) {
+	tests := map[string]ExistingPolicy{
+		"":                     ExistingSkip,
+		"skip":                 ExistingSkip,
+		" Overwrite ":          ExistingOverwrite,
+		"retag":                ExistingRetag,
+		"skip-if-same-quality": ExistingSkipIfSameQuality,
+		"RENAME":               ExistingRename,
+	}
+	for input, want := range tests {
+		got, err := ParseExistingPolicy(input)
+		if err != nil || got != want {
+			t.Fatalf("ParseExistingPolicy(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseExistingPolicy("replace"); err == nil {
+		t.Fatal("expected unknown policy to be rejected")
+	}
+	if got := NormalizeExistingPolicy("replace"); got != ExistingSkip {
+		t.Fatalf("NormalizeExistingPolicy(replace) = %q, want skip", got)
+	}
+}
+
+func TestExistingPolicyKeepExisting(t *testing.T) {
+	dir := t.TempDir()
+	low := filepath.Join(dir, "low.mp3")
+	high := filepath.Join(dir, "high.mp3")
+	writeUpgradeMP3(t, low, "1", 0x90)
+	writeUpgradeMP3(t, high, "2", 0xE0)
+
+	tests := []struct {
+		policy  ExistingPolicy
+		path    string
+		quality int
+		want    bool
+	}{
+		{ExistingSkip, low, 3, true},
+		{"", low, 3, true},
+		{ExistingOverwrite, high, 1, false},
+		{ExistingRename, high, 1, false},
+		{ExistingSkipIfSameQuality, low, 3, false},
+		{ExistingSkipIfSameQuality, low, 1, true},
+		{ExistingSkipIfSameQuality, high, 3, true},
+		{ExistingSkipIfSameQuality, high, 1, true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.keepExisting(tt.path, tt.quality); got != tt.want {
+			t.Fatalf("%q.keepExisting(%s, %d) = %v, want %v", tt.policy, filepath.Base(tt.path), tt.quality, got, tt.want)
+		}
+	}
+}
+
+func TestExistingPathChecksOtherFormat(t *testing.T) {
+	dir := t.TempDir()
+	flac := filepath.Join(dir, "Flac")
+	if err := os.WriteFile(flac+".flac", []byte("flac"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	low := filepath.Join(dir, "Low")
+	writeUpgradeMP3(t, low+".mp3", "1", 0x90)
+	var track types.TrackType
+
+	tests := []struct {
+		policy  ExistingPolicy
+		base    string
+		ext     string
+		quality int
+		want    string
+	}{
+		{ExistingSkipIfSameQuality, flac, ".mp3", 3, flac + ".flac"},
+		{ExistingSkip, flac, ".mp3", 3, flac + ".mp3"},
+		{ExistingSkipIfSameQuality, low, ".flac", 9, low + ".flac"},
+		{ExistingSkipIfSameQuality, low, ".mp3", 1, low + ".mp3"},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.existingPath(track, tt.base, tt.ext, tt.quality); got != tt.want {
+			t.Fatalf("%q.existingPath(%s%s, %d) = %s, want %s", tt.policy, filepath.Base(tt.base), tt.ext, tt.quality, filepath.Base(got), filepath.Base(tt.want))
+		}
+	}
+}
+
+func TestRemoveLowerQuality(t *testing.T) {
+	dir := t.TempDir()
+	song := filepath.Join(dir, "Song")
+	writeUpgradeMP3(t, song+".mp3", "1", 0xE0)
+	if err := os.WriteFile(song+".flac", []byte("flac"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := removeLowerQuality(song+".mp3", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(song + ".flac"); err != nil {
+		t.Fatal("a better copy was removed")
+	}
+	if err := removeLowerQuality(song+".flac", 9); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(song + ".mp3"); !os.IsNotExist(err) {
+		t.Fatalf("the replaced MP3 was kept: %v", err)
+	}
+
+	unreadable := filepath.Join(dir, "Unreadable")
+	if err := os.WriteFile(unreadable+".mp3", []byte("not audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeLowerQuality(unreadable+".flac", 9); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(unreadable + ".mp3"); err != nil {
+		t.Fatal("a file of unknown quality was removed")
+	}
+}
+
+func TestKeepBothPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Song.flac")
+	for _, name := range []string{"Song.flac", "Song (2).flac"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("flac"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := keepBothPath(path), filepath.Join(dir, "Song (3).flac"); got != want {
+		t.Fatalf("keepBothPath() = %q, want %q", got, want)
+	}
+}
+
+func TestPlanDownloadsOnExisting(t *testing.T) {
+	dir := t.TempDir()
+	layout := filepath.Join(dir, "{SNG_TITLE}")
+	if err := os.WriteFile(filepath.Join(dir, "One.flac"), []byte("flac"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tracks := []types.TrackType{planTrack("1", "One", 30_000_000, 9_000_000, 4_000_000)}
+
+	tests := []struct {
+		policy ExistingPolicy
+		status string
+		path   string
+	}{
+		{ExistingSkip, PlanExists, filepath.Join(dir, "One.flac")},
+		{ExistingOverwrite, PlanDownload, filepath.Join(dir, "One.flac")},
+		{ExistingRetag, PlanRetag, filepath.Join(dir, "One.flac")},
+		{ExistingRename, PlanDownload, filepath.Join(dir, "One (2).flac")},
+	}
+	for _, tt := range tests {
+		plan := PlanDownloads(tracks, PlanOptions{Quality: "flac", Path: layout, OnExisting: tt.policy})
+		item := plan.Tracks[0]
+		if item.Status != tt.status || item.Path != tt.path {
+			t.Fatalf("%s: track = %+v, want %s at %s", tt.policy, item, tt.status, tt.path)
+		}
+		if tt.policy == ExistingRetag && plan.Retags != 1 {
+			t.Fatalf("Retags = %d, want 1", plan.Retags)
+		}
+	}
+
+	plan := PlanDownloads(tracks, PlanOptions{Quality: "320", Path: layout, OnExisting: ExistingSkipIfSameQuality})
+	if item := plan.Tracks[0]; item.Status != PlanExists || item.Path != filepath.Join(dir, "One.flac") {
+		t.Fatalf("skip-if-same-quality at 320: track = %+v, want the existing FLAC", item)
+	}
+}
+
+func TestParseOptionsOnExisting(t *testing.T) {
+	opts, err := parseOptions([]string{"--on-existing", "Skip-If-Same-Quality"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.onExisting != string(ExistingSkipIfSameQuality) {
+		t.Fatalf("onExisting = %q", opts.onExisting)
+	}
+	if _, err := parseOptions([]string{"--on-existing", "replace"}); err == nil {
+		t.Fatal("parseOptions accepted --on-existing replace")
+	}
+}
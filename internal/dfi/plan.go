@@ -0,0 +1,287 @@
+package dfi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/d-fi/GoFi/metadata"
+	"github.com/d-fi/GoFi/types"
+)
+
+// Planned track statuses.
+const (
+	PlanDownload    = "download"
+	PlanExists      = "exists"
+	PlanArchived    = "archived"
+	PlanDuplicate   = "duplicate"
+	PlanUnavailable = "unavailable"
+	PlanRetag       = "retag"
+)
+
+// PlanOptions holds the download settings a plan is built for. They mirror the
+// fields of DownloadTrackOptions that decide where and how a track is saved.
+type PlanOptions struct {
+	LinkType        string
+	Quality         any
+	Info            any
+	Path            string
+	TrackNumber     bool
+	FallbackTrack   bool
+	FallbackQuality bool
+	CoverMode       metadata.CoverMode
+	CoverFileName   string
+	CreatePlaylist  bool
+	Archive         *Archive
+	OnExisting      ExistingPolicy
+}
+
+// PlannedTrack is what a download would do with one track.
+type PlannedTrack struct {
+	Index           int    `json:"index"`
+	SngID           string `json:"sngId"`
+	Title           string `json:"title"`
+	Artist          string `json:"artist"`
+	Path            string `json:"path,omitempty"`
+	Quality         string `json:"quality,omitempty"`
+	QualityFallback bool   `json:"qualityFallback,omitempty"`
+	TrackFallback   bool   `json:"trackFallback,omitempty"`
+	Size            int64  `json:"size"`
+	Status          string `json:"status"`
+}
+
+// DownloadPlan is the result of a dry run.
+type DownloadPlan struct {
+	LinkType     string         `json:"linkType"`
+	Tracks       []PlannedTrack `json:"tracks"`
+	CoverFiles   []string       `json:"coverFiles,omitempty"`
+	PlaylistPath string         `json:"playlistPath,omitempty"`
+	Downloads    int            `json:"downloads"`
+	Skipped      int            `json:"skipped"`
+	Retags       int            `json:"retags,omitempty"`
+	TotalSize    int64          `json:"totalSize"`
+}
+
+// PlanDownloads works out the save path, quality, expected size and skip
+// reason of every track the way DownloadTrack would, using only the song data
+// already fetched. It makes no media requests and writes nothing.
+func PlanDownloads(tracks []types.TrackType, options PlanOptions) DownloadPlan {
+	plan := DownloadPlan{LinkType: options.LinkType, Tracks: make([]PlannedTrack, 0, len(tracks))}
+	requested, _, _ := ParseQuality(options.Quality)
+	coverPolicy := CoverFilePolicy(tracks, options.Info, options.Path, options.TrackNumber)
+	seenPaths := map[string]bool{}
+	seenCovers := map[string]bool{}
+	var plannedPaths []string
+
+	for i, track := range tracks {
+		item := PlannedTrack{
+			Index:  i + 1,
+			SngID:  track.SNG_ID,
+			Title:  track.SNG_TITLE,
+			Artist: track.ART_NAME,
+		}
+
+		quality, size, fallback, trackFallback, available := trackPlanQuality(track, requested, options.FallbackTrack, options.FallbackQuality)
+		item.TrackFallback = trackFallback
+		ext, label := trackFormat(track, quality)
+		item.Quality = label
+		item.QualityFallback = fallback
+		item.Path = options.OnExisting.existingPath(track, SaveLayout(track, options.Info, options.Path, options.TrackNumber, len(tracks)), ext, quality)
+
+		exists := fileExists(item.Path)
+		switch entry, archived := options.Archive.Lookup(archiveID(track), requested); {
+		case archived && !options.OnExisting.replacesFiles():
+			item.Status = PlanArchived
+			item.Path = entry.Path
+			item.Quality = ""
+			item.QualityFallback = false
+		case seenPaths[item.Path]:
+			item.Status = PlanDuplicate
+		case exists && options.OnExisting.keepExisting(item.Path, quality):
+			item.Status = PlanExists
+		case exists && NormalizeExistingPolicy(options.OnExisting) == ExistingRetag:
+			item.Status = PlanRetag
+		case !available:
+			item.Status = PlanUnavailable
+			item.Path = ""
+			item.Quality = ""
+			item.QualityFallback = false
+			item.TrackFallback = false
+		default:
+			if exists && NormalizeExistingPolicy(options.OnExisting) == ExistingRename {
+				item.Path = keepBothPath(item.Path)
+			}
+			item.Status = PlanDownload
+			item.Size = size
+		}
+
+		if item.Status == PlanDownload || item.Status == PlanExists || item.Status == PlanRetag {
+			seenPaths[item.Path] = true
+			plannedPaths = append(plannedPaths, item.Path)
+			coverDir := coverFileDir(item.Path, options.Path)
+			if coverPolicy[coverDir] && metadata.ShouldSaveCoverFile(options.CoverMode) && !seenCovers[coverDir] {
+				seenCovers[coverDir] = true
+				plan.CoverFiles = append(plan.CoverFiles, filepath.Join(coverDir, metadata.NormalizeCoverFileName(options.CoverFileName)))
+			}
+		} else if item.Status == PlanArchived {
+			plannedPaths = append(plannedPaths, item.Path)
+		}
+
+		if item.Status == PlanDownload {
+			plan.Downloads++
+			plan.TotalSize += item.Size
+		} else if item.Status == PlanRetag {
+			plan.Retags++
+		} else {
+			plan.Skipped++
+		}
+		plan.Tracks = append(plan.Tracks, item)
+	}
+
+	if (options.CreatePlaylist || options.LinkType == "playlist") && len(plannedPaths) > 1 {
+		plan.PlaylistPath = playlistFilePath(options.Info, plannedPaths)
+	}
+	return plan
+}
+
+// trackPlanQuality picks the quality and size DownloadTrack would save track
+// in, moving to its fallback track when the track itself is unavailable.
+func trackPlanQuality(track types.TrackType, requested int, fallbackTrack, fallbackQuality bool) (quality int, size int64, fallback, trackFallback, available bool) {
+	if track.EPISODE != nil {
+		// Episodes streamed from the show's feed have no known size.
+		return requested, episodeFileSize(*track.EPISODE), false, false, episodeAvailable(*track.EPISODE)
+	}
+	quality, size, fallback = planQuality(track, requested, fallbackQuality)
+	if size == 0 && fallbackTrack && track.FALLBACK != nil && track.ART_ID == track.FALLBACK.ART_ID {
+		alternate := track
+		alternate.SongType = *track.FALLBACK
+		quality, size, fallback = planQuality(alternate, requested, fallbackQuality)
+		return quality, size, fallback, size > 0, size > 0
+	}
+	return quality, size, fallback, false, size > 0
+}
+
+// EstimateTrackSize returns the size track would be saved in at quality once
+// quality and track fallback apply, or 0 when it is unavailable.
+func EstimateTrackSize(track types.TrackType, quality any, fallbackTrack, fallbackQuality bool) int64 {
+	requested, _, _ := ParseQuality(quality)
+	_, size, _, _, available := trackPlanQuality(track, requested, fallbackTrack, fallbackQuality)
+	if !available {
+		return 0
+	}
+	return size
+}
+
+// planQuality picks the quality DownloadTrack would end up saving, based on
+// which FILESIZE fields are set. A zero size means nothing is available.
+func planQuality(track types.TrackType, requested int, fallbackQuality bool) (quality int, size int64, fallback bool) {
+	for _, candidate := range []int{9, 3, 1} {
+		if candidate > requested {
+			continue
+		}
+		if size := trackFileSize(track, candidate); size > 0 {
+			return candidate, size, candidate != requested
+		}
+		if !fallbackQuality {
+			break
+		}
+	}
+	return requested, 0, false
+}
+
+// trackFileSize returns the size Deezer reports for track in quality.
+func trackFileSize(track types.TrackType, quality int) int64 {
+	switch quality {
+	case 9:
+		return int64(track.FILESIZE_FLAC)
+	case 3:
+		return int64(track.FILESIZE_MP3_320)
+	default:
+		if track.FILESIZE_MP3_128 > 0 {
+			return int64(track.FILESIZE_MP3_128)
+		}
+		return int64(track.FILESIZE)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// WritePlanTable prints plan as an aligned table with a summary line.
+func WritePlanTable(w io.Writer, plan DownloadPlan) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "#\tSTATUS\tQUALITY\tSIZE\tPATH")
+	for _, item := range plan.Tracks {
+		quality := item.Quality
+		if item.QualityFallback {
+			quality += " (fallback)"
+		}
+		size := "-"
+		if item.Size > 0 {
+			size = formatSize(item.Size)
+		}
+		path := item.Path
+		if path == "" {
+			path = item.Title + " - " + item.Artist
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n", item.Index, item.Status, quality, size, path)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	for _, cover := range plan.CoverFiles {
+		fmt.Fprintln(w, "cover     "+cover)
+	}
+	if plan.PlaylistPath != "" {
+		fmt.Fprintln(w, "playlist  "+plan.PlaylistPath)
+	}
+	summary := fmt.Sprintf("Would download %d %s (%s), skip %d", plan.Downloads, plural("track", plan.Downloads), formatSize(plan.TotalSize), plan.Skipped)
+	if plan.Retags > 0 {
+		summary += fmt.Sprintf(", retag %d", plan.Retags)
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+// WritePlanJSON prints plan as a single indented JSON document.
+func WritePlanJSON(w io.Writer, plan DownloadPlan) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plan)
+}
+
+func formatSize(size int64) string {
+	return fmt.Sprintf("%.2fMiB", float64(size)/1024/1024)
+}
+
+// dryRunFlag is the value of --dry-run. A bare --dry-run selects the table
+// output and --dry-run=json the JSON output.
+type dryRunFlag string
+
+func (f *dryRunFlag) String() string {
+	return string(*f)
+}
+
+func (f *dryRunFlag) Set(value string) error {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "table":
+		*f = "table"
+	case "json":
+		*f = "json"
+	case "false", "":
+		*f = ""
+	default:
+		return fmt.Errorf("invalid dry-run format %q, use table or json", value)
+	}
+	return nil
+}
+
+func (f *dryRunFlag) IsBoolFlag() bool {
+	return true
+}
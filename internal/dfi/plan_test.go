@@ -0,0 +1,199 @@
+package dfi
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+)
+
+func planTrack(id, title string, flac, mp3320, mp3128 int) types.TrackType {
+	return types.TrackType{SongType: types.SongType{
+		SNG_ID:           id,
+		SNG_TITLE:        title,
+		ART_NAME:         "Artist",
+		ART_ID:           "1",
+		ALB_TITLE:        "Album",
+		ALB_PICTURE:      "cover",
+		FILESIZE_FLAC:    types.StringOrInt(flac),
+		FILESIZE_MP3_320: types.StringOrInt(mp3320),
+		FILESIZE_MP3_128: types.StringOrInt(mp3128),
+	}}
+}
+
+func TestPlanDownloads(t *testing.T) {
+	dir := t.TempDir()
+	layout := filepath.Join(dir, "{ALB_TITLE}", "{SNG_TITLE}")
+	if err := os.MkdirAll(filepath.Join(dir, "Album"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Album", "Existing.flac"), []byte("flac"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := OpenArchive(filepath.Join(dir, "archive.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	if err := archive.Record(ArchiveEntry{SngID: "4", Quality: 9, Path: "old/Archived.flac"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tracks := []types.TrackType{
+		planTrack("1", "Lossless", 30_000_000, 9_000_000, 4_000_000),
+		planTrack("2", "Lossy", 0, 9_000_000, 4_000_000),
+		planTrack("3", "Existing", 30_000_000, 9_000_000, 4_000_000),
+		planTrack("4", "Archived", 30_000_000, 9_000_000, 4_000_000),
+		planTrack("5", "Gone", 0, 0, 0),
+		planTrack("6", "Lossless", 30_000_000, 9_000_000, 4_000_000),
+	}
+	plan := PlanDownloads(tracks, PlanOptions{
+		LinkType:        "album",
+		Quality:         "flac",
+		Path:            layout,
+		FallbackQuality: true,
+		CoverMode:       "file",
+		CoverFileName:   "cover.jpg",
+		Archive:         archive,
+	})
+
+	want := []struct {
+		status, quality, path string
+		size                  int64
+		fallback              bool
+	}{
+		{PlanDownload, "flac", filepath.Join(dir, "Album", "Lossless.flac"), 30_000_000, false},
+		{PlanDownload, "320", filepath.Join(dir, "Album", "Lossy.mp3"), 9_000_000, true},
+		{PlanExists, "flac", filepath.Join(dir, "Album", "Existing.flac"), 0, false},
+		{PlanArchived, "", "old/Archived.flac", 0, false},
+		{PlanUnavailable, "", "", 0, false},
+		{PlanDuplicate, "flac", filepath.Join(dir, "Album", "Lossless.flac"), 0, false},
+	}
+	if len(plan.Tracks) != len(want) {
+		t.Fatalf("planned %d tracks, want %d", len(plan.Tracks), len(want))
+	}
+	for i, item := range plan.Tracks {
+		w := want[i]
+		if item.Status != w.status || item.Quality != w.quality || item.Path != w.path || item.Size != w.size || item.QualityFallback != w.fallback {
+			t.Fatalf("track %d = %+v, want %+v", i+1, item, w)
+		}
+	}
+	if plan.Downloads != 2 || plan.Skipped != 4 || plan.TotalSize != 39_000_000 {
+		t.Fatalf("summary = %d downloads, %d skipped, %d bytes", plan.Downloads, plan.Skipped, plan.TotalSize)
+	}
+	if len(plan.CoverFiles) != 1 || plan.CoverFiles[0] != filepath.Join(dir, "Album", "cover.jpg") {
+		t.Fatalf("CoverFiles = %v", plan.CoverFiles)
+	}
+	if plan.PlaylistPath != "" {
+		t.Fatalf("PlaylistPath = %q for an album", plan.PlaylistPath)
+	}
+}
+
+func TestPlanDownloadsWithoutQualityFallback(t *testing.T) {
+	plan := PlanDownloads([]types.TrackType{planTrack("2", "Lossy", 0, 9_000_000, 4_000_000)}, PlanOptions{
+		Quality: "flac",
+		Path:    filepath.Join(t.TempDir(), "{SNG_TITLE}"),
+	})
+	if got := plan.Tracks[0].Status; got != PlanUnavailable {
+		t.Fatalf("Status = %q, want %q", got, PlanUnavailable)
+	}
+}
+
+func TestPlanDownloadsPlaylistPath(t *testing.T) {
+	dir := t.TempDir()
+	plan := PlanDownloads([]types.TrackType{
+		planTrack("1", "One", 0, 9_000_000, 0),
+		planTrack("2", "Two", 0, 9_000_000, 0),
+	}, PlanOptions{
+		LinkType: "playlist",
+		Quality:  "320",
+		Info:     map[string]any{"TITLE": "Mix"},
+		Path:     filepath.Join(dir, "{SNG_TITLE}"),
+	})
+	if want := filepath.Join(dir, "Mix.m3u8"); plan.PlaylistPath != want {
+		t.Fatalf("PlaylistPath = %q, want %q", plan.PlaylistPath, want)
+	}
+}
+
+func TestWritePlanOutputs(t *testing.T) {
+	plan := DownloadPlan{
+		LinkType: "track",
+		Tracks: []PlannedTrack{
+			{Index: 1, SngID: "1", Title: "One", Artist: "A", Path: "Music/One.mp3", Quality: "320", QualityFallback: true, Size: 2 * 1024 * 1024, Status: PlanDownload},
+			{Index: 2, SngID: "2", Title: "Two", Artist: "A", Status: PlanUnavailable},
+		},
+		Downloads: 1,
+		Skipped:   1,
+		TotalSize: 2 * 1024 * 1024,
+	}
+
+	var table bytes.Buffer
+	if err := WritePlanTable(&table, plan); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"STATUS", "320 (fallback)", "2.00MiB", "Music/One.mp3", "Two - A", "Would download 1 track (2.00MiB), skip 1"} {
+		if !strings.Contains(table.String(), want) {
+			t.Fatalf("table missing %q:\n%s", want, table.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := WritePlanJSON(&out, plan); err != nil {
+		t.Fatal(err)
+	}
+	var decoded DownloadPlan
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Tracks[0].Path != "Music/One.mp3" || decoded.Tracks[1].Status != PlanUnavailable {
+		t.Fatalf("decoded plan = %+v", decoded)
+	}
+}
+
+func TestParseOptionsDryRun(t *testing.T) {
+	tests := []struct {
+		args []string
+		want dryRunFlag
+	}{
+		{args: nil, want: ""},
+		{args: []string{"--dry-run"}, want: "table"},
+		{args: []string{"--dry-run=json"}, want: "json"},
+		{args: []string{"--dry-run=table"}, want: "table"},
+	}
+	for _, tt := range tests {
+		opts, err := parseOptions(tt.args)
+		if err != nil {
+			t.Fatalf("parseOptions(%v) error = %v", tt.args, err)
+		}
+		if opts.dryRun != tt.want {
+			t.Fatalf("parseOptions(%v).dryRun = %q, want %q", tt.args, opts.dryRun, tt.want)
+		}
+	}
+	if _, err := parseOptions([]string{"--dry-run=xml"}); err == nil {
+		t.Fatal("parseOptions accepted --dry-run=xml")
+	}
+}
+
+func TestEstimateTrackSize(t *testing.T) {
+	lossy := planTrack("1", "Lossy", 0, 9_000_000, 4_000_000)
+	if got := EstimateTrackSize(lossy, "flac", true, true); got != 9_000_000 {
+		t.Fatalf("EstimateTrackSize with quality fallback = %d", got)
+	}
+	if got := EstimateTrackSize(lossy, "flac", true, false); got != 0 {
+		t.Fatalf("EstimateTrackSize without quality fallback = %d", got)
+	}
+
+	gone := planTrack("2", "Gone", 0, 0, 0)
+	alternate := planTrack("3", "Gone", 30_000_000, 9_000_000, 4_000_000).SongType
+	gone.FALLBACK = &alternate
+	if got := EstimateTrackSize(gone, "flac", true, true); got != 30_000_000 {
+		t.Fatalf("EstimateTrackSize with track fallback = %d", got)
+	}
+	if got := EstimateTrackSize(gone, "flac", false, true); got != 0 {
+		t.Fatalf("EstimateTrackSize without track fallback = %d", got)
+	}
+}
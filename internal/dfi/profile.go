@@ -0,0 +1,80 @@
+package dfi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/converter"
+	"github.com/d-fi/GoFi/utils"
+)
+
+// mePrefix starts inputs such as "me:loved" that stand for the logged-in
+// user's profile.
+const mePrefix = "me:"
+
+// fetchCurrentUser loads the account the ARL belongs to, whose library me:
+// inputs read.
+var fetchCurrentUser = api.GetUser
+
+// IsMeInput reports whether input is a "me:" input.
+func IsMeInput(input string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(input)), mePrefix)
+}
+
+// MeProfileURL turns "me:loved", "me:playlists", "me:albums", "me:artists" or
+// "me:flow" into the profile link of the logged-in user.
+func MeProfileURL(input string) (string, error) {
+	tab := strings.ToLower(strings.TrimSpace(input))
+	tab = strings.TrimSpace(strings.TrimPrefix(tab, mePrefix))
+	if !slices.Contains(converter.ProfileTabs(), tab) {
+		return "", fmt.Errorf("unknown input %q, use me:loved, me:playlists, me:albums, me:artists or me:flow", input)
+	}
+	user, err := fetchCurrentUser()
+	if err != nil {
+		return "", fmt.Errorf("unable to load the current user: %w", err)
+	}
+	if user.UserID == "" || user.UserID == "0" {
+		return "", fmt.Errorf("%s needs a logged-in Deezer account", input)
+	}
+	return converter.ProfileURL(user.UserID, tab), nil
+}
+
+// GroupTitle names one group of a profile link, such as a playlist title or
+// an artist name.
+func GroupTitle(group ResolvedInput) string {
+	data := utils.StructMap(group.LinkInfo)
+	for _, key := range []string{"TITLE", "ALB_TITLE", "ART_NAME"} {
+		if value, ok := data[key]; ok && fmt.Sprint(value) != "" {
+			return fmt.Sprint(value)
+		}
+	}
+	return group.LinkType + " " + group.Info.ID
+}
+
+func countGroupTracks(groups []ResolvedInput) int {
+	total := 0
+	for _, group := range groups {
+		total += len(group.Tracks)
+	}
+	return total
+}
+
+func promptGroups(w io.Writer, reader *bufio.Reader, groups []ResolvedInput) ([]ResolvedInput, error) {
+	fmt.Fprintf(w, "Select what to download. Total of %d %ss with %d tracks.\n", len(groups), groups[0].LinkType, countGroupTracks(groups))
+	for i, group := range groups {
+		fmt.Fprintf(w, "%d) %s - %d tracks\n", i+1, GroupTitle(group), len(group.Tracks))
+	}
+	indexes, err := readSelection(w, reader, len(groups))
+	if err != nil {
+		return nil, err
+	}
+	selected := make([]ResolvedInput, 0, len(indexes))
+	for _, index := range indexes {
+		selected = append(selected, groups[index])
+	}
+	return selected, nil
+}
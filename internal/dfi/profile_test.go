@@ -0,0 +1,95 @@
+package dfi
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+)
+
+func stubCurrentUser(t *testing.T, user types.UserType, err error) {
+	t.Helper()
+	original := fetchCurrentUser
+	fetchCurrentUser = func() (types.UserType, error) { return user, err }
+	t.Cleanup(func() { fetchCurrentUser = original })
+}
+
+func TestMeProfileURL(t *testing.T) {
+	stubCurrentUser(t, types.UserType{UserID: "2064440442"}, nil)
+
+	if !IsMeInput(" ME:Playlists") || IsMeInput("artist:Daft Punk") {
+		t.Fatal("IsMeInput did not tell me: inputs apart")
+	}
+	got, err := MeProfileURL(" ME:Playlists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://www.deezer.com/profile/2064440442/playlists"; got != want {
+		t.Fatalf("MeProfileURL = %q, want %q", got, want)
+	}
+	got, err = MeProfileURL("me:flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://www.deezer.com/profile/2064440442/flow"; got != want {
+		t.Fatalf("MeProfileURL = %q, want %q", got, want)
+	}
+	if _, err := MeProfileURL("me:followers"); err == nil {
+		t.Fatal("expected an unknown tab to be rejected")
+	}
+}
+
+func TestMeProfileURLNeedsUser(t *testing.T) {
+	stubCurrentUser(t, types.UserType{UserID: "0"}, nil)
+	if _, err := MeProfileURL("me:loved"); err == nil {
+		t.Fatal("expected a logged-out session to be rejected")
+	}
+
+	stubCurrentUser(t, types.UserType{}, errors.New("offline"))
+	if _, err := MeProfileURL("me:loved"); err == nil || !strings.Contains(err.Error(), "offline") {
+		t.Fatalf("MeProfileURL error = %v", err)
+	}
+}
+
+func testGroup(title string, ids ...string) ResolvedInput {
+	group := ResolvedInput{LinkType: "playlist", LinkInfo: types.PlaylistInfo{Title: title}}
+	for _, id := range ids {
+		var track types.TrackType
+		track.SNG_ID = id
+		group.Tracks = append(group.Tracks, track)
+	}
+	return group
+}
+
+func TestSelectGroupsByIndexes(t *testing.T) {
+	groups := []ResolvedInput{testGroup("One", "1", "2"), testGroup("Two", "3"), testGroup("Three", "4", "5")}
+
+	if got := SelectGroupsByIndexes(groups, nil); len(got) != 3 {
+		t.Fatalf("no indexes kept %d groups, want 3", len(got))
+	}
+	got := SelectGroupsByIndexes(groups, []int{1, 4})
+	if len(got) != 2 || GroupTitle(got[0]) != "One" || GroupTitle(got[1]) != "Three" {
+		t.Fatalf("selected groups = %+v", got)
+	}
+	if got[0].Tracks[0].SNG_ID != "2" || got[1].Tracks[0].SNG_ID != "5" || len(got[1].Tracks) != 1 {
+		t.Fatalf("selected tracks = %+v, %+v", got[0].Tracks, got[1].Tracks)
+	}
+}
+
+func TestPromptGroups(t *testing.T) {
+	groups := []ResolvedInput{testGroup("One", "1"), testGroup("Two", "2"), testGroup("Three", "3")}
+	var out bytes.Buffer
+	got, err := promptGroups(&out, bufio.NewReader(strings.NewReader("3, 1-1\n")), groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "2) Two - 1 tracks") {
+		t.Fatalf("prompt was not written to the given writer: %q", out.String())
+	}
+	if len(got) != 2 || GroupTitle(got[0]) != "Three" || GroupTitle(got[1]) != "One" {
+		t.Fatalf("promptGroups = %+v", got)
+	}
+}
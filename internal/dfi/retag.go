@@ -0,0 +1,363 @@
+package dfi
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/d-fi/GoFi/metadata"
+	"github.com/d-fi/GoFi/utils"
+)
+
+const (
+	retagSkipNoSourceID = "no SOURCEID tag"
+	retagSkipUpToDate   = "tags already up to date"
+)
+
+// retagValueWidth caps how much of a tag value a dry-run diff prints.
+const retagValueWidth = 60
+
+type retagOptions struct {
+	paths       []string
+	fields      []string
+	dryRun      bool
+	configFile  string
+	concurrency int
+}
+
+// retagCandidate is an audio file named on the command line or found in a
+// directory that was.
+type retagCandidate struct {
+	path     string
+	sngID    string
+	readErr  error
+	skipNote string
+}
+
+// retagResult is the outcome for a single file.
+type retagResult struct {
+	path    string
+	changes []metadata.TagChange
+	skipped string
+	err     error
+}
+
+// RunRetag implements `d-fi retag <path...>`: it looks up the SOURCEID of
+// every MP3 and FLAC file under the paths and writes fresh track, album and
+// lyrics tags and covers over the old ones. The audio frames stay as they are.
+func RunRetag(ctx context.Context, args []string) error {
+	opts, err := parseRetagOptions(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	printBanner(os.Stdout)
+	cfg := LoadConfig(opts.configFile)
+	if cfg.UserConfigLocation != "" {
+		fmt.Println(info("Config loaded --> " + cfg.UserConfigLocation))
+	}
+
+	candidates, err := scanRetagCandidates(opts.paths)
+	if err != nil {
+		return err
+	}
+	fmt.Println(info(fmt.Sprintf("Found %d audio %s", len(candidates), plural("file", len(candidates)))))
+
+	var pending []retagCandidate
+	var results []retagResult
+	for _, candidate := range candidates {
+		switch {
+		case candidate.readErr != nil:
+			results = append(results, retagResult{path: candidate.path, err: candidate.readErr})
+		case candidate.skipNote != "":
+			results = append(results, retagResult{path: candidate.path, skipped: candidate.skipNote})
+		default:
+			pending = append(pending, candidate)
+		}
+	}
+
+	if len(pending) > 0 {
+		if err := initSession(os.Stdout, cfg); err != nil {
+			return err
+		}
+		concurrency := opts.concurrency
+		if concurrency <= 0 {
+			concurrency = cfg.Concurrency
+		}
+		results = append(results, retagAll(ctx, cfg, pending, opts, max(concurrency, 1))...)
+	}
+
+	printRetagReport(os.Stdout, results, opts.dryRun)
+	return retagFailures(results)
+}
+
+// retagFailures returns an error when any file could not be retagged, so the
+// command exits non-zero.
+func retagFailures(results []retagResult) error {
+	failed := 0
+	for _, result := range results {
+		if result.err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("unable to retag %d %s", failed, plural("file", failed))
+}
+
+func parseRetagOptions(args []string) (retagOptions, error) {
+	var opts retagOptions
+	var fields string
+	fs := flag.NewFlagSet("d-fi retag", flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage of d-fi retag <path...>:")
+		fmt.Fprintln(w, "  --fields <list>               Only refresh these tags, comma separated:")
+		fmt.Fprintln(w, "                                "+strings.Join(metadata.TagFields(), ", "))
+		fmt.Fprintln(w, "  --dry-run                     Print the tag changes without writing them")
+		fmt.Fprintln(w, "  -c, --concurrency <number>    Number of files retagged at once")
+		fmt.Fprintln(w, "  -conf, --config-file <file>   Custom location to your config file")
+	}
+	fs.StringVar(&fields, "fields", "", "Only refresh these tags, comma separated")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "Print the tag changes without writing them")
+	fs.IntVar(&opts.concurrency, "concurrency", 0, "Number of files retagged at once")
+	fs.IntVar(&opts.concurrency, "c", 0, "Number of files retagged at once")
+	fs.StringVar(&opts.configFile, "config-file", "d-fi.config.json", "Custom location to your config file")
+	fs.StringVar(&opts.configFile, "conf", "d-fi.config.json", "Custom location to your config file")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return opts, fmt.Errorf("retag needs at least one file or directory")
+	}
+	var err error
+	if opts.fields, err = metadata.ParseTagFields(fields); err != nil {
+		return opts, err
+	}
+	opts.paths = fs.Args()
+	return opts, nil
+}
+
+// scanRetagCandidates lists the MP3 and FLAC files among paths, walking the
+// directories, with their SOURCEID. Partial and upgrade staging files are left
+// out.
+func scanRetagCandidates(paths []string) ([]retagCandidate, error) {
+	var candidates []retagCandidate
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || utils.IsPartialFile(entry.Name()) {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if ext != ".mp3" && ext != ".flac" {
+				return nil
+			}
+			if strings.HasSuffix(strings.TrimSuffix(path, filepath.Ext(path)), upgradeStagingSuffix) {
+				return nil
+			}
+
+			candidate := retagCandidate{path: path}
+			candidate.sngID, candidate.readErr = metadata.SourceID(path)
+			if candidate.readErr == nil && candidate.sngID == "" {
+				candidate.skipNote = retagSkipNoSourceID
+			}
+			candidates = append(candidates, candidate)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return candidates, nil
+}
+
+func retagAll(ctx context.Context, cfg Config, candidates []retagCandidate, opts retagOptions, concurrency int) []retagResult {
+	jobs := make(chan int)
+	results := make([]retagResult, len(candidates))
+	var wg sync.WaitGroup
+	for range min(len(candidates), concurrency) {
+		wg.Go(func() {
+			for index := range jobs {
+				results[index] = retagFile(ctx, cfg, candidates[index], opts)
+			}
+		})
+	}
+	for index := range candidates {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// retagFile tags a copy of the file's audio from scratch, keeps the tags
+// outside opts.fields as they were and writes the result back unless this is
+// a dry run.
+func retagFile(ctx context.Context, cfg Config, candidate retagCandidate, opts retagOptions) retagResult {
+	result := retagResult{path: candidate.path}
+	if err := ctx.Err(); err != nil {
+		result.err = err
+		return result
+	}
+	track, err := fetchTrackInfo(candidate.sngID)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	stat, err := os.Stat(candidate.path)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	data, err := os.ReadFile(candidate.path)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	quality, err := fileQuality(candidate.path)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	_, label := trackFormat(track, quality)
+
+	audio, err := metadata.StripTags(data)
+	if err != nil {
+		result.err = fmt.Errorf("%s: %w", candidate.path, err)
+		return result
+	}
+	fresh, err := metadata.AddTrackTags(audio, track, metadata.TagOptions{
+		CoverSize: CoverSizeForQuality(cfg.CoverSize, label),
+		CoverMode: cfg.Cover.Mode,
+		Lyrics:    cfg.Lyrics.EmbedModes(),
+		Profile:   cfg.Tags,
+	})
+	if err != nil {
+		result.err = err
+		return result
+	}
+	tagged, err := metadata.MergeTags(data, fresh, opts.fields, cfg.Tags)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.changes, err = tagChanges(data, tagged)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	if len(result.changes) == 0 {
+		result.skipped = retagSkipUpToDate
+	}
+	if opts.dryRun {
+		return result
+	}
+	if err := ctx.Err(); err != nil {
+		result.err = err
+		return result
+	}
+
+	if len(result.changes) > 0 {
+		if err := utils.WriteFileAtomic(candidate.path, tagged, stat.Mode().Perm()); err != nil {
+			result.err = err
+			return result
+		}
+	}
+	refreshLyrics := len(opts.fields) == 0 || slices.Contains(opts.fields, "lyrics")
+	if refreshLyrics && metadata.ShouldSaveLyricsFile(cfg.Lyrics.Modes) {
+		if _, err := metadata.SaveLyricsFile(candidate.path, track); err != nil {
+			result.err = err
+		}
+	}
+	return result
+}
+
+func tagChanges(before, after []byte) ([]metadata.TagChange, error) {
+	old, err := metadata.ListTags(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := metadata.ListTags(after)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.DiffTags(old, updated), nil
+}
+
+func printRetagReport(w io.Writer, results []retagResult, dryRun bool) {
+	var retagged, skipped, failed []retagResult
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			failed = append(failed, result)
+		case result.skipped != "":
+			skipped = append(skipped, result)
+		default:
+			retagged = append(retagged, result)
+		}
+	}
+
+	if dryRun {
+		fmt.Fprintln(w, success(fmt.Sprintf("Would retag %d %s", len(retagged), plural("file", len(retagged)))))
+		for _, result := range retagged {
+			fmt.Fprintln(w, info(result.path))
+			for _, change := range result.changes {
+				fmt.Fprintln(w, note(fmt.Sprintf("%s: %s -> %s", change.Key, formatTagValues(change.Before), formatTagValues(change.After))))
+			}
+		}
+	} else {
+		fmt.Fprintln(w, success(fmt.Sprintf("Retagged %d %s", len(retagged), plural("file", len(retagged)))))
+		for _, result := range retagged {
+			fmt.Fprintln(w, note(fmt.Sprintf("%s (%d %s changed)", result.path, len(result.changes), plural("tag", len(result.changes)))))
+		}
+	}
+
+	if len(skipped) > 0 {
+		notes := make([]string, 0, len(skipped))
+		for _, result := range skipped {
+			notes = append(notes, result.skipped)
+		}
+		fmt.Fprintln(w, info(fmt.Sprintf("Skipped %d %s (%s)", len(skipped), plural("file", len(skipped)), countReasons(notes))))
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintln(w, failure(fmt.Sprintf("Failed %d %s", len(failed), plural("file", len(failed)))))
+		for _, result := range failed {
+			fmt.Fprintln(w, note(fmt.Sprintf("%s: %v", result.path, result.err)))
+		}
+	}
+}
+
+// formatTagValues quotes the values of one tag for a diff line, shortening
+// long ones such as lyrics.
+func formatTagValues(values []string) string {
+	if len(values) == 0 {
+		return "(none)"
+	}
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		if utf8.RuneCountInString(value) > retagValueWidth {
+			value = string([]rune(value)[:retagValueWidth]) + "…"
+		}
+		quoted[i] = strconv.Quote(value)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,101 @@
+package dfi
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/d-fi/GoFi/metadata"
+)
+
+func TestParseRetagOptions(t *testing.T) {
+	opts, err := parseRetagOptions([]string{"--fields", "cover,lyrics", "--dry-run", "a.flac", "Music"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.dryRun || !reflect.DeepEqual(opts.fields, []string{"cover", "lyrics"}) || !reflect.DeepEqual(opts.paths, []string{"a.flac", "Music"}) {
+		t.Fatalf("parseRetagOptions() = %+v", opts)
+	}
+	if _, err := parseRetagOptions([]string{"--fields", "mood", "Music"}); err == nil {
+		t.Fatal("expected an unknown field to be rejected")
+	}
+}
+
+func TestScanRetagCandidates(t *testing.T) {
+	dir := t.TempDir()
+	album := filepath.Join(dir, "Album")
+	if err := os.MkdirAll(album, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeUpgradeMP3(t, filepath.Join(album, "a.mp3"), "3135556", 0xE0)
+	writeUpgradeMP3(t, filepath.Join(album, "b.mp3"), "", 0xE0)
+	writeUpgradeFlac(t, filepath.Join(album, "c"+upgradeStagingSuffix+".flac"), "3135557")
+	if err := os.WriteFile(filepath.Join(album, "cover.jpg"), []byte("jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	single := filepath.Join(dir, "single.flac")
+	writeUpgradeFlac(t, single, "3135558")
+
+	candidates, err := scanRetagCandidates([]string{album, single})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []retagCandidate{
+		{path: filepath.Join(album, "a.mp3"), sngID: "3135556"},
+		{path: filepath.Join(album, "b.mp3"), skipNote: retagSkipNoSourceID},
+		{path: single, sngID: "3135558"},
+	}
+	if !reflect.DeepEqual(candidates, want) {
+		t.Fatalf("scanRetagCandidates() = %+v", candidates)
+	}
+}
+
+func TestPrintRetagReport(t *testing.T) {
+	results := []retagResult{
+		{path: "a.flac", changes: []metadata.TagChange{
+			{Key: "GENRE", Before: []string{"Pop"}, After: []string{"Dance", "Electro"}},
+			{Key: "LYRICS", After: []string{strings.Repeat("la ", 30)}},
+		}},
+		{path: "b.mp3", skipped: retagSkipNoSourceID},
+		{path: "c.mp3", skipped: retagSkipUpToDate},
+		{path: "d.mp3", err: errors.New("boom")},
+	}
+
+	var out bytes.Buffer
+	printRetagReport(&out, results, true)
+	report := out.String()
+	for _, want := range []string{
+		"Would retag 1 file",
+		"a.flac",
+		`GENRE: "Pop" -> "Dance", "Electro"`,
+		`LYRICS: (none) -> "` + strings.Repeat("la ", 20) + `…"`,
+		"Skipped 2 files (no SOURCEID tag: 1, tags already up to date: 1)",
+		"Failed 1 file",
+		"d.mp3: boom",
+	} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("dry-run report missing %q:\n%s", want, report)
+		}
+	}
+
+	out.Reset()
+	printRetagReport(&out, results, false)
+	if report := out.String(); !strings.Contains(report, "Retagged 1 file") || !strings.Contains(report, "a.flac (2 tags changed)") {
+		t.Fatalf("report:\n%s", report)
+	}
+}
+
+func TestRetagFailures(t *testing.T) {
+	results := []retagResult{{path: "a.flac"}, {path: "b.mp3", skipped: retagSkipNoSourceID}}
+	if err := retagFailures(results); err != nil {
+		t.Fatalf("retagFailures without failures = %v", err)
+	}
+	results = append(results, retagResult{path: "c.mp3", err: errors.New("boom")}, retagResult{path: "d.mp3", err: errors.New("boom")})
+	if err := retagFailures(results); err == nil || err.Error() != "unable to retag 2 files" {
+		t.Fatalf("retagFailures = %v, want 2 failed files", err)
+	}
+}
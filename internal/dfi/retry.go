@@ -0,0 +1,125 @@
+package dfi
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/d-fi/GoFi/download"
+)
+
+// RetryConfig is the retry policy as written in the config file.
+type RetryConfig struct {
+	MaxAttempts  int      `json:"maxAttempts"`
+	InitialDelay string   `json:"initialDelay"`
+	MaxDelay     string   `json:"maxDelay"`
+	RetryOn      []string `json:"retryOn"`
+}
+
+func defaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:  3,
+		InitialDelay: "1s",
+		MaxDelay:     "30s",
+		RetryOn: []string{
+			string(download.ErrorNetwork),
+			string(download.ErrorCDN),
+			string(download.ErrorTokenExpired),
+		},
+	}
+}
+
+// NormalizeRetryConfig applies the valid fields of value on top of fallback.
+// Unknown error classes in retryOn are dropped.
+func NormalizeRetryConfig(value, fallback RetryConfig) RetryConfig {
+	out := fallback
+	if value.MaxAttempts > 0 {
+		out.MaxAttempts = value.MaxAttempts
+	}
+	if delay, err := time.ParseDuration(strings.TrimSpace(value.InitialDelay)); err == nil && delay >= 0 {
+		out.InitialDelay = strings.TrimSpace(value.InitialDelay)
+	}
+	if delay, err := time.ParseDuration(strings.TrimSpace(value.MaxDelay)); err == nil && delay >= 0 {
+		out.MaxDelay = strings.TrimSpace(value.MaxDelay)
+	}
+	if value.RetryOn != nil {
+		out.RetryOn = []string{}
+		for _, class := range value.RetryOn {
+			class = strings.ToLower(strings.TrimSpace(class))
+			if slices.Contains(download.ErrorClasses, download.ErrorClass(class)) && !slices.Contains(out.RetryOn, class) {
+				out.RetryOn = append(out.RetryOn, class)
+			}
+		}
+	}
+	return out
+}
+
+// Policy converts the config into the policy used by DownloadTrack.
+func (r RetryConfig) Policy() RetryPolicy {
+	initial, _ := time.ParseDuration(r.InitialDelay)
+	maxDelay, _ := time.ParseDuration(r.MaxDelay)
+	policy := RetryPolicy{
+		MaxAttempts:  r.MaxAttempts,
+		InitialDelay: initial,
+		MaxDelay:     maxDelay,
+	}
+	for _, class := range r.RetryOn {
+		policy.RetryOn = append(policy.RetryOn, download.ErrorClass(class))
+	}
+	return policy
+}
+
+// RetryPolicy controls how often a track download is retried. The zero value
+// never retries.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	RetryOn      []download.ErrorClass
+}
+
+// retries reports whether errors of class are retried at all.
+func (p RetryPolicy) retries(class download.ErrorClass) bool {
+	return p.MaxAttempts > 1 && class != download.ErrorCanceled && slices.Contains(p.RetryOn, class)
+}
+
+// allows reports whether another attempt may follow the given failed attempt.
+func (p RetryPolicy) allows(class download.ErrorClass, attempt int) bool {
+	return p.retries(class) && attempt < p.MaxAttempts
+}
+
+// backoff returns the delay before the attempt after attempt: exponential
+// growth from InitialDelay, capped at MaxDelay, with up to half of it jittered.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for range attempt - 1 {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 {
+		delay = min(delay, p.MaxDelay)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,110 @@
+package dfi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-fi/GoFi/download"
+)
+
+func TestNormalizeRetryConfig(t *testing.T) {
+	got := NormalizeRetryConfig(RetryConfig{
+		MaxAttempts:  5,
+		InitialDelay: "soon",
+		MaxDelay:     "10s",
+		RetryOn:      []string{"network", "NETWORK", "teapot", "geo"},
+	}, defaultRetryConfig())
+	want := RetryConfig{
+		MaxAttempts:  5,
+		InitialDelay: "1s",
+		MaxDelay:     "10s",
+		RetryOn:      []string{"network", "geo"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("NormalizeRetryConfig() = %+v, want %+v", got, want)
+	}
+
+	if got := NormalizeRetryConfig(RetryConfig{}, defaultRetryConfig()); !reflect.DeepEqual(got, defaultRetryConfig()) {
+		t.Fatalf("empty retry config should keep defaults, got %+v", got)
+	}
+	if got := NormalizeRetryConfig(RetryConfig{RetryOn: []string{}}, defaultRetryConfig()); len(got.RetryOn) != 0 {
+		t.Fatalf("empty retryOn should disable every class, got %v", got.RetryOn)
+	}
+}
+
+func TestRetryPolicyAllows(t *testing.T) {
+	policy := defaultRetryConfig().Policy()
+	if !policy.allows(download.ErrorNetwork, 1) || !policy.allows(download.ErrorNetwork, 2) {
+		t.Fatal("network errors should be retried until the last attempt")
+	}
+	if policy.allows(download.ErrorNetwork, 3) {
+		t.Fatal("no attempt should follow the last one")
+	}
+	if policy.allows(download.ErrorGeo, 1) {
+		t.Fatal("geo errors are not retried by default")
+	}
+	if (RetryPolicy{}).retries(download.ErrorNetwork) {
+		t.Fatal("the zero policy should never retry")
+	}
+	policy.RetryOn = append(policy.RetryOn, download.ErrorCanceled)
+	if policy.retries(download.ErrorCanceled) {
+		t.Fatal("canceled downloads should never be retried")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, ceiling := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		for range 20 {
+			delay := policy.backoff(attempt)
+			if delay < ceiling/2 || delay > ceiling {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", attempt, delay, ceiling/2, ceiling)
+			}
+		}
+	}
+}
+
+func TestDownloadToTempClassifiesHTTPErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		want   download.ErrorClass
+	}{
+		{status: http.StatusForbidden, want: download.ErrorCDN},
+		{status: http.StatusNotFound, want: download.ErrorCDN},
+		{status: http.StatusServiceUnavailable, want: download.ErrorNetwork},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		err := downloadToTemp(context.Background(), &download.TrackDownloadUrl{TrackUrl: server.URL, FileSize: 10}, filepath.Join(t.TempDir(), "d-fi_tmp"), nil)
+		server.Close()
+		if got := download.Classify(err); got != tt.want {
+			t.Fatalf("status %d classified as %q, want %q (err=%v)", tt.status, got, tt.want, err)
+		}
+	}
+}
+
+func TestPrintFailureSummary(t *testing.T) {
+	var out bytes.Buffer
+	printFailureSummary(&out, []trackFailure{
+		{title: "One", class: download.ErrorCDN, err: errors.New("403 Forbidden")},
+		{title: "Two", class: download.ErrorNetwork, err: errors.New("timeout")},
+		{title: "Three", class: download.ErrorNetwork, err: errors.New("reset")},
+	})
+	text := out.String()
+	if !strings.Contains(text, "Failed 3 tracks (network: 2, cdn: 1)") {
+		t.Fatalf("summary = %q", text)
+	}
+	if !strings.Contains(text, "[cdn] One: 403 Forbidden") {
+		t.Fatalf("summary does not list each track: %q", text)
+	}
+}
@@ -19,6 +19,9 @@ type ResolvedInput struct {
 	LinkType string
 	LinkInfo any
 	Tracks   []types.TrackType
+	// Groups splits a link that stands for several downloads, such as every
+	// playlist of a profile, into one input each.
+	Groups []ResolvedInput
 }
 
 type SearchOption struct {
@@ -27,17 +30,25 @@ type SearchOption struct {
 	URL         string `json:"url"`
 }
 
-func ParseResolvedURL(rawURL string) (ResolvedInput, error) {
-	data, err := converter.ParseInfo(rawURL)
+func ParseResolvedURL(rawURL string, options converter.ParseOptions) (ResolvedInput, error) {
+	data, err := converter.ParseInfoWithOptions(rawURL, options)
 	if err != nil {
 		return ResolvedInput{}, err
 	}
-	return ResolvedInput{
+	return resolvedFromParse(data), nil
+}
+
+func resolvedFromParse(data converter.ParseResult) ResolvedInput {
+	resolved := ResolvedInput{
 		Info:     data.Info,
 		LinkType: data.LinkType,
 		LinkInfo: data.LinkInfo,
 		Tracks:   data.Tracks,
-	}, nil
+	}
+	for _, group := range data.Groups {
+		resolved.Groups = append(resolved.Groups, resolvedFromParse(group))
+	}
+	return resolved
 }
 
 func ResolveTrackSearch(query string) (ResolvedInput, error) {
@@ -105,11 +116,33 @@ func SearchOptions(searchType, query string, limit int) ([]SearchOption, error)
 			})
 		}
 		return options, nil
+	case "radio":
+		search, err := api.SearchMusic(query, limit, "RADIO")
+		if err != nil {
+			return nil, err
+		}
+		options := make([]SearchOption, 0, len(search.RADIO.Data))
+		for _, item := range search.RADIO.Data {
+			options = append(options, SearchOption{
+				Title:       item.TITLE,
+				Description: RadioDescription(item),
+				URL:         "https://deezer.com/us/radio/" + item.RADIO_ID,
+			})
+		}
+		return options, nil
 	default:
 		return nil, fmt.Errorf("unsupported search type: %s", searchType)
 	}
 }
 
+// RadioDescription describes a mix found by search by its tags.
+func RadioDescription(radio types.RadioType) string {
+	if len(radio.TAGS) == 0 {
+		return "mix"
+	}
+	return "mix, " + strings.Join(radio.TAGS, ", ")
+}
+
 func FirstSearchResultURL(searchType, query string) (string, error) {
 	options, err := SearchOptions(searchType, query, 1)
 	if err != nil {
@@ -147,3 +180,32 @@ func ParseQualityStrict(value string) (quality int, ext string, label string, er
 		return 0, "", "", fmt.Errorf("invalid quality: %s", value)
 	}
 }
+
+// SelectGroupsByIndexes keeps the tracks of groups whose position in all the
+// groups' tracks, in order, is in indexes. Groups left without tracks are
+// dropped. No indexes keeps every group whole.
+func SelectGroupsByIndexes(groups []ResolvedInput, indexes []int) []ResolvedInput {
+	if len(indexes) == 0 {
+		return groups
+	}
+	wanted := map[int]bool{}
+	for _, index := range indexes {
+		wanted[index] = true
+	}
+	out := make([]ResolvedInput, 0, len(groups))
+	offset := 0
+	for _, group := range groups {
+		selected := group
+		selected.Tracks = nil
+		for i, track := range group.Tracks {
+			if wanted[offset+i] {
+				selected.Tracks = append(selected.Tracks, track)
+			}
+		}
+		offset += len(group.Tracks)
+		if len(selected.Tracks) > 0 {
+			out = append(out, selected)
+		}
+	}
+	return out
+}
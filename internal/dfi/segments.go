@@ -0,0 +1,315 @@
+package dfi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d-fi/GoFi/decrypt"
+	"github.com/d-fi/GoFi/download"
+)
+
+const (
+	// downloadSegmentCount is the number of parallel ranges used for large tracks.
+	downloadSegmentCount = 4
+	// segmentedDownloadMinSize keeps small MP3s on a single connection, where
+	// extra requests cost more than they save.
+	segmentedDownloadMinSize = 8 * 1024 * 1024
+	segmentStateSuffix       = ".segments"
+)
+
+var (
+	errRangeNotSupported = errors.New("server does not support range requests")
+	// errSegmentSizeMismatch means the server holds a different file than
+	// the one the segments were planned for, so the ranges on disk are stale.
+	errSegmentSizeMismatch = errors.New("server file size does not match")
+)
+
+// downloadSegment is a byte range of the temp file. End is exclusive and Done
+// counts the bytes already written from Start.
+type downloadSegment struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+func (segment downloadSegment) remaining() int64 {
+	return segment.End - segment.Start - segment.Done
+}
+
+// segmentState is persisted next to the temp file so an interrupted download
+// only fetches the ranges that are still missing.
+type segmentState struct {
+	Size     int64             `json:"size"`
+	Segments []downloadSegment `json:"segments"`
+}
+
+func segmentStatePath(tmpFile string) string {
+	return tmpFile + segmentStateSuffix
+}
+
+// splitDownloadSegments divides size bytes into at most count ranges. Every
+// boundary falls on a decryption stripe chunk, so each range holds whole chunks.
+func splitDownloadSegments(size int64, count int) []downloadSegment {
+	chunks := (size + decrypt.StripeChunkSize - 1) / decrypt.StripeChunkSize
+	if count < 1 {
+		count = 1
+	}
+	if int64(count) > chunks {
+		count = int(max(chunks, 1))
+	}
+
+	segments := make([]downloadSegment, 0, count)
+	perSegment := chunks / int64(count)
+	extra := chunks % int64(count)
+	var start int64
+	for i := 0; i < count; i++ {
+		length := perSegment
+		if int64(i) < extra {
+			length++
+		}
+		end := min(start+length*decrypt.StripeChunkSize, size)
+		segments = append(segments, downloadSegment{Start: start, End: end})
+		start = end
+	}
+	return segments
+}
+
+func loadSegmentState(tmpFile string, size int64) (*segmentState, bool) {
+	data, err := os.ReadFile(segmentStatePath(tmpFile))
+	if err != nil {
+		return nil, false
+	}
+	var state segmentState
+	if err := json.Unmarshal(data, &state); err != nil || state.Size != size || len(state.Segments) == 0 {
+		return nil, false
+	}
+	if stat, err := os.Stat(tmpFile); err != nil || stat.Size() != size {
+		return nil, false
+	}
+	var next int64
+	for _, segment := range state.Segments {
+		if segment.Start != next || segment.End <= segment.Start || segment.Done < 0 || segment.remaining() < 0 {
+			return nil, false
+		}
+		next = segment.End
+	}
+	if next != size {
+		return nil, false
+	}
+	return &state, true
+}
+
+func (state *segmentState) save(tmpFile string) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(segmentStatePath(tmpFile), data, 0644)
+}
+
+func (state *segmentState) transferred() int64 {
+	var total int64
+	for _, segment := range state.Segments {
+		total += segment.Done
+	}
+	return total
+}
+
+// useSegmentedDownload reports whether tmpFile should be fetched in parallel
+// ranges. A plain partial temp file without segment state keeps resuming over
+// one connection.
+func useSegmentedDownload(trackData *download.TrackDownloadUrl, tmpFile string) bool {
+	if trackData.FileSize < segmentedDownloadMinSize {
+		return false
+	}
+	if _, err := os.Stat(segmentStatePath(tmpFile)); err == nil {
+		return true
+	}
+	_, err := os.Stat(tmpFile)
+	return os.IsNotExist(err)
+}
+
+func removeSegmentedTemp(tmpFile string) {
+	_ = os.Remove(tmpFile)
+	_ = os.Remove(segmentStatePath(tmpFile))
+}
+
+// segmentProgress merges the progress of all segments into one callback and
+// keeps the resume state in step with what has been written.
+type segmentProgress struct {
+	mu                 sync.Mutex
+	state              *segmentState
+	tmpFile            string
+	onProgress         func(transferred, total int64)
+	transferred        int64
+	lastPrinted        int64
+	lastProgressUpdate time.Time
+}
+
+func (progress *segmentProgress) add(index int, n int64) {
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+
+	progress.state.Segments[index].Done += n
+	progress.transferred += n
+	total := progress.state.Size
+	now := time.Now()
+	completed := progress.transferred >= total
+	if (progress.transferred-progress.lastPrinted > 50000 && now.Sub(progress.lastProgressUpdate) >= time.Second) || completed {
+		progress.lastPrinted = progress.transferred
+		progress.lastProgressUpdate = now
+		_ = progress.state.save(progress.tmpFile)
+		if progress.onProgress != nil {
+			progress.onProgress(progress.transferred, total)
+		}
+	}
+}
+
+func (progress *segmentProgress) save() error {
+	progress.mu.Lock()
+	defer progress.mu.Unlock()
+	return progress.state.save(progress.tmpFile)
+}
+
+// downloadSegmentsToTemp fetches trackData into tmpFile over several ranged
+// requests. It returns errRangeNotSupported, with nothing left on disk, when the
+// server answers a range with the whole file, and errSegmentSizeMismatch when
+// it serves a file of another size. Any other failure keeps the partial file
+// and its state, so the next attempt only fetches the missing ranges.
+func downloadSegmentsToTemp(ctx context.Context, trackData *download.TrackDownloadUrl, tmpFile string, onProgress func(transferred, total int64)) error {
+	size := trackData.FileSize
+	state, ok := loadSegmentState(tmpFile, size)
+	if !ok {
+		removeSegmentedTemp(tmpFile)
+		state = &segmentState{Size: size, Segments: splitDownloadSegments(size, downloadSegmentCount)}
+	}
+
+	out, err := os.OpenFile(tmpFile, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := out.Truncate(size); err != nil {
+		return err
+	}
+	if err := state.save(tmpFile); err != nil {
+		return err
+	}
+
+	progress := &segmentProgress{
+		state:              state,
+		tmpFile:            tmpFile,
+		onProgress:         onProgress,
+		transferred:        state.transferred(),
+		lastProgressUpdate: time.Now().Add(-time.Second),
+	}
+	progress.lastPrinted = progress.transferred
+
+	segmentCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+	for index, segment := range state.Segments {
+		if segment.remaining() == 0 {
+			continue
+		}
+		wg.Go(func() {
+			if err := fetchDownloadSegment(segmentCtx, trackData.TrackUrl, out, segment, index, progress); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		})
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		out.Close()
+		if errors.Is(firstErr, errRangeNotSupported) || errors.Is(firstErr, errSegmentSizeMismatch) {
+			removeSegmentedTemp(tmpFile)
+			return firstErr
+		}
+		// Keep the partial file and its state so the next attempt resumes.
+		_ = progress.save()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return firstErr
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(segmentStatePath(tmpFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func fetchDownloadSegment(ctx context.Context, url string, out io.WriterAt, segment downloadSegment, index int, progress *segmentProgress) error {
+	offset := segment.Start + segment.Done
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, segment.End-1))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("download failed: %w", download.StatusError(resp))
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return errRangeNotSupported
+	}
+	if total, ok := contentRangeTotal(resp.Header.Get("Content-Range")); ok && total != progress.state.Size {
+		return fmt.Errorf("%w: %d bytes, expected %d", errSegmentSizeMismatch, total, progress.state.Size)
+	}
+
+	buffer := make([]byte, 32*1024)
+	body := downloadLimiter.Reader(ctx, io.LimitReader(resp.Body, segment.End-offset))
+	for offset < segment.End {
+		n, readErr := body.Read(buffer)
+		if n > 0 {
+			if _, err := out.WriteAt(buffer[:n], offset); err != nil {
+				return err
+			}
+			offset += int64(n)
+			progress.add(index, int64(n))
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	if offset < segment.End {
+		return fmt.Errorf("segment %d: %w", index, io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// contentRangeTotal returns the complete length from a "bytes 0-99/1000"
+// Content-Range header, when the server states one.
+func contentRangeTotal(value string) (int64, bool) {
+	_, total, ok := strings.Cut(value, "/")
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	return size, err == nil
+}
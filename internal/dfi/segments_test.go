@@ -0,0 +1,248 @@
+package dfi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/d-fi/GoFi/decrypt"
+	"github.com/d-fi/GoFi/download"
+)
+
+func testTrackBytes(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func rangeServer(t *testing.T, data []byte, ranges *[]string) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "track", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSplitDownloadSegmentsAlignsToStripe(t *testing.T) {
+	size := int64(segmentedDownloadMinSize + 12345)
+	segments := splitDownloadSegments(size, downloadSegmentCount)
+	if len(segments) != downloadSegmentCount {
+		t.Fatalf("segments = %d, want %d", len(segments), downloadSegmentCount)
+	}
+	var next int64
+	for i, segment := range segments {
+		if segment.Start != next {
+			t.Fatalf("segment %d starts at %d, want %d", i, segment.Start, next)
+		}
+		if segment.Start%decrypt.StripeChunkSize != 0 {
+			t.Fatalf("segment %d start %d is not stripe aligned", i, segment.Start)
+		}
+		next = segment.End
+	}
+	if next != size {
+		t.Fatalf("segments end at %d, want %d", next, size)
+	}
+
+	if got := splitDownloadSegments(100, downloadSegmentCount); len(got) != 1 || got[0].End != 100 {
+		t.Fatalf("small file segments = %+v, want one segment", got)
+	}
+}
+
+func TestDownloadToTempSegmented(t *testing.T) {
+	data := testTrackBytes(segmentedDownloadMinSize + 5000)
+	var ranges []string
+	server := rangeServer(t, data, &ranges)
+	tmpFile := filepath.Join(t.TempDir(), "d-fi_9_1_md5")
+
+	var lastTransferred, lastTotal int64
+	err := downloadToTemp(context.Background(), &download.TrackDownloadUrl{
+		TrackUrl: server.URL,
+		FileSize: int64(len(data)),
+	}, tmpFile, func(transferred, total int64) {
+		lastTransferred, lastTotal = transferred, total
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("segmented download does not match source")
+	}
+	if len(ranges) != downloadSegmentCount {
+		t.Fatalf("requests = %d, want %d", len(ranges), downloadSegmentCount)
+	}
+	if lastTransferred != int64(len(data)) || lastTotal != int64(len(data)) {
+		t.Fatalf("last progress = %d/%d, want %d", lastTransferred, lastTotal, len(data))
+	}
+	if _, err := os.Stat(segmentStatePath(tmpFile)); !os.IsNotExist(err) {
+		t.Fatalf("segment state should be removed, stat err = %v", err)
+	}
+}
+
+func TestDownloadToTempResumesSegments(t *testing.T) {
+	data := testTrackBytes(segmentedDownloadMinSize + 5000)
+	tmpFile := filepath.Join(t.TempDir(), "d-fi_9_1_md5")
+	segments := splitDownloadSegments(int64(len(data)), downloadSegmentCount)
+	segments[0].Done = segments[0].End - segments[0].Start
+	segments[1].Done = 4096
+
+	partial := make([]byte, len(data))
+	copy(partial[:segments[0].End], data)
+	copy(partial[segments[1].Start:segments[1].Start+4096], data[segments[1].Start:])
+	if err := os.WriteFile(tmpFile, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+	state := &segmentState{Size: int64(len(data)), Segments: segments}
+	if err := state.save(tmpFile); err != nil {
+		t.Fatal(err)
+	}
+
+	var ranges []string
+	server := rangeServer(t, data, &ranges)
+	err := downloadToTemp(context.Background(), &download.TrackDownloadUrl{
+		TrackUrl: server.URL,
+		FileSize: int64(len(data)),
+	}, tmpFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("resumed download does not match source")
+	}
+	if len(ranges) != downloadSegmentCount-1 {
+		t.Fatalf("requests = %q, want %d", ranges, downloadSegmentCount-1)
+	}
+	for _, value := range ranges {
+		if strings.HasPrefix(value, "bytes=0-") {
+			t.Fatalf("completed segment was fetched again: %q", ranges)
+		}
+	}
+}
+
+func TestDownloadToTempSegmentedFallsBackWhenRangeIgnored(t *testing.T) {
+	data := testTrackBytes(segmentedDownloadMinSize + 5000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+	tmpFile := filepath.Join(t.TempDir(), "d-fi_9_1_md5")
+
+	err := downloadToTemp(context.Background(), &download.TrackDownloadUrl{
+		TrackUrl: server.URL,
+		FileSize: int64(len(data)),
+	}, tmpFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("single connection fallback does not match source")
+	}
+	if _, err := os.Stat(segmentStatePath(tmpFile)); !os.IsNotExist(err) {
+		t.Fatalf("segment state should be removed, stat err = %v", err)
+	}
+}
+
+func TestDownloadToTempSegmentedKeepsProgressOnError(t *testing.T) {
+	data := testTrackBytes(segmentedDownloadMinSize + 5000)
+	tmpFile := filepath.Join(t.TempDir(), "d-fi_9_1_md5")
+	segments := splitDownloadSegments(int64(len(data)), downloadSegmentCount)
+	last := len(segments) - 1
+	for i := range last {
+		segments[i].Done = segments[i].End - segments[i].Start
+	}
+	partial := make([]byte, len(data))
+	copy(partial[:segments[last].Start], data)
+	if err := os.WriteFile(tmpFile, partial, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&segmentState{Size: int64(len(data)), Segments: segments}).save(tmpFile); err != nil {
+		t.Fatal(err)
+	}
+
+	// The connection drops after 4096 bytes of the missing segment.
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end := segments[last].Start, segments[last].End
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(data)))
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(data[start : start+4096])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer broken.Close()
+	trackData := &download.TrackDownloadUrl{TrackUrl: broken.URL, FileSize: int64(len(data))}
+	if err := downloadToTemp(context.Background(), trackData, tmpFile, nil); err == nil {
+		t.Fatal("expected the dropped connection to fail the download")
+	}
+	state, ok := loadSegmentState(tmpFile, int64(len(data)))
+	if !ok {
+		t.Fatal("partial file and segment state were not kept")
+	}
+	if done := state.Segments[last].Done; done != 4096 {
+		t.Fatalf("last segment done = %d, want 4096", done)
+	}
+
+	var ranges []string
+	trackData.TrackUrl = rangeServer(t, data, &ranges).URL
+	if err := downloadToTemp(context.Background(), trackData, tmpFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("bytes=%d-%d", segments[last].Start+4096, segments[last].End-1); len(ranges) != 1 || ranges[0] != want {
+		t.Fatalf("requests = %q, want only %q", ranges, want)
+	}
+	got, err := os.ReadFile(tmpFile)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("resumed download does not match source: %v", err)
+	}
+}
+
+func TestDownloadToTempSegmentedDropsStaleSizeOnMismatch(t *testing.T) {
+	data := testTrackBytes(segmentedDownloadMinSize + 5000)
+	var ranges []string
+	server := rangeServer(t, data[:len(data)-1000], &ranges)
+	tmpFile := filepath.Join(t.TempDir(), "d-fi_9_1_md5")
+
+	err := downloadToTemp(context.Background(), &download.TrackDownloadUrl{
+		TrackUrl: server.URL,
+		FileSize: int64(len(data)),
+	}, tmpFile, nil)
+	if !errors.Is(err, errSegmentSizeMismatch) {
+		t.Fatalf("err = %v, want errSegmentSizeMismatch", err)
+	}
+	for _, path := range []string{tmpFile, segmentStatePath(tmpFile)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed, stat err = %v", path, err)
+		}
+	}
+}
@@ -1,15 +1,152 @@
 package dfi
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/d-fi/GoFi/download"
+	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
+)
+
+const (
+	defaultDownloadTempMaxAge = time.Hour
+	// defaultTempMaxAge is how long an untouched resumable temp file is kept
+	// when the config does not say otherwise.
+	defaultTempMaxAge = 24 * time.Hour
+	tempInfoSuffix    = ".info"
+	// tempURLMargin keeps a recorded media URL from being reused right before
+	// it expires.
+	tempURLMargin = time.Minute
 )
 
-const defaultDownloadTempMaxAge = time.Hour
+var urlExpiryRE = regexp.MustCompile(`(?:^|[?&~=])exp=(\d+)`)
+
+// DefaultWorkDir is the directory resumable temp files go to when workDir is
+// not configured: d-fi in the user cache directory, or in the system temp
+// directory when there is no cache directory.
+func DefaultWorkDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "d-fi")
+	}
+	return filepath.Join(os.TempDir(), "d-fi")
+}
+
+// episodeTempMarker stands in for MD5_ORIGIN, which episodes do not have, in
+// the temp names of podcast episodes.
+const episodeTempMarker = "ep"
+
+// DownloadTempName is the file name of the resumable temp file for track in
+// quality. The same track and quality always map to the same name.
+func DownloadTempName(quality int, track types.TrackType) string {
+	if track.EPISODE != nil {
+		return fmt.Sprintf("d-fi_%d_%s_%s", quality, track.EPISODE.EpisodeID, episodeTempMarker)
+	}
+	return fmt.Sprintf("d-fi_%d_%s_%s", quality, track.SNG_ID, track.MD5_ORIGIN)
+}
+
+// tempInfo is stored next to a resumable temp file. It records the media URL
+// the temp came from and the size the finished file must have.
+type tempInfo struct {
+	SngID      string `json:"sngId"`
+	Quality    int    `json:"quality"`
+	URL        string `json:"url"`
+	URLExpires int64  `json:"urlExpires,omitempty"`
+	Size       int64  `json:"size"`
+	Encrypted  bool   `json:"encrypted"`
+}
 
-func CleanupStaleDownloadTemps(dir string, maxAge time.Duration) (int, error) {
+func tempInfoPath(tmpFile string) string {
+	return tmpFile + tempInfoSuffix
+}
+
+func loadTempInfo(tmpFile string) (tempInfo, bool) {
+	var info tempInfo
+	data, err := os.ReadFile(tempInfoPath(tmpFile))
+	if err != nil || json.Unmarshal(data, &info) != nil || info.Size <= 0 {
+		return tempInfo{}, false
+	}
+	return info, true
+}
+
+func (info tempInfo) save(tmpFile string) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tempInfoPath(tmpFile), data, 0644)
+}
+
+// trackData returns the recorded media URL when it is still valid at now and
+// the temp file holds part of the download. A URL without a known expiry is
+// never reused.
+func (info tempInfo) trackData(tmpFile string, now time.Time) (*download.TrackDownloadUrl, bool) {
+	if info.URL == "" || info.URLExpires == 0 || now.Add(tempURLMargin).Unix() >= info.URLExpires {
+		return nil, false
+	}
+	stat, err := os.Stat(tmpFile)
+	if err != nil || stat.Size() == 0 || stat.Size() > info.Size {
+		return nil, false
+	}
+	return &download.TrackDownloadUrl{TrackUrl: info.URL, IsEncrypted: info.Encrypted, FileSize: info.Size}, true
+}
+
+// urlExpiry reads the exp= field of a signed CDN URL. It returns 0 when the
+// URL carries no expiry.
+func urlExpiry(rawURL string) int64 {
+	match := urlExpiryRE.FindStringSubmatch(rawURL)
+	if match == nil {
+		return 0
+	}
+	expires, _ := strconv.ParseInt(match[1], 10, 64)
+	return expires
+}
+
+// removeDownloadTemp removes a temp file together with its sidecars.
+func removeDownloadTemp(tmpFile string) {
+	removeSegmentedTemp(tmpFile)
+	_ = os.Remove(tempInfoPath(tmpFile))
+}
+
+// activeTemps counts the temp files downloads in this process are using.
+var activeTemps = struct {
+	sync.Mutex
+	paths map[string]int
+}{paths: map[string]int{}}
+
+// claimDownloadTemp marks tmpFile as in use until the returned func is called.
+func claimDownloadTemp(tmpFile string) func() {
+	activeTemps.Lock()
+	activeTemps.paths[tmpFile]++
+	activeTemps.Unlock()
+	return func() {
+		activeTemps.Lock()
+		defer activeTemps.Unlock()
+		if activeTemps.paths[tmpFile]--; activeTemps.paths[tmpFile] <= 0 {
+			delete(activeTemps.paths, tmpFile)
+		}
+	}
+}
+
+func downloadTempInUse(tmpFile string) bool {
+	activeTemps.Lock()
+	defer activeTemps.Unlock()
+	return activeTemps.paths[tmpFile] > 0
+}
+
+// CleanupStaleDownloadTemps removes resumable temp files in dir that were not
+// written to for maxAge, along with their sidecars. Temps a download in this
+// process is using are kept, and so are temps for which keep reports true.
+// keep gets the temp file name without sidecar suffix and may be nil.
+func CleanupStaleDownloadTemps(dir string, maxAge time.Duration, keep func(name string) bool) (int, error) {
 	if dir == "" {
 		dir = "."
 	}
@@ -19,6 +156,9 @@ func CleanupStaleDownloadTemps(dir string, maxAge time.Duration) (int, error) {
 
 	entries, err := os.ReadDir(dir)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
 		return 0, err
 	}
 
@@ -28,15 +168,27 @@ func CleanupStaleDownloadTemps(dir string, maxAge time.Duration) (int, error) {
 		if entry.IsDir() || !isDownloadTempName(entry.Name()) {
 			continue
 		}
-		info, err := entry.Info()
-		if err != nil {
-			if os.IsNotExist(err) {
+		name := downloadTempBase(entry.Name())
+		if downloadTempInUse(filepath.Join(dir, name)) || (keep != nil && keep(name)) {
+			continue
+		}
+		if name != entry.Name() {
+			// A sidecar goes with its temp file, or on its own once the
+			// temp file is gone.
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				continue
+			}
+		} else {
+			info, err := entry.Info()
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return removed, err
+			}
+			if info.ModTime().After(cutoff) {
 				continue
 			}
-			return removed, err
-		}
-		if info.ModTime().After(cutoff) {
-			continue
 		}
 		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
 			if os.IsNotExist(err) {
@@ -49,6 +201,36 @@ func CleanupStaleDownloadTemps(dir string, maxAge time.Duration) (int, error) {
 	return removed, nil
 }
 
+// CleanupDownloadTemps runs CleanupStaleDownloadTemps over the work directory
+// of cfg and over the current directory, where earlier versions kept their
+// temp files.
+func CleanupDownloadTemps(cfg Config, keep func(name string) bool) (int, error) {
+	dirs := []string{cfg.TempDir()}
+	workDir, _ := filepath.Abs(dirs[0])
+	if cwd, _ := os.Getwd(); workDir != cwd {
+		dirs = append(dirs, ".")
+	}
+	removed := 0
+	for _, dir := range dirs {
+		n, err := CleanupStaleDownloadTemps(dir, cfg.TempMaxAgeDuration(), keep)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// downloadTempBase strips a sidecar suffix from a temp file name.
+func downloadTempBase(name string) string {
+	for _, suffix := range []string{segmentStateSuffix, tempInfoSuffix} {
+		if base, ok := strings.CutSuffix(name, suffix); ok {
+			return base
+		}
+	}
+	return name
+}
+
 func isDownloadTempName(name string) bool {
 	parts := strings.Split(name, "_")
 	if len(parts) != 4 || parts[0] != "d-fi" {
@@ -61,3 +243,85 @@ func isDownloadTempName(name string) bool {
 		return false
 	}
 }
+
+// CleanupPartialFiles removes the temp files that interrupted atomic writes
+// left next to saved tracks, covers and playlists. Only the directories a save
+// layout can write to are searched: the static prefix of each layout, down to
+// as many levels as the layout has. Files younger than maxAge may belong to a
+// running download and are kept.
+func CleanupPartialFiles(layouts []string, maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		maxAge = defaultDownloadTempMaxAge
+	}
+	depths := map[string]int{}
+	for _, layout := range layouts {
+		if strings.TrimSpace(layout) == "" {
+			continue
+		}
+		root, depth := layoutSearchRoot(layout)
+		depths[root] = max(depths[root], depth)
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-maxAge)
+	for root, maxDepth := range depths {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) || os.IsPermission(err) {
+					return nil
+				}
+				return err
+			}
+			if entry.IsDir() {
+				if path != root && pathDepth(root, path) > maxDepth {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !utils.IsPartialFile(entry.Name()) {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				return nil
+			}
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removed++
+			return nil
+		})
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// layoutSearchRoot splits a save layout into the directory before its first
+// placeholder and the number of directory levels the placeholders add below it.
+func layoutSearchRoot(layout string) (string, int) {
+	layout = filepath.ToSlash(layout)
+	static := layout
+	if i := strings.Index(layout, "{"); i >= 0 {
+		static = layout[:i]
+	}
+	root := "."
+	if i := strings.LastIndex(static, "/"); i >= 0 {
+		root = static[:i]
+		if root == "" {
+			root = "/"
+		}
+	}
+	rest := strings.TrimPrefix(layout, root)
+	depth := strings.Count(strings.Trim(rest, "/"), "/")
+	return filepath.FromSlash(root), depth
+}
+
+func pathDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(rel), "/") + 1
+}
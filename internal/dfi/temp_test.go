@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/d-fi/GoFi/utils"
 )
 
 func TestCleanupStaleDownloadTemps(t *testing.T) {
@@ -23,7 +25,7 @@ func TestCleanupStaleDownloadTemps(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	removed, err := CleanupStaleDownloadTemps(dir, time.Hour)
+	removed, err := CleanupStaleDownloadTemps(dir, time.Hour, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -40,18 +42,99 @@ func TestCleanupStaleDownloadTemps(t *testing.T) {
 	}
 }
 
+func TestCleanupStaleDownloadTempsKeepsActiveTemps(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "d-fi_9_1_md5")
+	claimed := filepath.Join(dir, "d-fi_9_2_md5")
+	stale := filepath.Join(dir, "d-fi_9_3_md5")
+	orphan := filepath.Join(dir, "d-fi_9_4_md5"+tempInfoSuffix)
+	oldTime := time.Now().Add(-48 * time.Hour)
+	for _, path := range []string{kept, claimed, stale, tempInfoPath(stale), orphan} {
+		if err := os.WriteFile(path, []byte("temp"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, oldTime, oldTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	release := claimDownloadTemp(claimed)
+	defer release()
+
+	removed, err := CleanupStaleDownloadTemps(dir, 24*time.Hour, func(name string) bool {
+		return name == filepath.Base(kept)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Fatalf("removed = %d, want 3", removed)
+	}
+	for _, path := range []string{kept, claimed} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s should remain: %v", filepath.Base(path), err)
+		}
+	}
+	for _, path := range []string{stale, tempInfoPath(stale), orphan} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s still exists or stat failed with %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestTempInfoTrackData(t *testing.T) {
+	dir := t.TempDir()
+	tmpFile := filepath.Join(dir, "d-fi_3_1_md5")
+	if err := os.WriteFile(tmpFile, []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	url := "https://cdn.example/media/1?hdnea=exp=1700003600~acl=/*~hmac=abc"
+	if got := urlExpiry(url); got != 1_700_003_600 {
+		t.Fatalf("urlExpiry() = %d", got)
+	}
+	info := tempInfo{SngID: "1", Quality: 3, URL: url, URLExpires: urlExpiry(url), Size: 100, Encrypted: true}
+	if err := info.save(tmpFile); err != nil {
+		t.Fatal(err)
+	}
+	loaded, ok := loadTempInfo(tmpFile)
+	if !ok || loaded != info {
+		t.Fatalf("loadTempInfo() = %+v, %v", loaded, ok)
+	}
+
+	data, ok := loaded.trackData(tmpFile, now)
+	if !ok || data.TrackUrl != url || data.FileSize != 100 || !data.IsEncrypted {
+		t.Fatalf("trackData() = %+v, %v", data, ok)
+	}
+	if _, ok := loaded.trackData(tmpFile, time.Unix(1_700_003_590, 0)); ok {
+		t.Fatal("trackData reused a URL about to expire")
+	}
+	loaded.Size = 3
+	if _, ok := loaded.trackData(tmpFile, now); ok {
+		t.Fatal("trackData reused a URL for a temp larger than the file")
+	}
+	if _, ok := (tempInfo{URL: "https://cdn.example/media/1", Size: 100}).trackData(tmpFile, now); ok {
+		t.Fatal("trackData reused a URL without expiry")
+	}
+
+	removeDownloadTemp(tmpFile)
+	if _, ok := loadTempInfo(tmpFile); ok {
+		t.Fatal("removeDownloadTemp left the sidecar")
+	}
+}
+
 func TestIsDownloadTempName(t *testing.T) {
 	tests := map[string]bool{
-		"d-fi_1_123_md5":       true,
-		"d-fi_3_123_md5":       true,
-		"d-fi_9_123_simulate":  true,
-		"d-fi_2_123_md5":       false,
-		"d-fi_3_123":           false,
-		"d-fi.config.json":     false,
-		"d-fi_3_123_md5_extra": false,
-		"d-fi_3__md5":          false,
-		"d-fi_3_123_":          false,
-		"other-d-fi_3_123_md5": false,
+		"d-fi_1_123_md5":          true,
+		"d-fi_3_123_md5":          true,
+		"d-fi_9_123_simulate":     true,
+		"d-fi_9_123_md5.segments": true,
+		"d-fi_2_123_md5":          false,
+		"d-fi_3_123":              false,
+		"d-fi.config.json":        false,
+		"d-fi_3_123_md5_extra":    false,
+		"d-fi_3__md5":             false,
+		"d-fi_3_123_":             false,
+		"other-d-fi_3_123_md5":    false,
 	}
 	for name, want := range tests {
 		if got := isDownloadTempName(name); got != want {
@@ -59,3 +142,101 @@ func TestIsDownloadTempName(t *testing.T) {
 		}
 	}
 }
+
+func TestCleanupPartialFiles(t *testing.T) {
+	dir := t.TempDir()
+	album := filepath.Join(dir, "Music", "Album")
+	tooDeep := filepath.Join(album, "Nested", "Deeper")
+	if err := os.MkdirAll(tooDeep, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(album, ".01 - Song.flac.123"+utils.PartialFileSuffix)
+	fresh := filepath.Join(album, ".02 - Song.flac.456"+utils.PartialFileSuffix)
+	saved := filepath.Join(album, "01 - Song.flac")
+	deep := filepath.Join(tooDeep, ".03 - Song.flac.789"+utils.PartialFileSuffix)
+	for _, path := range []string{stale, fresh, saved, deep} {
+		if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	oldTime := time.Now().Add(-2 * time.Hour)
+	for _, path := range []string{stale, deep} {
+		if err := os.Chtimes(path, oldTime, oldTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	layout := filepath.Join(dir, "Music", "{ALB_TITLE}", "{SNG_TITLE}")
+	removed, err := CleanupPartialFiles([]string{layout, "", filepath.Join(dir, "Missing", "{SNG_TITLE}")}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Fatalf("removed = %d, want 1", removed)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("stale partial still exists or stat failed with %v", err)
+	}
+	for _, path := range []string{fresh, saved, deep} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s should remain: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestCleanupLeftoversSkipsDryRun(t *testing.T) {
+	dir := t.TempDir()
+	var cfg Config
+	cfg.WorkDir = filepath.Join(dir, "work")
+	cfg.SaveLayout.Track = filepath.Join(dir, "Music", "{SNG_TITLE}")
+	if err := os.MkdirAll(cfg.WorkDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	partial := filepath.Join(dir, "Music", ".Song.flac.123"+utils.PartialFileSuffix)
+	temp := filepath.Join(cfg.WorkDir, "d-fi_3_2202736507_99573fea3e0593ead564fff9eab9edcf")
+	if err := os.MkdirAll(filepath.Dir(partial), 0755); err != nil {
+		t.Fatal(err)
+	}
+	oldTime := time.Now().Add(-48 * time.Hour)
+	for _, path := range []string{partial, temp} {
+		if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, oldTime, oldTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cleanupLeftovers(cfg, options{dryRun: "table"})
+	for _, path := range []string{partial, temp} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("dry run removed %s: %v", filepath.Base(path), err)
+		}
+	}
+	cleanupLeftovers(cfg, options{})
+	for _, path := range []string{partial, temp} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s still exists or stat failed with %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestLayoutSearchRoot(t *testing.T) {
+	tests := []struct {
+		layout string
+		root   string
+		depth  int
+	}{
+		{layout: "Music/{ALB_TITLE}/{SNG_TITLE}", root: "Music", depth: 1},
+		{layout: "Music/Artist - {ALB_TITLE}/{SNG_TITLE}", root: "Music", depth: 1},
+		{layout: "{ALB_TITLE}/{DISK_FOLDER}/{SNG_TITLE}", root: ".", depth: 2},
+		{layout: "./{SNG_TITLE}", root: ".", depth: 0},
+		{layout: "/srv/music/{ALB_TITLE}/{SNG_TITLE}", root: "/srv/music", depth: 1},
+	}
+	for _, tt := range tests {
+		root, depth := layoutSearchRoot(tt.layout)
+		if root != filepath.FromSlash(tt.root) || depth != tt.depth {
+			t.Fatalf("layoutSearchRoot(%q) = %q, %d; want %q, %d", tt.layout, root, depth, tt.root, tt.depth)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package dfi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/types"
+)
+
+// trackTokenRefreshMargin treats tokens this close to expiry as expired, so a
+// token stays valid for the whole URL lookup.
+const trackTokenRefreshMargin = 5 * time.Minute
+
+// fetchTrackInfo loads song data past the response cache. A cached response
+// can be up to an hour old and carry a token that is about to expire.
+var fetchTrackInfo = api.RefreshTrackInfo
+
+// trackTokenExpired reports whether the track token is missing, expired or
+// about to expire at now.
+func trackTokenExpired(track types.TrackType, now time.Time) bool {
+	if track.TRACK_TOKEN == "" {
+		return true
+	}
+	if track.TRACK_TOKEN_EXPIRE <= 0 {
+		return false
+	}
+	return now.Add(trackTokenRefreshMargin).Unix() >= int64(track.TRACK_TOKEN_EXPIRE)
+}
+
+// refreshTrackToken re-fetches the song data for track and copies over the
+// fields used to resolve media. Titles, positions and the rest of the metadata
+// are kept so the save path does not change.
+func refreshTrackToken(track types.TrackType) (types.TrackType, error) {
+	fresh, err := fetchTrackInfo(track.SNG_ID)
+	if err != nil {
+		return track, fmt.Errorf("refresh track token for %s: %w", track.SNG_ID, err)
+	}
+	if fresh.TRACK_TOKEN == "" {
+		return track, fmt.Errorf("refresh track token for %s: song data has no track token", track.SNG_ID)
+	}
+
+	track.TRACK_TOKEN = fresh.TRACK_TOKEN
+	track.TRACK_TOKEN_EXPIRE = fresh.TRACK_TOKEN_EXPIRE
+	track.MD5_ORIGIN = fresh.MD5_ORIGIN
+	track.MEDIA_VERSION = fresh.MEDIA_VERSION
+	track.MEDIA = fresh.MEDIA
+	track.RIGHTS = fresh.RIGHTS
+	track.FILESIZE = fresh.FILESIZE
+	track.FILESIZE_MP3_128 = fresh.FILESIZE_MP3_128
+	track.FILESIZE_MP3_320 = fresh.FILESIZE_MP3_320
+	track.FILESIZE_FLAC = fresh.FILESIZE_FLAC
+	if fresh.FALLBACK != nil {
+		track.FALLBACK = fresh.FALLBACK
+	}
+	return track, nil
+}
@@ -0,0 +1,91 @@
+package dfi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/d-fi/GoFi/types"
+)
+
+func TestTrackTokenExpired(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name   string
+		token  string
+		expire int
+		want   bool
+	}{
+		{name: "missing token", token: "", expire: 0, want: true},
+		{name: "no expiry", token: "token", expire: 0, want: false},
+		{name: "valid", token: "token", expire: int(now.Add(time.Hour).Unix()), want: false},
+		{name: "near expiry", token: "token", expire: int(now.Add(time.Minute).Unix()), want: true},
+		{name: "expired", token: "token", expire: int(now.Add(-time.Hour).Unix()), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var track types.TrackType
+			track.TRACK_TOKEN = tt.token
+			track.TRACK_TOKEN_EXPIRE = tt.expire
+			if got := trackTokenExpired(track, now); got != tt.want {
+				t.Fatalf("trackTokenExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshTrackTokenKeepsLayoutFields(t *testing.T) {
+	original := fetchTrackInfo
+	t.Cleanup(func() { fetchTrackInfo = original })
+	fetchTrackInfo = func(sngID string) (types.TrackType, error) {
+		var fresh types.TrackType
+		fresh.SNG_ID = sngID
+		fresh.SNG_TITLE = "Renamed"
+		fresh.TRACK_TOKEN = "fresh-token"
+		fresh.TRACK_TOKEN_EXPIRE = 1_800_000_000
+		fresh.MD5_ORIGIN = "fresh-md5"
+		fresh.MEDIA_VERSION = "9"
+		return fresh, nil
+	}
+
+	position := 12
+	var track types.TrackType
+	track.SNG_ID = "3135556"
+	track.SNG_TITLE = "Harder, Better, Faster, Stronger"
+	track.TRACK_TOKEN = "stale-token"
+	track.MD5_ORIGIN = "stale-md5"
+	track.TRACK_POSITION = &position
+
+	refreshed, err := refreshTrackToken(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.TRACK_TOKEN != "fresh-token" || refreshed.TRACK_TOKEN_EXPIRE != 1_800_000_000 {
+		t.Fatalf("token not refreshed: %q %d", refreshed.TRACK_TOKEN, refreshed.TRACK_TOKEN_EXPIRE)
+	}
+	if refreshed.MD5_ORIGIN != "fresh-md5" || refreshed.MEDIA_VERSION != "9" {
+		t.Fatalf("media fields not refreshed: %q %q", refreshed.MD5_ORIGIN, refreshed.MEDIA_VERSION)
+	}
+	if refreshed.SNG_TITLE != track.SNG_TITLE || refreshed.TRACK_POSITION != &position {
+		t.Fatal("refresh should keep title and position")
+	}
+}
+
+func TestRefreshTrackTokenFailure(t *testing.T) {
+	original := fetchTrackInfo
+	t.Cleanup(func() { fetchTrackInfo = original })
+	fetchTrackInfo = func(string) (types.TrackType, error) {
+		return types.TrackType{}, errors.New("offline")
+	}
+
+	var track types.TrackType
+	track.SNG_ID = "1"
+	track.TRACK_TOKEN = "stale"
+	refreshed, err := refreshTrackToken(track)
+	if err == nil {
+		t.Fatal("expected refresh error")
+	}
+	if refreshed.TRACK_TOKEN != "stale" {
+		t.Fatal("failed refresh should return the original track")
+	}
+}
@@ -0,0 +1,455 @@
+package dfi
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/d-fi/GoFi/download"
+	"github.com/d-fi/GoFi/metadata"
+	"github.com/d-fi/GoFi/utils"
+	"github.com/d-fi/GoFi/verify"
+)
+
+// upgradeStagingSuffix marks the file an upgrade downloads next to the
+// original before it replaces it.
+const upgradeStagingSuffix = ".upgrade"
+
+const (
+	upgradeSkipNoSourceID   = "no SOURCEID tag"
+	upgradeSkipAtTarget     = "already at target quality"
+	upgradeSkipNotAvailable = "no higher quality available"
+	upgradeSkipLicense      = "account can't stream a higher quality"
+)
+
+type upgradeOptions struct {
+	quality     string
+	configFile  string
+	concurrency int
+	dir         string
+}
+
+// upgradeCandidate is an audio file found in the library.
+type upgradeCandidate struct {
+	path     string
+	sngID    string
+	quality  int
+	readErr  error
+	skipNote string
+}
+
+// upgradeResult is the outcome for a single file.
+type upgradeResult struct {
+	path     string
+	newPath  string
+	from     int
+	to       int
+	skipped  string
+	err      error
+	errClass download.ErrorClass
+}
+
+// RunUpgrade implements `d-fi upgrade <dir>`: it replaces every file in dir
+// that carries a SOURCEID tag with a download in a better quality, when the
+// account can get one.
+func RunUpgrade(ctx context.Context, args []string) error {
+	opts, err := parseUpgradeOptions(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	target, _, targetLabel, err := ParseQualityStrict(opts.quality)
+	if err != nil {
+		return err
+	}
+
+	printBanner(os.Stdout)
+	cfg := LoadConfig(opts.configFile)
+	if cfg.UserConfigLocation != "" {
+		fmt.Println(info("Config loaded --> " + cfg.UserConfigLocation))
+	}
+	SetMaxBandwidth(cfg.MaxBandwidthBytes())
+
+	candidates, err := scanUpgradeCandidates(opts.dir, target)
+	if err != nil {
+		return err
+	}
+	fmt.Println(info(fmt.Sprintf("Found %d audio %s in %s", len(candidates), plural("file", len(candidates)), opts.dir)))
+
+	var pending []upgradeCandidate
+	var results []upgradeResult
+	for _, candidate := range candidates {
+		switch {
+		case candidate.readErr != nil:
+			results = append(results, upgradeResult{path: candidate.path, err: candidate.readErr, errClass: download.ErrorUnknown})
+		case candidate.skipNote != "":
+			results = append(results, upgradeResult{path: candidate.path, skipped: candidate.skipNote})
+		default:
+			pending = append(pending, candidate)
+		}
+	}
+
+	if len(pending) > 0 {
+		if err := initSession(os.Stdout, cfg); err != nil {
+			return err
+		}
+		fmt.Println(info(fmt.Sprintf("Upgrading up to %d %s to %s", len(pending), plural("file", len(pending)), targetLabel)))
+		concurrency := opts.concurrency
+		if concurrency <= 0 {
+			concurrency = cfg.Concurrency
+		}
+		results = append(results, upgradeAll(ctx, cfg, pending, target, max(concurrency, 1))...)
+	}
+
+	printUpgradeReport(os.Stdout, results)
+	updated, err := updateUpgradedPlaylists(opts.dir, results)
+	if updated > 0 {
+		fmt.Println(info(fmt.Sprintf("Updated %d %s", updated, plural("playlist", updated))))
+	}
+	if err != nil {
+		fmt.Println(warn("Unable to update playlists: " + err.Error()))
+	}
+	return nil
+}
+
+func parseUpgradeOptions(args []string) (upgradeOptions, error) {
+	var opts upgradeOptions
+	fs := flag.NewFlagSet("d-fi upgrade", flag.ContinueOnError)
+	fs.Usage = func() {
+		w := fs.Output()
+		fmt.Fprintln(w, "Usage of d-fi upgrade <dir>:")
+		fmt.Fprintln(w, "  -q, --quality <quality>       Target quality: 320/flac (default flac)")
+		fmt.Fprintln(w, "  -c, --concurrency <number>    Number of files upgraded at once")
+		fmt.Fprintln(w, "  -conf, --config-file <file>   Custom location to your config file")
+	}
+	fs.StringVar(&opts.quality, "quality", "flac", "Target quality: 320/flac")
+	fs.StringVar(&opts.quality, "q", "flac", "Target quality: 320/flac")
+	fs.IntVar(&opts.concurrency, "concurrency", 0, "Number of files upgraded at once")
+	fs.IntVar(&opts.concurrency, "c", 0, "Number of files upgraded at once")
+	fs.StringVar(&opts.configFile, "config-file", "d-fi.config.json", "Custom location to your config file")
+	fs.StringVar(&opts.configFile, "conf", "d-fi.config.json", "Custom location to your config file")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return opts, fmt.Errorf("upgrade needs exactly one directory")
+	}
+	opts.dir = fs.Arg(0)
+	return opts, nil
+}
+
+// scanUpgradeCandidates lists the MP3 and FLAC files under dir with their
+// SOURCEID and current quality. Files that cannot reach target are marked
+// skipped; leftover staging files from an interrupted upgrade are ignored.
+func scanUpgradeCandidates(dir string, target int) ([]upgradeCandidate, error) {
+	var candidates []upgradeCandidate
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".mp3" && ext != ".flac" {
+			return nil
+		}
+		if strings.HasSuffix(strings.TrimSuffix(path, filepath.Ext(path)), upgradeStagingSuffix) {
+			return nil
+		}
+
+		candidate := upgradeCandidate{path: path}
+		candidate.sngID, candidate.readErr = metadata.SourceID(path)
+		if candidate.readErr == nil && candidate.sngID == "" {
+			candidate.skipNote = upgradeSkipNoSourceID
+		}
+		if candidate.readErr == nil && candidate.skipNote == "" {
+			candidate.quality, candidate.readErr = fileQuality(path)
+		}
+		if candidate.readErr == nil && candidate.skipNote == "" && candidate.quality >= target {
+			candidate.skipNote = upgradeSkipAtTarget
+		}
+		candidates = append(candidates, candidate)
+		return nil
+	})
+	return candidates, err
+}
+
+// fileQuality maps a saved file onto the Deezer quality it was downloaded in:
+// 9 for FLAC, 3 for 320 kbps MP3 and 1 for anything lower.
+func fileQuality(path string) (int, error) {
+	if strings.EqualFold(filepath.Ext(path), ".flac") {
+		return 9, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return 0, fmt.Errorf("read %s: %w", path, err)
+	}
+	prefix := int64(len(header))
+	if string(header[:3]) == "ID3" {
+		prefix += int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F)
+		if header[5]&0x10 != 0 {
+			prefix += 10
+		}
+	}
+	data := make([]byte, prefix+4096)
+	if _, err := file.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	bitrate, err := verify.MP3Bitrate(data)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if bitrate >= 256 {
+		return 3, nil
+	}
+	return 1, nil
+}
+
+// upgradeQualities lists the qualities above current and up to target, best first.
+func upgradeQualities(current, target int) []int {
+	var qualities []int
+	for _, quality := range []int{9, 3, 1} {
+		if quality > current && quality <= target {
+			qualities = append(qualities, quality)
+		}
+	}
+	return qualities
+}
+
+func upgradeAll(ctx context.Context, cfg Config, candidates []upgradeCandidate, target, concurrency int) []upgradeResult {
+	jobs := make(chan int)
+	results := make([]upgradeResult, len(candidates))
+	retry := cfg.Retry.Policy()
+	var wg sync.WaitGroup
+	for range min(len(candidates), concurrency) {
+		wg.Go(func() {
+			for index := range jobs {
+				message := fmt.Sprintf("(%d/%d)", index+1, len(candidates))
+				results[index] = upgradeFile(ctx, cfg, candidates[index], target, retry, message)
+			}
+		})
+	}
+	for index := range candidates {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// upgradeFile downloads the best quality above the file's current one next to
+// it and then swaps it in. The basename is kept, so cover files and lyrics
+// that point at the basename stay valid. When the extension changes,
+// updateUpgradedPlaylists fixes the playlists.
+func upgradeFile(ctx context.Context, cfg Config, candidate upgradeCandidate, target int, retry RetryPolicy, message string) upgradeResult {
+	result := upgradeResult{path: candidate.path, from: candidate.quality}
+	track, err := fetchTrackInfo(candidate.sngID)
+	if err != nil {
+		result.err = err
+		result.errClass = download.Classify(err)
+		return result
+	}
+
+	base := strings.TrimSuffix(candidate.path, filepath.Ext(candidate.path))
+	staging := base + upgradeStagingSuffix
+	result.skipped = upgradeSkipNotAvailable
+	for _, quality := range upgradeQualities(candidate.quality, target) {
+		_, ext, _ := ParseQuality(quality)
+		_ = os.Remove(staging + ext)
+		savedPath, err := downloadTrack(ctx, nil, 0, DownloadTrackOptions{
+			Track:      track,
+			Quality:    quality,
+			CoverSizes: cfg.CoverSize,
+			CoverMode:  cfg.Cover.Mode,
+			// The .lrc sidecar of the old file keeps its name, so only the
+			// embedded lyrics are redone.
+			Lyrics:     cfg.Lyrics.EmbedModes(),
+			TagProfile: cfg.Tags,
+			SavePath:   staging,
+			WorkDir:    cfg.TempDir(),
+			Retry:      retry,
+			Message:    message,
+		})
+		if err != nil {
+			_ = os.Remove(staging + ext)
+			if download.Classify(err) == download.ErrorLicense {
+				// A lower quality may still be allowed on this account.
+				result.skipped = upgradeSkipLicense
+				continue
+			}
+			result.skipped = ""
+			result.err = err
+			result.errClass = download.Classify(err)
+			return result
+		}
+		if savedPath == "" {
+			continue
+		}
+		newPath, err := replaceUpgradedFile(candidate.path, savedPath, ext)
+		if err != nil {
+			result.skipped = ""
+			result.err = err
+			result.errClass = download.ErrorUnknown
+			return result
+		}
+		result.skipped = ""
+		result.newPath = newPath
+		result.to = quality
+		return result
+	}
+	return result
+}
+
+// replaceUpgradedFile moves the staged download onto the original basename
+// with ext and removes the original when the extension changed.
+func replaceUpgradedFile(original, staged, ext string) (string, error) {
+	target := strings.TrimSuffix(original, filepath.Ext(original)) + ext
+	if err := os.Rename(staged, target); err != nil {
+		_ = os.Remove(staged)
+		return "", err
+	}
+	if target != original {
+		if err := os.Remove(original); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return target, err
+		}
+	}
+	return target, nil
+}
+
+// updateUpgradedPlaylists rewrites the entries of the .m3u and .m3u8 files
+// under dir that point at a file an upgrade saved with another extension. It
+// returns the number of playlists it changed.
+func updateUpgradedPlaylists(dir string, results []upgradeResult) (int, error) {
+	renamed := map[string]string{}
+	for _, result := range results {
+		if result.err != nil || result.newPath == "" || result.newPath == result.path {
+			continue
+		}
+		if from, err := filepath.Abs(result.path); err == nil {
+			renamed[from] = filepath.Ext(result.newPath)
+		}
+	}
+	if len(renamed) == 0 {
+		return 0, nil
+	}
+
+	updated := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if entry.IsDir() || (ext != ".m3u8" && ext != ".m3u") {
+			return nil
+		}
+		changed, err := updatePlaylistEntries(path, renamed)
+		if changed {
+			updated++
+		}
+		return err
+	})
+	return updated, err
+}
+
+// updatePlaylistEntries swaps the extension of every entry of the playlist at
+// path that resolves to a key of renamed. Relative entries stay relative.
+func updatePlaylistEntries(path string, renamed map[string]string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	playlistDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return false, err
+	}
+	lines := strings.Split(string(data), "\n")
+	changed := false
+	for i, line := range lines {
+		entry := strings.TrimRight(line, "\r")
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		target := filepath.FromSlash(entry)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(playlistDir, target)
+		}
+		ext, ok := renamed[filepath.Clean(target)]
+		if !ok {
+			continue
+		}
+		lines[i] = strings.TrimSuffix(entry, filepath.Ext(entry)) + ext + line[len(entry):]
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, utils.WriteFileAtomic(path, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+func printUpgradeReport(w io.Writer, results []upgradeResult) {
+	var upgraded, skipped, failed []upgradeResult
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			failed = append(failed, result)
+		case result.skipped != "":
+			skipped = append(skipped, result)
+		default:
+			upgraded = append(upgraded, result)
+		}
+	}
+
+	fmt.Fprintln(w, success(fmt.Sprintf("Upgraded %d %s", len(upgraded), plural("file", len(upgraded)))))
+	for _, result := range upgraded {
+		_, _, from := ParseQuality(result.from)
+		_, _, to := ParseQuality(result.to)
+		fmt.Fprintln(w, note(fmt.Sprintf("%s -> %s: %s", from, to, result.newPath)))
+	}
+
+	if len(skipped) > 0 {
+		notes := make([]string, 0, len(skipped))
+		for _, result := range skipped {
+			notes = append(notes, result.skipped)
+		}
+		fmt.Fprintln(w, info(fmt.Sprintf("Skipped %d %s (%s)", len(skipped), plural("file", len(skipped)), countReasons(notes))))
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintln(w, failure(fmt.Sprintf("Failed %d %s", len(failed), plural("file", len(failed)))))
+		for _, result := range failed {
+			fmt.Fprintln(w, note(fmt.Sprintf("[%s] %s: %v", result.errClass, result.path, result.err)))
+		}
+	}
+}
+
+// countReasons summarizes skip notes as "reason: count", sorted by reason.
+func countReasons(notes []string) string {
+	counts := map[string]int{}
+	for _, skip := range notes {
+		counts[skip]++
+	}
+	reasons := make([]string, 0, len(counts))
+	for reason, count := range counts {
+		reasons = append(reasons, fmt.Sprintf("%s: %d", reason, count))
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", ")
+}
@@ -0,0 +1,237 @@
+package dfi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/download"
+)
+
+// writeUpgradeMP3 writes an ID3-tagged MP3 whose first frame header uses the
+// given MPEG-1 Layer III bitrate index byte (0x90 is 128 kbps, 0xE0 is 320 kbps).
+func writeUpgradeMP3(t *testing.T, path, sourceID string, bitrateByte byte) {
+	t.Helper()
+	tag := id3v2.NewEmptyTag()
+	tag.SetVersion(4)
+	if sourceID != "" {
+		tag.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{
+			Encoding:    id3v2.EncodingUTF8,
+			Description: "SOURCEID",
+			Value:       sourceID,
+		})
+	}
+	var out bytes.Buffer
+	if _, err := tag.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	out.Write([]byte{0xFF, 0xFB, bitrateByte, 0x00})
+	out.Write(make([]byte, 1024))
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeUpgradeFlac(t *testing.T, path, sourceID string) {
+	t.Helper()
+	comment := "SOURCEID=" + sourceID
+	var block bytes.Buffer
+	_ = binary.Write(&block, binary.LittleEndian, uint32(0))
+	_ = binary.Write(&block, binary.LittleEndian, uint32(1))
+	_ = binary.Write(&block, binary.LittleEndian, uint32(len(comment)))
+	block.WriteString(comment)
+
+	var out bytes.Buffer
+	out.WriteString("fLaC")
+	out.Write([]byte{0x00, 0, 0, 34})
+	out.Write(make([]byte, 34))
+	out.Write([]byte{0x84, 0, byte(block.Len() >> 8), byte(block.Len())})
+	out.Write(block.Bytes())
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanUpgradeCandidates(t *testing.T) {
+	dir := t.TempDir()
+	album := filepath.Join(dir, "Album")
+	if err := os.MkdirAll(album, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeUpgradeMP3(t, filepath.Join(album, "01 - Low.mp3"), "1", 0x90)
+	writeUpgradeMP3(t, filepath.Join(album, "02 - High.mp3"), "2", 0xE0)
+	writeUpgradeMP3(t, filepath.Join(album, "03 - Untagged.mp3"), "", 0x90)
+	writeUpgradeFlac(t, filepath.Join(album, "04 - Lossless.flac"), "4")
+	writeUpgradeMP3(t, filepath.Join(album, "05 - Staged.upgrade.mp3"), "5", 0xE0)
+	if err := os.WriteFile(filepath.Join(album, "cover.jpg"), []byte("jpg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := scanUpgradeCandidates(dir, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]upgradeCandidate{}
+	for _, candidate := range candidates {
+		if candidate.readErr != nil {
+			t.Fatalf("%s: %v", candidate.path, candidate.readErr)
+		}
+		got[filepath.Base(candidate.path)] = candidate
+	}
+	if len(got) != 4 {
+		t.Fatalf("candidates = %v, want 4 audio files without staging files", got)
+	}
+	if c := got["01 - Low.mp3"]; c.sngID != "1" || c.quality != 1 || c.skipNote != "" {
+		t.Fatalf("low = %+v", c)
+	}
+	if c := got["02 - High.mp3"]; c.sngID != "2" || c.quality != 3 || c.skipNote != "" {
+		t.Fatalf("high = %+v", c)
+	}
+	if c := got["03 - Untagged.mp3"]; c.skipNote != upgradeSkipNoSourceID {
+		t.Fatalf("untagged = %+v", c)
+	}
+	if c := got["04 - Lossless.flac"]; c.sngID != "4" || c.skipNote != upgradeSkipAtTarget {
+		t.Fatalf("lossless = %+v", c)
+	}
+
+	candidates, err = scanUpgradeCandidates(dir, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, candidate := range candidates {
+		if filepath.Base(candidate.path) == "02 - High.mp3" && candidate.skipNote != upgradeSkipAtTarget {
+			t.Fatalf("320 file with 320 target = %+v", candidate)
+		}
+	}
+}
+
+func TestUpgradeQualities(t *testing.T) {
+	tests := []struct {
+		current, target int
+		want            []int
+	}{
+		{current: 1, target: 9, want: []int{9, 3}},
+		{current: 3, target: 9, want: []int{9}},
+		{current: 1, target: 3, want: []int{3}},
+		{current: 9, target: 9, want: nil},
+	}
+	for _, tt := range tests {
+		if got := upgradeQualities(tt.current, tt.target); !slices.Equal(got, tt.want) {
+			t.Fatalf("upgradeQualities(%d, %d) = %v, want %v", tt.current, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceUpgradedFileKeepsSidecars(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "01 - Song.mp3")
+	staged := filepath.Join(dir, "01 - Song"+upgradeStagingSuffix+".flac")
+	lyrics := filepath.Join(dir, "01 - Song.lrc")
+	for path, content := range map[string]string{original: "mp3", staged: "flac", lyrics: "[00:01.00]hi"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := replaceUpgradedFile(original, staged, ".flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "01 - Song.flac"); got != want {
+		t.Fatalf("replaceUpgradedFile() = %q, want %q", got, want)
+	}
+	if data, err := os.ReadFile(got); err != nil || string(data) != "flac" {
+		t.Fatalf("upgraded file = %q, %v", data, err)
+	}
+	for _, path := range []string{original, staged} {
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s still exists: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(lyrics); err != nil {
+		t.Fatalf("sidecar removed: %v", err)
+	}
+}
+
+func TestReplaceUpgradedFileSameExtension(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "song.mp3")
+	staged := filepath.Join(dir, "song"+upgradeStagingSuffix+".mp3")
+	if err := os.WriteFile(original, []byte("128"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(staged, []byte("320"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := replaceUpgradedFile(original, staged, ".mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(got); err != nil || got != original || string(data) != "320" {
+		t.Fatalf("replaceUpgradedFile() = %q (%q, %v)", got, data, err)
+	}
+}
+
+func TestPrintUpgradeReport(t *testing.T) {
+	var out bytes.Buffer
+	printUpgradeReport(&out, []upgradeResult{
+		{path: "a.mp3", newPath: "a.flac", from: 3, to: 9},
+		{path: "b.mp3", skipped: upgradeSkipNoSourceID},
+		{path: "c.flac", skipped: upgradeSkipAtTarget},
+		{path: "d.flac", skipped: upgradeSkipAtTarget},
+		{path: "e.mp3", err: errors.New("boom"), errClass: download.ErrorNetwork},
+	})
+	report := out.String()
+	for _, want := range []string{
+		"Upgraded 1 file",
+		"320 -> flac: a.flac",
+		"Skipped 3 files (already at target quality: 2, no SOURCEID tag: 1)",
+		"Failed 1 file",
+		"[network] e.mp3: boom",
+	} {
+		if !strings.Contains(report, want) {
+			t.Fatalf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestUpdateUpgradedPlaylists(t *testing.T) {
+	dir := t.TempDir()
+	album := filepath.Join(dir, "Album")
+	playlist := filepath.Join(dir, "Playlist", "Mix.m3u8")
+	if err := os.MkdirAll(filepath.Dir(playlist), 0755); err != nil {
+		t.Fatal(err)
+	}
+	upgraded := filepath.Join(album, "01 - Song.mp3")
+	absolute, err := filepath.Abs(upgraded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "#EXTM3U\n../Album/01 - Song.mp3\n../Album/02 - Other.mp3\n" + absolute + "\n"
+	if err := os.WriteFile(playlist, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := updateUpgradedPlaylists(dir, []upgradeResult{
+		{path: upgraded, newPath: filepath.Join(album, "01 - Song.flac"), from: 3, to: 9},
+		{path: filepath.Join(album, "02 - Other.mp3"), newPath: filepath.Join(album, "02 - Other.mp3"), from: 1, to: 3},
+	})
+	if err != nil || updated != 1 {
+		t.Fatalf("updateUpgradedPlaylists() = %d, %v", updated, err)
+	}
+	data, err := os.ReadFile(playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#EXTM3U\n../Album/01 - Song.flac\n../Album/02 - Other.mp3\n" + strings.TrimSuffix(absolute, ".mp3") + ".flac\n"
+	if string(data) != want {
+		t.Fatalf("playlist =\n%s\nwant\n%s", data, want)
+	}
+}
@@ -2,6 +2,7 @@ package dfi
 
 import (
 	"fmt"
+	"maps"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -64,8 +65,15 @@ func SaveLayout(track types.TrackType, info any, path string, trackNumber bool,
 	if strings.HasPrefix(path, "{") {
 		path = "." + string(filepath.Separator) + path
 	}
+	trackMap := utils.StructMap(track)
+	if track.EPISODE != nil {
+		// Episode fields such as SHOW_NAME are used directly, and episodes
+		// have no track number to prefix.
+		maps.Copy(trackMap, utils.StructMap(track.EPISODE))
+		trackNumber = false
+	}
 	return utils.SaveLayout(utils.SaveLayoutProps{
-		Track:                utils.StructMap(track),
+		Track:                trackMap,
 		Album:                utils.StructMap(info),
 		Path:                 path,
 		MinimumIntegerDigits: minDigits,
@@ -0,0 +1,248 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/d-fi/GoFi/internal/dfi"
+	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
+)
+
+const (
+	trackPending = "pending"
+	trackDone    = "done"
+	trackFailed  = "failed"
+)
+
+const (
+	// maxFinishedJobs is how many finished jobs the state file keeps.
+	maxFinishedJobs = 100
+	// jobSaveDelay is how long finished tracks wait to be saved together.
+	jobSaveDelay = 2 * time.Second
+)
+
+// jobPlan is everything needed to run a job again after a restart.
+type jobPlan struct {
+	LinkType     string            `json:"linkType"`
+	Info         any               `json:"info,omitempty"`
+	Tracks       []types.TrackType `json:"tracks"`
+	PathTemplate string            `json:"pathTemplate"`
+	Config       dfi.Config        `json:"config"`
+	Concurrency  int               `json:"concurrency"`
+}
+
+type storedJob struct {
+	downloadJob
+	Plan        *jobPlan `json:"plan,omitempty"`
+	TrackStates []string `json:"trackStates,omitempty"`
+}
+
+type jobStateFile struct {
+	NextID int64       `json:"nextId"`
+	Jobs   []storedJob `json:"jobs"`
+}
+
+func defaultStatePath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "d-fi.jobs.json")
+}
+
+// loadJobs restores the job list from the state file. Jobs that were still
+// queued or running are queued again to resume once a session is available.
+func (s *Server) loadJobs() error {
+	data, err := os.ReadFile(s.statePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var state jobStateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID = max(s.nextID, state.NextID)
+	for _, stored := range state.Jobs {
+		job := stored.downloadJob
+		job.plan = stored.Plan
+		job.trackState = stored.TrackStates
+		job.trackPct = map[int]float64{}
+		s.nextID = max(s.nextID, job.ID)
+		switch job.Status {
+		case "queued", "running":
+			if job.plan == nil || len(job.trackState) != len(job.plan.Tracks) {
+				job.Status = "error"
+				job.Error = "Unable to resume download"
+				job.Current = ""
+				break
+			}
+			job.Status = "queued"
+			job.Current = "Waiting to resume"
+			job.restored = true
+		case "canceling":
+			job.Status = "canceled"
+			job.Error = "Canceled by user"
+			job.Current = ""
+		}
+		s.jobs[job.ID] = &job
+	}
+	return nil
+}
+
+// saveJobs writes the jobs to the state file, replacing it atomically. Only
+// active jobs keep their plan and track states, which are what a resume
+// needs. Finished jobs past the newest maxFinishedJobs are dropped.
+func (s *Server) saveJobs() {
+	s.mu.Lock()
+	if s.saveTimer != nil {
+		s.saveTimer.Stop()
+		s.saveTimer = nil
+	}
+	jobs := make([]*downloadJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sortJobs(jobs)
+	state := jobStateFile{NextID: s.nextID, Jobs: make([]storedJob, 0, len(jobs))}
+	finished := 0
+	for _, job := range jobs {
+		stored := storedJob{downloadJob: *cloneJob(job)}
+		if isActiveJob(job) {
+			stored.Plan = job.plan
+			stored.TrackStates = append([]string(nil), job.trackState...)
+		} else {
+			finished++
+			if finished > maxFinishedJobs {
+				delete(s.jobs, job.ID)
+				continue
+			}
+		}
+		state.Jobs = append(state.Jobs, stored)
+	}
+	s.mu.Unlock()
+
+	if err := s.writeJobState(state); err != nil {
+		log.Printf("d-fi web unable to save jobs: %v", err)
+	}
+}
+
+// scheduleSaveJobs saves the jobs after jobSaveDelay. Calls made while a
+// save is pending share it, so a job finishing many tracks in a row writes
+// the state file once.
+func (s *Server) scheduleSaveJobs() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.saveTimer != nil {
+		return
+	}
+	s.saveTimer = time.AfterFunc(jobSaveDelay, s.saveJobs)
+}
+
+func (s *Server) writeJobState(state jobStateFile) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if dir := filepath.Dir(s.statePath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return utils.WriteFileAtomic(s.statePath, append(data, '\n'), 0644)
+}
+
+// resumeJobs starts the jobs restored from the state file. It is safe to call
+// more than once; each job is only resumed the first time.
+func (s *Server) resumeJobs() {
+	s.mu.Lock()
+	var resumable []*downloadJob
+	for _, job := range s.jobs {
+		if job.restored {
+			job.restored = false
+			resumable = append(resumable, job)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, job := range resumable {
+		s.mu.Lock()
+		plan := *job.plan
+		quality := job.Quality
+		s.mu.Unlock()
+
+		archive, err := s.downloadArchive(plan.Config.Archive)
+		if err != nil {
+			s.updateJob(job.ID, func(job *downloadJob) {
+				job.Status = "error"
+				job.Error = err.Error()
+				job.Current = ""
+			})
+			s.saveJobs()
+			continue
+		}
+		ctx := s.attachJobContext(job.ID)
+		log.Printf("d-fi web resuming job %d (%s)", job.ID, job.Source)
+		go s.runDownloadJob(ctx, job.ID, plan, quality, archive)
+	}
+}
+
+// hasRestoredJobs reports whether any restored job is waiting to resume.
+func (s *Server) hasRestoredJobs() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.restored {
+			return true
+		}
+	}
+	return false
+}
+
+// jobTempNames returns the resumable temp file names that queued and running
+// jobs may still pick up, for every quality a track could fall back to.
+func (s *Server) jobTempNames() map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := map[string]bool{}
+	for _, job := range s.jobs {
+		if job.plan == nil || (job.Status != "queued" && job.Status != "running") {
+			continue
+		}
+		for _, index := range pendingTracks(job.trackState) {
+			if index >= len(job.plan.Tracks) {
+				continue
+			}
+			track := job.plan.Tracks[index]
+			for _, quality := range []int{1, 3, 9} {
+				names[dfi.DownloadTempName(quality, track)] = true
+				if track.FALLBACK != nil {
+					fallback := track
+					fallback.SongType = *track.FALLBACK
+					names[dfi.DownloadTempName(quality, fallback)] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// pendingTracks returns the indexes of tracks that have not finished yet.
+func pendingTracks(states []string) []int {
+	pending := []int{}
+	for i, state := range states {
+		if state != trackDone {
+			pending = append(pending, i)
+		}
+	}
+	return pending
+}
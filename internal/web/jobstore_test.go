@@ -0,0 +1,249 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/d-fi/GoFi/internal/dfi"
+	"github.com/d-fi/GoFi/types"
+)
+
+func TestJobsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{ConfigPath: filepath.Join(dir, "d-fi.config.json")}
+	server := NewServer(opts)
+
+	var first, second types.TrackType
+	first.SNG_ID = "1"
+	second.SNG_ID = "2"
+	now := time.Now()
+	server.jobs[7] = &downloadJob{
+		ID:          7,
+		Source:      "https://www.deezer.com/album/302127",
+		Quality:     "flac",
+		Status:      "running",
+		TotalTracks: 2,
+		DoneTracks:  1,
+		Files:       []string{"Music/one.flac"},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		trackPct:    map[int]float64{1: 40},
+		plan: &jobPlan{
+			LinkType:     "album",
+			Info:         map[string]any{"ALB_TITLE": "Discovery"},
+			Tracks:       []types.TrackType{first, second},
+			PathTemplate: "Music/{ALB_TITLE}/{SNG_TITLE}",
+			Config:       dfi.Config{Concurrency: 2},
+			Concurrency:  2,
+		},
+		trackState: []string{trackDone, trackPending},
+	}
+	server.nextID = 7
+	server.saveJobs()
+
+	if _, err := os.Stat(filepath.Join(dir, "d-fi.jobs.json")); err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+
+	restarted := NewServer(opts)
+	job := restarted.jobs[7]
+	if job == nil {
+		t.Fatal("job was not restored")
+	}
+	if job.Status != "queued" || !job.restored {
+		t.Fatalf("Status = %q, restored = %v; want queued and restored", job.Status, job.restored)
+	}
+	if job.DoneTracks != 1 || !reflect.DeepEqual(job.Files, []string{"Music/one.flac"}) {
+		t.Fatalf("progress not restored: done=%d files=%v", job.DoneTracks, job.Files)
+	}
+	if job.plan == nil || len(job.plan.Tracks) != 2 || job.plan.Tracks[1].SNG_ID != "2" {
+		t.Fatalf("plan not restored: %+v", job.plan)
+	}
+	if got := pendingTracks(job.trackState); !reflect.DeepEqual(got, []int{1}) {
+		t.Fatalf("pendingTracks = %v, want [1]", got)
+	}
+	if !restarted.hasRestoredJobs() {
+		t.Fatal("expected restored jobs")
+	}
+	if restarted.nextID != 7 {
+		t.Fatalf("nextID = %d, want 7", restarted.nextID)
+	}
+}
+
+func TestRestoredJobStatuses(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{ConfigPath: filepath.Join(dir, "d-fi.config.json")}
+	server := NewServer(opts)
+	now := time.Now()
+	server.jobs[1] = &downloadJob{ID: 1, Status: "canceling", CreatedAt: now, UpdatedAt: now}
+	server.jobs[2] = &downloadJob{ID: 2, Status: "running", CreatedAt: now, UpdatedAt: now}
+	server.jobs[3] = &downloadJob{ID: 3, Status: "done", CreatedAt: now, UpdatedAt: now}
+	server.saveJobs()
+
+	restarted := NewServer(opts)
+	if got := restarted.jobs[1].Status; got != "canceled" {
+		t.Fatalf("canceling job Status = %q, want canceled", got)
+	}
+	if got := restarted.jobs[2].Status; got != "error" {
+		t.Fatalf("job without a plan Status = %q, want error", got)
+	}
+	if got := restarted.jobs[3].Status; got != "done" {
+		t.Fatalf("done job Status = %q, want done", got)
+	}
+	if restarted.hasRestoredJobs() {
+		t.Fatal("no job should be waiting to resume")
+	}
+}
+
+func TestRestoredJobKeepsTrackExtras(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{ConfigPath: filepath.Join(dir, "d-fi.config.json")}
+	server := NewServer(opts)
+
+	var song, episode types.TrackType
+	song.SNG_ID = "1"
+	position := 3
+	song.TRACK_POSITION = &position
+	song.FALLBACK = &types.SongType{SNG_ID: "2"}
+	episode.SNG_ID = "10"
+	episode.EPISODE = &types.ShowEpisodeType{EpisodeID: "10", ShowName: "Show"}
+	now := time.Now()
+	server.jobs[1] = &downloadJob{
+		ID:          1,
+		Status:      "queued",
+		TotalTracks: 2,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		plan: &jobPlan{
+			LinkType: "show",
+			Tracks:   []types.TrackType{song, episode},
+		},
+		trackState: []string{trackPending, trackPending},
+	}
+	server.saveJobs()
+
+	tracks := NewServer(opts).jobs[1].plan.Tracks
+	if tracks[0].TRACK_POSITION == nil || *tracks[0].TRACK_POSITION != 3 {
+		t.Fatalf("TRACK_POSITION = %v, want 3", tracks[0].TRACK_POSITION)
+	}
+	if tracks[0].FALLBACK == nil || tracks[0].FALLBACK.SNG_ID != "2" {
+		t.Fatalf("FALLBACK = %+v, want SNG_ID 2", tracks[0].FALLBACK)
+	}
+	if tracks[1].EPISODE == nil || tracks[1].EPISODE.ShowName != "Show" {
+		t.Fatalf("EPISODE = %+v, want show Show", tracks[1].EPISODE)
+	}
+}
+
+func TestCancelRestoredJob(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+	now := time.Now()
+	server.jobs[1] = &downloadJob{ID: 1, Status: "queued", CreatedAt: now, UpdatedAt: now, restored: true}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs/1/cancel", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /api/jobs/1/cancel status = %d", rec.Code)
+	}
+	if got := server.jobs[1].Status; got != "canceled" {
+		t.Fatalf("Status = %q, want canceled", got)
+	}
+	data, err := os.ReadFile(server.statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"status": "canceled"`) {
+		t.Fatalf("state file was not updated: %s", data)
+	}
+}
+
+func TestJobTempNames(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+	track := func(id string) types.TrackType {
+		var track types.TrackType
+		track.SNG_ID = id
+		track.MD5_ORIGIN = "md5"
+		return track
+	}
+	now := time.Now()
+	server.jobs[1] = &downloadJob{
+		ID: 1, Status: "running", CreatedAt: now, UpdatedAt: now,
+		plan:       &jobPlan{Tracks: []types.TrackType{track("1"), track("2")}},
+		trackState: []string{trackDone, trackPending},
+	}
+	server.jobs[2] = &downloadJob{
+		ID: 2, Status: "done", CreatedAt: now, UpdatedAt: now,
+		plan:       &jobPlan{Tracks: []types.TrackType{track("3")}},
+		trackState: []string{trackFailed},
+	}
+
+	names := server.jobTempNames()
+	if len(names) != 3 {
+		t.Fatalf("jobTempNames() = %v, want the 3 qualities of track 2", names)
+	}
+	for _, quality := range []int{1, 3, 9} {
+		if !names[dfi.DownloadTempName(quality, track("2"))] {
+			t.Fatalf("jobTempNames() is missing quality %d of track 2: %v", quality, names)
+		}
+	}
+}
+
+func TestSaveJobsPrunesFinishedJobs(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{ConfigPath: filepath.Join(dir, "d-fi.config.json")}
+	server := NewServer(opts)
+	now := time.Now()
+	for id := int64(1); id <= maxFinishedJobs+5; id++ {
+		updated := now.Add(time.Duration(id) * time.Second)
+		server.jobs[id] = &downloadJob{
+			ID:         id,
+			Status:     "done",
+			CreatedAt:  now,
+			UpdatedAt:  updated,
+			plan:       &jobPlan{Tracks: make([]types.TrackType, 1)},
+			trackState: []string{trackDone},
+		}
+	}
+	server.jobs[1].Status = "running"
+	server.saveJobs()
+
+	if len(server.jobs) != maxFinishedJobs+1 {
+		t.Fatalf("len(jobs) = %d, want %d", len(server.jobs), maxFinishedJobs+1)
+	}
+	if server.jobs[1] == nil || server.jobs[2] != nil || server.jobs[maxFinishedJobs+5] == nil {
+		t.Fatal("expected the active job and the newest finished jobs to be kept")
+	}
+	restarted := NewServer(opts)
+	if len(restarted.jobs) != maxFinishedJobs+1 {
+		t.Fatalf("restored %d jobs, want %d", len(restarted.jobs), maxFinishedJobs+1)
+	}
+	if restarted.jobs[1].plan == nil {
+		t.Fatal("active job lost its plan")
+	}
+	if restarted.jobs[maxFinishedJobs+5].plan != nil {
+		t.Fatal("finished job kept its plan")
+	}
+}
+
+func TestScheduleSaveJobsCoalesces(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+	server.scheduleSaveJobs()
+	first := server.saveTimer
+	server.scheduleSaveJobs()
+	if first == nil || server.saveTimer != first {
+		t.Fatal("expected one pending save")
+	}
+	server.saveJobs()
+	if server.saveTimer != nil {
+		t.Fatal("saveJobs did not clear the pending save")
+	}
+	if _, err := os.Stat(server.statePath); err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+}
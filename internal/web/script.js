@@ -2,6 +2,8 @@ const state = {
   tracks: [],
   options: [],
   previewQuery: "",
+  previewSnapshot: 0,
+  previewDisk: null,
   previewLinkType: "",
   layoutFields: null,
   config: null,
@@ -19,6 +21,12 @@ const settingsSections = {
       "cfgTrackNumber",
       "cfgFallbackTrack",
       "cfgFallbackQuality",
+      "cfgArchive",
+      "cfgOnExisting",
+      "cfgMaxBandwidth",
+      "cfgDiskReserve",
+      "cfgWorkDir",
+      "cfgTempMaxAge",
     ],
     label: "Download",
   },
@@ -29,6 +37,7 @@ const settingsSections = {
       "cfgLayoutAlbum",
       "cfgLayoutArtist",
       "cfgLayoutPlaylist",
+      "cfgLayoutShow",
     ],
     label: "Layout",
   },
@@ -37,6 +46,21 @@ const settingsSections = {
     inputs: ["cfgResolveFullPath"],
     label: "Playlist",
   },
+  discography: {
+    button: "saveDiscographyBtn",
+    inputs: [
+      "cfgReleaseAlbum",
+      "cfgReleaseSingle",
+      "cfgReleaseEP",
+      "cfgReleaseCompilation",
+      "cfgReleaseLive",
+      "cfgFromYear",
+      "cfgToYear",
+      "cfgFeatured",
+      "cfgEdition",
+    ],
+    label: "Discography",
+  },
   cover: {
     button: "saveCoverBtn",
     inputs: [
@@ -48,6 +72,36 @@ const settingsSections = {
     ],
     label: "Cover",
   },
+  lyrics: {
+    button: "saveLyricsBtn",
+    inputs: ["cfgLyricsUnsynced", "cfgLyricsSynced", "cfgLyricsFile"],
+    label: "Lyrics",
+  },
+  tags: {
+    button: "saveTagsBtn",
+    inputs: [
+      "cfgTagsDisabled",
+      "cfgTagsArtistSeparator",
+      "cfgTagsMultiArtists",
+      "cfgTagsTrackPadding",
+      "cfgTagsVorbisKeys",
+      "cfgTagsID3Version",
+      "cfgTagsID3v1",
+    ],
+    label: "Tags",
+  },
+};
+const lyricsModeInputs = {
+  unsynced: "cfgLyricsUnsynced",
+  synced: "cfgLyricsSynced",
+  lrc: "cfgLyricsFile",
+};
+const releaseTypeInputs = {
+  album: "cfgReleaseAlbum",
+  single: "cfgReleaseSingle",
+  ep: "cfgReleaseEP",
+  compilation: "cfgReleaseCompilation",
+  live: "cfgReleaseLive",
 };
 const coverSizes = [56, 250, 500, 1000, 1200, 1400, 1500, 1800];
 const minCoverSize = 50;
@@ -134,7 +188,10 @@ function fillConfig(cfg) {
   fillConfigSection("downloads", cfg);
   fillConfigSection("layout", cfg);
   fillConfigSection("playlist", cfg);
+  fillConfigSection("discography", cfg);
   fillConfigSection("cover", cfg);
+  fillConfigSection("lyrics", cfg);
+  fillConfigSection("tags", cfg);
   snapshotAllSettings();
 }
 function fillConfigSection(section, cfg) {
@@ -143,6 +200,12 @@ function fillConfigSection(section, cfg) {
     $("cfgTrackNumber").checked = !!cfg.trackNumber;
     $("cfgFallbackTrack").checked = !!cfg.fallbackTrack;
     $("cfgFallbackQuality").checked = !!cfg.fallbackQuality;
+    $("cfgArchive").value = cfg.archive || "";
+    $("cfgOnExisting").value = cfg.onExisting || "skip";
+    $("cfgMaxBandwidth").value = cfg.maxBandwidth || "";
+    $("cfgDiskReserve").value = cfg.diskReserve || "";
+    $("cfgWorkDir").value = cfg.workDir || "";
+    $("cfgTempMaxAge").value = cfg.tempMaxAge || "";
     return;
   }
   if (section === "layout") {
@@ -150,12 +213,24 @@ function fillConfigSection(section, cfg) {
     $("cfgLayoutAlbum").value = cfg.saveLayout?.album || "";
     $("cfgLayoutArtist").value = cfg.saveLayout?.artist || "";
     $("cfgLayoutPlaylist").value = cfg.saveLayout?.playlist || "";
+    $("cfgLayoutShow").value = cfg.saveLayout?.show || "";
     return;
   }
   if (section === "playlist") {
     $("cfgResolveFullPath").checked = !!cfg.playlist?.resolveFullPath;
     return;
   }
+  if (section === "discography") {
+    const types = cfg.discography?.types || [];
+    Object.entries(releaseTypeInputs).forEach(([type, id]) => {
+      $(id).checked = !types.length || types.includes(type);
+    });
+    $("cfgFromYear").value = cfg.discography?.fromYear || "";
+    $("cfgToYear").value = cfg.discography?.toYear || "";
+    $("cfgFeatured").checked = !!cfg.discography?.featured;
+    $("cfgEdition").value = cfg.discography?.edition || "both";
+    return;
+  }
   if (section === "cover") {
     setCoverSizeValue("cfgCover128", cfg.coverSize?.["128"] || 500);
     setCoverSizeValue("cfgCover320", cfg.coverSize?.["320"] || 500);
@@ -163,6 +238,26 @@ function fillConfigSection(section, cfg) {
     $("cfgCoverMode").value = cfg.cover?.mode || "embed";
     $("cfgCoverFileName").value = cfg.cover?.fileName || "cover.jpg";
     syncCoverFileName();
+    return;
+  }
+  if (section === "lyrics") {
+    const modes = cfg.lyrics?.modes || ["unsynced"];
+    Object.entries(lyricsModeInputs).forEach(([mode, id]) => {
+      $(id).checked = modes.includes(mode);
+    });
+    return;
+  }
+  if (section === "tags") {
+    const tags = cfg.tags || {};
+    $("cfgTagsDisabled").value = (tags.disabled || []).join(", ");
+    $("cfgTagsArtistSeparator").value = tags.artistSeparator || "";
+    $("cfgTagsMultiArtists").checked = !!tags.multiValueArtists;
+    $("cfgTagsTrackPadding").value = tags.trackNumberPadding || 2;
+    $("cfgTagsVorbisKeys").value = Object.entries(tags.vorbisKeys || {})
+      .map(([field, key]) => field + "=" + key)
+      .join(", ");
+    $("cfgTagsID3Version").value = String(tags.id3Version || 4);
+    $("cfgTagsID3v1").checked = !!tags.id3v1;
   }
 }
 function fillCoverSizeOptions() {
@@ -198,6 +293,12 @@ function readConfigSection(section) {
       trackNumber: $("cfgTrackNumber").checked,
       fallbackTrack: $("cfgFallbackTrack").checked,
       fallbackQuality: $("cfgFallbackQuality").checked,
+      archive: $("cfgArchive").value.trim(),
+      onExisting: $("cfgOnExisting").value,
+      maxBandwidth: $("cfgMaxBandwidth").value.trim(),
+      diskReserve: $("cfgDiskReserve").value.trim(),
+      workDir: $("cfgWorkDir").value.trim(),
+      tempMaxAge: $("cfgTempMaxAge").value.trim(),
     };
   }
   if (section === "layout") {
@@ -206,6 +307,7 @@ function readConfigSection(section) {
       album: $("cfgLayoutAlbum").value,
       artist: $("cfgLayoutArtist").value,
       playlist: $("cfgLayoutPlaylist").value,
+      show: $("cfgLayoutShow").value,
     };
   }
   if (section === "playlist") {
@@ -213,6 +315,17 @@ function readConfigSection(section) {
       resolveFullPath: $("cfgResolveFullPath").checked,
     };
   }
+  if (section === "discography") {
+    return {
+      types: Object.entries(releaseTypeInputs)
+        .filter(([, id]) => $(id).checked)
+        .map(([type]) => type),
+      fromYear: Number($("cfgFromYear").value || 0),
+      toYear: Number($("cfgToYear").value || 0),
+      featured: $("cfgFeatured").checked,
+      edition: $("cfgEdition").value || "both",
+    };
+  }
   if (section === "cover") {
     return {
       coverSize: {
@@ -226,8 +339,37 @@ function readConfigSection(section) {
       },
     };
   }
+  if (section === "lyrics") {
+    return {
+      modes: Object.entries(lyricsModeInputs)
+        .filter(([, id]) => $(id).checked)
+        .map(([mode]) => mode),
+    };
+  }
+  if (section === "tags") {
+    return {
+      disabled: splitList($("cfgTagsDisabled").value),
+      artistSeparator: $("cfgTagsArtistSeparator").value,
+      multiValueArtists: $("cfgTagsMultiArtists").checked,
+      trackNumberPadding: Number($("cfgTagsTrackPadding").value || 2),
+      vorbisKeys: Object.fromEntries(
+        splitList($("cfgTagsVorbisKeys").value).map((pair) => {
+          const [field, key = ""] = pair.split("=");
+          return [field.trim(), key.trim()];
+        }),
+      ),
+      id3Version: Number($("cfgTagsID3Version").value || 4),
+      id3v1: $("cfgTagsID3v1").checked,
+    };
+  }
   return {};
 }
+function splitList(value) {
+  return value
+    .split(",")
+    .map((item) => item.trim())
+    .filter(Boolean);
+}
 function snapshotAllSettings() {
   Object.keys(settingsSections).forEach(snapshotSettingsSection);
 }
@@ -262,13 +404,25 @@ function readConfigForSection(section) {
     cfg.trackNumber = values.trackNumber;
     cfg.fallbackTrack = values.fallbackTrack;
     cfg.fallbackQuality = values.fallbackQuality;
+    cfg.archive = values.archive;
+    cfg.onExisting = values.onExisting;
+    cfg.maxBandwidth = values.maxBandwidth;
+    cfg.diskReserve = values.diskReserve;
+    cfg.workDir = values.workDir;
+    cfg.tempMaxAge = values.tempMaxAge;
   } else if (section === "layout") {
     cfg.saveLayout = values;
   } else if (section === "playlist") {
     cfg.playlist = values;
+  } else if (section === "discography") {
+    cfg.discography = values;
   } else if (section === "cover") {
     cfg.coverSize = values.coverSize;
     cfg.cover = values.cover;
+  } else if (section === "lyrics") {
+    cfg.lyrics = values;
+  } else if (section === "tags") {
+    cfg.tags = values;
   }
   return cfg;
 }
@@ -377,6 +531,7 @@ async function preview() {
   setMainMessage("Fetching preview...");
   try {
     state.previewQuery = "";
+    state.previewDisk = null;
     state.previewLinkType = "";
     state.layoutFields = null;
     state.options = [];
@@ -399,11 +554,14 @@ async function preview() {
       return;
     }
     const query = buildQuery();
+    const snapshot = snapshotSize();
     const data = await api("/api/preview", {
       method: "POST",
-      body: JSON.stringify({ query }),
+      body: JSON.stringify({ query, snapshot }),
     });
     state.previewQuery = query;
+    state.previewSnapshot = snapshot;
+    state.previewDisk = data.disk || null;
     state.previewLinkType = data.linkType || "";
     state.layoutFields = data.layoutFields || null;
     state.tracks = data.tracks || [];
@@ -426,6 +584,7 @@ function renderTracks() {
   body.innerHTML = state.tracks
     .map(
       (track, rowIndex) =>
+        groupHeader(track, state.tracks[rowIndex - 1]) +
         "<tr>" +
         '<td class="col-select"><input type="checkbox" data-index="' +
         track.index +
@@ -461,6 +620,14 @@ function renderTracks() {
   });
   syncSelectAllTracks();
 }
+function groupHeader(track, previous) {
+  if (!track.group || (previous && previous.group === track.group)) return "";
+  return (
+    '<tr class="group-row"><td colspan="6">' +
+    escapeHTML(track.group) +
+    "</td></tr>"
+  );
+}
 function syncSelectAllTracks(updateRange = true) {
   const selectAll = $("selectAllTracks");
   const downloadBtn = $("downloadSelectedBtn");
@@ -471,12 +638,42 @@ function syncSelectAllTracks(updateRange = true) {
   selectAll.checked = checkboxes.length > 0 && checked === checkboxes.length;
   selectAll.indeterminate = checked > 0 && checked < checkboxes.length;
   downloadBtn.disabled = checked === 0;
-  selectionCount.textContent =
-    checked + " of " + checkboxes.length + " selected";
+  const estimate = selectedSize(checkboxes);
+  const disk = state.previewDisk;
+  let summary = checked + " of " + checkboxes.length + " selected";
+  if (estimate > 0) summary += ", about " + formatBytes(estimate);
+  if (disk) summary += ", " + formatBytes(disk.free) + " free";
+  selectionCount.textContent = summary;
+  selectionCount.classList.toggle(
+    "over-space",
+    !!disk && estimate > disk.free - disk.reserve,
+  );
   if (updateRange) {
     $("trackRange").value = selectedRowsToRange(checkboxes);
   }
 }
+function selectedSize(checkboxes) {
+  const quality = $("quality").value;
+  const sizes = new Map(
+    state.tracks.map((track) => [track.index, track.sizes?.[quality] || 0]),
+  );
+  return checkboxes
+    .filter((el) => el.checked)
+    .reduce(
+      (total, el) => total + (sizes.get(Number(el.dataset.index)) || 0),
+      0,
+    );
+}
+function formatBytes(size) {
+  const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+  let value = size;
+  let unit = 0;
+  while (value >= 1024 && unit < units.length - 1) {
+    value /= 1024;
+    unit++;
+  }
+  return (unit ? value.toFixed(1) : value) + " " + units[unit];
+}
 function toggleAllTracks() {
   document
     .querySelectorAll("[data-index]")
@@ -589,13 +786,18 @@ function renderOptions() {
     button.addEventListener("click", async () => {
       const option = state.options[Number(button.dataset.optionIndex)];
       state.previewQuery = option.url;
+      state.previewSnapshot = snapshotSize();
       setMainMessage("Fetching tracks...");
       try {
         const data = await api("/api/preview", {
           method: "POST",
-          body: JSON.stringify({ query: option.url }),
+          body: JSON.stringify({
+            query: option.url,
+            snapshot: state.previewSnapshot,
+          }),
         });
         state.tracks = data.tracks || [];
+        state.previewDisk = data.disk || null;
         state.previewLinkType = data.linkType || "";
         state.layoutFields = data.layoutFields || null;
         state.options = [];
@@ -625,22 +827,41 @@ async function startDownload() {
     query: state.previewQuery,
     quality: $("quality").value,
     tracks: selected,
+    onExisting: $("onExisting").value,
+    snapshot: state.previewSnapshot || 0,
   };
   saveSelectedQuality();
   setMainMessage("Starting download...");
   try {
-    await api("/api/downloads", {
-      method: "POST",
-      body: JSON.stringify(body),
-    });
+    const data = await queueDownload(body);
     setMainMessage("");
-    showToast("Download queued.");
+    const queued = data.jobs?.length || 1;
+    showToast(queued > 1 ? queued + " downloads queued." : "Download queued.");
     await loadJobs();
   } catch (err) {
     showToast(err.message, "error");
     setMainMessage("");
   }
 }
+async function queueDownload(body) {
+  try {
+    return await api("/api/downloads", {
+      method: "POST",
+      body: JSON.stringify(body),
+    });
+  } catch (err) {
+    if (
+      err.status !== 507 ||
+      !confirm(err.message + "\n\nDownload anyway?")
+    ) {
+      throw err;
+    }
+    return api("/api/downloads", {
+      method: "POST",
+      body: JSON.stringify({ ...body, skipSpaceCheck: true }),
+    });
+  }
+}
 async function loadJobs() {
   if (state.jobsLoading) return;
   state.jobsLoading = true;
@@ -681,13 +902,24 @@ function syncClearHistoryButton(jobs) {
 function buildQuery() {
   const value = $("query").value.trim();
   const type = $("queryType").value;
+  if (type === "me") return "me:" + (value || "loved");
   if (!value || type === "auto" || looksLikeURL(value)) return value;
   return type + ":" + value;
 }
+function snapshotSize() {
+  const size = Number($("snapshot").value);
+  return Number.isInteger(size) && size > 0 ? size : 0;
+}
 function needsOptionSelection() {
   const value = $("query").value.trim();
   const type = $("queryType").value;
-  return value && type !== "auto" && !looksLikeURL(value);
+  return value && type !== "auto" && type !== "me" && !looksLikeURL(value);
+}
+function syncQueryPlaceholder() {
+  $("query").placeholder =
+    $("queryType").value === "me"
+      ? "loved, playlists, albums, artists or flow"
+      : "Paste a music URL or enter search text";
 }
 function looksLikeURL(value) {
   return (
@@ -814,8 +1046,12 @@ function renderJobs(jobs) {
         action +
         "</div>" +
         (job.error
-          ? '<div class="error">' + escapeHTML(job.error) + "</div>"
+          ? '<div class="error">' +
+            (job.errorClass ? "[" + escapeHTML(job.errorClass) + "] " : "") +
+            escapeHTML(job.error) +
+            "</div>"
           : "") +
+        jobFailures(job) +
         (files ? '<div class="files">' + files + "</div>" : "") +
         "</div>"
       );
@@ -827,6 +1063,24 @@ function renderJobs(jobs) {
     );
   });
 }
+function jobFailures(job) {
+  const failures = job.failures || [];
+  if (failures.length < 2) return "";
+  return (
+    '<div class="files">' +
+    failures
+      .map(
+        (item) =>
+          "<div>[" +
+          escapeHTML(item.class) +
+          "] " +
+          escapeHTML(item.title) +
+          "</div>",
+      )
+      .join("") +
+    "</div>"
+  );
+}
 function jobTitle(job) {
   if (job.status === "running") return "Downloading";
   if (job.status === "done") return "Done";
@@ -899,8 +1153,14 @@ $("toggleArlBtn").addEventListener("click", () =>
 $("saveDownloadsBtn").addEventListener("click", () => saveConfig("downloads"));
 $("saveLayoutBtn").addEventListener("click", () => saveConfig("layout"));
 $("savePlaylistBtn").addEventListener("click", () => saveConfig("playlist"));
+$("saveDiscographyBtn").addEventListener("click", () =>
+  saveConfig("discography"),
+);
 $("saveCoverBtn").addEventListener("click", () => saveConfig("cover"));
+$("saveLyricsBtn").addEventListener("click", () => saveConfig("lyrics"));
+$("saveTagsBtn").addEventListener("click", () => saveConfig("tags"));
 $("layoutFieldsBtn").addEventListener("click", openLayoutFields);
+$("queryType").addEventListener("change", syncQueryPlaceholder);
 $("closeLayoutFieldsBtn").addEventListener("click", () =>
   $("layoutFieldsDialog").close(),
 );
@@ -913,7 +1173,10 @@ $("previewBtn").addEventListener("click", preview);
 $("query").addEventListener("keydown", (event) => {
   if (event.key === "Enter") preview();
 });
-$("quality").addEventListener("change", saveSelectedQuality);
+$("quality").addEventListener("change", () => {
+  saveSelectedQuality();
+  syncSelectAllTracks(false);
+});
 $("downloadSelectedBtn").addEventListener("click", startDownload);
 $("clearHistoryBtn").addEventListener("click", clearHistory);
 $("selectAllTracks").addEventListener("change", toggleAllTracks);
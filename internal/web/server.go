@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
@@ -17,6 +18,8 @@ import (
 	"time"
 
 	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/converter"
+	"github.com/d-fi/GoFi/download"
 	"github.com/d-fi/GoFi/internal/dfi"
 	"github.com/d-fi/GoFi/metadata"
 	"github.com/d-fi/GoFi/request"
@@ -24,20 +27,35 @@ import (
 	"github.com/d-fi/GoFi/utils"
 )
 
+// tempSweepInterval is how often a running server looks for stale resumable
+// temp files.
+const tempSweepInterval = time.Hour
+
 type Options struct {
 	Addr       string
 	ConfigPath string
+	// StatePath is where jobs are persisted. It defaults to d-fi.jobs.json
+	// next to the config file.
+	StatePath string
 }
 
 type Server struct {
-	cfgPath string
-	mux     *http.ServeMux
+	cfgPath   string
+	statePath string
+	mux       *http.ServeMux
+	stateMu   sync.Mutex
 
 	mu      sync.Mutex
 	cfg     dfi.Config
 	session sessionState
 	jobs    map[int64]*downloadJob
 	nextID  int64
+	archive *dfi.Archive
+	// archiveRefs counts the holders of each open archive. An archive that
+	// is no longer configured is closed once its count drops to zero.
+	archiveRefs map[*dfi.Archive]int
+	// saveTimer is the pending save of scheduleSaveJobs.
+	saveTimer *time.Timer
 }
 
 type sessionState struct {
@@ -47,20 +65,33 @@ type sessionState struct {
 }
 
 type downloadJob struct {
-	ID          int64     `json:"id"`
-	Source      string    `json:"source"`
-	Quality     string    `json:"quality"`
-	Status      string    `json:"status"`
-	TotalTracks int       `json:"totalTracks"`
-	DoneTracks  int       `json:"doneTracks"`
-	Progress    float64   `json:"progress"`
-	Current     string    `json:"current,omitempty"`
-	Error       string    `json:"error,omitempty"`
-	Files       []string  `json:"files,omitempty"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          int64        `json:"id"`
+	Source      string       `json:"source"`
+	Quality     string       `json:"quality"`
+	Status      string       `json:"status"`
+	TotalTracks int          `json:"totalTracks"`
+	DoneTracks  int          `json:"doneTracks"`
+	Progress    float64      `json:"progress"`
+	Current     string       `json:"current,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	ErrorClass  string       `json:"errorClass,omitempty"`
+	Failures    []jobFailure `json:"failures,omitempty"`
+	Files       []string     `json:"files,omitempty"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
 	cancel      context.CancelFunc
 	trackPct    map[int]float64
+	plan        *jobPlan
+	trackState  []string
+	restored    bool
+}
+
+// jobFailure is a track that failed and the class of its final error.
+type jobFailure struct {
+	Index int    `json:"index"`
+	Title string `json:"title"`
+	Class string `json:"class"`
+	Error string `json:"error"`
 }
 
 type configResponse struct {
@@ -71,6 +102,10 @@ type configResponse struct {
 
 type previewRequest struct {
 	Query string `json:"query"`
+	// Discography overrides the configured artist filter for this preview.
+	Discography *converter.DiscographyFilter `json:"discography"`
+	// Snapshot keeps the first Snapshot tracks of a mix in a dated playlist.
+	Snapshot int `json:"snapshot"`
 }
 
 type searchOptionsRequest struct {
@@ -82,6 +117,8 @@ type previewResponse struct {
 	LinkType     string           `json:"linkType"`
 	Tracks       []trackPreview   `json:"tracks"`
 	LayoutFields layoutFieldGroup `json:"layoutFields"`
+	// Disk is the free space and reserve of the volume the tracks save to.
+	Disk *dfi.SpaceCheck `json:"disk,omitempty"`
 }
 
 type layoutFieldGroup struct {
@@ -103,24 +140,38 @@ type trackPreview struct {
 	Artist   string `json:"artist"`
 	Album    string `json:"album"`
 	Duration int    `json:"duration"`
+	// Group names the playlist, album or artist a track belongs to when the
+	// link stands for several of them.
+	Group string `json:"group,omitempty"`
+	// Sizes estimates the file size for each quality, after fallback.
+	Sizes map[string]int64 `json:"sizes,omitempty"`
 }
 
 type startRequest struct {
-	Query   string `json:"query"`
-	Quality string `json:"quality"`
-	Tracks  []int  `json:"tracks"`
+	Query      string `json:"query"`
+	Quality    string `json:"quality"`
+	Tracks     []int  `json:"tracks"`
+	OnExisting string `json:"onExisting"`
+	// Discography overrides the configured artist filter for this job.
+	Discography *converter.DiscographyFilter `json:"discography"`
+	Snapshot    int                          `json:"snapshot"`
+	// SkipSpaceCheck starts the job even when it does not fit on disk.
+	SkipSpaceCheck bool `json:"skipSpaceCheck"`
 }
 
 type jobResponse struct {
 	Job *downloadJob `json:"job"`
+	// Jobs lists every job started, one per group for profile links.
+	Jobs []*downloadJob `json:"jobs,omitempty"`
 }
 
 func Run(ctx context.Context, opts Options) error {
-	if _, err := dfi.CleanupStaleDownloadTemps(".", time.Hour); err != nil {
-		log.Printf("d-fi web stale resumable cleanup failed: %v", err)
-	}
-
 	srv := NewServer(opts)
+	srv.cleanupDownloadTemps()
+	go srv.sweepDownloadTemps(ctx)
+	if _, err := dfi.CleanupPartialFiles(srv.currentConfig().Layouts(), time.Hour); err != nil {
+		log.Printf("d-fi web partial file cleanup failed: %v", err)
+	}
 	server := &http.Server{
 		Addr:              opts.Addr,
 		Handler:           srv,
@@ -148,15 +199,51 @@ func Run(ctx context.Context, opts Options) error {
 	}
 }
 
+// cleanupDownloadTemps removes stale resumable temp files. Temps of queued and
+// running jobs are kept however old they are, so restored jobs can resume.
+func (s *Server) cleanupDownloadTemps() {
+	keep := s.jobTempNames()
+	removed, err := dfi.CleanupDownloadTemps(s.currentConfig(), func(name string) bool { return keep[name] })
+	if err != nil {
+		log.Printf("d-fi web stale resumable cleanup failed: %v", err)
+	} else if removed > 0 {
+		log.Printf("d-fi web removed %d stale resumable files", removed)
+	}
+}
+
+// sweepDownloadTemps repeats the cleanup while the server runs, so a long
+// running server does not collect temps of downloads that were given up.
+func (s *Server) sweepDownloadTemps(ctx context.Context) {
+	ticker := time.NewTicker(tempSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.cleanupDownloadTemps()
+		}
+	}
+}
+
 func NewServer(opts Options) *Server {
 	if opts.ConfigPath == "" {
 		opts.ConfigPath = "d-fi.config.json"
 	}
+	if opts.StatePath == "" {
+		opts.StatePath = defaultStatePath(opts.ConfigPath)
+	}
 	s := &Server{
-		cfgPath: opts.ConfigPath,
-		cfg:     dfi.LoadConfig(opts.ConfigPath),
-		mux:     http.NewServeMux(),
-		jobs:    map[int64]*downloadJob{},
+		cfgPath:     opts.ConfigPath,
+		statePath:   opts.StatePath,
+		cfg:         dfi.LoadConfig(opts.ConfigPath),
+		mux:         http.NewServeMux(),
+		jobs:        map[int64]*downloadJob{},
+		archiveRefs: map[*dfi.Archive]int{},
+	}
+	dfi.SetMaxBandwidth(s.cfg.MaxBandwidthBytes())
+	if err := s.loadJobs(); err != nil {
+		log.Printf("d-fi web unable to load jobs from %s: %v", s.statePath, err)
 	}
 	s.routes()
 	return s
@@ -174,6 +261,7 @@ func (s *Server) routes() {
 	s.mux.HandleFunc("PUT /api/config", s.handleUpdateConfig)
 	s.mux.HandleFunc("POST /api/search-options", s.handleSearchOptions)
 	s.mux.HandleFunc("POST /api/preview", s.handlePreview)
+	s.mux.HandleFunc("POST /api/plan", s.handlePlan)
 	s.mux.HandleFunc("POST /api/downloads", s.handleStartDownload)
 	s.mux.HandleFunc("GET /api/jobs", s.handleJobs)
 	s.mux.HandleFunc("DELETE /api/jobs", s.handleClearJobs)
@@ -211,6 +299,41 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	maxBandwidth, err := dfi.ParseBandwidth(cfg.MaxBandwidth)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if cfg.TempMaxAge != "" {
+		if _, err := dfi.ParseTempMaxAge(cfg.TempMaxAge); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if _, err := dfi.ParseDiskReserve(cfg.DiskReserve); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	onExisting, err := dfi.ParseExistingPolicy(string(cfg.OnExisting))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	discography, err := cfg.Discography.Normalize()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	lyricsModes, err := metadata.NormalizeLyricsModes(cfg.Lyrics.Modes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	tagProfile, err := cfg.Tags.Normalize()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
 
 	s.mu.Lock()
 	newARL := strings.TrimSpace(cfg.Cookies.ARL)
@@ -233,9 +356,28 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 	if cfg.Cover.FileName != "" {
 		s.cfg.Cover.FileName = metadata.NormalizeCoverFileName(cfg.Cover.FileName)
 	}
+	if cfg.Lyrics.Modes != nil {
+		s.cfg.Lyrics.Modes = lyricsModes
+	}
+	s.cfg.Tags = tagProfile
 	s.cfg.Cookies = cfg.Cookies
+	s.cfg.Archive = strings.TrimSpace(cfg.Archive)
+	s.cfg.MaxBandwidth = strings.TrimSpace(cfg.MaxBandwidth)
+	if cfg.DiskReserve != "" {
+		s.cfg.DiskReserve = strings.TrimSpace(cfg.DiskReserve)
+	}
+	s.cfg.Retry = dfi.NormalizeRetryConfig(cfg.Retry, s.cfg.Retry)
+	s.cfg.WorkDir = strings.TrimSpace(cfg.WorkDir)
+	if cfg.TempMaxAge != "" {
+		s.cfg.TempMaxAge = strings.TrimSpace(cfg.TempMaxAge)
+	}
+	if cfg.OnExisting != "" {
+		s.cfg.OnExisting = onExisting
+	}
+	s.cfg.Discography = discography
 	cfgToSave := s.cfg
 	s.mu.Unlock()
+	dfi.SetMaxBandwidth(maxBandwidth)
 
 	if err := cfgToSave.Save(); err != nil {
 		writeError(w, http.StatusInternalServerError, err)
@@ -260,15 +402,30 @@ func (s *Server) handlePreview(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
-	res, err := s.resolveInput(strings.TrimSpace(req.Query))
+	parse, err := parseOptions(s.currentConfig(), req.Discography, req.Snapshot)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := s.resolveInput(strings.TrimSpace(req.Query), parse)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	layoutSource := res
+	if len(res.Groups) > 0 {
+		layoutSource = res.Groups[0]
+	}
+	cfg := s.currentConfig()
+	tracks := previewResolved(res)
+	for i, track := range res.Tracks {
+		tracks[i].Sizes = previewSizes(cfg, track)
+	}
 	writeJSON(w, http.StatusOK, previewResponse{
 		LinkType:     res.LinkType,
-		Tracks:       previewTracks(res.Tracks),
-		LayoutFields: layoutFields(res.LinkType, res.LinkInfo, res.Tracks),
+		Tracks:       tracks,
+		LayoutFields: layoutFields(layoutSource.LinkType, layoutSource.LinkInfo, layoutSource.Tracks),
+		Disk:         previewDisk(cfg, layoutSource),
 	})
 }
 
@@ -292,49 +449,205 @@ func (s *Server) handleSearchOptions(w http.ResponseWriter, r *http.Request) {
 	}{Options: options})
 }
 
-func (s *Server) handleStartDownload(w http.ResponseWriter, r *http.Request) {
+// preparedDownload is a start request resolved into tracks and settings.
+type preparedDownload struct {
+	cfg          dfi.Config
+	label        string
+	res          dfi.ResolvedInput
+	tracks       []types.TrackType
+	pathTemplate string
+	archive      *dfi.Archive
+	source       string
+	// groups splits a profile link into one download each.
+	groups         []preparedDownload
+	skipSpaceCheck bool
+}
+
+// downloads returns the downloads a prepared request starts.
+func (p preparedDownload) downloads() []preparedDownload {
+	if len(p.groups) > 0 {
+		return p.groups
+	}
+	return []preparedDownload{p}
+}
+
+// prepareDownload resolves a start request. The returned status is the HTTP
+// status to report with the error.
+func (s *Server) prepareDownload(r *http.Request) (preparedDownload, int, error) {
+	var prepared preparedDownload
 	if err := s.ensureSession(); err != nil {
-		writeError(w, http.StatusUnauthorized, err)
-		return
+		return prepared, http.StatusUnauthorized, err
 	}
 
 	var req startRequest
 	if err := readJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
+		return prepared, http.StatusBadRequest, err
 	}
 	req.Query = strings.TrimSpace(req.Query)
 	if req.Query == "" {
-		writeError(w, http.StatusBadRequest, fmt.Errorf("missing URL or search"))
-		return
+		return prepared, http.StatusBadRequest, fmt.Errorf("missing URL or search")
 	}
 
-	cfg := s.currentConfig()
+	prepared.cfg = s.currentConfig()
 	_, _, label, err := dfi.ParseQualityStrict(req.Quality)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
+		return prepared, http.StatusBadRequest, err
+	}
+	prepared.label = label
+	if req.OnExisting != "" {
+		// The job's config snapshot carries the policy for this job only.
+		prepared.cfg.OnExisting, err = dfi.ParseExistingPolicy(req.OnExisting)
+		if err != nil {
+			return prepared, http.StatusBadRequest, err
+		}
+	}
+	parse, err := parseOptions(prepared.cfg, req.Discography, req.Snapshot)
+	if err != nil {
+		return prepared, http.StatusBadRequest, err
+	}
+	prepared.res, err = s.resolveInput(req.Query, parse)
+	if err != nil {
+		return prepared, http.StatusBadRequest, err
+	}
+	prepared.tracks = dfi.SelectTracksByIndexes(prepared.res.Tracks, req.Tracks)
+	if len(prepared.tracks) == 0 {
+		return prepared, http.StatusBadRequest, fmt.Errorf("no tracks selected")
+	}
+	prepared.pathTemplate = prepared.cfg.Layout(prepared.res.LinkType)
+	prepared.archive, err = s.downloadArchive(prepared.cfg.Archive)
+	if err != nil {
+		return prepared, http.StatusInternalServerError, err
+	}
+	prepared.source = req.Query
+	prepared.skipSpaceCheck = req.SkipSpaceCheck
+	for _, group := range dfi.SelectGroupsByIndexes(prepared.res.Groups, req.Tracks) {
+		part := prepared
+		part.groups = nil
+		part.res = group
+		part.tracks = group.Tracks
+		part.pathTemplate = prepared.cfg.Layout(group.LinkType)
+		if group.Info.ID != "" {
+			part.source = "https://www.deezer.com/" + group.Info.Type + "/" + group.Info.ID
+		}
+		prepared.groups = append(prepared.groups, part)
+	}
+	return prepared, 0, nil
+}
+
+// handlePlan answers what a download request would save without fetching any
+// media.
+func (s *Server) handlePlan(w http.ResponseWriter, r *http.Request) {
+	prepared, status, err := s.prepareDownload(r)
+	if err != nil {
+		writeError(w, status, err)
 		return
 	}
+	defer s.releaseArchive(prepared.archive)
+	plans := make([]dfi.DownloadPlan, 0, len(prepared.groups))
+	for _, part := range prepared.downloads() {
+		plans = append(plans, part.plan())
+	}
+	if len(prepared.groups) == 0 {
+		writeJSON(w, http.StatusOK, plans[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Plans []dfi.DownloadPlan `json:"plans"`
+	}{Plans: plans})
+}
+
+// plan returns what p would save, the way a dry run does.
+func (p preparedDownload) plan() dfi.DownloadPlan {
+	cfg := p.cfg
+	return dfi.PlanDownloads(p.tracks, dfi.PlanOptions{
+		LinkType:        p.res.LinkType,
+		Quality:         p.label,
+		Info:            p.res.LinkInfo,
+		Path:            p.pathTemplate,
+		TrackNumber:     cfg.TrackNumber,
+		FallbackTrack:   cfg.FallbackTrack,
+		FallbackQuality: cfg.FallbackQuality,
+		CoverMode:       cfg.Cover.Mode,
+		CoverFileName:   cfg.Cover.FileName,
+		Archive:         p.archive,
+		OnExisting:      cfg.OnExisting,
+	})
+}
+
+// checkDiskSpace compares the estimated size of every download of prepared
+// with the free space of the volume the first one saves to. ok is false when
+// nothing could be checked.
+func checkDiskSpace(prepared preparedDownload) (check dfi.SpaceCheck, ok bool) {
+	var estimate int64
+	dir := ""
+	for _, part := range prepared.downloads() {
+		plan := part.plan()
+		estimate += plan.TotalSize
+		if dir == "" {
+			dir = dfi.PlanDir(plan)
+		}
+	}
+	if dir == "" {
+		return check, false
+	}
+	check, err := dfi.CheckDiskSpace(dir, estimate, prepared.cfg.DiskReserveBytes())
+	if err != nil {
+		log.Printf("d-fi web unable to check free disk space: %v", err)
+		return check, false
+	}
+	return check, true
+}
+
+func (s *Server) handleStartDownload(w http.ResponseWriter, r *http.Request) {
+	prepared, status, err := s.prepareDownload(r)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	defer s.releaseArchive(prepared.archive)
+	if !prepared.skipSpaceCheck {
+		if check, ok := checkDiskSpace(prepared); ok && !check.Enough {
+			writeJSON(w, http.StatusInsufficientStorage, map[string]any{"error": check.Err().Error(), "disk": check})
+			return
+		}
+	}
+	var jobs []*downloadJob
+	for _, part := range prepared.downloads() {
+		jobs = append(jobs, s.startJob(part))
+	}
+	response := jobResponse{Job: jobs[0]}
+	if len(prepared.groups) > 0 {
+		response.Jobs = jobs
+	}
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+// startJob queues a job for prepared and returns a snapshot of it.
+func (s *Server) startJob(prepared preparedDownload) *downloadJob {
+	cfg, label, res, tracks, pathTemplate, archive := prepared.cfg, prepared.label, prepared.res, prepared.tracks, prepared.pathTemplate, prepared.archive
 	concurrency := cfg.Concurrency
 	if concurrency <= 0 {
 		concurrency = 1
 	}
-	res, err := s.resolveInput(req.Query)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, err)
-		return
+
+	plan := jobPlan{
+		LinkType:     res.LinkType,
+		Info:         res.LinkInfo,
+		Tracks:       tracks,
+		PathTemplate: pathTemplate,
+		Config:       cfg,
+		Concurrency:  concurrency,
 	}
-	tracks := dfi.SelectTracksByIndexes(res.Tracks, req.Tracks)
-	if len(tracks) == 0 {
-		writeError(w, http.StatusBadRequest, fmt.Errorf("no tracks selected"))
-		return
+	plan.Config.Cookies = dfi.Cookies{}
+	trackState := make([]string, len(tracks))
+	for i := range trackState {
+		trackState[i] = trackPending
 	}
-	pathTemplate := cfg.Layout(res.LinkType)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	job := &downloadJob{
 		ID:          atomic.AddInt64(&s.nextID, 1),
-		Source:      req.Query,
+		Source:      prepared.source,
 		Quality:     label,
 		Status:      "queued",
 		TotalTracks: len(tracks),
@@ -342,13 +655,17 @@ func (s *Server) handleStartDownload(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt:   time.Now(),
 		cancel:      cancel,
 		trackPct:    map[int]float64{},
+		plan:        &plan,
+		trackState:  trackState,
 	}
 	s.mu.Lock()
 	s.jobs[job.ID] = job
 	s.mu.Unlock()
+	s.saveJobs()
 
-	go s.runDownloadJob(ctx, job.ID, res.LinkType, res.LinkInfo, tracks, pathTemplate, label, cfg, concurrency)
-	writeJSON(w, http.StatusAccepted, jobResponse{Job: s.snapshotJob(job.ID)})
+	s.retainArchive(archive)
+	go s.runDownloadJob(ctx, job.ID, plan, label, archive)
+	return s.snapshotJob(job.ID)
 }
 
 func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
@@ -370,6 +687,7 @@ func (s *Server) handleClearJobs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	s.mu.Unlock()
+	s.saveJobs()
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -386,7 +704,14 @@ func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, fmt.Errorf("job not found"))
 		return
 	}
-	if live.Status == "queued" || live.Status == "running" {
+	if live.restored {
+		// Not resumed yet, so there is no worker left to finish canceling.
+		live.restored = false
+		live.Status = "canceled"
+		live.Current = ""
+		live.Error = "Canceled by user"
+		live.UpdatedAt = time.Now()
+	} else if live.Status == "queued" || live.Status == "running" {
 		live.Status = "canceling"
 		live.Current = "Canceling download"
 		live.Error = ""
@@ -396,21 +721,33 @@ func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
 		live.cancel()
 	}
 	s.mu.Unlock()
+	s.saveJobs()
 	writeJSON(w, http.StatusOK, jobResponse{Job: s.snapshotJob(id)})
 }
 
-func (s *Server) runDownloadJob(ctx context.Context, jobID int64, linkType string, info any, tracks []types.TrackType, pathTemplate, quality string, cfg dfi.Config, concurrency int) {
+// runDownloadJob runs a job to the end and then releases the reference to
+// archive its caller took.
+func (s *Server) runDownloadJob(ctx context.Context, jobID int64, plan jobPlan, quality string, archive *dfi.Archive) {
+	defer s.releaseArchive(archive)
+	var pending []int
 	s.updateJob(jobID, func(job *downloadJob) {
 		job.Status = "running"
+		job.Error = ""
+		job.ErrorClass = ""
+		job.Failures = nil
+		pending = pendingTracks(job.trackState)
 	})
 
-	sem := make(chan struct{}, concurrency)
+	linkType, info, tracks, pathTemplate, cfg := plan.LinkType, plan.Info, plan.Tracks, plan.PathTemplate, plan.Config
+	sem := make(chan struct{}, max(1, plan.Concurrency))
 	var wg sync.WaitGroup
 	var failed atomic.Int64
 	coverPolicy := dfi.CoverFilePolicy(tracks, info, pathTemplate, cfg.TrackNumber)
+	retry := cfg.Retry.Policy()
 
 trackLoop:
-	for i, track := range tracks {
+	for _, i := range pending {
+		track := tracks[i]
 		if ctx.Err() != nil {
 			break
 		}
@@ -437,11 +774,17 @@ trackLoop:
 				CoverMode:       cfg.Cover.Mode,
 				CoverFileName:   cfg.Cover.FileName,
 				CoverFilePolicy: coverPolicy,
+				Lyrics:          cfg.Lyrics.Modes,
+				TagProfile:      cfg.Tags,
 				Path:            pathTemplate,
 				TotalTracks:     len(tracks),
 				TrackNumber:     cfg.TrackNumber,
 				FallbackTrack:   cfg.FallbackTrack,
 				FallbackQuality: cfg.FallbackQuality,
+				Archive:         archive,
+				WorkDir:         cfg.TempDir(),
+				OnExisting:      cfg.OnExisting,
+				Retry:           retry,
 				Hooks: dfi.DownloadTrackHooks{
 					Status: func(message string) {
 						s.updateJob(jobID, func(job *downloadJob) {
@@ -468,24 +811,35 @@ trackLoop:
 						return
 					}
 					failed.Add(1)
+					class := string(download.Classify(err))
 					job.Error = err.Error()
+					job.ErrorClass = class
+					job.Failures = append(job.Failures, jobFailure{
+						Index: index,
+						Title: track.SNG_TITLE + " - " + track.ART_NAME,
+						Class: class,
+						Error: err.Error(),
+					})
+					job.trackState[index] = trackFailed
 				} else {
 					if ctx.Err() != nil {
 						return
 					}
 					job.DoneTracks++
 					job.Progress = jobProgress(job)
+					job.trackState[index] = trackDone
 					if path != "" {
 						job.Files = append(job.Files, path)
 					}
 				}
 			})
+			s.scheduleSaveJobs()
 		}(i, track)
 	}
 
 	wg.Wait()
 	playlistPath := ""
-	if ctx.Err() == nil && failed.Load() == 0 && linkType == "playlist" && os.Getenv("SIMULATE") == "" {
+	if ctx.Err() == nil && failed.Load() == 0 && linkType == "playlist" {
 		if job := s.snapshotJob(jobID); job != nil && len(job.Files) > 1 {
 			var err error
 			playlistPath, err = dfi.WritePlaylistFile(info, job.Files, cfg.Playlist.ResolveFullPath)
@@ -494,6 +848,7 @@ trackLoop:
 					job.Status = "error"
 					job.Error = err.Error()
 				})
+				s.saveJobs()
 				return
 			}
 		}
@@ -516,14 +871,53 @@ trackLoop:
 			job.Files = append(job.Files, playlistPath)
 		}
 	})
+	s.saveJobs()
+}
+
+// attachJobContext gives a job a fresh cancelable context.
+func (s *Server) attachJobContext(id int64) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.updateJob(id, func(job *downloadJob) {
+		job.cancel = cancel
+	})
+	return ctx
+}
+
+// discographyFilter returns the artist filter a request asked for, or the
+// configured one when it sent none.
+func discographyFilter(cfg dfi.Config, override *converter.DiscographyFilter) (converter.DiscographyFilter, error) {
+	if override == nil {
+		return cfg.Discography, nil
+	}
+	return override.Normalize()
 }
 
-func (s *Server) resolveInput(query string) (dfi.ResolvedInput, error) {
+// parseOptions returns how a request resolves its query: with its own
+// discography filter or the configured one, and its mix snapshot size.
+func parseOptions(cfg dfi.Config, discography *converter.DiscographyFilter, snapshot int) (converter.ParseOptions, error) {
+	filter, err := discographyFilter(cfg, discography)
+	if err != nil {
+		return converter.ParseOptions{}, err
+	}
+	if snapshot < 0 {
+		return converter.ParseOptions{}, fmt.Errorf("invalid snapshot size %d", snapshot)
+	}
+	return converter.ParseOptions{Discography: filter, Snapshot: snapshot}, nil
+}
+
+func (s *Server) resolveInput(query string, parse converter.ParseOptions) (dfi.ResolvedInput, error) {
 	if query == "" {
 		return dfi.ResolvedInput{}, fmt.Errorf("missing URL or search")
 	}
+	if dfi.IsMeInput(query) {
+		url, err := dfi.MeProfileURL(query)
+		if err != nil {
+			return dfi.ResolvedInput{}, err
+		}
+		return s.resolveInput(url, parse)
+	}
 	if dfi.LooksLikeURL(query) {
-		data, err := dfi.ParseResolvedURL(query)
+		data, err := dfi.ParseResolvedURL(query, parse)
 		if err != nil {
 			return dfi.ResolvedInput{}, err
 		}
@@ -531,6 +925,15 @@ func (s *Server) resolveInput(query string) (dfi.ResolvedInput, error) {
 		if data.LinkType == "playlist" {
 			tracks = dfi.DedupePlaylistTracks(tracks)
 		}
+		if len(data.Groups) > 0 {
+			tracks = nil
+			for i, group := range data.Groups {
+				if group.LinkType == "playlist" {
+					data.Groups[i].Tracks = dfi.DedupePlaylistTracks(group.Tracks)
+				}
+				tracks = append(tracks, data.Groups[i].Tracks...)
+			}
+		}
 		data.Tracks = tracks
 		return data, nil
 	}
@@ -541,19 +944,25 @@ func (s *Server) resolveInput(query string) (dfi.ResolvedInput, error) {
 		if err != nil {
 			return dfi.ResolvedInput{}, err
 		}
-		return s.resolveInput(url)
+		return s.resolveInput(url, parse)
 	case strings.HasPrefix(query, "album:"):
 		url, err := dfi.FirstSearchResultURL("album", strings.TrimPrefix(query, "album:"))
 		if err != nil {
 			return dfi.ResolvedInput{}, err
 		}
-		return s.resolveInput(url)
+		return s.resolveInput(url, parse)
 	case strings.HasPrefix(query, "playlist:"):
 		url, err := dfi.FirstSearchResultURL("playlist", strings.TrimPrefix(query, "playlist:"))
 		if err != nil {
 			return dfi.ResolvedInput{}, err
 		}
-		return s.resolveInput(url)
+		return s.resolveInput(url, parse)
+	case strings.HasPrefix(query, "radio:"):
+		url, err := dfi.FirstSearchResultURL("radio", strings.TrimPrefix(query, "radio:"))
+		if err != nil {
+			return dfi.ResolvedInput{}, err
+		}
+		return s.resolveInput(url, parse)
 	default:
 		data, err := dfi.ResolveTrackSearch(query)
 		if err != nil {
@@ -563,6 +972,64 @@ func (s *Server) resolveInput(query string) (dfi.ResolvedInput, error) {
 	}
 }
 
+// downloadArchive returns the archive opened from path, reopening it when the
+// configured path changes. Jobs already running keep the archive they started
+// with. The caller holds a reference to the returned archive and must pass it
+// to releaseArchive.
+func (s *Server) downloadArchive(path string) (*dfi.Archive, error) {
+	path = strings.TrimSpace(path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path == "" {
+		return nil, nil
+	}
+	if s.archive == nil || s.archive.Path() != path {
+		archive, err := dfi.OpenArchive(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open download archive: %w", err)
+		}
+		previous := s.archive
+		s.archive = archive
+		s.closeUnusedArchive(previous)
+	}
+	s.archiveRefs[s.archive]++
+	return s.archive, nil
+}
+
+// retainArchive takes another reference to archive.
+func (s *Server) retainArchive(archive *dfi.Archive) {
+	if archive == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.archiveRefs[archive]++
+}
+
+// releaseArchive drops a reference to archive taken by downloadArchive or
+// retainArchive.
+func (s *Server) releaseArchive(archive *dfi.Archive) {
+	if archive == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.archiveRefs[archive]--
+	s.closeUnusedArchive(archive)
+}
+
+// closeUnusedArchive closes archive when it is no longer the configured one
+// and nothing holds it. s.mu must be held.
+func (s *Server) closeUnusedArchive(archive *dfi.Archive) {
+	if archive == nil || archive == s.archive || s.archiveRefs[archive] > 0 {
+		return
+	}
+	delete(s.archiveRefs, archive)
+	if err := archive.Close(); err != nil {
+		log.Printf("d-fi web unable to close download archive: %v", err)
+	}
+}
+
 func (s *Server) currentConfig() dfi.Config {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -624,6 +1091,7 @@ func (s *Server) connectWithARL(arl string) (sessionState, error) {
 	}
 	state := sessionState{Ready: true, UserName: user.BlogName}
 	s.setSession(state)
+	go s.resumeJobs()
 	return state, nil
 }
 
@@ -660,7 +1128,10 @@ func cloneJob(job *downloadJob) *downloadJob {
 	out := *job
 	out.cancel = nil
 	out.trackPct = nil
+	out.plan = nil
+	out.trackState = nil
 	out.Files = append([]string(nil), job.Files...)
+	out.Failures = append([]jobFailure(nil), job.Failures...)
 	return &out
 }
 
@@ -696,6 +1167,48 @@ func sortJobs(jobs []*downloadJob) {
 	})
 }
 
+// previewResolved lists the tracks of res, naming the group of each track
+// when res has groups.
+func previewResolved(res dfi.ResolvedInput) []trackPreview {
+	if len(res.Groups) == 0 {
+		return previewTracks(res.Tracks)
+	}
+	out := previewTracks(res.Tracks)
+	row := 0
+	for _, group := range res.Groups {
+		title := dfi.GroupTitle(group)
+		for i := range group.Tracks {
+			out[row].Group = title
+			out[row].Position = i + 1
+			row++
+		}
+	}
+	return out
+}
+
+// previewSizes estimates the size of track in every quality.
+func previewSizes(cfg dfi.Config, track types.TrackType) map[string]int64 {
+	sizes := map[string]int64{}
+	for _, quality := range []string{"128", "320", "flac"} {
+		sizes[quality] = dfi.EstimateTrackSize(track, quality, cfg.FallbackTrack, cfg.FallbackQuality)
+	}
+	return sizes
+}
+
+// previewDisk returns the free space and reserve of the volume res saves to,
+// or nil when it cannot be read.
+func previewDisk(cfg dfi.Config, res dfi.ResolvedInput) *dfi.SpaceCheck {
+	if len(res.Tracks) == 0 {
+		return nil
+	}
+	path := dfi.SaveLayout(res.Tracks[0], res.LinkInfo, cfg.Layout(res.LinkType), cfg.TrackNumber, len(res.Tracks))
+	check, err := dfi.CheckDiskSpace(filepath.Dir(path), 0, cfg.DiskReserveBytes())
+	if err != nil {
+		return nil
+	}
+	return &check
+}
+
 func previewTracks(tracks []types.TrackType) []trackPreview {
 	out := make([]trackPreview, 0, len(tracks))
 	for i, track := range tracks {
@@ -728,11 +1241,20 @@ func layoutFields(linkType string, info any, tracks []types.TrackType) layoutFie
 	if linkType == "playlist" {
 		fields.Always = append(fields.Always, layoutField{Key: "TITLE", Scope: "playlist"})
 	}
+	if linkType == "show" {
+		fields.Always = append(fields.Always,
+			layoutField{Key: "SHOW_NAME", Scope: "show"},
+			layoutField{Key: "EPISODE_TITLE", Scope: "show"},
+		)
+	}
 
 	current := map[string]layoutField{}
 	addLayoutFields(current, "info", utils.StructMap(info))
 	if len(tracks) > 0 {
 		addLayoutFields(current, "track", utils.StructMap(tracks[0]))
+		if tracks[0].EPISODE != nil {
+			addLayoutFields(current, "show", utils.StructMap(tracks[0].EPISODE))
+		}
 	}
 	if date := layoutReleaseDate(current); date != "" {
 		current["RELEASE_DATE"] = layoutField{Key: "RELEASE_DATE", Scope: "derived", Sample: date}
@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/d-fi/GoFi/converter"
+	"github.com/d-fi/GoFi/internal/dfi"
+	"github.com/d-fi/GoFi/metadata"
 	"github.com/d-fi/GoFi/types"
 )
 
@@ -120,6 +125,184 @@ func TestConfigUpdatePreservesCoverSettingsWhenMissing(t *testing.T) {
 	}
 }
 
+func TestConfigUpdateAppliesMaxBandwidth(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+	t.Cleanup(func() { dfi.SetMaxBandwidth(0) })
+
+	req := httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "maxBandwidth": "4MiB/s"}`)))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /api/config status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := server.currentConfig().MaxBandwidth; got != "4MiB/s" {
+		t.Fatalf("MaxBandwidth = %q, want 4MiB/s", got)
+	}
+	if got := dfi.MaxBandwidth(); got != 4*1024*1024 {
+		t.Fatalf("live bandwidth limit = %d, want %d", got, 4*1024*1024)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "maxBandwidth": "fast"}`)))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT /api/config with invalid bandwidth status = %d", rec.Code)
+	}
+	if got := dfi.MaxBandwidth(); got != 4*1024*1024 {
+		t.Fatalf("invalid update changed the live limit to %d", got)
+	}
+}
+
+func TestConfigUpdateDiskReserve(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "diskReserve": "5GiB"}`)))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /api/config status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := server.currentConfig().DiskReserve; got != "5GiB" {
+		t.Fatalf("DiskReserve = %q, want 5GiB", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "diskReserve": "most"}`)))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT /api/config with invalid diskReserve status = %d", rec.Code)
+	}
+}
+
+func TestConfigUpdateLyricsModes(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "lyrics": {"modes": ["synced", "lrc"]}}`)))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /api/config status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := server.currentConfig().Lyrics.Modes; len(got) != 2 || got[0] != metadata.LyricsSynced || got[1] != metadata.LyricsFile {
+		t.Fatalf("Lyrics.Modes = %v, want synced and lrc", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "lyrics": {"modes": ["karaoke"]}}`)))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT /api/config with invalid lyrics mode status = %d", rec.Code)
+	}
+}
+
+func TestConfigUpdateTags(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "tags": {"disabled": ["source"], "vorbisKeys": {"organization": "PUBLISHER"}}}`)))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /api/config status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	tags := server.currentConfig().Tags
+	if len(tags.Disabled) != 1 || tags.Disabled[0] != "SOURCE" || tags.VorbisKeys["ORGANIZATION"] != "PUBLISHER" {
+		t.Fatalf("Tags = %+v", tags)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "tags": {"vorbisKeys": {"SOURCEID": "DEEZERID"}}}`)))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT /api/config with a renamed SOURCEID status = %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "tags": {"id3Version": 2}}`)))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT /api/config with id3Version 2 status = %d", rec.Code)
+	}
+}
+
+func TestConfigUpdateOnExisting(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "onExisting": "rename"}`)))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /api/config status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := server.currentConfig().OnExisting; got != dfi.ExistingRename {
+		t.Fatalf("OnExisting = %q, want rename", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(`{"concurrency": 2, "onExisting": "replace"}`)))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT /api/config with invalid onExisting status = %d", rec.Code)
+	}
+	if got := server.currentConfig().OnExisting; got != dfi.ExistingRename {
+		t.Fatalf("invalid update changed OnExisting to %q", got)
+	}
+}
+
+func TestConfigUpdateDiscography(t *testing.T) {
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+
+	body := `{"concurrency": 2, "discography": {"types": ["single", "EP"], "toYear": 2010, "edition": "explicit"}}`
+	req := httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /api/config status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	want := converter.DiscographyFilter{Types: []string{"single", "ep"}, ToYear: 2010, Edition: "explicit"}
+	if got := server.currentConfig().Discography; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Discography = %+v, want %+v", got, want)
+	}
+
+	body = `{"concurrency": 2, "discography": {"fromYear": 2011, "toYear": 2010}}`
+	req = httptest.NewRequest(http.MethodPut, "/api/config", bytes.NewReader([]byte(body)))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT /api/config with invalid discography status = %d", rec.Code)
+	}
+	if got := server.currentConfig().Discography; !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid update changed Discography to %+v", got)
+	}
+}
+
+func TestDiscographyFilterOverride(t *testing.T) {
+	cfg := dfi.Config{Discography: converter.DiscographyFilter{Types: []string{"album"}, Edition: "both"}}
+
+	filter, err := discographyFilter(cfg, nil)
+	if err != nil || !reflect.DeepEqual(filter, cfg.Discography) {
+		t.Fatalf("discographyFilter(nil) = %+v, %v", filter, err)
+	}
+	filter, err = discographyFilter(cfg, &converter.DiscographyFilter{Types: []string{"live"}, Featured: true})
+	if err != nil || !reflect.DeepEqual(filter.Types, []string{"live"}) || !filter.Featured || filter.Edition != "both" {
+		t.Fatalf("discographyFilter(override) = %+v, %v", filter, err)
+	}
+	if _, err := discographyFilter(cfg, &converter.DiscographyFilter{Edition: "radio"}); err == nil {
+		t.Fatal("expected an invalid override to be rejected")
+	}
+}
+
+func TestParseOptionsSnapshot(t *testing.T) {
+	cfg := dfi.Config{Discography: converter.DiscographyFilter{Types: []string{"album"}, Edition: "both"}}
+
+	parse, err := parseOptions(cfg, nil, 30)
+	if err != nil || parse.Snapshot != 30 || !reflect.DeepEqual(parse.Discography, cfg.Discography) {
+		t.Fatalf("parseOptions = %+v, %v", parse, err)
+	}
+	if _, err := parseOptions(cfg, nil, -5); err == nil {
+		t.Fatal("expected a negative snapshot size to be rejected")
+	}
+}
+
 func TestConfigUpdateNormalizesCoverSizes(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "d-fi.config.json")
 	server := NewServer(Options{ConfigPath: path})
@@ -311,3 +494,113 @@ func layoutFieldSample(fields []layoutField, key string) string {
 	}
 	return ""
 }
+
+func TestPlanRequiresSession(t *testing.T) {
+	t.Setenv("DEEZER_ARL", "")
+	server := NewServer(Options{ConfigPath: filepath.Join(t.TempDir(), "d-fi.config.json")})
+
+	body := bytes.NewReader([]byte(`{"query":"https://www.deezer.com/track/3135556","quality":"flac"}`))
+	req := httptest.NewRequest(http.MethodPost, "/api/plan", body)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("POST /api/plan status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestPreviewResolvedNamesGroups(t *testing.T) {
+	group := func(title string, ids ...string) dfi.ResolvedInput {
+		res := dfi.ResolvedInput{LinkType: "playlist", LinkInfo: types.PlaylistInfo{Title: title}}
+		for _, id := range ids {
+			var track types.TrackType
+			track.SNG_ID = id
+			res.Tracks = append(res.Tracks, track)
+		}
+		return res
+	}
+	first, second := group("Road Trip", "1", "2"), group("Focus", "3")
+	res := dfi.ResolvedInput{
+		LinkType: "profile",
+		Tracks:   append(append([]types.TrackType{}, first.Tracks...), second.Tracks...),
+		Groups:   []dfi.ResolvedInput{first, second},
+	}
+
+	got := previewResolved(res)
+	if len(got) != 3 {
+		t.Fatalf("previewResolved returned %d rows, want 3", len(got))
+	}
+	want := []struct {
+		index, position int
+		group           string
+	}{{0, 1, "Road Trip"}, {1, 2, "Road Trip"}, {2, 1, "Focus"}}
+	for i, row := range got {
+		if row.Index != want[i].index || row.Position != want[i].position || row.Group != want[i].group {
+			t.Fatalf("row %d = %+v, want %+v", i, row, want[i])
+		}
+	}
+}
+
+func TestCheckDiskSpaceUsesReserve(t *testing.T) {
+	var track types.TrackType
+	track.SNG_ID = "1"
+	track.SNG_TITLE = "One"
+	track.FILESIZE_MP3_320 = 9_000_000
+	prepared := preparedDownload{
+		cfg:          dfi.Config{DiskReserve: "0"},
+		label:        "320",
+		res:          dfi.ResolvedInput{LinkType: "track"},
+		tracks:       []types.TrackType{track},
+		pathTemplate: filepath.Join(t.TempDir(), "{SNG_TITLE}"),
+	}
+
+	check, ok := checkDiskSpace(prepared)
+	if !ok || check.Estimate != 9_000_000 || check.Free <= 0 {
+		t.Fatalf("checkDiskSpace = %+v, %v", check, ok)
+	}
+
+	prepared.cfg.DiskReserve = "1000000GiB"
+	if check, ok := checkDiskSpace(prepared); !ok || check.Enough {
+		t.Fatalf("checkDiskSpace with a huge reserve = %+v, %v", check, ok)
+	}
+}
+
+func TestPreviewSizesApplyFallback(t *testing.T) {
+	var track types.TrackType
+	track.FILESIZE_MP3_128 = 4_000_000
+	track.FILESIZE_MP3_320 = 9_000_000
+
+	sizes := previewSizes(dfi.Config{FallbackQuality: true}, track)
+	if sizes["128"] != 4_000_000 || sizes["320"] != 9_000_000 || sizes["flac"] != 9_000_000 {
+		t.Fatalf("previewSizes = %v", sizes)
+	}
+	if sizes := previewSizes(dfi.Config{}, track); sizes["flac"] != 0 {
+		t.Fatalf("previewSizes without fallback = %v", sizes)
+	}
+}
+
+func TestReplacedArchiveClosesAfterLastJob(t *testing.T) {
+	dir := t.TempDir()
+	server := NewServer(Options{ConfigPath: filepath.Join(dir, "d-fi.config.json")})
+
+	first, err := server.downloadArchive(filepath.Join(dir, "first.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := server.downloadArchive(filepath.Join(dir, "second.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Record(dfi.ArchiveEntry{SngID: "1", Quality: 3}); err != nil {
+		t.Fatalf("archive in use was closed: %v", err)
+	}
+
+	server.releaseArchive(first)
+	if err := first.Record(dfi.ArchiveEntry{SngID: "2", Quality: 3}); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("replaced archive Record() error = %v, want closed", err)
+	}
+	server.releaseArchive(second)
+	if err := second.Record(dfi.ArchiveEntry{SngID: "1", Quality: 3}); err != nil {
+		t.Fatalf("configured archive was closed: %v", err)
+	}
+	second.Close()
+}
@@ -11,7 +11,7 @@ import (
 	"time"
 
 	"github.com/d-fi/GoFi/logger"
-	"github.com/d-fi/GoFi/request"
+	"github.com/d-fi/GoFi/types"
 	"github.com/d-fi/GoFi/utils"
 	"github.com/hashicorp/golang-lru/v2/expirable"
 )
@@ -46,42 +46,72 @@ var albumCoverURL = func(albumPicture string, albumCoverSize int) string {
 		albumPicture, albumCoverSize, albumCoverSize)
 }
 
+var showArtURL = func(showArt string, size int) string {
+	return fmt.Sprintf("https://e-cdns-images.dzcdn.net/images/talk/%s/%dx%d-000000-80-0-0.jpg",
+		showArt, size, size)
+}
+
 // DownloadAlbumCover downloads an album cover based on the provided album picture hash and cover size.
-func DownloadAlbumCover(albumPicture string, albumCoverSize int) ([]byte, error) {
+func (c *Client) DownloadAlbumCover(albumPicture string, albumCoverSize int) ([]byte, error) {
 	logger.Debug("Attempting to download album cover with hash: %s and size: %d", albumPicture, albumCoverSize)
 
 	if albumPicture == "" {
 		logger.Debug("Album picture hash is empty.")
 		return nil, errors.New("album picture hash is empty")
 	}
+	return c.downloadImage("album cover", fmt.Sprintf("%s%d", albumPicture, albumCoverSize), albumCoverSize, func() string {
+		return albumCoverURL(albumPicture, albumCoverSize)
+	})
+}
 
-	if !IsValidCoverSize(albumCoverSize) {
-		logger.Debug("Invalid cover size requested: %d", albumCoverSize)
-		return nil, fmt.Errorf("invalid cover size: %d", albumCoverSize)
+// DownloadShowArt downloads the artwork of a podcast show.
+func (c *Client) DownloadShowArt(showArt string, size int) ([]byte, error) {
+	logger.Debug("Attempting to download show art with hash: %s and size: %d", showArt, size)
+
+	if showArt == "" {
+		return nil, errors.New("show art hash is empty")
+	}
+	return c.downloadImage("show art", fmt.Sprintf("talk/%s%d", showArt, size), size, func() string {
+		return showArtURL(showArt, size)
+	})
+}
+
+// DownloadTrackCover downloads the album cover of track, or the show art
+// when track is a podcast episode.
+func (c *Client) DownloadTrackCover(track types.TrackType, size int) ([]byte, error) {
+	if track.EPISODE != nil {
+		return c.DownloadShowArt(track.EPISODE.ShowArtMD5, size)
+	}
+	return c.DownloadAlbumCover(track.ALB_PICTURE, size)
+}
+
+func (c *Client) downloadImage(what, cacheKey string, size int, imageURL func() string) ([]byte, error) {
+	if !IsValidCoverSize(size) {
+		logger.Debug("Invalid cover size requested: %d", size)
+		return nil, fmt.Errorf("invalid cover size: %d", size)
 	}
 
-	cacheKey := fmt.Sprintf("%s%d", albumPicture, albumCoverSize)
 	if cachedData, ok := albumCoverCache.Get(cacheKey); ok {
-		logger.Debug("Album cover retrieved from cache: %s", cacheKey)
+		logger.Debug("%s retrieved from cache: %s", what, cacheKey)
 		return cachedData, nil
 	}
 
-	url := albumCoverURL(albumPicture, albumCoverSize)
-	logger.Debug("Downloading album cover from URL: %s", url)
+	url := imageURL()
+	logger.Debug("Downloading %s from URL: %s", what, url)
 
-	resp, err := request.Client.R().Get(url)
+	resp, err := c.session.Client.R().Get(url)
 	if err != nil {
-		logger.Debug("Failed to download album cover: %v", err)
-		return nil, fmt.Errorf("failed to download album cover: %w", err)
+		logger.Debug("Failed to download %s: %v", what, err)
+		return nil, fmt.Errorf("failed to download %s: %w", what, err)
 	}
 	if resp.StatusCode() < http.StatusOK || resp.StatusCode() >= http.StatusMultipleChoices {
-		logger.Debug("Failed to download album cover: %s", resp.Status())
-		return nil, fmt.Errorf("failed to download album cover: %s", resp.Status())
+		logger.Debug("Failed to download %s: %s", what, resp.Status())
+		return nil, fmt.Errorf("failed to download %s: %s", what, resp.Status())
 	}
 
 	data := resp.Body()
 	albumCoverCache.Add(cacheKey, data)
-	logger.Debug("Album cover downloaded and cached successfully: %s", cacheKey)
+	logger.Debug("%s downloaded and cached successfully: %s", what, cacheKey)
 
 	return data, nil
 }
@@ -108,11 +138,24 @@ func NormalizeCoverFileName(fileName string) string {
 	return fileName
 }
 
-func SaveAlbumCoverFile(dir string, fileName string, albumPicture string, albumCoverSize int) (string, error) {
-	cover, err := DownloadAlbumCover(albumPicture, albumCoverSize)
+func (c *Client) SaveAlbumCoverFile(dir string, fileName string, albumPicture string, albumCoverSize int) (string, error) {
+	cover, err := c.DownloadAlbumCover(albumPicture, albumCoverSize)
+	if err != nil {
+		return "", err
+	}
+	return saveCoverFile(dir, fileName, cover)
+}
+
+// SaveTrackCoverFile saves the cover DownloadTrackCover picks for track.
+func (c *Client) SaveTrackCoverFile(dir string, fileName string, track types.TrackType, size int) (string, error) {
+	cover, err := c.DownloadTrackCover(track, size)
 	if err != nil {
 		return "", err
 	}
+	return saveCoverFile(dir, fileName, cover)
+}
+
+func saveCoverFile(dir string, fileName string, cover []byte) (string, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", err
 	}
@@ -124,7 +167,7 @@ func SaveAlbumCoverFile(dir string, fileName string, albumPicture string, albumC
 		logger.Debug("Skipping cover file because %s already exists with different data", path)
 		return "", nil
 	}
-	if err := os.WriteFile(path, cover, 0644); err != nil {
+	if err := utils.WriteFileAtomic(path, cover, 0644); err != nil {
 		return "", err
 	}
 	return path, nil
@@ -36,11 +36,9 @@ func TestDownloadAlbumCover(t *testing.T) {
 }
 
 func TestDownloadAlbumCoverDoesNotCacheHTTPError(t *testing.T) {
-	previousClient := request.Client
 	previousCache := albumCoverCache
 	previousAlbumCoverURL := albumCoverURL
 	t.Cleanup(func() {
-		request.Client = previousClient
 		albumCoverCache = previousCache
 		albumCoverURL = previousAlbumCoverURL
 	})
@@ -57,17 +55,19 @@ func TestDownloadAlbumCoverDoesNotCacheHTTPError(t *testing.T) {
 	}))
 	t.Cleanup(server.Close)
 
-	request.Client = resty.New()
+	session := request.NewSession()
+	session.Client = resty.New()
+	client := New(session)
 	albumCoverCache = expirable.NewLRU[string, []byte](cacheSize, nil, cacheTTL)
 	albumCoverURL = func(albumPicture string, albumCoverSize int) string {
 		return server.URL
 	}
 
-	_, err := DownloadAlbumCover(ALB_PICTURE, 500)
+	_, err := client.DownloadAlbumCover(ALB_PICTURE, 500)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "403 Forbidden")
 
-	cover, err := DownloadAlbumCover(ALB_PICTURE, 500)
+	cover, err := client.DownloadAlbumCover(ALB_PICTURE, 500)
 	require.NoError(t, err)
 	assert.Equal(t, []byte("jpeg"), cover)
 	assert.Equal(t, 2, requests)
@@ -0,0 +1,61 @@
+package metadata
+
+import (
+	"github.com/d-fi/GoFi/api"
+	"github.com/d-fi/GoFi/request"
+	"github.com/d-fi/GoFi/types"
+)
+
+// Client downloads covers and tag data through one session. The package-level
+// functions use the default session from request.Default.
+type Client struct {
+	session *request.Session
+	api     *api.Client
+}
+
+// New returns a Client bound to session. A nil session means request.Default.
+func New(session *request.Session) *Client {
+	if session == nil {
+		session = request.Default()
+	}
+	return &Client{session: session, api: api.New(session)}
+}
+
+func defaultClient() *Client {
+	return New(request.Default())
+}
+
+// DownloadAlbumCover downloads an album cover with the default session.
+func DownloadAlbumCover(albumPicture string, albumCoverSize int) ([]byte, error) {
+	return defaultClient().DownloadAlbumCover(albumPicture, albumCoverSize)
+}
+
+// SaveAlbumCoverFile saves an album cover next to tracks with the default session.
+func SaveAlbumCoverFile(dir string, fileName string, albumPicture string, albumCoverSize int) (string, error) {
+	return defaultClient().SaveAlbumCoverFile(dir, fileName, albumPicture, albumCoverSize)
+}
+
+// DownloadShowArt downloads the artwork of a podcast show with the default session.
+func DownloadShowArt(showArt string, size int) ([]byte, error) {
+	return defaultClient().DownloadShowArt(showArt, size)
+}
+
+// SaveTrackCoverFile saves the album cover or show art of track next to it with the default session.
+func SaveTrackCoverFile(dir string, fileName string, track types.TrackType, size int) (string, error) {
+	return defaultClient().SaveTrackCoverFile(dir, fileName, track, size)
+}
+
+// AddTrackTags tags an MP3 or FLAC buffer with the default session.
+func AddTrackTags(trackBuffer []byte, track types.TrackType, options TagOptions) ([]byte, error) {
+	return defaultClient().AddTrackTags(trackBuffer, track, options)
+}
+
+// AddTrackTagsFile writes a tagged copy of an MP3 or FLAC file with the default session.
+func AddTrackTagsFile(src, dst string, track types.TrackType, options TagOptions) error {
+	return defaultClient().AddTrackTagsFile(src, dst, track, options)
+}
+
+// SaveLyricsFile writes an .lrc sidecar next to audioPath with the default session.
+func SaveLyricsFile(audioPath string, track types.TrackType) (string, error) {
+	return defaultClient().SaveLyricsFile(audioPath, track)
+}
@@ -0,0 +1,91 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/logger"
+	"github.com/d-fi/GoFi/types"
+)
+
+func (c *Client) addEpisodeTags(trackBuffer []byte, episode types.ShowEpisodeType, options TagOptions) ([]byte, error) {
+	logger.Debug("Starting to add episode tags for episode: %s", episode.EpisodeTitle)
+
+	var cover []byte
+	if ShouldEmbedCover(options.CoverMode) {
+		coverData, err := c.DownloadShowArt(episode.ShowArtMD5, options.CoverSize)
+		if err != nil {
+			logger.Debug("Failed to download show art: %v", err)
+			return nil, err
+		}
+		cover = coverData
+	}
+	return WriteMetadataEpisode(trackBuffer, episode, cover)
+}
+
+// WriteMetadataEpisode tags an MP3 podcast episode. The show fills the album
+// and artist frames, and the publish date goes to TDRC.
+func WriteMetadataEpisode(buffer []byte, episode types.ShowEpisodeType, cover []byte) ([]byte, error) {
+	logger.Debug("Starting MP3 metadata writing for episode: %s", episode.EpisodeTitle)
+
+	tag, audioData, err := splitID3(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	tag.SetVersion(4)
+	tag.SetDefaultEncoding(id3v2.EncodingUTF8)
+
+	tag.SetTitle(episode.EpisodeTitle)
+	tag.SetAlbum(episode.ShowName)
+	tag.SetArtist(episode.ShowName)
+	tag.SetGenre("Podcast")
+	tag.AddTextFrame("TPE2", id3v2.EncodingUTF8, episode.ShowName)
+	if duration, err := strconv.Atoi(episode.Duration); err == nil && duration > 0 {
+		tag.AddTextFrame("TLEN", id3v2.EncodingUTF8, fmt.Sprintf("%d", duration*1000))
+	}
+	if date := episodeDate(episode.EpisodePublishedTime); date != "" {
+		tag.AddTextFrame("TDRC", id3v2.EncodingUTF8, date)
+	}
+	if episode.EpisodeDescription != "" {
+		tag.AddCommentFrame(id3v2.CommentFrame{
+			Encoding: id3v2.EncodingUTF8,
+			Language: "eng",
+			Text:     episode.EpisodeDescription,
+		})
+	}
+	tag.AddTextFrame("TMED", id3v2.EncodingUTF8, "Digital Media")
+	addUserTextFrame(tag, "SOURCE", "Deezer")
+	// Not SOURCEID: that holds a song ID, and d-fi upgrade looks songs up by it.
+	addUserTextFrame(tag, "EPISODEID", episode.EpisodeID)
+	addUserTextFrame(tag, "SHOWID", episode.ShowID)
+
+	if cover != nil {
+		tag.AddAttachedPicture(id3v2.PictureFrame{
+			Encoding:    id3v2.EncodingUTF8,
+			MimeType:    "image/jpeg",
+			PictureType: 3,
+			Picture:     cover,
+		})
+	}
+
+	var newBuffer bytes.Buffer
+	if _, err := tag.WriteTo(&newBuffer); err != nil {
+		return nil, err
+	}
+	newBuffer.Write(audioData)
+	return newBuffer.Bytes(), nil
+}
+
+// episodeDate returns the date part of a Deezer timestamp such as
+// "2021-04-20 09:00:00".
+func episodeDate(timestamp string) string {
+	date, _, _ := strings.Cut(strings.TrimSpace(timestamp), " ")
+	if date == "0000-00-00" {
+		return ""
+	}
+	return date
+}
@@ -0,0 +1,65 @@
+package metadata
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/types"
+)
+
+func TestWriteMetadataEpisode(t *testing.T) {
+	audio := []byte{0xff, 0xfb, 0x90, 0x64, 0, 0, 0, 0}
+	tagged, err := WriteMetadataEpisode(audio, types.ShowEpisodeType{
+		EpisodeID:            "294961882",
+		EpisodeTitle:         "Frustration is your friend",
+		EpisodeDescription:   "An episode.",
+		ShowID:               "1235862",
+		ShowName:             "Masters of Scale",
+		Duration:             "2022",
+		EpisodePublishedTime: "2021-04-20 09:00:00",
+	}, []byte("jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(tagged, audio) {
+		t.Fatal("audio frames were not kept")
+	}
+
+	tag, err := id3v2.ParseReader(bytes.NewReader(tagged), id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string]string{
+		"TIT2": "Frustration is your friend",
+		"TALB": "Masters of Scale",
+		"TPE1": "Masters of Scale",
+		"TCON": "Podcast",
+		"TDRC": "2021-04-20",
+		"TLEN": "2022000",
+	}
+	for id, want := range checks {
+		if got := tag.GetTextFrame(id).Text; got != want {
+			t.Fatalf("%s = %q, want %q", id, got, want)
+		}
+	}
+	comments := tag.GetFrames(tag.CommonID("Comments"))
+	if len(comments) != 1 || comments[0].(id3v2.CommentFrame).Text != "An episode." {
+		t.Fatalf("comments = %v", comments)
+	}
+	if len(tag.GetFrames(tag.CommonID("Attached picture"))) != 1 {
+		t.Fatal("show art was not embedded")
+	}
+
+	ids := map[string]string{}
+	for _, frame := range tag.GetFrames(tag.CommonID("User defined text information frame")) {
+		userFrame := frame.(id3v2.UserDefinedTextFrame)
+		ids[userFrame.Description] = userFrame.Value
+	}
+	if ids["EPISODEID"] != "294961882" || ids["SHOWID"] != "1235862" {
+		t.Fatalf("TXXX frames = %v", ids)
+	}
+	if _, ok := ids["SOURCEID"]; ok {
+		t.Fatal("episodes must not get a SOURCEID")
+	}
+}
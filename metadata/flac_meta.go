@@ -11,6 +11,12 @@ import (
 )
 
 func WriteMetadataFlac(buffer []byte, track types.TrackType, album *types.AlbumTypePublicApi, releaseDate string, dimension int, cover []byte) ([]byte, error) {
+	return WriteMetadataFlacWithProfile(buffer, track, album, releaseDate, dimension, cover, TagProfile{})
+}
+
+// WriteMetadataFlacWithProfile is WriteMetadataFlac with the tags chosen and
+// formatted by profile.
+func WriteMetadataFlacWithProfile(buffer []byte, track types.TrackType, album *types.AlbumTypePublicApi, releaseDate string, dimension int, cover []byte, profile TagProfile) ([]byte, error) {
 	logger.Debug("Initializing FLAC metadata writing for track: %s", track.SNG_TITLE)
 
 	flac, err := metaflac.NewMetaflac(buffer)
@@ -18,65 +24,68 @@ func WriteMetadataFlac(buffer []byte, track types.TrackType, album *types.AlbumT
 		logger.Debug("Failed to initialize FLAC metadata: %v", err)
 		return nil, err
 	}
+	tags := vorbisTags{flac: flac, profile: profile}
 
 	releaseYear := utils.ReleaseYear(releaseDate)
 	logger.Debug("Release year extracted: %s", releaseYear)
 
-	flac.SetTag("TITLE=" + track.SNG_TITLE)
-	flac.SetTag("ALBUM=" + track.ALB_TITLE)
+	tags.set("TITLE", track.SNG_TITLE)
+	tags.set("ALBUM", track.ALB_TITLE)
 
 	var artistNames []string
 	for _, artist := range track.ARTISTS {
 		artistNames = append(artistNames, artist.ART_NAME)
 	}
-	flac.SetTag("ARTIST=" + strings.Join(artistNames, ", "))
+	for _, artist := range profile.artistValues(artistNames, ", ") {
+		tags.set("ARTIST", artist)
+	}
 	logger.Debug("Set basic track tags: TITLE, ALBUM, ARTIST")
 
-	flac.SetTag(fmt.Sprintf("TRACKNUMBER=%02d", int(track.TRACK_NUMBER)))
+	tags.set("TRACKNUMBER", profile.trackNumber(int(track.TRACK_NUMBER)))
 
 	if album != nil {
-		TOTALTRACKS := fmt.Sprintf("%02d", album.NbTracks)
+		TOTALTRACKS := profile.trackNumber(album.NbTracks)
 		logger.Debug("Total tracks set: %s", TOTALTRACKS)
 
 		if len(album.Genres.Data) > 0 {
 			for _, genre := range album.Genres.Data {
-				flac.SetTag("GENRE=" + genre.Name)
+				tags.set("GENRE", genre.Name)
 			}
 			logger.Debug("Set genre tags")
 		}
 
-		flac.SetTag("TRACKTOTAL=" + TOTALTRACKS)
-		flac.SetTag("TOTALTRACKS=" + TOTALTRACKS)
-		flac.SetTag("RELEASETYPE=" + album.RecordType)
-		flac.SetTag("ALBUMARTIST=" + album.Artist.Name)
-		flac.SetTag("BARCODE=" + album.UPC)
-		flac.SetTag("LABEL=" + album.Label)
-		flac.SetTag("DATE=" + releaseDate)
-		flac.SetTag("YEAR=" + releaseYear)
+		tags.set("TRACKTOTAL", TOTALTRACKS)
+		tags.set("TOTALTRACKS", TOTALTRACKS)
+		tags.set("RELEASETYPE", album.RecordType)
+		tags.set("ALBUMARTIST", album.Artist.Name)
+		tags.set("BARCODE", album.UPC)
+		tags.set("LABEL", album.Label)
+		tags.set("DATE", releaseDate)
+		tags.set("YEAR", releaseYear)
 		logger.Debug("Set album-related tags")
 
 		compilation := "0"
 		if strings.Contains(strings.ToLower(album.Artist.Name), "various") {
 			compilation = "1"
 		}
-		flac.SetTag("COMPILATION=" + compilation)
+		tags.set("COMPILATION", compilation)
 		logger.Debug("Set compilation tag")
 	}
 
 	if track.DISK_NUMBER != 0 {
-		flac.SetTag(fmt.Sprintf("DISCNUMBER=%d", int(track.DISK_NUMBER)))
+		tags.set("DISCNUMBER", fmt.Sprintf("%d", int(track.DISK_NUMBER)))
 	}
 
-	flac.SetTag("ISRC=" + track.ISRC)
-	flac.SetTag(fmt.Sprintf("LENGTH=%d", int(track.DURATION)))
-	flac.SetTag("MEDIA=Digital Media")
+	tags.set("ISRC", track.ISRC)
+	tags.set("LENGTH", fmt.Sprintf("%d", int(track.DURATION)))
+	tags.set("MEDIA", "Digital Media")
 
 	if track.LYRICS != nil {
-		flac.SetTag("LYRICS=" + track.LYRICS.LYRICS_TEXT)
+		tags.setLyrics(track, *track.LYRICS)
 	}
 
 	if track.EXPLICIT_LYRICS != nil {
-		flac.SetTag(fmt.Sprintf("EXPLICIT=%t", bool(*track.EXPLICIT_LYRICS)))
+		tags.set("EXPLICIT", fmt.Sprintf("%t", bool(*track.EXPLICIT_LYRICS)))
 	}
 
 	if track.SNG_CONTRIBUTORS != nil {
@@ -88,29 +97,15 @@ func WriteMetadataFlac(buffer []byte, track types.TrackType, album *types.AlbumT
 				copyright += " "
 			}
 			copyright += contributors.MainArtist[0]
-			flac.SetTag("COPYRIGHT=" + copyright)
-		}
-		if len(contributors.Publisher) > 0 {
-			flac.SetTag("ORGANIZATION=" + strings.Join(contributors.Publisher, ", "))
-		}
-		if len(contributors.Composer) > 0 {
-			flac.SetTag("COMPOSER=" + strings.Join(contributors.Composer, ", "))
-		}
-		if len(contributors.Producer) > 0 {
-			flac.SetTag("PRODUCER=" + strings.Join(contributors.Producer, ", "))
-		}
-		if len(contributors.Engineer) > 0 {
-			flac.SetTag("ENGINEER=" + strings.Join(contributors.Engineer, ", "))
-		}
-		if len(contributors.Writer) > 0 {
-			flac.SetTag("WRITER=" + strings.Join(contributors.Writer, ", "))
-		}
-		if len(contributors.Author) > 0 {
-			flac.SetTag("AUTHOR=" + strings.Join(contributors.Author, ", "))
-		}
-		if len(contributors.Mixer) > 0 {
-			flac.SetTag("MIXER=" + strings.Join(contributors.Mixer, ", "))
+			tags.set("COPYRIGHT", copyright)
 		}
+		tags.setList("ORGANIZATION", contributors.Publisher)
+		tags.setList("COMPOSER", contributors.Composer)
+		tags.setList("PRODUCER", contributors.Producer)
+		tags.setList("ENGINEER", contributors.Engineer)
+		tags.setList("WRITER", contributors.Writer)
+		tags.setList("AUTHOR", contributors.Author)
+		tags.setList("MIXER", contributors.Mixer)
 		logger.Debug("Set contributor tags")
 	}
 
@@ -128,11 +123,52 @@ func WriteMetadataFlac(buffer []byte, track types.TrackType, album *types.AlbumT
 		logger.Debug("Imported cover picture with dimensions: %dx%d", dimension, dimension)
 	}
 
-	flac.SetTag("SOURCE=Deezer")
-	flac.SetTag("SOURCEID=" + track.SNG_ID)
+	tags.set("SOURCE", "Deezer")
+	tags.set("SOURCEID", track.SNG_ID)
 	logger.Debug("Set source-related tags")
 
 	newBuffer := flac.GetBuffer()
 	logger.Debug("FLAC metadata writing complete for track: %s", track.SNG_TITLE)
 	return newBuffer, nil
 }
+
+// vorbisTags writes Vorbis comments through a TagProfile, which may skip or
+// rename them.
+type vorbisTags struct {
+	flac    *metaflac.Metaflac
+	profile TagProfile
+}
+
+func (tags vorbisTags) set(field, value string) {
+	if !tags.profile.enabled(field) {
+		return
+	}
+	tags.flac.SetTag(tags.profile.vorbisKey(field) + "=" + value)
+}
+
+func (tags vorbisTags) setList(field string, values []string) {
+	if len(values) > 0 {
+		tags.set(field, strings.Join(values, ", "))
+	}
+}
+
+// setLyrics writes the plain lyrics to LYRICS and the LRC form to
+// SYNCEDLYRICS. When only synced lyrics are embedded LYRICS holds the LRC form
+// too, since that is the tag most players read.
+func (tags vorbisTags) setLyrics(track types.TrackType, lyrics types.LyricsType) {
+	synced := LRC(track, lyrics)
+	if lyrics.LYRICS_TEXT != "" {
+		tags.set("LYRICS", lyrics.LYRICS_TEXT)
+	} else if synced != "" {
+		tags.set("LYRICS", synced)
+	}
+	if synced != "" {
+		tags.set("SYNCEDLYRICS", synced)
+	}
+	if lyrics.LYRICS_WRITERS != nil && *lyrics.LYRICS_WRITERS != "" {
+		tags.set("LYRICIST", *lyrics.LYRICS_WRITERS)
+	}
+	if lyrics.LYRICS_COPYRIGHTS != nil && *lyrics.LYRICS_COPYRIGHTS != "" {
+		tags.set("LYRICSCOPYRIGHT", *lyrics.LYRICS_COPYRIGHTS)
+	}
+}
@@ -13,61 +13,54 @@ import (
 )
 
 func WriteMetadataMp3(buffer []byte, track types.TrackType, album *types.AlbumTypePublicApi, releaseDate string, cover []byte) ([]byte, error) {
+	return WriteMetadataMp3WithProfile(buffer, track, album, releaseDate, cover, TagProfile{})
+}
+
+// WriteMetadataMp3WithProfile is WriteMetadataMp3 with the tags chosen and
+// formatted by profile.
+func WriteMetadataMp3WithProfile(buffer []byte, track types.TrackType, album *types.AlbumTypePublicApi, releaseDate string, cover []byte, profile TagProfile) ([]byte, error) {
 	logger.Debug("Starting MP3 metadata writing for track: %s", track.SNG_TITLE)
 
-	reader := bytes.NewReader(buffer)
-	tag, err := id3v2.ParseReader(reader, id3v2.Options{Parse: true})
-	var audioData []byte
+	tag, audioData, err := splitID3(buffer)
 	if err != nil {
-		tag = id3v2.NewEmptyTag()
-		audioData = buffer
-		logger.Debug("No existing tags found, creating new tag")
-	} else {
-		audioData, err = io.ReadAll(reader)
-		if err != nil {
-			logger.Debug("Failed to read audio data: %v", err)
-			return nil, err
-		}
-		logger.Debug("Existing tags found, extracted audio data")
+		logger.Debug("Failed to read audio data: %v", err)
+		return nil, err
 	}
 
-	tag.SetVersion(4)
-	tag.SetDefaultEncoding(id3v2.EncodingUTF8)
+	frames := id3Frames{tag: tag, profile: profile, encoding: profile.id3Encoding()}
+	tag.SetVersion(profile.id3Version())
+	tag.SetDefaultEncoding(frames.encoding)
 
-	tag.SetTitle(track.SNG_TITLE)
-	tag.SetAlbum(track.ALB_TITLE)
-	tag.SetArtist(strings.Join(processArtistNames(track.ARTISTS), "/"))
-	tag.AddTextFrame("TLEN", id3v2.EncodingUTF8, fmt.Sprintf("%d", track.DURATION*1000))
-	tag.AddTextFrame("TSRC", id3v2.EncodingUTF8, track.ISRC)
+	frames.text("TITLE", "TIT2", track.SNG_TITLE)
+	frames.text("ALBUM", "TALB", track.ALB_TITLE)
+	frames.text("ARTIST", "TPE1", frames.joinValues(profile.artistValues(processArtistNames(track.ARTISTS), "/")))
+	frames.text("LENGTH", "TLEN", fmt.Sprintf("%d", track.DURATION*1000))
+	frames.text("ISRC", "TSRC", track.ISRC)
 
 	if album != nil {
-		setAlbumMetadata(tag, album, releaseDate)
+		frames.setAlbum(album, releaseDate)
 	}
 
-	tag.AddTextFrame("TMED", id3v2.EncodingUTF8, "Digital Media")
-	addUserTextFrame(tag, "SOURCE", "Deezer")
-	addUserTextFrame(tag, "SOURCEID", track.SNG_ID)
+	frames.text("MEDIA", "TMED", "Digital Media")
+	frames.user("SOURCE", "SOURCE", "Deezer")
+	frames.user("SOURCEID", "SOURCEID", track.SNG_ID)
 
 	if track.DISK_NUMBER != 0 {
-		setTrackNumberFrames(tag, track, album)
+		frames.setTrackNumber(track, album)
 	}
 
-	setContributorsMetadata(tag, track, album, releaseDate)
+	frames.setContributors(track, album, releaseDate)
 
 	if track.LYRICS != nil {
-		tag.AddUnsynchronisedLyricsFrame(id3v2.UnsynchronisedLyricsFrame{
-			Encoding: id3v2.EncodingUTF8,
-			Language: "eng",
-			Lyrics:   track.LYRICS.LYRICS_TEXT,
-		})
+		frames.setLyrics(*track.LYRICS)
 	}
 	if track.EXPLICIT_LYRICS != nil {
-		addUserTextFrame(tag, "EXPLICIT", fmt.Sprintf("%t", *track.EXPLICIT_LYRICS))
+		frames.user("EXPLICIT", "EXPLICIT", fmt.Sprintf("%t", *track.EXPLICIT_LYRICS))
 	}
 
 	if cover != nil {
 		pic := id3v2.PictureFrame{
-			Encoding:    id3v2.EncodingUTF8,
+			Encoding:    frames.encoding,
 			MimeType:    "image/jpeg",
 			PictureType: 3,
 			Description: "",
@@ -83,12 +76,38 @@ func WriteMetadataMp3(buffer []byte, track types.TrackType, album *types.AlbumTy
 		return nil, err
 	}
 
+	if profile.ID3v1 {
+		audioData = append(withoutID3v1(audioData), id3v1Tag(track, album, releaseDate, profile)...)
+	}
 	newBuffer.Write(audioData)
 	logger.Debug("Completed MP3 metadata writing for track: %s", track.SNG_TITLE)
 
 	return newBuffer.Bytes(), nil
 }
 
+// splitID3 returns the leading ID3v2 tag of buffer and the audio after it.
+// Untagged audio, or a tag that fails to parse, gets a new empty tag and
+// keeps every byte: id3v2.ParseReader would consume the first 10 bytes of
+// untagged audio looking for a header.
+func splitID3(buffer []byte) (*id3v2.Tag, []byte, error) {
+	if !bytes.HasPrefix(buffer, []byte("ID3")) {
+		logger.Debug("No existing tags found, creating new tag")
+		return id3v2.NewEmptyTag(), buffer, nil
+	}
+	reader := bytes.NewReader(buffer)
+	tag, err := id3v2.ParseReader(reader, id3v2.Options{Parse: true})
+	if err != nil {
+		logger.Debug("Unable to parse existing tags, creating new tag: %v", err)
+		return id3v2.NewEmptyTag(), buffer, nil
+	}
+	audioData, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Debug("Existing tags found, extracted audio data")
+	return tag, audioData, nil
+}
+
 func processArtistNames(artists []types.ArtistType) []string {
 	var names []string
 	for _, artist := range artists {
@@ -103,44 +122,81 @@ func processArtistNames(artists []types.ArtistType) []string {
 	return names
 }
 
-func setAlbumMetadata(tag *id3v2.Tag, album *types.AlbumTypePublicApi, releaseDate string) {
+// id3Frames adds ID3v2 frames through a TagProfile. Each frame is switched
+// on and off by the Vorbis field it carries, and its text is in the encoding
+// of the profile's ID3v2 version.
+type id3Frames struct {
+	tag      *id3v2.Tag
+	profile  TagProfile
+	encoding id3v2.Encoding
+}
+
+func (frames id3Frames) text(field, id, value string) {
+	if frames.profile.enabled(field) {
+		frames.tag.AddTextFrame(id, frames.encoding, value)
+	}
+}
+
+func (frames id3Frames) user(field, description, value string) {
+	if !frames.profile.enabled(field) {
+		return
+	}
+	frames.tag.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{
+		Encoding:    frames.encoding,
+		Description: description,
+		Value:       value,
+	})
+	logger.Debug("Added TXXX frame: %s = %s", description, value)
+}
+
+// joinValues joins the values of a text frame. ID3v2.4 separates them with a
+// null byte; ID3v2.3 has no multiple values, so they are joined as one.
+func (frames id3Frames) joinValues(values []string) string {
+	if frames.tag.Version() == 4 {
+		return strings.Join(values, "\x00")
+	}
+	return strings.Join(values, frames.profile.artistSeparator("/"))
+}
+
+func (frames id3Frames) setAlbum(album *types.AlbumTypePublicApi, releaseDate string) {
 	if len(album.Genres.Data) > 0 {
 		var genres []string
 		for _, genre := range album.Genres.Data {
 			genres = append(genres, genre.Name)
 		}
-		tag.SetGenre(strings.Join(genres, ", "))
+		frames.text("GENRE", "TCON", strings.Join(genres, ", "))
 	}
 
 	releaseDates := strings.Split(releaseDate, "-")
 	if year := utils.ReleaseYear(releaseDate); year != "" {
-		tag.AddTextFrame("TDRC", id3v2.EncodingUTF8, year)
-		tag.AddTextFrame("TYER", id3v2.EncodingUTF8, year)
+		// TDRC replaced TYER and TDAT in ID3v2.4.
+		if frames.tag.Version() == 4 {
+			frames.text("DATE", "TDRC", year)
+		}
+		frames.text("YEAR", "TYER", year)
 	}
 	if len(releaseDates) >= 3 {
-		tag.AddTextFrame("TDAT", id3v2.EncodingUTF8, releaseDates[2]+releaseDates[1])
+		frames.text("DATE", "TDAT", releaseDates[2]+releaseDates[1])
 	}
 
-	tag.AddTextFrame("TPE2", id3v2.EncodingUTF8, album.Artist.Name)
+	frames.text("ALBUMARTIST", "TPE2", album.Artist.Name)
 
-	addUserTextFrame(tag, "RELEASETYPE", album.RecordType)
-	addUserTextFrame(tag, "BARCODE", album.UPC)
-	addUserTextFrame(tag, "LABEL", album.Label)
-	addUserTextFrame(tag, "COMPILATION", ifMatchVarious(album.Artist.Name))
+	frames.user("RELEASETYPE", "RELEASETYPE", album.RecordType)
+	frames.user("BARCODE", "BARCODE", album.UPC)
+	frames.user("LABEL", "LABEL", album.Label)
+	frames.user("COMPILATION", "COMPILATION", ifMatchVarious(album.Artist.Name))
 }
 
-func setTrackNumberFrames(tag *id3v2.Tag, track types.TrackType, album *types.AlbumTypePublicApi) {
-	trackNumber := fmt.Sprintf("%02d", int(track.TRACK_NUMBER))
-	if album != nil {
-		totalTracks := fmt.Sprintf("%02d", album.NbTracks)
-		tag.AddTextFrame("TRCK", id3v2.EncodingUTF8, fmt.Sprintf("%s/%s", trackNumber, totalTracks))
-	} else {
-		tag.AddTextFrame("TRCK", id3v2.EncodingUTF8, trackNumber)
+func (frames id3Frames) setTrackNumber(track types.TrackType, album *types.AlbumTypePublicApi) {
+	trackNumber := frames.profile.trackNumber(int(track.TRACK_NUMBER))
+	if album != nil && frames.profile.enabled("TRACKTOTAL") {
+		trackNumber += "/" + frames.profile.trackNumber(album.NbTracks)
 	}
-	tag.AddTextFrame("TPOS", id3v2.EncodingUTF8, fmt.Sprintf("%d", int(track.DISK_NUMBER)))
+	frames.text("TRACKNUMBER", "TRCK", trackNumber)
+	frames.text("DISCNUMBER", "TPOS", fmt.Sprintf("%d", int(track.DISK_NUMBER)))
 }
 
-func setContributorsMetadata(tag *id3v2.Tag, track types.TrackType, album *types.AlbumTypePublicApi, releaseDate string) {
+func (frames id3Frames) setContributors(track types.TrackType, album *types.AlbumTypePublicApi, releaseDate string) {
 	contributors := track.SNG_CONTRIBUTORS
 	if contributors == nil {
 		return
@@ -151,31 +207,51 @@ func setContributorsMetadata(tag *id3v2.Tag, track types.TrackType, album *types
 		if album != nil {
 			releaseYear = utils.ReleaseYear(releaseDate)
 		}
-		tag.AddTextFrame("TCOP", id3v2.EncodingUTF8, fmt.Sprintf("%s %s", releaseYear, contributors.MainArtist[0]))
+		frames.text("COPYRIGHT", "TCOP", fmt.Sprintf("%s %s", releaseYear, contributors.MainArtist[0]))
 	}
 
-	addContributorsFrames(tag, contributors)
-}
-
-func addContributorsFrames(tag *id3v2.Tag, contributors *types.SongContributors) {
 	if len(contributors.Publisher) > 0 {
-		tag.AddTextFrame("TPUB", id3v2.EncodingUTF8, strings.Join(contributors.Publisher, "/"))
+		frames.text("ORGANIZATION", "TPUB", strings.Join(contributors.Publisher, "/"))
 	}
 	if len(contributors.Composer) > 0 {
-		tag.AddTextFrame("TCOM", id3v2.EncodingUTF8, strings.Join(contributors.Composer, "/"))
+		frames.text("COMPOSER", "TCOM", strings.Join(contributors.Composer, "/"))
 	}
 	if len(contributors.Writer) > 0 {
-		addUserTextFrame(tag, "LYRICIST", strings.Join(contributors.Writer, "/"))
+		frames.user("WRITER", "LYRICIST", strings.Join(contributors.Writer, "/"))
 	}
 	if len(contributors.Author) > 0 {
-		addUserTextFrame(tag, "AUTHOR", strings.Join(contributors.Author, "/"))
+		frames.user("AUTHOR", "AUTHOR", strings.Join(contributors.Author, "/"))
 	}
 	if len(contributors.Mixer) > 0 {
-		addUserTextFrame(tag, "MIXARTIST", strings.Join(contributors.Mixer, "/"))
+		frames.user("MIXER", "MIXARTIST", strings.Join(contributors.Mixer, "/"))
 	}
 	if len(contributors.Producer) > 0 && len(contributors.Engineer) > 0 {
 		involvedPeople := append(contributors.Producer, contributors.Engineer...)
-		addUserTextFrame(tag, "INVOLVEDPEOPLE", strings.Join(involvedPeople, "/"))
+		frames.user("INVOLVEDPEOPLE", "INVOLVEDPEOPLE", strings.Join(involvedPeople, "/"))
+	}
+}
+
+func (frames id3Frames) setLyrics(lyrics types.LyricsType) {
+	if lyrics.LYRICS_TEXT != "" && frames.profile.enabled("LYRICS") {
+		frames.tag.AddUnsynchronisedLyricsFrame(id3v2.UnsynchronisedLyricsFrame{
+			Encoding: frames.encoding,
+			Language: "eng",
+			Lyrics:   lyrics.LYRICS_TEXT,
+		})
+	}
+	if lines := SyncedLines(lyrics); len(lines) > 0 && frames.profile.enabled("SYNCEDLYRICS") {
+		frames.tag.AddFrame("SYLT", SynchronisedLyricsFrame{
+			Language: "eng",
+			Lines:    lines,
+			UTF16:    frames.tag.Version() == 3,
+		})
+		logger.Debug("Added SYLT frame with %d lines", len(lines))
+	}
+	if lyrics.LYRICS_WRITERS != nil && *lyrics.LYRICS_WRITERS != "" {
+		frames.text("LYRICIST", "TEXT", *lyrics.LYRICS_WRITERS)
+	}
+	if lyrics.LYRICS_COPYRIGHTS != nil && *lyrics.LYRICS_COPYRIGHTS != "" {
+		frames.user("LYRICSCOPYRIGHT", "LYRICSCOPYRIGHT", *lyrics.LYRICS_COPYRIGHTS)
 	}
 }
 
@@ -0,0 +1,108 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
+)
+
+const id3v1Size = 128
+
+// id3v1Genres is the standard ID3v1 genre list; a genre is stored as its index.
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// id3v1Tag builds the 128 byte ID3v1.1 tag of track. Text is cut to the
+// field sizes and characters outside Latin-1 become '?'. The genre is the
+// first album genre found in the ID3v1 list, or none.
+func id3v1Tag(track types.TrackType, album *types.AlbumTypePublicApi, releaseDate string, profile TagProfile) []byte {
+	tag := make([]byte, id3v1Size)
+	copy(tag, "TAG")
+	field := func(name string, offset, size int, value string) {
+		if profile.enabled(name) {
+			copy(tag[offset:offset+size], latin1(value))
+		}
+	}
+	field("TITLE", 3, 30, track.SNG_TITLE)
+	field("ARTIST", 33, 30, strings.Join(processArtistNames(track.ARTISTS), profile.artistSeparator("/")))
+	field("ALBUM", 63, 30, track.ALB_TITLE)
+	if album != nil {
+		field("YEAR", 93, 4, utils.ReleaseYear(releaseDate))
+	}
+	// Bytes 97 to 124 are the comment, then a zero byte marks ID3v1.1.
+	if number := int(track.TRACK_NUMBER); number > 0 && number < 256 && profile.enabled("TRACKNUMBER") {
+		tag[126] = byte(number)
+	}
+	tag[127] = 0xFF
+	if album != nil && profile.enabled("GENRE") {
+		for _, genre := range album.Genres.Data {
+			if index := id3v1Genre(genre.Name); index >= 0 {
+				tag[127] = byte(index)
+				break
+			}
+		}
+	}
+	return tag
+}
+
+func id3v1Genre(name string) int {
+	for i, genre := range id3v1Genres {
+		if strings.EqualFold(genre, strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+func latin1(value string) []byte {
+	encoded := make([]byte, 0, len(value))
+	for _, r := range value {
+		if r > 0xFF {
+			r = '?'
+		}
+		encoded = append(encoded, byte(r))
+	}
+	return encoded
+}
+
+// withoutID3v1 returns audio without a trailing ID3v1 tag.
+func withoutID3v1(audio []byte) []byte {
+	if len(audio) >= id3v1Size && bytes.HasPrefix(audio[len(audio)-id3v1Size:], []byte("TAG")) {
+		return audio[:len(audio)-id3v1Size]
+	}
+	return audio
+}
+
+// id3v1Summary describes an ID3v1 tag as "title / artist / album / year",
+// followed by the track number and genre index when set.
+func id3v1Summary(tag []byte) string {
+	text := func(offset, size int) string {
+		value := bytes.TrimRight(tag[offset:offset+size], "\x00 ")
+		runes := make([]rune, len(value))
+		for i, b := range value {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	summary := strings.Join([]string{text(3, 30), text(33, 30), text(63, 30), text(93, 4)}, " / ")
+	if tag[125] == 0 && tag[126] != 0 {
+		summary += fmt.Sprintf(", track %d", tag[126])
+	}
+	if tag[127] != 0xFF {
+		summary += fmt.Sprintf(", genre %d", tag[127])
+	}
+	return summary
+}
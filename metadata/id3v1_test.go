@@ -0,0 +1,75 @@
+package metadata
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/d-fi/GoFi/types"
+)
+
+func TestID3v1Tag(t *testing.T) {
+	track, album := testProfileTrack()
+	track.SNG_TITLE = "Hey Brother (Avicii by Avicii Remix) – Extended Version"
+	album.Genres.Data = []types.GenreTypePublicApi{{Name: "Electro"}, {Name: "Dance"}}
+	audio := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...)
+
+	tagged, err := WriteMetadataMp3WithProfile(audio, track, album, "2013-09-13", nil, TagProfile{ID3v1: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tagging a file that already ends with an ID3v1 tag replaces it.
+	tagged, err = WriteMetadataMp3WithProfile(tagged, track, album, "2013-09-13", nil, TagProfile{ID3v1: true, Disabled: []string{"ALBUM"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	audioData, err := StripTags(tagged)
+	if err != nil || !bytes.Equal(audioData, audio) {
+		t.Fatalf("StripTags() left %d bytes, err = %v", len(audioData), err)
+	}
+
+	tag := tagged[len(tagged)-id3v1Size:]
+	want := "Hey Brother (Avicii by Avicii / Avicii/Aloe Blacc /  / 2013, track 3, genre 3"
+	if got := id3v1Summary(tag); got != want {
+		t.Fatalf("ID3v1 = %q, want %q", got, want)
+	}
+	if strings.Count(string(tagged), "TAG") != 1 {
+		t.Fatal("expected a single ID3v1 tag")
+	}
+
+	if got := latin1("Beyoncé – 1+1"); !bytes.Equal(got, []byte("Beyonc\xe9 ? 1+1")) {
+		t.Fatalf("latin1() = %q", got)
+	}
+}
+
+func TestMergeTagsRejectsID3VersionChange(t *testing.T) {
+	track, album := testProfileTrack()
+	audio := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...)
+	current, err := WriteMetadataMp3(audio, track, album, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := WriteMetadataMp3WithProfile(audio, track, album, "", nil, TagProfile{ID3Version: 3, ID3v1: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MergeTags(current, fresh, []string{"title"}, TagProfile{ID3Version: 3}); err == nil {
+		t.Fatal("expected merging ID3v2.3 frames into an ID3v2.4 tag to fail")
+	}
+
+	before, err := ListTags(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := ListTags(fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, change := range DiffTags(before, after) {
+		keys = append(keys, change.Key)
+	}
+	if got := strings.Join(keys, ","); got != "ID3v1,ID3v2" {
+		t.Fatalf("changed keys = %s, want ID3v1,ID3v2", got)
+	}
+}
@@ -0,0 +1,219 @@
+package metadata
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/d-fi/GoFi/logger"
+	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
+)
+
+// LyricsMode is one way of saving lyrics. Modes combine freely.
+type LyricsMode string
+
+const (
+	// LyricsUnsynced embeds the plain lyrics text (USLT, Vorbis LYRICS).
+	LyricsUnsynced LyricsMode = "unsynced"
+	// LyricsSynced embeds the timed lines (SYLT, Vorbis SYNCEDLYRICS).
+	LyricsSynced LyricsMode = "synced"
+	// LyricsFile writes an .lrc sidecar next to the audio file.
+	LyricsFile LyricsMode = "lrc"
+)
+
+// LyricsModes lists the supported lyrics modes.
+func LyricsModes() []LyricsMode {
+	return []LyricsMode{LyricsUnsynced, LyricsSynced, LyricsFile}
+}
+
+// DefaultLyricsModes is what TagOptions.Lyrics falls back to when nil.
+func DefaultLyricsModes() []LyricsMode {
+	return []LyricsMode{LyricsUnsynced}
+}
+
+// NormalizeLyricsModes lowercases and dedupes modes. "none" and an empty list
+// both turn lyrics off; unknown modes are an error.
+func NormalizeLyricsModes(modes []LyricsMode) ([]LyricsMode, error) {
+	normalized := []LyricsMode{}
+	for _, mode := range modes {
+		mode = LyricsMode(strings.ToLower(strings.TrimSpace(string(mode))))
+		switch mode {
+		case "none":
+			continue
+		case LyricsUnsynced, LyricsSynced, LyricsFile:
+			if !HasLyricsMode(normalized, mode) {
+				normalized = append(normalized, mode)
+			}
+		default:
+			return nil, fmt.Errorf("unknown lyrics mode %q, use unsynced, synced, lrc or none", mode)
+		}
+	}
+	return normalized, nil
+}
+
+// ParseLyricsModes parses a comma separated list such as "synced,lrc".
+func ParseLyricsModes(value string) ([]LyricsMode, error) {
+	var modes []LyricsMode
+	for part := range strings.SplitSeq(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			modes = append(modes, LyricsMode(part))
+		}
+	}
+	return NormalizeLyricsModes(modes)
+}
+
+// HasLyricsMode reports whether mode is one of modes.
+func HasLyricsMode(modes []LyricsMode, mode LyricsMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldSaveLyricsFile reports whether modes ask for an .lrc sidecar.
+func ShouldSaveLyricsFile(modes []LyricsMode) bool {
+	return HasLyricsMode(modes, LyricsFile)
+}
+
+func tagLyricsModes(modes []LyricsMode) []LyricsMode {
+	if modes == nil {
+		return DefaultLyricsModes()
+	}
+	return modes
+}
+
+// embeddedLyrics trims lyrics down to the parts modes embed, or returns nil
+// when nothing is embedded. The writers and copyrights stay with either part.
+func embeddedLyrics(lyrics types.LyricsType, modes []LyricsMode) *types.LyricsType {
+	unsynced := HasLyricsMode(modes, LyricsUnsynced)
+	synced := HasLyricsMode(modes, LyricsSynced)
+	if !unsynced && !synced {
+		return nil
+	}
+	if !unsynced {
+		lyrics.LYRICS_TEXT = ""
+	}
+	if !synced {
+		lyrics.LYRICS_SYNC_JSON = nil
+	}
+	return &lyrics
+}
+
+// SyncedLine is one timed line of synchronised lyrics.
+type SyncedLine struct {
+	Text string
+	// Milliseconds is when the line starts, from the start of the track.
+	Milliseconds uint32
+}
+
+// SyncedLines returns the timed lines of lyrics in order. Lines Deezer sent
+// without a usable timestamp are dropped.
+func SyncedLines(lyrics types.LyricsType) []SyncedLine {
+	var lines []SyncedLine
+	for _, sync := range lyrics.LYRICS_SYNC_JSON {
+		ms, ok := syncMilliseconds(sync)
+		if !ok {
+			continue
+		}
+		lines = append(lines, SyncedLine{Text: sync.Line, Milliseconds: ms})
+	}
+	return lines
+}
+
+func syncMilliseconds(sync types.LyricsSync) (uint32, bool) {
+	if ms, err := strconv.ParseUint(strings.TrimSpace(sync.Milliseconds), 10, 32); err == nil {
+		return uint32(ms), true
+	}
+	// Fall back to the "[mm:ss.xx]" form.
+	stamp := strings.Trim(strings.TrimSpace(sync.LrcTimestamp), "[]")
+	minutes, seconds, ok := strings.Cut(stamp, ":")
+	if !ok {
+		return 0, false
+	}
+	m, err := strconv.ParseUint(minutes, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	s, err := strconv.ParseFloat(seconds, 64)
+	if err != nil || s < 0 {
+		return 0, false
+	}
+	return uint32(m)*60000 + uint32(s*1000+0.5), true
+}
+
+func lrcTimestamp(ms uint32) string {
+	return fmt.Sprintf("[%02d:%02d.%02d]", ms/60000, ms/1000%60, ms%1000/10)
+}
+
+// LRC renders the timed lines of lyrics as an LRC file headed by the track's
+// title, artist, album, length, lyrics writers and copyrights. It returns ""
+// when there are no timed lines.
+func LRC(track types.TrackType, lyrics types.LyricsType) string {
+	lines := SyncedLines(lyrics)
+	if len(lines) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	header := func(tag, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fmt.Fprintf(&b, "[%s:%s]\n", tag, value)
+		}
+	}
+	header("ti", track.SNG_TITLE)
+	header("ar", track.ART_NAME)
+	header("al", track.ALB_TITLE)
+	if track.DURATION > 0 {
+		header("length", fmt.Sprintf("%02d:%02d", int(track.DURATION)/60, int(track.DURATION)%60))
+	}
+	if lyrics.LYRICS_WRITERS != nil {
+		header("au", *lyrics.LYRICS_WRITERS)
+	}
+	if lyrics.LYRICS_COPYRIGHTS != nil {
+		header("copyright", *lyrics.LYRICS_COPYRIGHTS)
+	}
+	for _, line := range lines {
+		b.WriteString(lrcTimestamp(line.Milliseconds) + line.Text + "\n")
+	}
+	return b.String()
+}
+
+// LyricsFilePath returns the .lrc sidecar path for an audio file.
+func LyricsFilePath(audioPath string) string {
+	return strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + ".lrc"
+}
+
+func (c *Client) trackLyrics(track types.TrackType) (types.LyricsType, bool) {
+	if track.LYRICS_ID <= 0 || track.EPISODE != nil {
+		return types.LyricsType{}, false
+	}
+	lyrics, err := c.api.GetLyrics(track.SNG_ID)
+	if err != nil {
+		logger.Debug("Failed to fetch lyrics: %v", err)
+		return types.LyricsType{}, false
+	}
+	logger.Debug("Fetched lyrics successfully for track: %s", track.SNG_TITLE)
+	return lyrics, true
+}
+
+// SaveLyricsFile writes the synchronised lyrics of track to an .lrc file next
+// to audioPath. It returns "" without error when the track has no timed lines.
+func (c *Client) SaveLyricsFile(audioPath string, track types.TrackType) (string, error) {
+	lyrics, ok := c.trackLyrics(track)
+	if !ok {
+		return "", nil
+	}
+	content := LRC(track, lyrics)
+	if content == "" {
+		return "", nil
+	}
+	path := LyricsFilePath(audioPath)
+	if err := utils.WriteFileAtomic(path, []byte(content), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,149 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/metaflac"
+	"github.com/d-fi/GoFi/types"
+)
+
+func testLyrics() types.LyricsType {
+	writers := "Aloe Blacc, Tim Bergling"
+	copyrights := "Sony/ATV Music Publishing"
+	return types.LyricsType{
+		LYRICS_TEXT: "Hey brother\nThere's an endless road",
+		LYRICS_SYNC_JSON: []types.LyricsSync{
+			{LrcTimestamp: "[00:03.58]", Milliseconds: "3580", Line: "Hey brother"},
+			{LrcTimestamp: "[01:05.20]", Line: "There's an endless road"},
+			{Line: ""},
+		},
+		LYRICS_WRITERS:    &writers,
+		LYRICS_COPYRIGHTS: &copyrights,
+	}
+}
+
+func testLyricsTrack(lyrics *types.LyricsType) types.TrackType {
+	var track types.TrackType
+	track.SNG_TITLE = "Hey Brother"
+	track.ART_NAME = "Avicii"
+	track.ALB_TITLE = "True"
+	track.DURATION = 255
+	track.LYRICS = lyrics
+	return track
+}
+
+func TestNormalizeLyricsModes(t *testing.T) {
+	modes, err := NormalizeLyricsModes([]LyricsMode{" LRC", "synced", "lrc", "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(modes, []LyricsMode{LyricsFile, LyricsSynced}) {
+		t.Fatalf("NormalizeLyricsModes() = %v", modes)
+	}
+	if _, err := ParseLyricsModes("unsynced,karaoke"); err == nil {
+		t.Fatal("expected an unknown mode to be rejected")
+	}
+	if modes, err := ParseLyricsModes("none"); err != nil || modes == nil || len(modes) != 0 {
+		t.Fatalf("ParseLyricsModes(none) = %#v, %v", modes, err)
+	}
+	if got := tagLyricsModes(nil); !reflect.DeepEqual(got, DefaultLyricsModes()) {
+		t.Fatalf("nil modes = %v, want the default", got)
+	}
+}
+
+func TestEmbeddedLyricsKeepsChosenParts(t *testing.T) {
+	if got := embeddedLyrics(testLyrics(), []LyricsMode{LyricsFile}); got != nil {
+		t.Fatalf("lrc only embedded %+v", got)
+	}
+	got := embeddedLyrics(testLyrics(), []LyricsMode{LyricsSynced})
+	if got == nil || got.LYRICS_TEXT != "" || len(got.LYRICS_SYNC_JSON) != 3 || got.LYRICS_WRITERS == nil {
+		t.Fatalf("synced only = %+v", got)
+	}
+	got = embeddedLyrics(testLyrics(), []LyricsMode{LyricsUnsynced})
+	if got == nil || got.LYRICS_TEXT == "" || got.LYRICS_SYNC_JSON != nil {
+		t.Fatalf("unsynced only = %+v", got)
+	}
+}
+
+func TestLRC(t *testing.T) {
+	track := testLyricsTrack(nil)
+	want := "[ti:Hey Brother]\n" +
+		"[ar:Avicii]\n" +
+		"[al:True]\n" +
+		"[length:04:15]\n" +
+		"[au:Aloe Blacc, Tim Bergling]\n" +
+		"[copyright:Sony/ATV Music Publishing]\n" +
+		"[00:03.58]Hey brother\n" +
+		"[01:05.20]There's an endless road\n"
+	if got := LRC(track, testLyrics()); got != want {
+		t.Fatalf("LRC() =\n%s\nwant\n%s", got, want)
+	}
+	if got := LRC(track, types.LyricsType{LYRICS_TEXT: "Plain"}); got != "" {
+		t.Fatalf("LRC() without timed lines = %q", got)
+	}
+	if got := LyricsFilePath(filepath.Join("Music", "True", "Hey Brother.flac")); got != filepath.Join("Music", "True", "Hey Brother.lrc") {
+		t.Fatalf("LyricsFilePath() = %q", got)
+	}
+}
+
+func TestWriteMetadataMp3SyncedLyrics(t *testing.T) {
+	lyrics := embeddedLyrics(testLyrics(), []LyricsMode{LyricsSynced})
+	audio := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...)
+	tagged, err := WriteMetadataMp3(audio, testLyricsTrack(lyrics), nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := id3v2.ParseReader(bytes.NewReader(tagged), id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := tag.GetFrames("USLT"); len(frames) != 0 {
+		t.Fatalf("synced only wrote %d USLT frames", len(frames))
+	}
+	frames := tag.GetFrames("SYLT")
+	if len(frames) != 1 {
+		t.Fatalf("got %d SYLT frames", len(frames))
+	}
+	want := []byte{3, 'e', 'n', 'g', 2, 1, 0}
+	want = append(want, "Hey brother\x00\x00\x00\x0d\xfc"...)
+	want = append(want, "There's an endless road\x00\x00\x00\xfe\xb0"...)
+	if body := frames[0].(id3v2.UnknownFrame).Body; !bytes.Equal(body, want) {
+		t.Fatalf("SYLT body = % x\nwant % x", body, want)
+	}
+	if got := tag.GetTextFrame("TEXT").Text; got != "Aloe Blacc, Tim Bergling" {
+		t.Fatalf("TEXT = %q", got)
+	}
+}
+
+func TestWriteMetadataFlacSyncedLyrics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.flac")
+	writeTestFlac(t, path, "TITLE=Song")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := testLyricsTrack(embeddedLyrics(testLyrics(), []LyricsMode{LyricsSynced}))
+	tagged, err := WriteMetadataFlac(data, track, nil, "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flac, err := metaflac.NewMetaflac(tagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lrc := LRC(track, *track.LYRICS)
+	if synced := flac.GetTag("SYNCEDLYRICS"); len(synced) != 1 || synced[0] != "SYNCEDLYRICS="+lrc {
+		t.Fatalf("SYNCEDLYRICS = %q", synced)
+	}
+	if lyrics := flac.GetTag("LYRICS"); len(lyrics) != 1 || lyrics[0] != "LYRICS="+lrc {
+		t.Fatalf("LYRICS = %q, want the LRC form", lyrics)
+	}
+	if got := flac.GetTag("LYRICSCOPYRIGHT"); len(got) != 1 || got[0] != "LYRICSCOPYRIGHT=Sony/ATV Music Publishing" {
+		t.Fatalf("LYRICSCOPYRIGHT = %q", got)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
-	"github.com/d-fi/GoFi/api"
 	"github.com/d-fi/GoFi/logger"
 	"github.com/d-fi/GoFi/types"
 	"github.com/d-fi/GoFi/utils"
@@ -26,6 +25,11 @@ type TagOptions struct {
 	CoverSize int
 	CoverMode CoverMode
 	AlbumInfo any
+	// Lyrics picks which lyrics are embedded. Nil means DefaultLyricsModes; an
+	// LyricsFile mode is left to SaveLyricsFile.
+	Lyrics []LyricsMode
+	// Profile chooses and formats the written tags. Episodes ignore it.
+	Profile TagProfile
 }
 
 func NormalizeCoverMode(mode CoverMode) CoverMode {
@@ -57,13 +61,17 @@ func ShouldSaveCoverFile(mode CoverMode) bool {
 }
 
 // AddTrackTags adds metadata to the track buffer (MP3 or FLAC) based on track and album information.
-func AddTrackTags(trackBuffer []byte, track types.TrackType, options TagOptions) ([]byte, error) {
+// Podcast episodes get episode tags instead.
+func (c *Client) AddTrackTags(trackBuffer []byte, track types.TrackType, options TagOptions) ([]byte, error) {
+	if track.EPISODE != nil {
+		return c.addEpisodeTags(trackBuffer, *track.EPISODE, options)
+	}
 	logger.Debug("Starting to add track tags for track: %s", track.SNG_TITLE)
 
 	coverMode := NormalizeCoverMode(options.CoverMode)
 	var cover []byte
 	if ShouldEmbedCover(coverMode) {
-		coverData, coverErr := DownloadAlbumCover(track.ALB_PICTURE, options.CoverSize)
+		coverData, coverErr := c.DownloadAlbumCover(track.ALB_PICTURE, options.CoverSize)
 		if coverErr != nil {
 			logger.Debug("Failed to download album cover: %v", coverErr)
 			return nil, coverErr
@@ -72,19 +80,14 @@ func AddTrackTags(trackBuffer []byte, track types.TrackType, options TagOptions)
 		logger.Debug("Downloaded album cover successfully")
 	}
 
-	var lyrics types.LyricsType
-	if track.LYRICS_ID > 0 {
-		var lyricsErr error
-		lyrics, lyricsErr = api.GetLyrics(track.SNG_ID)
-		if lyricsErr == nil {
-			track.LYRICS = &lyrics
-			logger.Debug("Fetched lyrics successfully for track: %s", track.SNG_TITLE)
-		} else {
-			logger.Debug("Failed to fetch lyrics: %v", lyricsErr)
-		}
+	if lyrics, ok := c.trackLyrics(track); ok {
+		track.LYRICS = &lyrics
+	}
+	if track.LYRICS != nil {
+		track.LYRICS = embeddedLyrics(*track.LYRICS, tagLyricsModes(options.Lyrics))
 	}
 
-	album, albumErr := api.GetAlbumInfoPublicApi(track.ALB_ID)
+	album, albumErr := c.api.GetAlbumInfoPublicApi(track.ALB_ID)
 	if albumErr != nil {
 		logger.Debug("Failed to fetch album info: %v", albumErr)
 		return nil, albumErr
@@ -110,11 +113,11 @@ func AddTrackTags(trackBuffer []byte, track types.TrackType, options TagOptions)
 	isFlac := bytes.HasPrefix(trackBuffer, []byte("fLaC"))
 	if isFlac {
 		logger.Debug("Detected FLAC format for track: %s", track.SNG_TITLE)
-		return WriteMetadataFlac(trackBuffer, track, &album, releaseDate, options.CoverSize, cover)
+		return WriteMetadataFlacWithProfile(trackBuffer, track, &album, releaseDate, options.CoverSize, cover, options.Profile)
 	}
 
 	logger.Debug("Detected MP3 format for track: %s", track.SNG_TITLE)
-	return WriteMetadataMp3(trackBuffer, track, &album, releaseDate, cover)
+	return WriteMetadataMp3WithProfile(trackBuffer, track, &album, releaseDate, cover, options.Profile)
 }
 
 func tagReleaseDate(album *types.AlbumTypePublicApi, albumInfo any, track types.TrackType) string {
@@ -1,6 +1,7 @@
 package metadata
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/d-fi/GoFi/types"
@@ -63,3 +64,24 @@ func TestTagReleaseDateFallsBackToPublicAlbumDate(t *testing.T) {
 		t.Fatalf("tagReleaseDate = %q, want 2011-06-10", got)
 	}
 }
+
+func TestWriteMetadataMp3KeepsUntaggedAudio(t *testing.T) {
+	audio := bytes.Repeat(append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...), 3)
+	var track types.TrackType
+	track.SNG_TITLE = "Hey Brother"
+	tagged, err := WriteMetadataMp3(audio, track, nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(tagged, audio) {
+		t.Fatal("leading audio bytes were dropped")
+	}
+
+	retagged, err := WriteMetadataMp3(tagged, track, nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(retagged, audio) || bytes.Count(retagged, []byte("ID3")) != 1 {
+		t.Fatal("retagging did not replace the existing tag")
+	}
+}
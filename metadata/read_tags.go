@@ -0,0 +1,368 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/metaflac"
+	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/verify"
+)
+
+// mp3HeaderScan is how much audio after the ID3v2 tag ReadTags reads to find
+// the first MPEG frame header.
+const mp3HeaderScan = 4096
+
+// Tags is what ReadTags reads back from an MP3 or FLAC file, with the same
+// meaning in both formats.
+type Tags struct {
+	// Format is "mp3" or "flac".
+	Format string
+	Title  string
+	// Artists holds one entry per stored value: the null separated values of
+	// an ID3v2.4 TPE1 frame, or one entry per FLAC ARTIST comment. A value
+	// joined with a separator, such as "AC/DC" or an ID3v2.3 "A/B", stays
+	// whole.
+	Artists      []string
+	Album        string
+	AlbumArtist  string
+	TrackNumber  int
+	TrackTotal   int
+	DiscNumber   int
+	DiscTotal    int
+	ISRC         string
+	UPC          string
+	SourceID     string
+	Lyrics       string
+	SyncedLyrics []SyncedLine
+	Pictures     []Picture
+	Audio        AudioProperties
+}
+
+// Picture is an embedded image.
+type Picture struct {
+	// Type is the ID3v2/FLAC picture type, 3 for a front cover.
+	Type        int
+	MimeType    string
+	Description string
+	// Width and Height are only known for FLAC pictures.
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// AudioProperties describes the audio stream. MP3 values come from the first
+// frame header and assume a constant bitrate, which is what Deezer serves.
+type AudioProperties struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	// Bitrate is in kbps; for FLAC it is the average over the file.
+	Bitrate  int
+	Duration time.Duration
+}
+
+// ReadTags reads the tags, pictures and audio properties of an MP3 or FLAC
+// file. Only the tag blocks and the first audio frame are read.
+func ReadTags(path string) (Tags, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Tags{}, err
+	}
+	defer file.Close()
+	return ReadTagsFrom(file)
+}
+
+// ReadTagsFrom is ReadTags for an open file or an in-memory buffer.
+func ReadTagsFrom(r io.ReadSeeker) (Tags, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Tags{}, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Tags{}, err
+	}
+	marker := make([]byte, 4)
+	if _, err := io.ReadFull(r, marker); err != nil {
+		return Tags{}, fmt.Errorf("read audio header: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Tags{}, err
+	}
+	if string(marker) == "fLaC" {
+		return readFlacTags(r, size)
+	}
+	return readMP3Tags(r, size)
+}
+
+func readMP3Tags(r io.ReadSeeker, size int64) (Tags, error) {
+	tags := Tags{Format: "mp3"}
+	header := make([]byte, 10)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return tags, err
+	}
+	header = header[:n]
+
+	var tagSize int64
+	if bytes.HasPrefix(header, []byte("ID3")) && len(header) == 10 {
+		tagSize = 10 + (int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F))
+		if header[5]&0x10 != 0 {
+			tagSize += 10
+		}
+	}
+	if tagSize > size {
+		return tags, errors.New("ID3 tag is larger than the file")
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return tags, err
+	}
+	head := make([]byte, min(tagSize+mp3HeaderScan, size))
+	if _, err := io.ReadFull(r, head); err != nil {
+		return tags, err
+	}
+
+	tag, err := parseID3(head[:tagSize])
+	if err != nil {
+		return tags, err
+	}
+	setID3Fields(&tags, tag)
+
+	audioStart, stream, err := firstMP3Frame(head, tagSize)
+	if err != nil {
+		return tags, err
+	}
+	audioSize := size - audioStart
+	if audioSize >= 128 {
+		trailer := make([]byte, 3)
+		if _, err := r.Seek(size-128, io.SeekStart); err == nil {
+			if _, err := io.ReadFull(r, trailer); err == nil && string(trailer) == "TAG" {
+				audioSize -= 128
+			}
+		}
+	}
+	tags.Audio = AudioProperties{
+		SampleRate: stream.SampleRate,
+		Channels:   stream.Channels,
+		Bitrate:    stream.Bitrate,
+		Duration:   time.Duration(audioSize*8/int64(stream.Bitrate)) * time.Millisecond,
+	}
+	return tags, nil
+}
+
+// firstMP3Frame finds the first MPEG frame header at or after offset in
+// head. Files tagged by older GoFi versions lost the first bytes of their
+// first frame, so the header may come a little later.
+func firstMP3Frame(head []byte, offset int64) (int64, verify.MP3Stream, error) {
+	var firstErr error
+	for position := offset; position < int64(len(head)); position++ {
+		if head[position] != 0xFF {
+			continue
+		}
+		stream, err := verify.MP3Info(head[position:])
+		if err == nil {
+			return position, stream, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		_, firstErr = verify.MP3Info(nil)
+	}
+	return 0, verify.MP3Stream{}, firstErr
+}
+
+func setID3Fields(tags *Tags, tag *id3v2.Tag) {
+	text := func(id string) string {
+		return strings.TrimSpace(tag.GetTextFrame(id).Text)
+	}
+	userText := func(description string) string {
+		for _, frame := range tag.GetFrames("TXXX") {
+			if userTextMatches(frame, description) {
+				return strings.TrimSpace(frame.(id3v2.UserDefinedTextFrame).Value)
+			}
+		}
+		return ""
+	}
+
+	tags.Title = text("TIT2")
+	tags.Artists = splitValues(text("TPE1"), "\x00")
+	tags.Album = text("TALB")
+	tags.AlbumArtist = text("TPE2")
+	tags.TrackNumber, tags.TrackTotal = parsePosition(text("TRCK"))
+	tags.DiscNumber, tags.DiscTotal = parsePosition(text("TPOS"))
+	tags.ISRC = text("TSRC")
+	tags.UPC = userText("BARCODE")
+	tags.SourceID = userText("SOURCEID")
+
+	for _, frame := range tag.GetFrames("USLT") {
+		if lyrics, ok := frame.(id3v2.UnsynchronisedLyricsFrame); ok {
+			tags.Lyrics = lyrics.Lyrics
+			break
+		}
+	}
+	for _, frame := range tag.GetFrames("SYLT") {
+		if unknown, ok := frame.(id3v2.UnknownFrame); ok {
+			tags.SyncedLyrics = parseSYLT(unknown.Body)
+			break
+		}
+	}
+	for _, frame := range tag.GetFrames("APIC") {
+		if picture, ok := frame.(id3v2.PictureFrame); ok {
+			tags.Pictures = append(tags.Pictures, Picture{
+				Type:        int(picture.PictureType),
+				MimeType:    picture.MimeType,
+				Description: picture.Description,
+				Data:        picture.Picture,
+			})
+		}
+	}
+}
+
+func readFlacTags(r io.Reader, size int64) (Tags, error) {
+	tags := Tags{Format: "flac"}
+	var blocks bytes.Buffer
+	if _, err := io.CopyN(&blocks, r, 4); err != nil {
+		return tags, err
+	}
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return tags, fmt.Errorf("read FLAC metadata block: %w", err)
+		}
+		blocks.Write(header)
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		if _, err := io.CopyN(&blocks, r, length); err != nil {
+			return tags, fmt.Errorf("read FLAC metadata block: %w", err)
+		}
+		if header[0]&0x80 != 0 {
+			break
+		}
+	}
+
+	flac, err := metaflac.NewMetaflac(blocks.Bytes())
+	if err != nil {
+		return tags, err
+	}
+	values := func(name string) []string {
+		var found []string
+		for _, comment := range flac.GetTag(name) {
+			_, value, _ := strings.Cut(comment, "=")
+			found = append(found, strings.TrimSpace(value))
+		}
+		return found
+	}
+	first := func(names ...string) string {
+		for _, name := range names {
+			if found := values(name); len(found) > 0 {
+				return found[0]
+			}
+		}
+		return ""
+	}
+
+	tags.Title = first("TITLE")
+	tags.Artists = values("ARTIST")
+	tags.Album = first("ALBUM")
+	tags.AlbumArtist = first("ALBUMARTIST")
+	tags.TrackNumber, tags.TrackTotal = parsePosition(first("TRACKNUMBER"))
+	if total, err := strconv.Atoi(first("TRACKTOTAL", "TOTALTRACKS")); err == nil {
+		tags.TrackTotal = total
+	}
+	tags.DiscNumber, tags.DiscTotal = parsePosition(first("DISCNUMBER"))
+	if total, err := strconv.Atoi(first("DISCTOTAL", "TOTALDISCS")); err == nil {
+		tags.DiscTotal = total
+	}
+	tags.ISRC = first("ISRC")
+	tags.UPC = first("BARCODE", "UPC")
+	tags.SourceID = first("SOURCEID")
+	tags.Lyrics = first("LYRICS")
+	tags.SyncedLyrics = parseLRC(first("SYNCEDLYRICS"))
+
+	datas := flac.GetPicturesDatas()
+	for i, spec := range flac.GetPicturesSpecs() {
+		tags.Pictures = append(tags.Pictures, Picture{
+			Type:        int(spec.Type),
+			MimeType:    spec.Mime,
+			Description: spec.Description,
+			Width:       int(spec.Width),
+			Height:      int(spec.Height),
+			Data:        datas[i],
+		})
+	}
+
+	tags.Audio = AudioProperties{
+		SampleRate:    int(flac.GetSampleRate()),
+		Channels:      int(flac.GetChannels()),
+		BitsPerSample: int(flac.GetBps()),
+	}
+	if samples := flac.GetTotalSamples(); samples > 0 && tags.Audio.SampleRate > 0 {
+		tags.Audio.Duration = time.Duration(samples) * time.Second / time.Duration(tags.Audio.SampleRate)
+		audioSize := size - int64(blocks.Len())
+		tags.Audio.Bitrate = int(audioSize * 8 * int64(tags.Audio.SampleRate) / int64(samples) / 1000)
+	}
+	return tags, nil
+}
+
+// parsePosition parses "3" or "3/12" as a number and an optional total.
+func parsePosition(value string) (int, int) {
+	number, total, _ := strings.Cut(value, "/")
+	n, _ := strconv.Atoi(strings.TrimSpace(number))
+	t, _ := strconv.Atoi(strings.TrimSpace(total))
+	return n, t
+}
+
+func splitValues(value string, separators ...string) []string {
+	if value == "" {
+		return nil
+	}
+	parts := []string{value}
+	for _, separator := range separators {
+		var split []string
+		for _, part := range parts {
+			split = append(split, strings.Split(part, separator)...)
+		}
+		parts = split
+	}
+	var values []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// parseLRC reads the timed lines of an LRC text and skips its header tags.
+// A line with several timestamps is repeated for each of them.
+func parseLRC(text string) []SyncedLine {
+	var lines []SyncedLine
+	for raw := range strings.SplitSeq(text, "\n") {
+		rest := strings.TrimRight(raw, "\r")
+		var stamps []uint32
+		for strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				break
+			}
+			ms, ok := syncMilliseconds(types.LyricsSync{LrcTimestamp: rest[:end+1]})
+			if !ok {
+				break
+			}
+			stamps = append(stamps, ms)
+			rest = rest[end+1:]
+		}
+		for _, ms := range stamps {
+			lines = append(lines, SyncedLine{Text: rest, Milliseconds: ms})
+		}
+	}
+	return lines
+}
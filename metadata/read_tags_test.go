@@ -0,0 +1,147 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/types"
+)
+
+func TestReadTagsMP3(t *testing.T) {
+	lyrics := testLyrics()
+	track := testLyricsTrack(&lyrics)
+	track.SNG_ID = "3135556"
+	track.ISRC = "SEUM71301326"
+	track.ARTISTS = []types.ArtistType{{ART_NAME: "Avicii"}, {ART_NAME: "Aloe Blacc"}}
+	track.DISK_NUMBER = 1
+	track.TRACK_NUMBER = 3
+	album := &types.AlbumTypePublicApi{UPC: "602537518357", NbTracks: 12}
+	album.Artist.Name = "Avicii"
+
+	audio := bytes.Repeat(append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...), 10)
+	tagged, err := WriteMetadataMp3(audio, track, album, "2013-09-13", []byte{0xFF, 0xD8, 0xFF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged = append(tagged, append([]byte("TAG"), make([]byte, 125)...)...)
+
+	tags, err := ReadTagsFrom(bytes.NewReader(tagged))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Tags{
+		Format:       "mp3",
+		Title:        "Hey Brother",
+		Artists:      []string{"Avicii/Aloe Blacc"},
+		Album:        "True",
+		AlbumArtist:  "Avicii",
+		TrackNumber:  3,
+		TrackTotal:   12,
+		DiscNumber:   1,
+		ISRC:         "SEUM71301326",
+		UPC:          "602537518357",
+		SourceID:     "3135556",
+		Lyrics:       lyrics.LYRICS_TEXT,
+		SyncedLyrics: SyncedLines(lyrics),
+		Pictures:     []Picture{{Type: 3, MimeType: "image/jpeg", Data: []byte{0xFF, 0xD8, 0xFF}}},
+		Audio:        AudioProperties{SampleRate: 44100, Channels: 2, Bitrate: 128, Duration: 260 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Fatalf("ReadTagsFrom() =\n%+v\nwant\n%+v", tags, want)
+	}
+}
+
+func TestReadTagsFlac(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.flac")
+	writeTestFlac(t, path,
+		"TITLE=Hey Brother",
+		"ARTIST=Avicii",
+		"ARTIST=Aloe Blacc",
+		"TRACKNUMBER=03",
+		"TRACKTOTAL=12",
+		"DISCNUMBER=1",
+		"BARCODE=602537518357",
+		"SOURCEID=3135556",
+		"SYNCEDLYRICS=[ti:Hey Brother]\n[00:03.58]Hey brother\n[01:05.20][01:10.00]Oh\n",
+	)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 44.1 kHz, stereo, 16 bit, 10 seconds.
+	binary.BigEndian.PutUint64(data[8+10:], uint64(44100)<<44|uint64(1)<<41|uint64(15)<<36|441000)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := ReadTags(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags.Format != "flac" || tags.Title != "Hey Brother" || !reflect.DeepEqual(tags.Artists, []string{"Avicii", "Aloe Blacc"}) {
+		t.Fatalf("ReadTags() = %+v", tags)
+	}
+	if tags.TrackNumber != 3 || tags.TrackTotal != 12 || tags.DiscNumber != 1 || tags.UPC != "602537518357" || tags.SourceID != "3135556" {
+		t.Fatalf("ReadTags() = %+v", tags)
+	}
+	wantLines := []SyncedLine{{"Hey brother", 3580}, {"Oh", 65200}, {"Oh", 70000}}
+	if !reflect.DeepEqual(tags.SyncedLyrics, wantLines) {
+		t.Fatalf("SyncedLyrics = %+v", tags.SyncedLyrics)
+	}
+	wantAudio := AudioProperties{SampleRate: 44100, Channels: 2, BitsPerSample: 16, Duration: 10 * time.Second}
+	if tags.Audio != wantAudio {
+		t.Fatalf("Audio = %+v", tags.Audio)
+	}
+}
+
+func TestParseSYLTUTF16(t *testing.T) {
+	body := []byte{1, 'e', 'n', 'g', 2, 1, 0xFF, 0xFE, 0, 0}
+	body = append(body, 0xFF, 0xFE, 'H', 0, 'i', 0, 0, 0, 0, 0, 0x03, 0xE8)
+	if got := parseSYLT(body); !reflect.DeepEqual(got, []SyncedLine{{"Hi", 1000}}) {
+		t.Fatalf("parseSYLT() = %+v", got)
+	}
+	frames := SynchronisedLyricsFrame{Language: "eng", Lines: []SyncedLine{{"Hé", 5}}}
+	var out bytes.Buffer
+	if _, err := frames.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got := parseSYLT(out.Bytes()); !reflect.DeepEqual(got, frames.Lines) {
+		t.Fatalf("parseSYLT() of a written frame = %+v", got)
+	}
+}
+
+func TestReadTagsKeepsJoinedArtists(t *testing.T) {
+	audio := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...)
+	for _, test := range []struct {
+		version byte
+		artist  string
+		want    []string
+	}{
+		{4, "AC/DC\x00Bon Scott", []string{"AC/DC", "Bon Scott"}},
+		{4, "AC/DC; Bon Scott", []string{"AC/DC; Bon Scott"}},
+		{3, "AC/DC", []string{"AC/DC"}},
+	} {
+		tag := id3v2.NewEmptyTag()
+		tag.SetVersion(test.version)
+		tag.AddTextFrame("TPE1", id3v2.EncodingUTF8, test.artist)
+		var tagged bytes.Buffer
+		if _, err := tag.WriteTo(&tagged); err != nil {
+			t.Fatal(err)
+		}
+		tagged.Write(audio)
+
+		tags, err := ReadTagsFrom(bytes.NewReader(tagged.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tags.Artists, test.want) {
+			t.Fatalf("ID3v2.%d TPE1 %q: Artists = %q, want %q", test.version, test.artist, tags.Artists, test.want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/metaflac"
+)
+
+// tagField is a group of tags retag can refresh on its own, as ID3v2 frame
+// IDs ("TXXX:<description>" for user text frames) and Vorbis comment names.
+type tagField struct {
+	id3    []string
+	vorbis []string
+}
+
+var tagFields = map[string]tagField{
+	"title":       {[]string{"TIT2"}, []string{"TITLE"}},
+	"artist":      {[]string{"TPE1"}, []string{"ARTIST"}},
+	"album":       {[]string{"TALB"}, []string{"ALBUM"}},
+	"albumartist": {[]string{"TPE2"}, []string{"ALBUMARTIST"}},
+	"track":       {[]string{"TRCK"}, []string{"TRACKNUMBER", "TRACKTOTAL", "TOTALTRACKS"}},
+	"disc":        {[]string{"TPOS"}, []string{"DISCNUMBER"}},
+	"date":        {[]string{"TDRC", "TYER", "TDAT"}, []string{"DATE", "YEAR"}},
+	"genre":       {[]string{"TCON"}, []string{"GENRE"}},
+	"isrc":        {[]string{"TSRC"}, []string{"ISRC"}},
+	"length":      {[]string{"TLEN"}, []string{"LENGTH"}},
+	"media":       {[]string{"TMED"}, []string{"MEDIA"}},
+	"label":       {[]string{"TXXX:LABEL"}, []string{"LABEL"}},
+	"barcode":     {[]string{"TXXX:BARCODE"}, []string{"BARCODE"}},
+	"releasetype": {[]string{"TXXX:RELEASETYPE"}, []string{"RELEASETYPE"}},
+	"compilation": {[]string{"TXXX:COMPILATION"}, []string{"COMPILATION"}},
+	"explicit":    {[]string{"TXXX:EXPLICIT"}, []string{"EXPLICIT"}},
+	"credits": {
+		[]string{"TCOP", "TPUB", "TCOM", "TXXX:LYRICIST", "TXXX:AUTHOR", "TXXX:MIXARTIST", "TXXX:INVOLVEDPEOPLE"},
+		[]string{"COPYRIGHT", "ORGANIZATION", "COMPOSER", "PRODUCER", "ENGINEER", "WRITER", "AUTHOR", "MIXER"},
+	},
+	"lyrics": {
+		[]string{"USLT", "SYLT", "TEXT", "TXXX:LYRICSCOPYRIGHT"},
+		[]string{"LYRICS", "SYNCEDLYRICS", "LYRICIST", "LYRICSCOPYRIGHT"},
+	},
+	"cover":  {[]string{"APIC"}, []string{"PICTURE"}},
+	"source": {[]string{"TXXX:SOURCE", "TXXX:SOURCEID"}, []string{"SOURCE", "SOURCEID"}},
+}
+
+// TagFields lists the field names MergeTags accepts, sorted.
+func TagFields() []string {
+	names := make([]string, 0, len(tagFields))
+	for name := range tagFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseTagFields parses a comma separated field list such as "cover,lyrics".
+func ParseTagFields(value string) ([]string, error) {
+	var fields []string
+	for part := range strings.SplitSeq(value, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" || slices.Contains(fields, name) {
+			continue
+		}
+		if _, ok := tagFields[name]; !ok {
+			return nil, fmt.Errorf("unknown tag field %q, use one of %s", name, strings.Join(TagFields(), ", "))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// fieldKeys returns the keys of fields. FLAC keys include the names profile
+// renames them to, so both old and new comments are replaced.
+func fieldKeys(fields []string, flac bool, profile TagProfile) []string {
+	var keys []string
+	for _, name := range fields {
+		if flac {
+			for _, key := range tagFields[name].vorbis {
+				keys = append(keys, key)
+				if renamed := profile.vorbisKey(key); renamed != key {
+					keys = append(keys, renamed)
+				}
+			}
+		} else {
+			keys = append(keys, tagFields[name].id3...)
+		}
+	}
+	return keys
+}
+
+// MergeTags returns current with the tags of fields taken from fresh, a copy
+// of the same audio tagged again. Tags outside fields keep their current
+// values; no fields takes every tag from fresh. profile is the one fresh was
+// tagged with.
+func MergeTags(current, fresh []byte, fields []string, profile TagProfile) ([]byte, error) {
+	if len(fields) == 0 {
+		return fresh, nil
+	}
+	if bytes.HasPrefix(current, []byte("fLaC")) {
+		return mergeFlacTags(current, fresh, fieldKeys(fields, true, profile))
+	}
+	return mergeID3Tags(current, fresh, fieldKeys(fields, false, profile))
+}
+
+func mergeFlacTags(current, fresh []byte, keys []string) ([]byte, error) {
+	target, err := metaflac.NewMetaflac(current)
+	if err != nil {
+		return nil, err
+	}
+	source, err := metaflac.NewMetaflac(fresh)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if key == "PICTURE" {
+			target.RemoveAllPictures()
+			datas := source.GetPicturesDatas()
+			for i, spec := range source.GetPicturesSpecs() {
+				target.ImportPicture(datas[i], spec)
+			}
+			continue
+		}
+		target.RemoveTag(key)
+		for _, comment := range source.GetTag(key) {
+			if err := target.SetTag(comment); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return target.GetBuffer(), nil
+}
+
+func mergeID3Tags(current, fresh []byte, keys []string) ([]byte, error) {
+	audio, err := StripTags(current)
+	if err != nil {
+		return nil, err
+	}
+	target, err := parseID3(current)
+	if err != nil {
+		return nil, err
+	}
+	source, err := parseID3(fresh)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(current, []byte("ID3")) && target.Version() != source.Version() {
+		return nil, fmt.Errorf("can't merge ID3v2.%d tags into ID3v2.%d tags, retag every field to change the version", source.Version(), target.Version())
+	}
+	target.SetVersion(source.Version())
+	for _, key := range keys {
+		id, description, isUserText := strings.Cut(key, ":")
+		if !isUserText {
+			target.DeleteFrames(id)
+			for _, frame := range source.GetFrames(id) {
+				target.AddFrame(id, frame)
+			}
+			continue
+		}
+		userFrames := target.GetFrames(id)
+		target.DeleteFrames(id)
+		for _, frame := range userFrames {
+			if !userTextMatches(frame, description) {
+				target.AddFrame(id, frame)
+			}
+		}
+		for _, frame := range source.GetFrames(id) {
+			if userTextMatches(frame, description) {
+				target.AddFrame(id, frame)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if _, err := target.WriteTo(&out); err != nil {
+		return nil, err
+	}
+	out.Write(audio)
+	// The ID3v1 tag follows the profile fresh was tagged with.
+	if trimmed := withoutID3v1(fresh); len(trimmed) < len(fresh) {
+		out.Write(fresh[len(trimmed):])
+	}
+	return out.Bytes(), nil
+}
+
+func parseID3(buffer []byte) (*id3v2.Tag, error) {
+	if !bytes.HasPrefix(buffer, []byte("ID3")) {
+		tag := id3v2.NewEmptyTag()
+		tag.SetVersion(4)
+		return tag, nil
+	}
+	return id3v2.ParseReader(bytes.NewReader(buffer), id3v2.Options{Parse: true})
+}
+
+func userTextMatches(frame id3v2.Framer, description string) bool {
+	userFrame, ok := frame.(id3v2.UserDefinedTextFrame)
+	return ok && strings.EqualFold(userFrame.Description, description)
+}
+
+// TagValue is one tag of a file as ListTags reports it. Binary frames and
+// pictures are summarized by their size and checksum.
+type TagValue struct {
+	Key   string
+	Value string
+}
+
+// ListTags returns the ID3v2 frames or Vorbis comments and pictures of an
+// MP3 or FLAC buffer, sorted by key. An MP3 also lists its ID3v2 version and
+// ID3v1 tag, if any.
+func ListTags(buffer []byte) ([]TagValue, error) {
+	var values []TagValue
+	if bytes.HasPrefix(buffer, []byte("fLaC")) {
+		flac, err := metaflac.NewMetaflac(buffer)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range flac.GetAllTags() {
+			name, value, _ := strings.Cut(comment, "=")
+			values = append(values, TagValue{strings.ToUpper(name), value})
+		}
+		datas := flac.GetPicturesDatas()
+		for i, spec := range flac.GetPicturesSpecs() {
+			values = append(values, TagValue{"PICTURE", fmt.Sprintf("%s %dx%d, %s", spec.Mime, spec.Width, spec.Height, binarySummary(datas[i]))})
+		}
+	} else {
+		tag, err := parseID3(buffer)
+		if err != nil {
+			return nil, err
+		}
+		for id, frames := range tag.AllFrames() {
+			for _, frame := range frames {
+				values = append(values, id3Value(id, frame))
+			}
+		}
+		if bytes.HasPrefix(buffer, []byte("ID3")) {
+			values = append(values, TagValue{"ID3v2", fmt.Sprintf("2.%d", tag.Version())})
+		}
+		if audio := withoutID3v1(buffer); len(audio) < len(buffer) {
+			values = append(values, TagValue{"ID3v1", id3v1Summary(buffer[len(audio):])})
+		}
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		if values[i].Key != values[j].Key {
+			return values[i].Key < values[j].Key
+		}
+		return values[i].Value < values[j].Value
+	})
+	return values, nil
+}
+
+func id3Value(id string, frame id3v2.Framer) TagValue {
+	switch frame := frame.(type) {
+	case id3v2.TextFrame:
+		return TagValue{id, frame.Text}
+	case id3v2.UserDefinedTextFrame:
+		return TagValue{id + ":" + frame.Description, frame.Value}
+	case id3v2.UnsynchronisedLyricsFrame:
+		return TagValue{id, frame.Lyrics}
+	case id3v2.CommentFrame:
+		return TagValue{id, frame.Text}
+	case id3v2.PictureFrame:
+		return TagValue{id, frame.MimeType + ", " + binarySummary(frame.Picture)}
+	case id3v2.UnknownFrame:
+		return TagValue{id, binarySummary(frame.Body)}
+	default:
+		var body bytes.Buffer
+		_, _ = frame.WriteTo(&body)
+		return TagValue{id, binarySummary(body.Bytes())}
+	}
+}
+
+func binarySummary(data []byte) string {
+	return fmt.Sprintf("%d bytes, crc %08x", len(data), crc32.ChecksumIEEE(data))
+}
+
+// TagChange is a key whose values differ between two ListTags results.
+type TagChange struct {
+	Key    string
+	Before []string
+	After  []string
+}
+
+// DiffTags compares two ListTags results and returns the changed keys in order.
+func DiffTags(before, after []TagValue) []TagChange {
+	group := func(values []TagValue) map[string][]string {
+		grouped := map[string][]string{}
+		for _, value := range values {
+			grouped[value.Key] = append(grouped[value.Key], value.Value)
+		}
+		return grouped
+	}
+	old, updated := group(before), group(after)
+	keys := map[string]bool{}
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range updated {
+		keys[key] = true
+	}
+
+	var changes []TagChange
+	for key := range keys {
+		if !slices.Equal(old[key], updated[key]) {
+			changes = append(changes, TagChange{Key: key, Before: old[key], After: updated[key]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
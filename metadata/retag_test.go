@@ -0,0 +1,121 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/metaflac"
+)
+
+func tagMP3(t *testing.T, audio []byte, title, label string) []byte {
+	t.Helper()
+	tag := id3v2.NewEmptyTag()
+	tag.SetVersion(4)
+	tag.SetDefaultEncoding(id3v2.EncodingUTF8)
+	tag.SetTitle(title)
+	addUserTextFrame(tag, "LABEL", label)
+	addUserTextFrame(tag, "SOURCEID", "3135556")
+	var out bytes.Buffer
+	if _, err := tag.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	out.Write(audio)
+	return out.Bytes()
+}
+
+func TestParseTagFields(t *testing.T) {
+	fields, err := ParseTagFields(" Cover, lyrics,cover,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fields, []string{"cover", "lyrics"}) {
+		t.Fatalf("ParseTagFields() = %v", fields)
+	}
+	if _, err := ParseTagFields("title,mood"); err == nil {
+		t.Fatal("expected an unknown field to be rejected")
+	}
+}
+
+func TestMergeTagsMP3KeepsOtherFields(t *testing.T) {
+	audio := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...)
+	current := tagMP3(t, audio, "Old Title", "Old Label")
+	fresh := tagMP3(t, audio, "New Title", "New Label")
+
+	merged, err := MergeTags(current, fresh, []string{"label"}, TagProfile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := StripTags(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, audio) {
+		t.Fatal("MergeTags() changed the audio frames")
+	}
+
+	before, err := ListTags(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := ListTags(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TagChange{{Key: "TXXX:LABEL", Before: []string{"Old Label"}, After: []string{"New Label"}}}
+	if changes := DiffTags(before, after); !reflect.DeepEqual(changes, want) {
+		t.Fatalf("DiffTags() = %+v", changes)
+	}
+
+	if merged, err := MergeTags(current, fresh, nil, TagProfile{}); err != nil || !bytes.Equal(merged, fresh) {
+		t.Fatalf("MergeTags() without fields should take every tag from fresh, err = %v", err)
+	}
+}
+
+func TestMergeTagsFlac(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFlac(t, filepath.Join(dir, "current.flac"), "TITLE=Old", "GENRE=Pop", "SOURCEID=3135556")
+	writeTestFlac(t, filepath.Join(dir, "fresh.flac"), "TITLE=New", "GENRE=Dance", "GENRE=Electro", "SOURCEID=3135556")
+	current, err := os.ReadFile(filepath.Join(dir, "current.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	freshBuffer, err := os.ReadFile(filepath.Join(dir, "fresh.flac"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := metaflac.NewMetaflac(freshBuffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh.ImportPicture([]byte{0xFF, 0xD8, 0xFF}, metaflac.PictureSpec{Type: 3, Mime: "image/jpeg", Width: 500, Height: 500, Depth: 24})
+
+	merged, err := MergeTags(current, fresh.GetBuffer(), []string{"genre", "cover"}, TagProfile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flac, err := metaflac.NewMetaflac(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := flac.GetTag("TITLE"); !reflect.DeepEqual(got, []string{"TITLE=Old"}) {
+		t.Fatalf("TITLE = %q, want it untouched", got)
+	}
+	if got := flac.GetTag("GENRE"); !reflect.DeepEqual(got, []string{"GENRE=Dance", "GENRE=Electro"}) {
+		t.Fatalf("GENRE = %q", got)
+	}
+	if datas := flac.GetPicturesDatas(); len(datas) != 1 || !bytes.Equal(datas[0], []byte{0xFF, 0xD8, 0xFF}) {
+		t.Fatalf("pictures = %v", datas)
+	}
+
+	tags, err := ListTags(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags[0].Key != "GENRE" || tags[2].Key != "PICTURE" || tags[2].Value != "image/jpeg 500x500, "+binarySummary([]byte{0xFF, 0xD8, 0xFF}) {
+		t.Fatalf("ListTags() = %+v", tags)
+	}
+}
@@ -0,0 +1,110 @@
+package metadata
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+const flacVorbisCommentType = 4
+
+// SourceID returns the Deezer SNG_ID stored in the SOURCEID tag of a saved
+// FLAC or MP3 file. Only the tag blocks are read, not the audio. An empty
+// string with a nil error means the file has no SOURCEID tag.
+func SourceID(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	marker, err := reader.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if string(marker) == "fLaC" {
+		return flacSourceID(reader)
+	}
+
+	tag, err := id3v2.ParseReader(reader, id3v2.Options{Parse: true, ParseFrames: []string{"TXXX"}})
+	if err != nil {
+		return "", err
+	}
+	for _, frame := range tag.GetFrames(tag.CommonID("User defined text information frame")) {
+		if userFrame, ok := frame.(id3v2.UserDefinedTextFrame); ok && userFrame.Description == "SOURCEID" {
+			return strings.TrimSpace(userFrame.Value), nil
+		}
+	}
+	return "", nil
+}
+
+// flacSourceID walks the FLAC metadata blocks and reads SOURCEID from the
+// Vorbis comment block.
+func flacSourceID(reader io.Reader) (string, error) {
+	if _, err := io.CopyN(io.Discard, reader, 4); err != nil {
+		return "", err
+	}
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return "", fmt.Errorf("read FLAC metadata block: %w", err)
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		if blockType != flacVorbisCommentType {
+			if _, err := io.CopyN(io.Discard, reader, length); err != nil {
+				return "", err
+			}
+			if last {
+				return "", nil
+			}
+			continue
+		}
+
+		block := make([]byte, length)
+		if _, err := io.ReadFull(reader, block); err != nil {
+			return "", err
+		}
+		for _, comment := range parseVorbisComments(block) {
+			name, value, ok := strings.Cut(comment, "=")
+			if ok && strings.EqualFold(name, "SOURCEID") {
+				return strings.TrimSpace(value), nil
+			}
+		}
+		return "", nil
+	}
+}
+
+func parseVorbisComments(block []byte) []string {
+	if len(block) < 4 {
+		return nil
+	}
+	offset := 4 + int(binary.LittleEndian.Uint32(block))
+	if offset+4 > len(block) {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint32(block[offset:]))
+	offset += 4
+	comments := make([]string, 0, min(count, 64))
+	for range count {
+		if offset+4 > len(block) {
+			break
+		}
+		length := int(binary.LittleEndian.Uint32(block[offset:]))
+		offset += 4
+		if length < 0 || offset+length > len(block) {
+			break
+		}
+		comments = append(comments, string(block[offset:offset+length]))
+		offset += length
+	}
+	return comments
+}
@@ -0,0 +1,96 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+func writeTestFlac(t *testing.T, path string, comments ...string) {
+	t.Helper()
+	var block bytes.Buffer
+	vendor := "GoFi"
+	_ = binary.Write(&block, binary.LittleEndian, uint32(len(vendor)))
+	block.WriteString(vendor)
+	_ = binary.Write(&block, binary.LittleEndian, uint32(len(comments)))
+	for _, comment := range comments {
+		_ = binary.Write(&block, binary.LittleEndian, uint32(len(comment)))
+		block.WriteString(comment)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("fLaC")
+	out.Write([]byte{0x00, 0, 0, 34})
+	out.Write(make([]byte, 34))
+	out.Write([]byte{0x80 | flacVorbisCommentType, byte(block.Len() >> 16), byte(block.Len() >> 8), byte(block.Len())})
+	out.Write(block.Bytes())
+	out.Write([]byte{0xFF, 0xF8, 0x00, 0x00})
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestMP3(t *testing.T, path, sourceID string) {
+	t.Helper()
+	tag := id3v2.NewEmptyTag()
+	tag.SetVersion(4)
+	tag.SetTitle("Song")
+	if sourceID != "" {
+		addUserTextFrame(tag, "SOURCEID", sourceID)
+	}
+	var out bytes.Buffer
+	if _, err := tag.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	out.Write([]byte{0xFF, 0xFB, 0x90, 0x00})
+	out.Write(make([]byte, 413))
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSourceIDFlac(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.flac")
+	writeTestFlac(t, path, "TITLE=Song", "sourceid=3135556")
+	got, err := SourceID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "3135556" {
+		t.Fatalf("SourceID() = %q, want 3135556", got)
+	}
+}
+
+func TestSourceIDMP3(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.mp3")
+	writeTestMP3(t, path, "3135556")
+	got, err := SourceID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "3135556" {
+		t.Fatalf("SourceID() = %q, want 3135556", got)
+	}
+}
+
+func TestSourceIDMissing(t *testing.T) {
+	dir := t.TempDir()
+	flacPath := filepath.Join(dir, "song.flac")
+	writeTestFlac(t, flacPath, "TITLE=Song")
+	mp3Path := filepath.Join(dir, "song.mp3")
+	writeTestMP3(t, mp3Path, "")
+
+	for _, path := range []string{flacPath, mp3Path} {
+		got, err := SourceID(path)
+		if err != nil {
+			t.Fatalf("SourceID(%s) error = %v", path, err)
+		}
+		if got != "" {
+			t.Fatalf("SourceID(%s) = %q, want empty", path, got)
+		}
+	}
+}
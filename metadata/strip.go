@@ -0,0 +1,39 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/d-fi/GoFi/metaflac"
+)
+
+// StripTags returns buffer without the tags and cover art AddTrackTags writes:
+// the leading ID3v2 and trailing ID3v1 tags of an MP3, or the Vorbis comments
+// and pictures of a FLAC. The audio frames are left untouched, so the result
+// can be tagged again.
+func StripTags(buffer []byte) ([]byte, error) {
+	if bytes.HasPrefix(buffer, []byte("fLaC")) {
+		flac, err := metaflac.NewMetaflac(buffer)
+		if err != nil {
+			return nil, err
+		}
+		flac.RemoveAllTags()
+		flac.RemoveAllPictures()
+		return flac.GetBuffer(), nil
+	}
+
+	if !bytes.HasPrefix(buffer, []byte("ID3")) {
+		return withoutID3v1(buffer), nil
+	}
+	if len(buffer) < 10 {
+		return nil, errors.New("truncated ID3 header")
+	}
+	size := 10 + (int(buffer[6]&0x7F)<<21 | int(buffer[7]&0x7F)<<14 | int(buffer[8]&0x7F)<<7 | int(buffer[9]&0x7F))
+	if buffer[5]&0x10 != 0 {
+		size += 10
+	}
+	if size > len(buffer) {
+		return nil, errors.New("ID3 tag is larger than the file")
+	}
+	return withoutID3v1(buffer[size:]), nil
+}
@@ -0,0 +1,52 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/d-fi/GoFi/metaflac"
+)
+
+func TestStripTagsMP3(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.mp3")
+	writeTestMP3(t, path, "3135556")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := StripTags(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(stripped, []byte{0xFF, 0xFB}) || len(stripped) != 417 {
+		t.Fatalf("StripTags() left %d bytes starting with % x", len(stripped), stripped[:min(4, len(stripped))])
+	}
+	if _, err := StripTags([]byte("ID3\x04\x00\x00\x00\x00\x7F\x7F")); err == nil {
+		t.Fatal("expected an oversized ID3 tag to be rejected")
+	}
+}
+
+func TestStripTagsFlac(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.flac")
+	writeTestFlac(t, path, "TITLE=Song", "SOURCEID=3135556")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped, err := StripTags(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flac, err := metaflac.NewMetaflac(stripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := flac.GetAllTags(); len(tags) != 0 {
+		t.Fatalf("tags after StripTags = %v", tags)
+	}
+	if !bytes.HasSuffix(stripped, []byte{0xFF, 0xF8, 0x00, 0x00}) {
+		t.Fatal("StripTags changed the audio frames")
+	}
+}
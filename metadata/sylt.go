@@ -0,0 +1,146 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"unicode/utf16"
+)
+
+// SynchronisedLyricsFrame is an ID3v2 SYLT frame. bogem/id3v2 parses and
+// writes it only as raw bytes, so this builds the body itself: UTF-8 text,
+// millisecond timestamps and the "lyrics" content type.
+type SynchronisedLyricsFrame struct {
+	Language          string
+	ContentDescriptor string
+	Lines             []SyncedLine
+	// UTF16 writes the text as UTF-16 with a byte order mark, for ID3v2.3
+	// tags, which have no UTF-8.
+	UTF16 bool
+}
+
+const (
+	syltEncodingUTF16   = 1
+	syltEncodingUTF8    = 3
+	syltMilliseconds    = 2
+	syltContentTypeText = 1
+)
+
+func (f SynchronisedLyricsFrame) body() []byte {
+	language := (f.Language + "xxx")[:3]
+	encoding := byte(syltEncodingUTF8)
+	if f.UTF16 {
+		encoding = syltEncodingUTF16
+	}
+	body := []byte{encoding}
+	body = append(body, language...)
+	body = append(body, syltMilliseconds, syltContentTypeText)
+	body = f.appendText(body, f.ContentDescriptor)
+	for _, line := range f.Lines {
+		body = f.appendText(body, line.Text)
+		body = binary.BigEndian.AppendUint32(body, line.Milliseconds)
+	}
+	return body
+}
+
+// appendText appends a null terminated string in the frame's encoding.
+func (f SynchronisedLyricsFrame) appendText(body []byte, text string) []byte {
+	if !f.UTF16 {
+		body = append(body, text...)
+		return append(body, 0)
+	}
+	body = append(body, 0xFF, 0xFE)
+	for _, unit := range utf16.Encode([]rune(text)) {
+		body = binary.LittleEndian.AppendUint16(body, unit)
+	}
+	return append(body, 0, 0)
+}
+
+func (f SynchronisedLyricsFrame) Size() int {
+	return len(f.body())
+}
+
+func (f SynchronisedLyricsFrame) UniqueIdentifier() string {
+	return f.Language + f.ContentDescriptor
+}
+
+func (f SynchronisedLyricsFrame) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(f.body())
+	return int64(n), err
+}
+
+// parseSYLT reads the lines of a SYLT frame body. Frames timed in MPEG frames
+// rather than milliseconds can't be mapped to a time and give no lines.
+func parseSYLT(body []byte) []SyncedLine {
+	if len(body) < 6 || body[4] != syltMilliseconds {
+		return nil
+	}
+	encoding := body[0]
+	rest := body[6:]
+	_, rest, ok := cutSYLTText(rest, encoding)
+	if !ok {
+		return nil
+	}
+	var lines []SyncedLine
+	for len(rest) > 0 {
+		var text string
+		text, rest, ok = cutSYLTText(rest, encoding)
+		if !ok || len(rest) < 4 {
+			break
+		}
+		lines = append(lines, SyncedLine{Text: text, Milliseconds: binary.BigEndian.Uint32(rest)})
+		rest = rest[4:]
+	}
+	return lines
+}
+
+// cutSYLTText splits a terminated string in the frame's text encoding off
+// the front of data and returns it as UTF-8.
+func cutSYLTText(data []byte, encoding byte) (string, []byte, bool) {
+	if encoding != 1 && encoding != 2 {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			return "", nil, false
+		}
+		text := data[:end]
+		if encoding == 0 {
+			runes := make([]rune, len(text))
+			for i, b := range text {
+				runes[i] = rune(b)
+			}
+			return string(runes), data[end+1:], true
+		}
+		return string(text), data[end+1:], true
+	}
+
+	end := -1
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 && data[i+1] == 0 {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", nil, false
+	}
+	text := data[:end]
+	bigEndian := true
+	if encoding == 1 && len(text) >= 2 {
+		switch {
+		case text[0] == 0xFF && text[1] == 0xFE:
+			bigEndian = false
+			text = text[2:]
+		case text[0] == 0xFE && text[1] == 0xFF:
+			text = text[2:]
+		}
+	}
+	units := make([]uint16, len(text)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(text[2*i:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(text[2*i:])
+		}
+	}
+	return string(utf16.Decode(units)), data[end+2:], true
+}
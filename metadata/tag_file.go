@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/d-fi/GoFi/logger"
+	"github.com/d-fi/GoFi/types"
+	"github.com/d-fi/GoFi/utils"
+)
+
+// AddTrackTagsFile writes the audio file at src to dst tagged as AddTrackTags
+// would. Only the tags at the start of src are held in memory; the audio after
+// them is copied across. dst is replaced atomically and src is left as it is.
+func (c *Client) AddTrackTagsFile(src, dst string, track types.TrackType, options TagOptions) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	headLength, err := tagHeadLength(file, stat.Size())
+	if err != nil {
+		return err
+	}
+	head := make([]byte, headLength)
+	if _, err := file.ReadAt(head, 0); err != nil {
+		return err
+	}
+	tagged, err := c.AddTrackTags(head, track, options)
+	if err != nil {
+		return err
+	}
+	// The tagged head ends with any ID3v1 tag the profile asked for, which
+	// belongs after the audio and replaces the one src may end with.
+	taggedLength, err := tagHeadLength(bytes.NewReader(tagged), int64(len(tagged)))
+	if err != nil {
+		return err
+	}
+	header, trailer := tagged[:taggedLength], tagged[taggedLength:]
+	audioEnd := stat.Size()
+	if len(trailer) > 0 && hasID3v1(file, headLength, audioEnd) {
+		audioEnd -= id3v1Size
+	}
+
+	out, err := utils.CreatePartial(dst)
+	if err != nil {
+		return err
+	}
+	audio := io.NewSectionReader(file, headLength, audioEnd-headLength)
+	if _, err = out.Write(header); err == nil {
+		if _, err = io.Copy(out, audio); err == nil {
+			_, err = out.Write(trailer)
+		}
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	// src may be dst, and an open file can't be replaced on every platform.
+	file.Close()
+	logger.Debug("Wrote tagged copy of %s to %s", src, dst)
+	return utils.CommitPartial(out, dst, 0644)
+}
+
+// tagHeadLength returns the length of the tags at the start of an audio file:
+// the FLAC marker with every metadata block, or a leading ID3v2 tag. Untagged
+// MP3 audio has none.
+func tagHeadLength(r io.ReaderAt, size int64) (int64, error) {
+	header := make([]byte, 10)
+	n, err := r.ReadAt(header, 0)
+	if n < len(header) && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		offset := int64(4)
+		block := make([]byte, 4)
+		for {
+			if _, err := r.ReadAt(block, offset); err != nil {
+				return 0, fmt.Errorf("read FLAC metadata block: %w", err)
+			}
+			offset += 4 + (int64(block[1])<<16 | int64(block[2])<<8 | int64(block[3]))
+			if offset > size {
+				return 0, errors.New("FLAC metadata is larger than the file")
+			}
+			if block[0]&0x80 != 0 {
+				return offset, nil
+			}
+		}
+	case bytes.HasPrefix(header, []byte("ID3")) && len(header) == 10:
+		length := 10 + (int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F))
+		if header[5]&0x10 != 0 {
+			length += 10
+		}
+		if length > size {
+			return 0, errors.New("ID3 tag is larger than the file")
+		}
+		return length, nil
+	}
+	return 0, nil
+}
+
+// hasID3v1 reports whether the audio between start and end ends with an ID3v1 tag.
+func hasID3v1(r io.ReaderAt, start, end int64) bool {
+	if end-start < id3v1Size {
+		return false
+	}
+	marker := make([]byte, 3)
+	_, err := r.ReadAt(marker, end-id3v1Size)
+	return err == nil && string(marker) == "TAG"
+}
@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/types"
+)
+
+func TestAddTrackTagsFileStreamsAudio(t *testing.T) {
+	audio := bytes.Repeat(append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...), 50)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mp3")
+	dst := filepath.Join(dir, "dst.mp3")
+	if err := os.WriteFile(src, audio, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var track types.TrackType
+	track.EPISODE = &types.ShowEpisodeType{EpisodeID: "10", EpisodeTitle: "Episode", ShowName: "Show"}
+
+	client := New(nil)
+	if err := client.AddTrackTagsFile(src, dst, track, TagOptions{CoverMode: CoverModeNone}); err != nil {
+		t.Fatal(err)
+	}
+	tagged, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(tagged, audio) {
+		t.Fatal("audio was not copied whole")
+	}
+	if data, err := os.ReadFile(src); err != nil || !bytes.Equal(data, audio) {
+		t.Fatalf("src was changed: %v", err)
+	}
+
+	// Tagging the tagged file again replaces its tag instead of stacking one more.
+	track.EPISODE.EpisodeTitle = "Renamed"
+	if err := client.AddTrackTagsFile(dst, dst, track, TagOptions{CoverMode: CoverModeNone}); err != nil {
+		t.Fatal(err)
+	}
+	retagged, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(retagged, audio) || bytes.Count(retagged, []byte("ID3")) != 1 {
+		t.Fatal("retagging did not replace the existing tag")
+	}
+	tag, err := id3v2.ParseReader(bytes.NewReader(retagged), id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Title() != "Renamed" {
+		t.Fatalf("title = %q, want Renamed", tag.Title())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("partial files left behind: %v %v", entries, err)
+	}
+}
+
+func TestTagHeadLength(t *testing.T) {
+	flac := append([]byte("fLaC"), 0x80, 0, 0, 34)
+	flac = append(flac, make([]byte, 34)...)
+	frames := []byte{0xFF, 0xF8, 0xC9, 0x18}
+	tests := []struct {
+		name string
+		data []byte
+		want int64
+	}{
+		{"flac", append(append([]byte(nil), flac...), frames...), int64(len(flac))},
+		{"id3", append([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 1, 2}, make([]byte, 200)...), 10 + 130},
+		{"untagged mp3", frames, 0},
+		{"empty", nil, 0},
+	}
+	for _, test := range tests {
+		got, err := tagHeadLength(bytes.NewReader(test.data), int64(len(test.data)))
+		if err != nil || got != test.want {
+			t.Fatalf("%s: tagHeadLength = %d, %v, want %d", test.name, got, err, test.want)
+		}
+	}
+
+	if _, err := tagHeadLength(bytes.NewReader(flac[:20]), 20); err == nil {
+		t.Fatal("truncated FLAC metadata was accepted")
+	}
+}
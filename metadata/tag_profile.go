@@ -0,0 +1,172 @@
+package metadata
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bogem/id3v2/v2"
+)
+
+// tagProfileFields are the fields a TagProfile can turn off or rename, by
+// their default Vorbis comment names. INVOLVEDPEOPLE is the MP3 TXXX frame
+// that joins producers and engineers.
+var tagProfileFields = []string{
+	"TITLE", "ARTIST", "ALBUM", "ALBUMARTIST",
+	"TRACKNUMBER", "TRACKTOTAL", "TOTALTRACKS", "DISCNUMBER",
+	"GENRE", "DATE", "YEAR", "ISRC", "LENGTH", "MEDIA",
+	"RELEASETYPE", "BARCODE", "LABEL", "COMPILATION", "EXPLICIT",
+	"COPYRIGHT", "ORGANIZATION", "COMPOSER", "PRODUCER", "ENGINEER",
+	"WRITER", "AUTHOR", "MIXER", "INVOLVEDPEOPLE",
+	"LYRICS", "SYNCEDLYRICS", "LYRICIST", "LYRICSCOPYRIGHT",
+	"SOURCE", "SOURCEID",
+}
+
+// TagProfile chooses which tags WriteMetadataMp3WithProfile and
+// WriteMetadataFlacWithProfile write and how. The zero value writes every tag the way GoFi always has.
+type TagProfile struct {
+	// Disabled lists fields to leave out, such as "TOTALTRACKS" or "SOURCE".
+	// MP3 frames are named by the Vorbis field they carry.
+	Disabled []string `json:"disabled"`
+	// ArtistSeparator joins several artists into one value. Empty keeps the
+	// format default: ", " for FLAC and "/" for MP3.
+	ArtistSeparator string `json:"artistSeparator"`
+	// MultiValueArtists writes one ARTIST comment per artist in FLAC and a
+	// null separated TPE1 in ID3v2.4 instead of joining them. ID3v2.3 has no
+	// multiple values and still joins them.
+	MultiValueArtists bool `json:"multiValueArtists"`
+	// TrackNumberPadding is the minimum number of digits of track numbers and
+	// totals. Zero means 2; 1 turns padding off.
+	TrackNumberPadding int `json:"trackNumberPadding"`
+	// VorbisKeys renames FLAC comments, for example {"ORGANIZATION": "LABEL"}.
+	VorbisKeys map[string]string `json:"vorbisKeys"`
+	// ID3Version is the ID3v2 version of MP3 tags, 3 or 4. Zero means 4.
+	// Version 3 has no UTF-8, so its text is UTF-16, and it dates releases
+	// with TYER and TDAT instead of TDRC.
+	ID3Version int `json:"id3Version"`
+	// ID3v1 appends an ID3v1.1 tag to MP3 files for players that read no
+	// ID3v2.
+	ID3v1 bool `json:"id3v1"`
+}
+
+// TagProfileFields lists the field names TagProfile accepts.
+func TagProfileFields() []string {
+	return slices.Clone(tagProfileFields)
+}
+
+// Normalize validates profile and returns it with uppercase field names.
+func (profile TagProfile) Normalize() (TagProfile, error) {
+	var disabled []string
+	for _, field := range profile.Disabled {
+		field, err := profileField(field)
+		if err != nil {
+			return profile, err
+		}
+		if !slices.Contains(disabled, field) {
+			disabled = append(disabled, field)
+		}
+	}
+	profile.Disabled = disabled
+
+	if profile.TrackNumberPadding < 0 || profile.TrackNumberPadding > 6 {
+		return profile, fmt.Errorf("trackNumberPadding %d is out of range, use 1 to 6 or 0 for the default", profile.TrackNumberPadding)
+	}
+
+	if profile.ID3Version != 0 && profile.ID3Version != 3 && profile.ID3Version != 4 {
+		return profile, fmt.Errorf("unsupported id3Version %d, use 3 or 4", profile.ID3Version)
+	}
+
+	if len(profile.VorbisKeys) > 0 {
+		keys := make(map[string]string, len(profile.VorbisKeys))
+		for field, key := range profile.VorbisKeys {
+			field, err := profileField(field)
+			if err != nil {
+				return profile, err
+			}
+			key = strings.ToUpper(strings.TrimSpace(key))
+			if !validVorbisKey(key) {
+				return profile, fmt.Errorf("invalid Vorbis comment name %q for %s", key, field)
+			}
+			if field == "INVOLVEDPEOPLE" {
+				return profile, fmt.Errorf("%s is an MP3 frame and has no Vorbis comment", field)
+			}
+			// Upgrade and retag find files by SOURCEID.
+			if field == "SOURCEID" || key == "SOURCEID" {
+				return profile, fmt.Errorf("%s can't be renamed to %s", field, key)
+			}
+			keys[field] = key
+		}
+		profile.VorbisKeys = keys
+	}
+	return profile, nil
+}
+
+func profileField(name string) (string, error) {
+	field := strings.ToUpper(strings.TrimSpace(name))
+	if !slices.Contains(tagProfileFields, field) {
+		return "", fmt.Errorf("unknown tag %q, use one of %s", name, strings.Join(tagProfileFields, ", "))
+	}
+	return field, nil
+}
+
+// validVorbisKey follows the Vorbis comment spec: printable ASCII without '='.
+func validVorbisKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r < 0x20 || r > 0x7D || r == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+func (profile TagProfile) enabled(field string) bool {
+	return !slices.Contains(profile.Disabled, field)
+}
+
+func (profile TagProfile) vorbisKey(field string) string {
+	if key, ok := profile.VorbisKeys[field]; ok {
+		return key
+	}
+	return field
+}
+
+func (profile TagProfile) trackNumber(number int) string {
+	digits := profile.TrackNumberPadding
+	if digits == 0 {
+		digits = 2
+	}
+	return fmt.Sprintf("%0*d", digits, number)
+}
+
+func (profile TagProfile) artistSeparator(fallback string) string {
+	if profile.ArtistSeparator == "" {
+		return fallback
+	}
+	return profile.ArtistSeparator
+}
+
+// artistValues returns the ARTIST values to write: each name on its own, or
+// all of them joined by the profile separator or fallback.
+func (profile TagProfile) artistValues(names []string, fallback string) []string {
+	if profile.MultiValueArtists {
+		return names
+	}
+	return []string{strings.Join(names, profile.artistSeparator(fallback))}
+}
+
+func (profile TagProfile) id3Version() byte {
+	if profile.ID3Version == 3 {
+		return 3
+	}
+	return 4
+}
+
+func (profile TagProfile) id3Encoding() id3v2.Encoding {
+	if profile.id3Version() == 3 {
+		return id3v2.EncodingUTF16
+	}
+	return id3v2.EncodingUTF8
+}
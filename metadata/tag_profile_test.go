@@ -0,0 +1,198 @@
+package metadata
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/bogem/id3v2/v2"
+	"github.com/d-fi/GoFi/metaflac"
+	"github.com/d-fi/GoFi/types"
+)
+
+func testProfileTrack() (types.TrackType, *types.AlbumTypePublicApi) {
+	track := testLyricsTrack(nil)
+	track.SNG_ID = "3135556"
+	track.ARTISTS = []types.ArtistType{{ART_NAME: "Avicii"}, {ART_NAME: "Aloe Blacc"}}
+	track.DISK_NUMBER = 1
+	track.TRACK_NUMBER = 3
+	track.SNG_CONTRIBUTORS = &types.SongContributors{Publisher: []string{"Universal Music"}}
+	album := &types.AlbumTypePublicApi{UPC: "602537518357", Label: "PRMD", NbTracks: 12}
+	album.Artist.Name = "Avicii"
+	return track, album
+}
+
+func TestTagProfileNormalize(t *testing.T) {
+	profile, err := TagProfile{
+		Disabled:   []string{" totaltracks", "SOURCE", "TotalTracks"},
+		VorbisKeys: map[string]string{"organization": " publisher "},
+	}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"TOTALTRACKS", "SOURCE"}; !reflect.DeepEqual(profile.Disabled, want) {
+		t.Fatalf("Disabled = %q, want %q", profile.Disabled, want)
+	}
+	if want := map[string]string{"ORGANIZATION": "PUBLISHER"}; !reflect.DeepEqual(profile.VorbisKeys, want) {
+		t.Fatalf("VorbisKeys = %q, want %q", profile.VorbisKeys, want)
+	}
+
+	for _, bad := range []TagProfile{
+		{Disabled: []string{"COMMENT"}},
+		{TrackNumberPadding: -1},
+		{TrackNumberPadding: 7},
+		{VorbisKeys: map[string]string{"LABEL": "RECORD=LABEL"}},
+		{VorbisKeys: map[string]string{"LABEL": ""}},
+		{VorbisKeys: map[string]string{"SOURCEID": "DEEZERID"}},
+		{VorbisKeys: map[string]string{"SOURCE": "SOURCEID"}},
+		{VorbisKeys: map[string]string{"INVOLVEDPEOPLE": "CREDITS"}},
+		{ID3Version: 2},
+	} {
+		if _, err := bad.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestWriteMetadataFlacProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.flac")
+	writeTestFlac(t, path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	track, album := testProfileTrack()
+	profile := TagProfile{
+		Disabled:           []string{"TOTALTRACKS", "SOURCE", "BARCODE"},
+		MultiValueArtists:  true,
+		TrackNumberPadding: 1,
+		VorbisKeys:         map[string]string{"ORGANIZATION": "PUBLISHER"},
+	}
+	tagged, err := WriteMetadataFlacWithProfile(data, track, album, "2013-09-13", 0, nil, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flac, err := metaflac.NewMetaflac(tagged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string][]string{
+		"ARTIST":       {"ARTIST=Avicii", "ARTIST=Aloe Blacc"},
+		"TRACKNUMBER":  {"TRACKNUMBER=3"},
+		"TRACKTOTAL":   {"TRACKTOTAL=12"},
+		"TOTALTRACKS":  nil,
+		"SOURCE":       nil,
+		"BARCODE":      nil,
+		"SOURCEID":     {"SOURCEID=3135556"},
+		"ORGANIZATION": nil,
+		"PUBLISHER":    {"PUBLISHER=Universal Music"},
+	} {
+		if got := flac.GetTag(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWriteMetadataMp3Profile(t *testing.T) {
+	track, album := testProfileTrack()
+	audio := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...)
+	profile := TagProfile{
+		Disabled:           []string{"TRACKTOTAL", "SOURCE", "LABEL"},
+		ArtistSeparator:    "; ",
+		TrackNumberPadding: 3,
+		// Vorbis renames leave ID3 frames alone.
+		VorbisKeys: map[string]string{"ORGANIZATION": "PUBLISHER"},
+	}
+	tagged, err := WriteMetadataMp3WithProfile(audio, track, album, "2013-09-13", nil, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := id3v2.ParseReader(bytes.NewReader(tagged), id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]string{
+		"TPE1": "Avicii; Aloe Blacc",
+		"TRCK": "003",
+		"TPOS": "1",
+		"TPUB": "Universal Music",
+	} {
+		if got := tag.GetTextFrame(id).Text; got != want {
+			t.Errorf("%s = %q, want %q", id, got, want)
+		}
+	}
+	var descriptions []string
+	for _, frame := range tag.GetFrames("TXXX") {
+		descriptions = append(descriptions, frame.(id3v2.UserDefinedTextFrame).Description)
+	}
+	slices.Sort(descriptions)
+	if want := []string{"BARCODE", "COMPILATION", "RELEASETYPE", "SOURCEID"}; !slices.Equal(descriptions, want) {
+		t.Fatalf("TXXX frames = %q, want %q", descriptions, want)
+	}
+
+	tagged, err = WriteMetadataMp3WithProfile(audio, track, album, "", nil, TagProfile{MultiValueArtists: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := ReadTagsFrom(bytes.NewReader(tagged))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Avicii", "Aloe Blacc"}; !reflect.DeepEqual(tags.Artists, want) {
+		t.Fatalf("multi-valued artists = %q, want %q", tags.Artists, want)
+	}
+}
+
+func TestWriteMetadataMp3ID3v23(t *testing.T) {
+	track, album := testProfileTrack()
+	lyrics := testLyrics()
+	track.LYRICS = &lyrics
+	track.SNG_TITLE = "Hey Brother ♥"
+	audio := append([]byte{0xFF, 0xFB, 0x90, 0x00}, make([]byte, 413)...)
+	tagged, err := WriteMetadataMp3WithProfile(audio, track, album, "2013-09-13", nil, TagProfile{ID3Version: 3, MultiValueArtists: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tagged[3] != 3 {
+		t.Fatalf("ID3v2 major version = %d, want 3", tagged[3])
+	}
+	tag, err := id3v2.ParseReader(bytes.NewReader(tagged), id3v2.Options{Parse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title := tag.GetTextFrame("TIT2")
+	if title.Text != "Hey Brother ♥" || !title.Encoding.Equals(id3v2.EncodingUTF16) {
+		t.Fatalf("TIT2 = %q in %s", title.Text, title.Encoding.Name)
+	}
+	for id, want := range map[string]string{
+		"TPE1": "Avicii/Aloe Blacc",
+		"TYER": "2013",
+		"TDAT": "1309",
+		"TDRC": "",
+	} {
+		if got := tag.GetTextFrame(id).Text; got != want {
+			t.Errorf("%s = %q, want %q", id, got, want)
+		}
+	}
+
+	frames := tag.GetFrames("SYLT")
+	if len(frames) != 1 {
+		t.Fatalf("got %d SYLT frames", len(frames))
+	}
+	body := frames[0].(id3v2.UnknownFrame).Body
+	if body[0] != 1 {
+		t.Fatalf("SYLT encoding = %d, want UTF-16", body[0])
+	}
+	if got, want := parseSYLT(body), SyncedLines(lyrics); !reflect.DeepEqual(got, want) {
+		t.Fatalf("SYLT lines = %+v, want %+v", got, want)
+	}
+	if audioData, err := StripTags(tagged); err != nil || !bytes.Equal(audioData, audio) {
+		t.Fatalf("StripTags() changed the audio, err = %v", err)
+	}
+}
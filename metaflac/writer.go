@@ -194,6 +194,11 @@ func (m *Metaflac) GetPicturesSpecs() []PictureSpec {
 	return m.picturesSpecs
 }
 
+// GetPicturesDatas returns the image data of all pictures, in the order of GetPicturesSpecs.
+func (m *Metaflac) GetPicturesDatas() [][]byte {
+	return m.picturesDatas
+}
+
 // GetMd5sum returns the MD5 signature from the STREAMINFO block.
 func (m *Metaflac) GetMd5sum() string {
 	if len(m.streamInfo) < 34 {
@@ -334,6 +339,13 @@ func (m *Metaflac) ImportPicture(pictureData []byte, spec PictureSpec) {
 	m.picturesDatas = append(m.picturesDatas, pictureData)
 }
 
+// RemoveAllPictures removes all PICTURE blocks.
+func (m *Metaflac) RemoveAllPictures() {
+	m.pictures = [][]byte{}
+	m.picturesSpecs = []PictureSpec{}
+	m.picturesDatas = [][]byte{}
+}
+
 // GetAllTags returns all tags.
 func (m *Metaflac) GetAllTags() []string {
 	return m.tags
@@ -1,8 +1,12 @@
 package request
 
 import (
+	"errors"
 	"os"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Harder, Better, Faster, Stronger by Daft Punk
@@ -24,5 +28,55 @@ func TestInitDeezerAPI(t *testing.T) {
 	}
 
 	t.Logf("Deezer API session: %s", session)
+}
+
+func TestSessionLicenseIsLoadedOnceAndClearedOnReset(t *testing.T) {
+	session := NewSession()
+	loads := 0
+	load := func() (*License, error) {
+		loads++
+		return &License{LicenseToken: "token"}, nil
+	}
+
+	license, err := session.License(load)
+	require.NoError(t, err)
+	assert.Equal(t, "token", license.LicenseToken)
+	_, err = session.License(load)
+	require.NoError(t, err)
+	assert.Equal(t, 1, loads)
+
+	session.reset()
+	_, err = session.License(load)
+	require.NoError(t, err)
+	assert.Equal(t, 2, loads)
+}
+
+func TestSessionLicenseDoesNotCacheErrors(t *testing.T) {
+	session := NewSession()
+	_, err := session.License(func() (*License, error) { return nil, errors.New("offline") })
+	require.Error(t, err)
+
+	license, err := session.License(func() (*License, error) { return &License{Country: "US"}, nil })
+	require.NoError(t, err)
+	assert.Equal(t, "US", license.Country)
+}
+
+func TestSessionCloseStopsRefresh(t *testing.T) {
+	session := NewSession()
+	session.startRefresh()
+	require.NotNil(t, session.refreshTicker)
+
+	session.Close()
+	assert.Nil(t, session.refreshTicker)
+	session.Close()
+
+	session.startRefresh()
+	assert.NotNil(t, session.refreshTicker)
+	session.Close()
+}
 
+func TestLoginRejectsInvalidARLLength(t *testing.T) {
+	_, err := NewSession().Login("short")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "192 characters")
 }
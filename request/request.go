@@ -10,7 +10,6 @@ import (
 
 	"github.com/d-fi/GoFi/logger"
 	"github.com/d-fi/GoFi/utils"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 )
 
 const (
@@ -18,9 +17,6 @@ const (
 	cacheTTL  = 60 * time.Minute
 )
 
-var cache = expirable.NewLRU[string, []byte](cacheSize, nil, cacheTTL)
-var publicAPIBaseURL = "https://api.deezer.com"
-
 func checkResponse(data []byte) (json.RawMessage, error) {
 	logger.Debug("Checking API response")
 	var apiResponse APIResponse
@@ -46,15 +42,47 @@ func checkResponse(data []byte) (json.RawMessage, error) {
 	return apiResponse.Results, nil
 }
 
+// Request calls a gateway method on the default session.
 func Request(body map[string]any, method string) ([]byte, error) {
+	return defaultSession.Request(body, method)
+}
+
+// RequestFresh calls a gateway method on the default session past the cache.
+func RequestFresh(body map[string]any, method string) ([]byte, error) {
+	return defaultSession.RequestFresh(body, method)
+}
+
+// RequestGet calls a gateway method with query parameters on the default session.
+func RequestGet(method string, params map[string]any, key ...string) ([]byte, error) {
+	return defaultSession.RequestGet(method, params, key...)
+}
+
+// RequestPublicApi fetches slug from the public Deezer API on the default session.
+func RequestPublicApi(slug string) ([]byte, error) {
+	return defaultSession.RequestPublicApi(slug)
+}
+
+// Request posts body to the gateway method and caches the results per session.
+func (s *Session) Request(body map[string]any, method string) ([]byte, error) {
+	return s.request(body, method, true)
+}
+
+// RequestFresh is Request without the cache lookup, for data that goes stale
+// before the cache expires, such as track tokens. The results still replace
+// the cached ones.
+func (s *Session) RequestFresh(body map[string]any, method string) ([]byte, error) {
+	return s.request(body, method, false)
+}
+
+func (s *Session) request(body map[string]any, method string, useCache bool) ([]byte, error) {
 	cacheKey := method + ":" + fmt.Sprintf("%v", body)
-	if cachedData, ok := cache.Get(cacheKey); ok && len(cachedData) > 0 {
+	if cachedData, ok := s.cache.Get(cacheKey); ok && useCache && len(cachedData) > 0 {
 		logger.Debug("Cache hit for request with method: %s", method)
 		return cachedData, nil
 	}
 
 	logger.Debug("Making request with method: %s", method)
-	resp, err := Client.R().
+	resp, err := s.Client.R().
 		SetBody(body).
 		SetQueryParam("method", method).
 		Post("/gateway.php")
@@ -71,12 +99,14 @@ func Request(body map[string]any, method string) ([]byte, error) {
 		return nil, err
 	}
 
-	cache.Add(cacheKey, results)
+	s.cache.Add(cacheKey, results)
 	logger.Debug("Request successful, response cached")
 	return results, nil
 }
 
-func RequestGet(method string, params map[string]any, key ...string) ([]byte, error) {
+// RequestGet calls the gateway method with params as query parameters. key
+// overrides the cache key when the params are too large to use.
+func (s *Session) RequestGet(method string, params map[string]any, key ...string) ([]byte, error) {
 	queryParams := utils.ConvertToQueryParams(params)
 	cacheKeyPart := "get_request"
 	if len(key) > 0 && key[0] != "" {
@@ -85,13 +115,13 @@ func RequestGet(method string, params map[string]any, key ...string) ([]byte, er
 		cacheKeyPart = encodedParams
 	}
 	cacheKey := method + ":" + cacheKeyPart
-	if cachedData, ok := cache.Get(cacheKey); ok && len(cachedData) > 0 {
+	if cachedData, ok := s.cache.Get(cacheKey); ok && len(cachedData) > 0 {
 		logger.Debug("Cache hit for GET request with method: %s", method)
 		return cachedData, nil
 	}
 
 	logger.Debug("Making GET request with method: %s", method)
-	resp, err := Client.R().
+	resp, err := s.Client.R().
 		SetQueryParams(queryParams).
 		SetQueryParam("method", method).
 		Get("/gateway.php")
@@ -108,7 +138,7 @@ func RequestGet(method string, params map[string]any, key ...string) ([]byte, er
 		return nil, err
 	}
 
-	cache.Add(cacheKey, results)
+	s.cache.Add(cacheKey, results)
 	logger.Debug("GET request successful, response cached")
 	return results, nil
 }
@@ -121,14 +151,15 @@ func encodeQueryParams(params map[string]string) string {
 	return values.Encode()
 }
 
-func RequestPublicApi(slug string) ([]byte, error) {
-	if cachedData, ok := cache.Get(slug); ok && len(cachedData) > 0 {
+// RequestPublicApi fetches slug from the public Deezer API.
+func (s *Session) RequestPublicApi(slug string) ([]byte, error) {
+	if cachedData, ok := s.cache.Get(slug); ok && len(cachedData) > 0 {
 		logger.Debug("Cache hit for public API request: %s", slug)
 		return cachedData, nil
 	}
 
 	logger.Debug("Making public API request: %s", slug)
-	resp, err := Client.R().Get(publicAPIBaseURL + slug)
+	resp, err := s.Client.R().Get(s.publicAPIBaseURL + slug)
 	if err != nil {
 		logger.Debug("Failed to make public API request: %v", err)
 		return nil, err
@@ -147,7 +178,7 @@ func RequestPublicApi(slug string) ([]byte, error) {
 		return nil, fmt.Errorf("public API request failed: %s", resp.Status())
 	}
 
-	cache.Add(slug, results)
+	s.cache.Add(slug, results)
 	logger.Debug("Public API request successful, response cached")
 	return results, nil
 }
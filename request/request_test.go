@@ -7,19 +7,11 @@ import (
 	"testing"
 
 	"github.com/go-resty/resty/v2"
-	"github.com/hashicorp/golang-lru/v2/expirable"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestRequestGetCacheKeyIncludesParams(t *testing.T) {
-	previousClient := Client
-	previousCache := cache
-	t.Cleanup(func() {
-		Client = previousClient
-		cache = previousCache
-	})
-
 	requests := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		require.Equal(t, "/gateway.php", r.URL.Path)
@@ -30,14 +22,14 @@ func TestRequestGetCacheKeyIncludesParams(t *testing.T) {
 	}))
 	t.Cleanup(server.Close)
 
-	Client = resty.New().SetBaseURL(server.URL)
-	cache = expirable.NewLRU[string, []byte](cacheSize, nil, cacheTTL)
+	session := NewSession()
+	session.Client = resty.New().SetBaseURL(server.URL)
 
-	first, err := RequestGet("test_method", map[string]any{"page": 1})
+	first, err := session.RequestGet("test_method", map[string]any{"page": 1})
 	require.NoError(t, err)
-	second, err := RequestGet("test_method", map[string]any{"page": 2})
+	second, err := session.RequestGet("test_method", map[string]any{"page": 2})
 	require.NoError(t, err)
-	again, err := RequestGet("test_method", map[string]any{"page": 1})
+	again, err := session.RequestGet("test_method", map[string]any{"page": 1})
 	require.NoError(t, err)
 
 	assert.JSONEq(t, `{"page":"1"}`, string(first))
@@ -47,15 +39,6 @@ func TestRequestGetCacheKeyIncludesParams(t *testing.T) {
 }
 
 func TestRequestPublicApiDoesNotCacheHTTPError(t *testing.T) {
-	previousClient := Client
-	previousCache := cache
-	previousPublicAPIBaseURL := publicAPIBaseURL
-	t.Cleanup(func() {
-		Client = previousClient
-		cache = previousCache
-		publicAPIBaseURL = previousPublicAPIBaseURL
-	})
-
 	requests := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		require.Equal(t, "/album/1", r.URL.Path)
@@ -69,15 +52,15 @@ func TestRequestPublicApiDoesNotCacheHTTPError(t *testing.T) {
 	}))
 	t.Cleanup(server.Close)
 
-	Client = resty.New()
-	cache = expirable.NewLRU[string, []byte](cacheSize, nil, cacheTTL)
-	publicAPIBaseURL = server.URL
+	session := NewSession()
+	session.Client = resty.New()
+	session.publicAPIBaseURL = server.URL
 
-	_, err := RequestPublicApi("/album/1")
+	_, err := session.RequestPublicApi("/album/1")
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "403 Forbidden")
 
-	body, err := RequestPublicApi("/album/1")
+	body, err := session.RequestPublicApi("/album/1")
 	require.NoError(t, err)
 	assert.JSONEq(t, `{"id":1}`, string(body))
 	assert.Equal(t, 2, requests)
@@ -88,3 +71,56 @@ func TestEncodeQueryParamsIsStable(t *testing.T) {
 
 	assert.Equal(t, "a=1&b=2", encodeQueryParams(params))
 }
+
+func TestSessionsKeepSeparateCaches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := fmt.Fprintf(w, `{"error":[],"results":{"sid":%q}}`, r.URL.Query().Get("sid"))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	first := NewSession()
+	first.Client = resty.New().SetBaseURL(server.URL).SetQueryParam("sid", "first")
+	second := NewSession()
+	second.Client = resty.New().SetBaseURL(server.URL).SetQueryParam("sid", "second")
+
+	firstBody, err := first.Request(nil, "user_getInfo")
+	require.NoError(t, err)
+	secondBody, err := second.Request(nil, "user_getInfo")
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"sid":"first"}`, string(firstBody))
+	assert.JSONEq(t, `{"sid":"second"}`, string(secondBody))
+}
+
+func TestRequestFreshBypassesCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, err := fmt.Fprintf(w, `{"error":[],"results":{"TRACK_TOKEN":"token-%d"}}`, requests)
+		require.NoError(t, err)
+	}))
+	t.Cleanup(server.Close)
+
+	session := NewSession()
+	session.Client = resty.New().SetBaseURL(server.URL)
+	body := map[string]any{"sng_id": "3135556"}
+
+	first, err := session.Request(body, "song.getData")
+	require.NoError(t, err)
+	cached, err := session.Request(body, "song.getData")
+	require.NoError(t, err)
+	assert.Equal(t, string(first), string(cached))
+	assert.Equal(t, 1, requests)
+
+	fresh, err := session.RequestFresh(body, "song.getData")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"TRACK_TOKEN":"token-2"}`, string(fresh))
+	assert.Equal(t, 2, requests)
+
+	// The fresh results replace the cached ones.
+	again, err := session.Request(body, "song.getData")
+	require.NoError(t, err)
+	assert.Equal(t, string(fresh), string(again))
+	assert.Equal(t, 2, requests)
+}
@@ -0,0 +1,237 @@
+package request
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http/cookiejar"
+	"sync"
+	"time"
+
+	"github.com/d-fi/GoFi/logger"
+	"github.com/go-resty/resty/v2"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+const (
+	sessionRefreshInterval = time.Hour
+	defaultPublicAPIBase   = "https://api.deezer.com"
+)
+
+// License holds the streaming rights of the account behind a session.
+type License struct {
+	LicenseToken      string
+	CanStreamLossless bool
+	CanStreamHQ       bool
+	Country           string
+}
+
+// Session is one logged in Deezer account. It owns its HTTP client, cookies,
+// response cache, license data and session refresh loop, so several accounts
+// can be used side by side in one process.
+type Session struct {
+	Client *resty.Client
+
+	mu               sync.RWMutex
+	arl              string
+	sessionID        string
+	cache            *expirable.LRU[string, []byte]
+	publicAPIBaseURL string
+	refreshTicker    *time.Ticker
+	stopRefresh      chan struct{}
+
+	licenseMu sync.Mutex
+	license   *License
+}
+
+// NewSession returns a session that is not logged in yet. Call Login before
+// using endpoints that need an account.
+func NewSession() *Session {
+	return &Session{
+		Client:           newRestyClient(),
+		cache:            expirable.NewLRU[string, []byte](cacheSize, nil, cacheTTL),
+		publicAPIBaseURL: defaultPublicAPIBase,
+	}
+}
+
+func newRestyClient() *resty.Client {
+	return resty.New().
+		SetBaseURL("https://api.deezer.com/1.0").
+		SetHeader("Accept", "*/*").
+		SetHeader("Accept-Encoding", "gzip, deflate").
+		SetHeader("Accept-Language", "en-US").
+		SetHeader("Cache-Control", "no-cache").
+		SetHeader("Content-Type", "application/json; charset=UTF-8").
+		SetHeader("User-Agent", "Deezer/8.32.0.2 (iOS; 14.4; Mobile; en; iPhone10_5)").
+		SetQueryParam("version", "8.32.0").
+		SetQueryParam("api_key", "ZAIVAHCEISOHWAICUQUEXAEPICENGUAFAEZAIPHAELEEVAHPHUCUFONGUAPASUAY").
+		SetQueryParam("output", "3").
+		SetQueryParam("input", "3").
+		SetQueryParam("buildId", "ios12_universal").
+		SetQueryParam("screenHeight", "480").
+		SetQueryParam("screenWidth", "320").
+		SetQueryParam("lang", "en").
+		SetTLSClientConfig(&tls.Config{InsecureSkipVerify: false}).
+		SetRetryCount(3).
+		SetRetryWaitTime(2 * time.Second).
+		SetRetryMaxWaitTime(5 * time.Second)
+}
+
+// Login authenticates the session with an ARL cookie and starts the hourly
+// session refresh. Logging in again with another ARL switches accounts and
+// drops the cookies, cached responses and license of the previous one.
+func (s *Session) Login(arl string) (string, error) {
+	logger.Debug("Initializing Deezer API with ARL length: %d", len(arl))
+
+	if len(arl) != 192 {
+		logger.Debug("Invalid ARL length: %d", len(arl))
+		return "", fmt.Errorf("invalid arl, length should be 192 characters; you have provided %d characters", len(arl))
+	}
+
+	s.mu.Lock()
+	switching := s.arl != "" && s.arl != arl
+	s.arl = arl
+	s.mu.Unlock()
+	if switching {
+		logger.Debug("Switching Deezer account, clearing previous session state")
+		s.reset()
+	}
+
+	sessionID, err := s.ping(arl)
+	if err != nil {
+		logger.Debug("Failed to initialize Deezer API: %v", err)
+		return "", fmt.Errorf("failed to initialize Deezer API: %v", err)
+	}
+	logger.Debug("Deezer API initialized successfully, session ID: %s", sessionID)
+
+	s.startRefresh()
+	return sessionID, nil
+}
+
+// reset clears everything that belongs to the previously logged in account.
+func (s *Session) reset() {
+	if jar, err := cookiejar.New(nil); err == nil {
+		s.Client.SetCookieJar(jar)
+	}
+	s.Client.QueryParam.Del("sid")
+	s.cache.Purge()
+
+	s.licenseMu.Lock()
+	s.license = nil
+	s.licenseMu.Unlock()
+}
+
+func (s *Session) ping(arl string) (string, error) {
+	resp, err := s.Client.R().
+		SetHeader("Cookie", "arl="+arl).
+		SetQueryParam("method", "deezer.ping").
+		SetQueryParam("api_version", "1.0").
+		SetQueryParam("api_token", "").
+		Get("https://www.deezer.com/ajax/gw-light.php")
+
+	if err != nil {
+		return "", fmt.Errorf("failed to reach Deezer: %v", err)
+	}
+
+	if resp.IsError() {
+		return "", fmt.Errorf("received error response from Deezer: %v", resp.Status())
+	}
+
+	var data UserData
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return "", fmt.Errorf("failed to parse Deezer API response: %v", err)
+	}
+
+	if data.Results.Session == "" {
+		return "", fmt.Errorf("failed to retrieve session from API response")
+	}
+
+	s.mu.Lock()
+	s.sessionID = data.Results.Session
+	s.mu.Unlock()
+	s.Client.SetQueryParam("sid", data.Results.Session)
+	return data.Results.Session, nil
+}
+
+func (s *Session) startRefresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshTicker != nil {
+		return
+	}
+
+	ticker := time.NewTicker(sessionRefreshInterval)
+	stop := make(chan struct{})
+	s.refreshTicker = ticker
+	s.stopRefresh = stop
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				logger.Debug("Refreshing session ID...")
+				if _, err := s.refresh(); err != nil {
+					logger.Error("Failed to refresh session: %v", err)
+				} else {
+					logger.Debug("Session refreshed successfully.")
+				}
+			}
+		}
+	}()
+}
+
+// refresh renews the Deezer session using the stored ARL.
+func (s *Session) refresh() (string, error) {
+	logger.Debug("Refreshing Deezer session with ARL")
+	sessionID, err := s.ping(s.ARL())
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh Deezer session: %v", err)
+	}
+	logger.Debug("Session refreshed successfully, new session ID: %s", sessionID)
+	return sessionID, nil
+}
+
+// Close stops the session refresh loop. The session can still be used for
+// requests, and a later Login starts the loop again.
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshTicker == nil {
+		return
+	}
+	s.refreshTicker.Stop()
+	close(s.stopRefresh)
+	s.refreshTicker = nil
+	s.stopRefresh = nil
+}
+
+// ARL returns the ARL cookie the session was logged in with.
+func (s *Session) ARL() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.arl
+}
+
+// SessionID returns the current Deezer session ID, or an empty string before Login.
+func (s *Session) SessionID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sessionID
+}
+
+// License returns the cached license of the session, calling load to fetch it
+// the first time. Concurrent callers wait for a single load.
+func (s *Session) License(load func() (*License, error)) (*License, error) {
+	s.licenseMu.Lock()
+	defer s.licenseMu.Unlock()
+	if s.license != nil {
+		return s.license, nil
+	}
+	license, err := load()
+	if err != nil {
+		return nil, err
+	}
+	s.license = license
+	return license, nil
+}
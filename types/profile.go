@@ -31,3 +31,12 @@ type UserProfileType struct {
 	DISPLAY_NAME  string `json:"DISPLAY_NAME"`
 	TYPE_INTERNAL string `json:"__TYPE__"`
 }
+
+// ProfileTabType is one tab of a user's profile page, such as their loved
+// tracks or playlists, with the profile's owner.
+type ProfileTabType[T any] struct {
+	USER  UserProfileType `json:"USER"`
+	Data  []T             `json:"data"`
+	Count int             `json:"count"`
+	Total int             `json:"total"`
+}
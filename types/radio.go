@@ -7,3 +7,19 @@ type RadioType struct {
 	TAGS          []string `json:"TAGS"`
 	TYPE_INTERNAL string   `json:"__TYPE__"`
 }
+
+// RadioTracksType holds the tracks of a gateway radio such as Flow.
+type RadioTracksType struct {
+	Data []TrackType `json:"data"`
+}
+
+// RadioTypePublicApi represents a mix from the public API.
+type RadioTypePublicApi struct {
+	ID          int    `json:"id"`          // Radio ID, e.g., 37151
+	Title       string `json:"title"`       // Radio title, e.g., 'Hits'
+	Description string `json:"description"` // Radio description
+	Picture     string `json:"picture"`     // Picture URL
+	MD5Image    string `json:"md5_image"`   // MD5 hash of the image
+	Tracklist   string `json:"tracklist"`   // Tracklist link, e.g., 'https://api.deezer.com/radio/37151/tracks'
+	Type        string `json:"type"`        // Type, e.g., 'radio'
+}
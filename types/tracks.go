@@ -1,6 +1,7 @@
 package types
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -109,8 +110,40 @@ type SongType struct {
 // TrackType represents detailed information about a track including song and fallback data.
 type TrackType struct {
 	SongType
-	FALLBACK       *SongType `json:"FALLBACK,omitempty"`       // Fallback song type
-	TRACK_POSITION *int      `json:"TRACK_POSITION,omitempty"` // Track position
+	FALLBACK       *SongType        `json:"FALLBACK,omitempty"`       // Fallback song type
+	TRACK_POSITION *int             `json:"TRACK_POSITION,omitempty"` // Track position
+	EPISODE        *ShowEpisodeType `json:"EPISODE,omitempty"`        // Podcast episode this track stands for
+}
+
+// UnmarshalJSON for TrackType decodes the embedded SongType and the fields
+// TrackType adds. Without it the promoted SongType method would drop them.
+func (track *TrackType) UnmarshalJSON(data []byte) error {
+	if err := track.SongType.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	var aux struct {
+		FALLBACK       json.RawMessage  `json:"FALLBACK"`
+		TRACK_POSITION *StringOrInt     `json:"TRACK_POSITION"`
+		EPISODE        *ShowEpisodeType `json:"EPISODE"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	track.FALLBACK = nil
+	// Anything but an object, such as an empty list, means no fallback.
+	if fallback := bytes.TrimSpace(aux.FALLBACK); len(fallback) > 0 && fallback[0] == '{' {
+		track.FALLBACK = new(SongType)
+		if err := track.FALLBACK.UnmarshalJSON(fallback); err != nil {
+			return err
+		}
+	}
+	track.TRACK_POSITION = nil
+	if aux.TRACK_POSITION != nil {
+		position := int(*aux.TRACK_POSITION)
+		track.TRACK_POSITION = &position
+	}
+	track.EPISODE = aux.EPISODE
+	return nil
 }
 
 // UnmarshalJSON for SongType accepts Deezer ID fields as either strings or numbers.
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTrackTypeUnmarshalsFallbackAndPosition(t *testing.T) {
+	var track TrackType
+	data := `{"SNG_ID":3135556,"SNG_TITLE":"Harder, Better, Faster, Stronger","TRACK_POSITION":"4",` +
+		`"FALLBACK":{"SNG_ID":"3135553","ART_ID":27,"SNG_TITLE":"Harder, Better, Faster, Stronger"}}`
+	if err := json.Unmarshal([]byte(data), &track); err != nil {
+		t.Fatal(err)
+	}
+	if track.SNG_ID != "3135556" || track.SNG_TITLE != "Harder, Better, Faster, Stronger" {
+		t.Fatalf("song fields = %q, %q", track.SNG_ID, track.SNG_TITLE)
+	}
+	if track.FALLBACK == nil || track.FALLBACK.SNG_ID != "3135553" || track.FALLBACK.ART_ID != "27" {
+		t.Fatalf("FALLBACK = %+v", track.FALLBACK)
+	}
+	if track.TRACK_POSITION == nil || *track.TRACK_POSITION != 4 {
+		t.Fatalf("TRACK_POSITION = %v, want 4", track.TRACK_POSITION)
+	}
+}
+
+func TestTrackTypeWithoutFallback(t *testing.T) {
+	for _, data := range []string{
+		`{"SNG_ID":"1"}`,
+		`{"SNG_ID":"1","FALLBACK":[]}`,
+		`{"SNG_ID":"1","FALLBACK":null,"TRACK_POSITION":null}`,
+	} {
+		track := TrackType{FALLBACK: &SongType{SNG_ID: "stale"}}
+		if err := json.Unmarshal([]byte(data), &track); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		if track.SNG_ID != "1" || track.FALLBACK != nil || track.TRACK_POSITION != nil {
+			t.Fatalf("%s: track = %+v", data, track)
+		}
+	}
+}
+
+func TestTrackTypeRoundTrip(t *testing.T) {
+	var track TrackType
+	track.SNG_ID = "1"
+	position := 7
+	track.TRACK_POSITION = &position
+	track.FALLBACK = &SongType{SNG_ID: "2"}
+
+	data, err := json.Marshal(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded TrackType
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.SNG_ID != "1" || decoded.FALLBACK == nil || decoded.FALLBACK.SNG_ID != "2" || decoded.TRACK_POSITION == nil || *decoded.TRACK_POSITION != 7 {
+		t.Fatalf("decoded = %+v", decoded)
+	}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PartialFileSuffix ends the name of the temp file CreatePartial makes for a
+// write that is renamed into place once complete. A file with this suffix
+// that is still on disk was left behind by an interrupted write.
+const PartialFileSuffix = ".d-fi-partial"
+
+// partialNameMax keeps temp names within the usual 255 byte file name limit
+// when the final name is already long.
+const partialNameMax = 200
+
+// WriteFileAtomic writes data to a temp file in the directory of path, syncs
+// it and renames it over path. Readers see either the old file or the complete
+// new one, never a truncated write.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := CreatePartial(path)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	return CommitPartial(tmp, path, perm)
+}
+
+// CreatePartial creates the temp file a streamed write to path goes through.
+// It lives in the directory of path so CommitPartial can rename it into place.
+func CreatePartial(path string) (*os.File, error) {
+	base := filepath.Base(path)
+	if len(base) > partialNameMax {
+		base = strings.ToValidUTF8(base[:partialNameMax], "")
+	}
+	return os.CreateTemp(filepath.Dir(path), "."+base+".*"+PartialFileSuffix)
+}
+
+// CommitPartial syncs and closes a file from CreatePartial and renames it over
+// path. The temp file is removed when any step fails.
+func CommitPartial(tmp *os.File, path string, perm os.FileMode) error {
+	tmpPath := tmp.Name()
+	cleanup := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return cleanup(err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// IsPartialFile reports whether name is a WriteFileAtomic temp file name.
+func IsPartialFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, PartialFileSuffix)
+}
+
+// syncDir flushes the directory entry of a rename. Not every platform can
+// open a directory for syncing, so failures are ignored.
+func syncDir(dir string) {
+	file, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = file.Sync()
+	_ = file.Close()
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "01 - Song.flac")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0600))
+
+	require.NoError(t, WriteFileAtomic(path, []byte("new audio"), 0644))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new audio", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temp file should be renamed away")
+}
+
+func TestWriteFileAtomicLongName(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, strings.Repeat("a", 240)+".mp3")
+
+	require.NoError(t, WriteFileAtomic(path, []byte("audio"), 0644))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(data))
+}
+
+func TestWriteFileAtomicMissingDirLeavesNothing(t *testing.T) {
+	dir := t.TempDir()
+	err := WriteFileAtomic(filepath.Join(dir, "missing", "song.mp3"), []byte("audio"), 0644)
+	require.Error(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCommitPartial(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "01 - Song.mp3")
+
+	tmp, err := CreatePartial(path)
+	require.NoError(t, err)
+	assert.True(t, IsPartialFile(filepath.Base(tmp.Name())))
+	_, err = tmp.WriteString("streamed audio")
+	require.NoError(t, err)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "path should not exist before the commit")
+
+	require.NoError(t, CommitPartial(tmp, path, 0644))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "streamed audio", string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temp file should be renamed away")
+}
+
+func TestIsPartialFile(t *testing.T) {
+	assert.True(t, IsPartialFile(".01 - Song.flac.123456"+PartialFileSuffix))
+	assert.False(t, IsPartialFile("01 - Song.flac"))
+	assert.False(t, IsPartialFile(".hidden"))
+}
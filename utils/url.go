@@ -10,6 +10,19 @@ import (
 	"github.com/d-fi/GoFi/logger"
 )
 
+// StatusCodeError reports an HTTP response outside the 2xx range.
+type StatusCodeError struct {
+	StatusCode int
+	Status     string // Optional full status line, such as "404 Not Found".
+}
+
+func (e *StatusCodeError) Error() string {
+	if e.Status != "" {
+		return e.Status
+	}
+	return fmt.Sprintf("received non-success status code: %d", e.StatusCode)
+}
+
 // CheckURLFileSize performs a HEAD request to check the availability of a URL
 // and returns the content length if available.
 // The timeout parameter is optional; if nil, it defaults to 10 seconds.
@@ -41,7 +54,7 @@ func CheckURLFileSize(ctx context.Context, url string, timeout *time.Duration) (
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		logger.Debug("Non-success status code: %d", resp.StatusCode)
-		return 0, fmt.Errorf("received non-success status code: %d", resp.StatusCode)
+		return 0, &StatusCodeError{StatusCode: resp.StatusCode}
 	}
 
 	contentLength := resp.ContentLength
@@ -0,0 +1,318 @@
+package verify
+
+import (
+	"encoding/binary"
+)
+
+const (
+	flacStreamInfoType   = 0
+	flacStreamInfoLength = 34
+	flacMinFrameHeader   = 6
+	flacMaxFrameHeader   = 16
+	// flacFrameWindow is how much data is first searched for the end of a
+	// frame. It doubles up to flacMaxFrame, which no valid frame exceeds:
+	// 65535 verbatim samples on 8 channels of 32 bits are 2 MiB.
+	flacFrameWindow = 64 << 10
+	flacMaxFrame    = 4 << 20
+)
+
+// flacStreamInfo holds the STREAMINFO fields that frame verification relies on.
+type flacStreamInfo struct {
+	minBlockSize int
+	maxBlockSize int
+	sampleRate   int
+	totalSamples uint64
+}
+
+// flacFrameHeader is a parsed FLAC frame header.
+type flacFrameHeader struct {
+	length    int
+	blockSize int
+}
+
+// FLAC parses STREAMINFO and walks every frame, checking each header CRC-8,
+// each frame CRC-16 and that the frames add up to the STREAMINFO sample count.
+func FLAC(data []byte) error {
+	return flac(bytesSource(data))
+}
+
+func flac(s *source) error {
+	info, offset, err := parseFlacMetadata(s)
+	if err != nil {
+		return err
+	}
+	if offset >= s.size {
+		return corrupt("flac: no audio frames")
+	}
+
+	var samples uint64
+	frames := 0
+	position := offset
+	for position < s.size {
+		length, blockSize, err := flacFrame(s, position, info)
+		if err != nil {
+			return err
+		}
+		if length == 0 {
+			return corrupt("flac: frame %d at byte %d fails CRC-16 or is truncated", frames, position)
+		}
+		samples += uint64(blockSize)
+		frames++
+		position += int64(length)
+	}
+
+	if info.totalSamples > 0 && samples != info.totalSamples {
+		return corrupt("flac: frames hold %d samples, STREAMINFO declares %d", samples, info.totalSamples)
+	}
+	return nil
+}
+
+// flacFrame returns the length and block size of the frame at position, or a
+// zero length when no valid end is found. The window searched for the end
+// grows until it holds the frame.
+func flacFrame(s *source, position int64, info flacStreamInfo) (int, int, error) {
+	for window := flacFrameWindow; ; window *= 2 {
+		data, err := s.at(position, window)
+		if err != nil {
+			return 0, 0, err
+		}
+		header, ok := parseFlacFrameHeader(data, 0, info)
+		if !ok {
+			return 0, 0, corrupt("flac: invalid frame header at byte %d", position)
+		}
+		final := position+int64(len(data)) == s.size
+		if end, ok := findFlacFrameEnd(data, 0, header, info, final); ok {
+			return end, header.blockSize, nil
+		}
+		if final || window >= flacMaxFrame {
+			return 0, 0, nil
+		}
+	}
+}
+
+// parseFlacMetadata validates the metadata block chain and returns the
+// STREAMINFO fields and the offset of the first audio frame.
+func parseFlacMetadata(s *source) (flacStreamInfo, int64, error) {
+	var info flacStreamInfo
+	marker, err := s.at(0, 4)
+	if err != nil {
+		return info, 0, err
+	}
+	if string(marker) != "fLaC" {
+		return info, 0, corrupt("flac: missing fLaC marker")
+	}
+
+	var position int64 = 4
+	first := true
+	for {
+		header, err := s.at(position, 4)
+		if err != nil {
+			return info, 0, err
+		}
+		if len(header) < 4 {
+			return info, 0, corrupt("flac: metadata block header truncated")
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		position += 4
+		if position+length > s.size {
+			return info, 0, corrupt("flac: metadata block %d truncated", blockType)
+		}
+		if first {
+			if blockType != flacStreamInfoType || length != flacStreamInfoLength {
+				return info, 0, corrupt("flac: first metadata block is not STREAMINFO")
+			}
+			block, err := s.at(position, flacStreamInfoLength)
+			if err != nil {
+				return info, 0, err
+			}
+			info = parseStreamInfoBlock(block)
+			if info.sampleRate == 0 || info.maxBlockSize < 16 || info.minBlockSize > info.maxBlockSize {
+				return info, 0, corrupt("flac: invalid STREAMINFO")
+			}
+			first = false
+		} else if blockType == 127 {
+			return info, 0, corrupt("flac: invalid metadata block type")
+		}
+		position += length
+		if last {
+			return info, position, nil
+		}
+	}
+}
+
+func parseStreamInfoBlock(block []byte) flacStreamInfo {
+	packed := binary.BigEndian.Uint64(block[10:18])
+	return flacStreamInfo{
+		minBlockSize: int(binary.BigEndian.Uint16(block[0:2])),
+		maxBlockSize: int(binary.BigEndian.Uint16(block[2:4])),
+		sampleRate:   int(packed >> 44),
+		totalSamples: packed & 0x0F_FFFF_FFFF,
+	}
+}
+
+func isFlacFrameSync(data []byte, position int) bool {
+	return position+1 < len(data) && data[position] == 0xFF && data[position+1]&0xFE == 0xF8
+}
+
+// parseFlacFrameHeader decodes the frame header at position and checks its CRC-8.
+func parseFlacFrameHeader(data []byte, position int, info flacStreamInfo) (flacFrameHeader, bool) {
+	var header flacFrameHeader
+	if position+flacMinFrameHeader > len(data) || !isFlacFrameSync(data, position) {
+		return header, false
+	}
+
+	blockSizeCode := data[position+2] >> 4
+	sampleRateCode := data[position+2] & 0x0F
+	channelAssignment := data[position+3] >> 4
+	sampleSizeCode := data[position+3] >> 1 & 0x07
+	if blockSizeCode == 0 || sampleRateCode == 15 || channelAssignment > 10 || sampleSizeCode == 3 || data[position+3]&0x01 != 0 {
+		return header, false
+	}
+
+	cursor := position + 4
+	numberLength := utf8CodedLength(data[cursor])
+	if numberLength == 0 || cursor+numberLength > len(data) {
+		return header, false
+	}
+	for i := 1; i < numberLength; i++ {
+		if data[cursor+i]&0xC0 != 0x80 {
+			return header, false
+		}
+	}
+	cursor += numberLength
+
+	switch {
+	case blockSizeCode == 1:
+		header.blockSize = 192
+	case blockSizeCode <= 5:
+		header.blockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6:
+		if cursor+1 > len(data) {
+			return header, false
+		}
+		header.blockSize = int(data[cursor]) + 1
+		cursor++
+	case blockSizeCode == 7:
+		if cursor+2 > len(data) {
+			return header, false
+		}
+		header.blockSize = int(binary.BigEndian.Uint16(data[cursor:])) + 1
+		cursor += 2
+	default:
+		header.blockSize = 256 << (blockSizeCode - 8)
+	}
+	if header.blockSize > info.maxBlockSize {
+		return header, false
+	}
+
+	switch sampleRateCode {
+	case 12:
+		cursor++
+	case 13, 14:
+		cursor += 2
+	}
+	if cursor+1 > len(data) {
+		return header, false
+	}
+	if crc8(data[position:cursor]) != data[cursor] {
+		return header, false
+	}
+	header.length = cursor + 1 - position
+	return header, true
+}
+
+// utf8CodedLength returns the byte length of the UTF-8 style frame or sample
+// number that starts with first, or 0 when first is not a valid lead byte.
+func utf8CodedLength(first byte) int {
+	switch {
+	case first&0x80 == 0:
+		return 1
+	case first&0xE0 == 0xC0:
+		return 2
+	case first&0xF0 == 0xE0:
+		return 3
+	case first&0xF8 == 0xF0:
+		return 4
+	case first&0xFC == 0xF8:
+		return 5
+	case first&0xFE == 0xFC:
+		return 6
+	case first == 0xFE:
+		return 7
+	default:
+		return 0
+	}
+}
+
+// findFlacFrameEnd returns the offset just past the frame that starts at
+// position. Subframes are not decoded, so the end is the first following frame
+// header whose preceding two bytes hold the CRC-16 of everything since position,
+// or the end of the data for the last frame. Unless data is final, it stops
+// short of the last header's worth of bytes so that every candidate header is
+// whole.
+func findFlacFrameEnd(data []byte, position int, header flacFrameHeader, info flacStreamInfo, final bool) (int, bool) {
+	var crc uint16
+	covered := position
+	limit := len(data)
+	if !final {
+		limit -= flacMaxFrameHeader
+	}
+	for end := position + header.length + 2; end <= limit; end++ {
+		for ; covered < end-2; covered++ {
+			crc = crc16Table[byte(crc>>8)^data[covered]] ^ crc<<8
+		}
+		if end != len(data) && !isFlacFrameSync(data, end) {
+			continue
+		}
+		if binary.BigEndian.Uint16(data[end-2:end]) != crc {
+			continue
+		}
+		if end == len(data) {
+			return end, true
+		}
+		if _, ok := parseFlacFrameHeader(data, end, info); ok {
+			return end, true
+		}
+	}
+	return 0, false
+}
+
+var crc8Table = func() (table [256]byte) {
+	for i := range table {
+		crc := byte(i)
+		for range 8 {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc = crc8Table[crc^b]
+	}
+	return crc
+}
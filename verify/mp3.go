@@ -0,0 +1,187 @@
+package verify
+
+import (
+	"bytes"
+)
+
+const mp3FrameHeaderLength = 4
+
+var (
+	mp3BitratesV1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3BitratesV2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+
+	mp3SampleRates = map[byte][3]int{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+// mp3FrameHeader is a parsed MPEG audio Layer III frame header.
+type mp3FrameHeader struct {
+	version    byte
+	bitrate    int
+	sampleRate int
+	channels   int
+	length     int
+}
+
+// MP3Stream describes the audio of an MP3 as its first frame header has it.
+type MP3Stream struct {
+	// Bitrate is in kbps.
+	Bitrate    int
+	SampleRate int
+	Channels   int
+}
+
+// MP3 walks the MPEG frame sync headers from the first audio frame to the end
+// of the file. Leading ID3v2 tags and a trailing ID3v1 or APE tag are skipped.
+func MP3(data []byte) error {
+	return mp3(bytesSource(data))
+}
+
+func mp3(s *source) error {
+	position, err := skipID3v2(s)
+	if err != nil {
+		return err
+	}
+	trailing, err := trailingTagLength(s)
+	if err != nil {
+		return err
+	}
+	end := s.size - trailing
+	if position >= end {
+		return corrupt("mp3: no audio frames")
+	}
+
+	var first mp3FrameHeader
+	frames := 0
+	for position < end {
+		head, err := s.at(position, int(min(mp3FrameHeaderLength, end-position)))
+		if err != nil {
+			return err
+		}
+		header, ok := parseMP3FrameHeader(head)
+		if !ok {
+			return corrupt("mp3: lost frame sync at byte %d after %d frames", position, frames)
+		}
+		if frames == 0 {
+			first = header
+		} else if header.version != first.version || header.sampleRate != first.sampleRate {
+			return corrupt("mp3: frame %d at byte %d changes stream format", frames, position)
+		}
+		if position+int64(header.length) > end {
+			return corrupt("mp3: frame %d at byte %d is truncated", frames, position)
+		}
+		position += int64(header.length)
+		frames++
+	}
+	return nil
+}
+
+// MP3Bitrate returns the bitrate in kbps of the first MPEG frame after any
+// leading ID3v2 tags. data only needs to reach the first frame header.
+func MP3Bitrate(data []byte) (int, error) {
+	stream, err := MP3Info(data)
+	return stream.Bitrate, err
+}
+
+// MP3Info reads the first MPEG frame header after any leading ID3v2 tags.
+// data only needs to reach that header.
+func MP3Info(data []byte) (MP3Stream, error) {
+	s := bytesSource(data)
+	position, _ := skipID3v2(s)
+	head, _ := s.at(position, mp3FrameHeaderLength)
+	header, ok := parseMP3FrameHeader(head)
+	if !ok {
+		return MP3Stream{}, corrupt("mp3: no frame header at byte %d", position)
+	}
+	return MP3Stream{Bitrate: header.bitrate, SampleRate: header.sampleRate, Channels: header.channels}, nil
+}
+
+func parseMP3FrameHeader(data []byte) (mp3FrameHeader, bool) {
+	var header mp3FrameHeader
+	if len(data) < mp3FrameHeaderLength || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+		return header, false
+	}
+	version := data[1] >> 3 & 0x03
+	layer := data[1] >> 1 & 0x03
+	bitrateIndex := data[2] >> 4
+	sampleRateIndex := data[2] >> 2 & 0x03
+	padding := int(data[2] >> 1 & 0x01)
+
+	// Deezer only serves Layer III, so Layer I and II are treated as invalid.
+	rates, ok := mp3SampleRates[version]
+	if !ok || layer != 1 || sampleRateIndex == 3 {
+		return header, false
+	}
+	bitrate := mp3BitratesV2[bitrateIndex]
+	samplesFactor := 72000
+	if version == 3 {
+		bitrate = mp3BitratesV1[bitrateIndex]
+		samplesFactor = 144000
+	}
+	if bitrate == 0 {
+		return header, false
+	}
+
+	header.version = version
+	header.bitrate = bitrate
+	header.sampleRate = rates[sampleRateIndex]
+	header.channels = 2
+	if data[3]>>6 == 3 {
+		header.channels = 1
+	}
+	header.length = samplesFactor*bitrate/header.sampleRate + padding
+	return header, true
+}
+
+// skipID3v2 returns the offset just past any leading ID3v2 tags.
+func skipID3v2(s *source) (int64, error) {
+	var position int64
+	for {
+		data, err := s.at(position, 10)
+		if err != nil {
+			return 0, err
+		}
+		if len(data) < 10 || !bytes.Equal(data[:3], []byte("ID3")) {
+			break
+		}
+		size := int64(data[6]&0x7F)<<21 | int64(data[7]&0x7F)<<14 | int64(data[8]&0x7F)<<7 | int64(data[9]&0x7F)
+		hasFooter := data[5]&0x10 != 0
+		position += 10 + size
+		if hasFooter {
+			position += 10
+		}
+	}
+	return min(position, s.size), nil
+}
+
+// trailingTagLength returns the size of an ID3v1 tag and an APEv2 tag at the end of the data.
+func trailingTagLength(s *source) (int64, error) {
+	var length int64
+	if s.size >= 128 {
+		data, err := s.at(s.size-128, 3)
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(data, []byte("TAG")) {
+			length = 128
+		}
+	}
+	if footer := s.size - length - 32; footer >= 0 {
+		data, err := s.at(footer, 32)
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(data[:8], []byte("APETAGEX")) {
+			size := int64(data[12]) | int64(data[13])<<8 | int64(data[14])<<16 | int64(data[15])<<24
+			hasHeader := data[23]&0x80 != 0
+			if hasHeader {
+				size += 32
+			}
+			length += size
+		}
+	}
+	return min(length, s.size), nil
+}
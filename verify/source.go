@@ -0,0 +1,51 @@
+package verify
+
+import "io"
+
+// sourceWindow is how much of a file a source reads at a time.
+const sourceWindow = 256 << 10
+
+// source gives the verifiers access to audio that is either held in memory or
+// read from a file through a window, so a file is never loaded whole.
+type source struct {
+	r    io.ReaderAt
+	size int64
+	// buf holds the data from off onwards.
+	off int64
+	buf []byte
+}
+
+func bytesSource(data []byte) *source {
+	return &source{size: int64(len(data)), buf: data}
+}
+
+func readerSource(r io.ReaderAt, size int64) *source {
+	return &source{r: r, size: size}
+}
+
+// at returns the n bytes from offset, or fewer where the data ends. The slice
+// is only valid until the next call.
+func (s *source) at(offset int64, n int) ([]byte, error) {
+	if offset < 0 || offset >= s.size {
+		return nil, nil
+	}
+	end := min(offset+int64(n), s.size)
+	if offset >= s.off && end <= s.off+int64(len(s.buf)) {
+		return s.buf[offset-s.off : end-s.off], nil
+	}
+
+	size := min(max(int64(n), sourceWindow), s.size-offset)
+	if int64(cap(s.buf)) < size {
+		s.buf = make([]byte, size)
+	}
+	s.buf = s.buf[:size]
+	if read, err := s.r.ReadAt(s.buf, offset); int64(read) < size {
+		s.buf = s.buf[:0]
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	s.off = offset
+	return s.buf[:end-offset], nil
+}
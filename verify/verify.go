@@ -0,0 +1,48 @@
+// Package verify checks that downloaded audio is structurally intact before it
+// is tagged and saved.
+package verify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrCorrupt is wrapped by every error that reports damaged audio data.
+var ErrCorrupt = errors.New("corrupt audio")
+
+func corrupt(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, args...))
+}
+
+// Audio verifies a decrypted FLAC or MP3 file, picking the format from its header.
+func Audio(data []byte) error {
+	return audio(bytesSource(data))
+}
+
+// File verifies the FLAC or MP3 file at path like Audio. The file is read a
+// window at a time instead of being loaded whole.
+func File(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return audio(readerSource(file, stat.Size()))
+}
+
+func audio(s *source) error {
+	head, err := s.at(0, 4)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(head, []byte("fLaC")) {
+		return flac(s)
+	}
+	return mp3(s)
+}
@@ -0,0 +1,200 @@
+package verify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc16Table[byte(crc>>8)^b] ^ crc<<8
+	}
+	return crc
+}
+
+// buildFlac returns a FLAC stream of full 4096-sample frames plus one shorter
+// final frame. declaredSamples overrides the STREAMINFO sample count when non-zero.
+func buildFlac(t *testing.T, frames, lastBlock int, declaredSamples uint64) []byte {
+	t.Helper()
+	return buildFlacFrames(t, frames, lastBlock, declaredSamples, 300)
+}
+
+// buildFlacFrames is buildFlac with frame i holding payload+i bytes after its header.
+func buildFlacFrames(t *testing.T, frames, lastBlock int, declaredSamples uint64, payload int) []byte {
+	t.Helper()
+	total := uint64(frames*4096 + lastBlock)
+	if declaredSamples != 0 {
+		total = declaredSamples
+	}
+
+	var out bytes.Buffer
+	out.WriteString("fLaC")
+	streamInfo := make([]byte, flacStreamInfoLength)
+	binary.BigEndian.PutUint16(streamInfo[0:], 16)
+	binary.BigEndian.PutUint16(streamInfo[2:], 4096)
+	binary.BigEndian.PutUint64(streamInfo[10:], uint64(44100)<<44|uint64(1)<<41|uint64(15)<<36|total)
+	out.Write([]byte{0x80, 0, 0, flacStreamInfoLength})
+	out.Write(streamInfo)
+
+	for i := 0; i <= frames; i++ {
+		if i == frames && lastBlock == 0 {
+			break
+		}
+		header := []byte{0xFF, 0xF8, 0xC9, 0x18, byte(i)}
+		if i == frames {
+			header[2] = 0x79
+			header = binary.BigEndian.AppendUint16(header, uint16(lastBlock-1))
+		}
+		header = append(header, crc8(header))
+		frame := append([]byte(nil), header...)
+		for j := range payload + i {
+			frame = append(frame, byte((i+j)%200))
+		}
+		frame = binary.BigEndian.AppendUint16(frame, crc16(frame))
+		out.Write(frame)
+	}
+	return out.Bytes()
+}
+
+func buildMP3(frames int) []byte {
+	var out bytes.Buffer
+	out.Write([]byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 20})
+	out.Write(make([]byte, 20))
+	for i := range frames {
+		// MPEG 1 Layer III, 128 kbps, 44.1 kHz, padding on odd frames.
+		header := []byte{0xFF, 0xFB, 0x90, 0x00}
+		length := 417
+		if i%2 == 1 {
+			header[2] |= 0x02
+			length++
+		}
+		out.Write(header)
+		for j := range length - len(header) {
+			out.WriteByte(byte(j % 200))
+		}
+	}
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	out.Write(tag)
+	return out.Bytes()
+}
+
+func TestFLACValid(t *testing.T) {
+	data := buildFlac(t, 5, 1000, 0)
+	assert.NoError(t, FLAC(data))
+	assert.NoError(t, Audio(data))
+}
+
+func TestFLACDetectsTruncation(t *testing.T) {
+	data := buildFlac(t, 5, 1000, 0)
+	err := FLAC(data[:len(data)-50])
+	require.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestFLACDetectsCorruptFrame(t *testing.T) {
+	data := buildFlac(t, 5, 1000, 0)
+	data[len(data)/2] ^= 0x01
+	err := FLAC(data)
+	require.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestFLACDetectsSampleCountMismatch(t *testing.T) {
+	data := buildFlac(t, 5, 1000, 5*4096+999)
+	err := FLAC(data)
+	require.ErrorIs(t, err, ErrCorrupt)
+	assert.Contains(t, err.Error(), "samples")
+}
+
+func TestFLACRequiresStreamInfo(t *testing.T) {
+	data := buildFlac(t, 1, 0, 0)
+	data[4] = 0x81
+	require.ErrorIs(t, FLAC(data), ErrCorrupt)
+}
+
+func TestMP3Valid(t *testing.T) {
+	data := buildMP3(20)
+	assert.NoError(t, MP3(data))
+	assert.NoError(t, Audio(data))
+}
+
+func TestMP3DetectsTruncation(t *testing.T) {
+	data := buildMP3(20)
+	data = data[:len(data)-128-100]
+	require.ErrorIs(t, MP3(data), ErrCorrupt)
+}
+
+func TestMP3DetectsLostSync(t *testing.T) {
+	data := buildMP3(20)
+	offset := 30 + 417*3
+	copy(data[offset:], []byte{0x12, 0x34, 0x56, 0x78})
+	err := MP3(data)
+	require.ErrorIs(t, err, ErrCorrupt)
+	assert.Contains(t, err.Error(), "after 3 frames")
+}
+
+func TestMP3RejectsEmpty(t *testing.T) {
+	require.ErrorIs(t, Audio(nil), ErrCorrupt)
+}
+
+func TestMP3Bitrate(t *testing.T) {
+	data := buildMP3(2)
+	bitrate, err := MP3Bitrate(data[:30+4])
+	require.NoError(t, err)
+	assert.Equal(t, 128, bitrate)
+
+	_, err = MP3Bitrate(data[:30])
+	require.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestMP3Info(t *testing.T) {
+	data := buildMP3(1)
+	stream, err := MP3Info(data)
+	require.NoError(t, err)
+	assert.Equal(t, MP3Stream{Bitrate: 128, SampleRate: 44100, Channels: 2}, stream)
+
+	data[30+3] = 0xC0
+	stream, err = MP3Info(data)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stream.Channels)
+}
+
+func writeAudioFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audio")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return path
+}
+
+func TestFileFLACAcrossWindows(t *testing.T) {
+	data := buildFlacFrames(t, 100, 1000, 0, 6000)
+	require.NoError(t, FLAC(data))
+	require.Greater(t, len(data), 2*sourceWindow)
+	assert.NoError(t, File(writeAudioFile(t, data)))
+
+	data[len(data)-sourceWindow] ^= 0x01
+	require.ErrorIs(t, File(writeAudioFile(t, data)), ErrCorrupt)
+	require.ErrorIs(t, File(writeAudioFile(t, data[:len(data)-50])), ErrCorrupt)
+}
+
+func TestFileFLACFrameLargerThanSearchWindow(t *testing.T) {
+	data := buildFlacFrames(t, 3, 1000, 0, 3*flacFrameWindow)
+	assert.NoError(t, File(writeAudioFile(t, data)))
+	assert.NoError(t, FLAC(data))
+}
+
+func TestFileMP3(t *testing.T) {
+	data := buildMP3(1500)
+	require.Greater(t, len(data), 2*sourceWindow)
+	assert.NoError(t, File(writeAudioFile(t, data)))
+
+	require.ErrorIs(t, File(writeAudioFile(t, data[:len(data)-128-100])), ErrCorrupt)
+	require.ErrorIs(t, File(writeAudioFile(t, nil)), ErrCorrupt)
+	require.ErrorIs(t, File(filepath.Join(t.TempDir(), "missing")), os.ErrNotExist)
+}